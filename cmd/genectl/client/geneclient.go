@@ -18,6 +18,7 @@ package client
 
 import (
 	"github.com/spf13/cobra"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/clientcmd"
 	"kubegene.io/kubegene/cmd/genectl/util"
 	execclientset "kubegene.io/kubegene/pkg/client/clientset/versioned"
@@ -39,6 +40,16 @@ func GetGeneClient(cmd *cobra.Command) (execclientset.Interface, error) {
 	return geneClient, nil
 }
 
+// GetKubeClient returns the client of the kubernetes resources, such as the
+// jobs and pods of the executions.
+func GetKubeClient(cmd *cobra.Command) (kubernetes.Interface, error) {
+	restConfig, err := GetkubeClientConfig(cmd).ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(restConfig)
+}
+
 func GetkubeClientConfig(cmd *cobra.Command) clientcmd.ClientConfig {
 	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
 	// use the standard defaults for this client command
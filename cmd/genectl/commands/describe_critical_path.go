@@ -0,0 +1,158 @@
+/*
+Copyright 2019 The Kubegene Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+
+	execv1alpha1 "kubegene.io/kubegene/pkg/apis/gene/v1alpha1"
+	"kubegene.io/kubegene/pkg/dag"
+	"kubegene.io/kubegene/pkg/graph"
+)
+
+// vertexDuration is the duration of the jobs of a vertex.
+type vertexDuration struct {
+	duration time.Duration
+	// phase is the phase of the jobs of the vertex, empty if none started.
+	phase execv1alpha1.VertexPhase
+	// estimated is whether no job of the vertex has started and the duration
+	// is the mean duration of the finished jobs of its task.
+	estimated bool
+}
+
+// vertexDurations returns the durations of the vertices of the graph of the
+// execution from the status of their jobs. A running job lasts until now.
+// The jobs of a dynamic vertex run in parallel, except the iterations of a
+// loop which run one after the other.
+func vertexDurations(exec *execv1alpha1.Execution, g *graph.Graph, now time.Time) map[*graph.Vertex]vertexDuration {
+	jobDuration := func(status execv1alpha1.VertexStatus) time.Duration {
+		if status.StartedAt.IsZero() {
+			return 0
+		}
+		end := now
+		if !status.FinishedAt.IsZero() {
+			end = status.FinishedAt.Time
+		} else if status.Phase != execv1alpha1.VertexRunning && !exec.Status.FinishedAt.IsZero() {
+			end = exec.Status.FinishedAt.Time
+		}
+		if end.Before(status.StartedAt.Time) {
+			return 0
+		}
+		return end.Sub(status.StartedAt.Time)
+	}
+
+	// the mean duration of the finished jobs of every task.
+	totals := make(map[string]time.Duration)
+	counts := make(map[string]int)
+	for _, status := range exec.Status.Vertices {
+		if status.Type == execv1alpha1.DAGVertexType || status.FinishedAt.IsZero() {
+			continue
+		}
+		totals[status.Task] += jobDuration(status)
+		counts[status.Task]++
+	}
+
+	// the statuses of the jobs keyed by job name, and by task for the
+	// dynamic vertices whose jobs are the jobs of their task.
+	byName := make(map[string][]execv1alpha1.VertexStatus)
+	byTask := make(map[string][]execv1alpha1.VertexStatus)
+	for _, status := range exec.Status.Vertices {
+		if status.Type == execv1alpha1.DAGVertexType {
+			continue
+		}
+		byName[status.Name] = append(byName[status.Name], status)
+		byTask[status.Task] = append(byTask[status.Task], status)
+	}
+
+	durations := make(map[*graph.Vertex]vertexDuration)
+	for _, vertex := range g.Vertices() {
+		task := dag.JobTask(vertex.Data.Job)
+		statuses := byName[vertex.Data.Job.Name]
+		if vertex.IsDynamic() {
+			statuses = byTask[task]
+		}
+		var result vertexDuration
+		for _, status := range statuses {
+			duration := jobDuration(status)
+			switch {
+			case vertex.IsLoop():
+				result.duration += duration
+			case duration > result.duration:
+				result.duration = duration
+			}
+			if len(result.phase) == 0 || status.Phase == execv1alpha1.VertexRunning {
+				result.phase = status.Phase
+			}
+		}
+		if len(result.phase) == 0 {
+			if counts[task] != 0 {
+				result.duration = totals[task] / time.Duration(counts[task])
+			}
+			result.estimated = true
+		}
+		durations[vertex] = result
+	}
+	return durations
+}
+
+// DescribeCriticalPath prints the critical path of the execution, the chain
+// of jobs whose durations add up to the longest, weighted by the actual
+// durations of the jobs that have started and the estimated durations of
+// the others.
+func DescribeCriticalPath(out io.Writer, exec *execv1alpha1.Execution, now time.Time) {
+	g, err := dag.NewGraph(exec)
+	if err != nil {
+		ExitWithError(err)
+	}
+	durations := vertexDurations(exec, g, now)
+	path, total, err := g.CriticalPath(func(vertex *graph.Vertex) time.Duration {
+		return durations[vertex].duration
+	})
+	if err != nil {
+		ExitWithError(err)
+	}
+	levels, err := g.Levels()
+	if err != nil {
+		ExitWithError(err)
+	}
+
+	buf := &bytes.Buffer{}
+	tabWriter := newTabWriter(buf)
+	writer := NewExecutionWriter(tabWriter)
+
+	writer.Write(0, "Critical Path:\t%s\n", total.Round(time.Second))
+	writer.Write(1, "Level\tJob\tPhase\tDuration\n")
+	writer.Write(1, "-----\t---\t-----\t--------\n")
+	for _, vertex := range path {
+		duration := durations[vertex]
+		phase := string(duration.phase)
+		if len(phase) == 0 {
+			phase = "Pending"
+		}
+		value := duration.duration.Round(time.Second).String()
+		if duration.estimated {
+			value = "~" + value + " (estimated)"
+		}
+		writer.Write(1, "%d\t%s\t%s\t%s\n", levels[vertex], vertex.Data.Job.Name, phase, value)
+	}
+
+	tabWriter.Flush()
+	fmt.Fprintf(out, "%s\n", buf.String())
+}
@@ -0,0 +1,168 @@
+/*
+Copyright 2019 The Kubegene Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commands
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	execv1alpha1 "kubegene.io/kubegene/pkg/apis/gene/v1alpha1"
+	"kubegene.io/kubegene/pkg/dag"
+)
+
+var criticalPathStart = time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// minuteOf returns the time of the given minute after the start.
+func minuteOf(minute int) metav1.Time {
+	return metav1.NewTime(criticalPathStart.Add(time.Duration(minute) * time.Minute))
+}
+
+// newJobStatus returns the status of a job of the graph execution that has
+// started and finished at the given minutes, a negative minute is unset.
+func newJobStatus(task string, index int, phase execv1alpha1.VertexPhase, started, finished int) execv1alpha1.VertexStatus {
+	status := execv1alpha1.VertexStatus{
+		Name:  dag.JobName("exec", task, index),
+		Type:  execv1alpha1.JobVertexType,
+		Task:  task,
+		Index: int32(index),
+		Phase: phase,
+	}
+	if started >= 0 {
+		status.StartedAt = minuteOf(started)
+	}
+	if finished >= 0 {
+		status.FinishedAt = minuteOf(finished)
+	}
+	return status
+}
+
+func TestVertexDurations(t *testing.T) {
+	now := criticalPathStart.Add(10 * time.Minute)
+	testCases := []struct {
+		name     string
+		statuses []execv1alpha1.VertexStatus
+		// finished is the minute the execution finished at, negative if running.
+		finished  int
+		job       string
+		duration  time.Duration
+		phase     execv1alpha1.VertexPhase
+		estimated bool
+	}{
+		{
+			name:     "finished job",
+			statuses: []execv1alpha1.VertexStatus{newJobStatus("split", 0, execv1alpha1.VertexSucceeded, 1, 4)},
+			finished: -1,
+			job:      "exec.split.0",
+			duration: 3 * time.Minute,
+			phase:    execv1alpha1.VertexSucceeded,
+		},
+		{
+			name:     "running job lasts until now",
+			statuses: []execv1alpha1.VertexStatus{newJobStatus("split", 0, execv1alpha1.VertexRunning, 4, -1)},
+			finished: -1,
+			job:      "exec.split.0",
+			duration: 6 * time.Minute,
+			phase:    execv1alpha1.VertexRunning,
+		},
+		{
+			name:     "failed job without finish time lasts until now",
+			statuses: []execv1alpha1.VertexStatus{newJobStatus("split", 0, execv1alpha1.VertexFailed, 2, -1)},
+			finished: -1,
+			job:      "exec.split.0",
+			duration: 8 * time.Minute,
+			phase:    execv1alpha1.VertexFailed,
+		},
+		{
+			name:     "failed job without finish time lasts until the execution finished",
+			statuses: []execv1alpha1.VertexStatus{newJobStatus("split", 0, execv1alpha1.VertexFailed, 2, -1)},
+			finished: 5,
+			job:      "exec.split.0",
+			duration: 3 * time.Minute,
+			phase:    execv1alpha1.VertexFailed,
+		},
+		{
+			name: "unstarted job is estimated from the finished jobs of its task",
+			statuses: []execv1alpha1.VertexStatus{
+				newJobStatus("align", 0, execv1alpha1.VertexSucceeded, 0, 2),
+			},
+			finished:  -1,
+			job:       "exec.align.1",
+			duration:  2 * time.Minute,
+			estimated: true,
+		},
+		{
+			name:      "unstarted job without finished jobs of its task",
+			finished:  -1,
+			job:       "exec.merge.0",
+			estimated: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		exec := newGraphExecution()
+		exec.Status.Vertices = make(map[string]execv1alpha1.VertexStatus)
+		for _, status := range testCase.statuses {
+			exec.Status.Vertices[status.Name] = status
+		}
+		if testCase.finished >= 0 {
+			exec.Status.FinishedAt = minuteOf(testCase.finished)
+		}
+		g, err := dag.NewGraph(exec)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		durations := vertexDurations(exec, g, now)
+		result := durations[g.FindVertexByName(testCase.job)]
+		if result.duration != testCase.duration || result.phase != testCase.phase || result.estimated != testCase.estimated {
+			t.Errorf("%s: expected %v %q estimated %v, got %v %q estimated %v", testCase.name,
+				testCase.duration, testCase.phase, testCase.estimated, result.duration, result.phase, result.estimated)
+		}
+	}
+}
+
+func TestDescribeCriticalPath(t *testing.T) {
+	exec := newGraphExecution()
+	exec.Status.Vertices = make(map[string]execv1alpha1.VertexStatus)
+	for _, status := range []execv1alpha1.VertexStatus{
+		newJobStatus("split", 0, execv1alpha1.VertexSucceeded, 0, 2),
+		newJobStatus("split", 1, execv1alpha1.VertexSucceeded, 0, 3),
+		newJobStatus("align", 0, execv1alpha1.VertexSucceeded, 2, 6),
+		newJobStatus("align", 1, execv1alpha1.VertexRunning, 3, -1),
+	} {
+		exec.Status.Vertices[status.Name] = status
+	}
+
+	// split/1 and the running align/1 last longer than split/0 and align/0,
+	// merge has not started and no job of its task has finished.
+	expected := `Critical Path:  10m0s
+  Level         Job           Phase      Duration
+  -----         ---           -----      --------
+  0             exec.split.1  Succeeded  3m0s
+  1             exec.align.1  Running    7m0s
+  2             exec.merge.0  Pending    ~0s (estimated)
+
+`
+	buf := &bytes.Buffer{}
+	DescribeCriticalPath(buf, exec, criticalPathStart.Add(10*time.Minute))
+	if buf.String() != expected {
+		t.Errorf("expected critical path:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
@@ -24,17 +24,27 @@ import (
 	"sort"
 	"strings"
 	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
 	"kubegene.io/kubegene/cmd/genectl/client"
 	execv1alpha1 "kubegene.io/kubegene/pkg/apis/gene/v1alpha1"
 )
 
-var descExecExample = `genectl describe execution my-exec –n gene-system`
+var descExecExample = `genectl describe execution my-exec –n gene-system
+
+		# Describe an execution and the chain of jobs that decides how long it runs
+		genectl describe execution my-exec --critical-path
+
+		# Refresh the description with the progress of every task until the execution completes
+		genectl describe execution my-exec --watch`
 
 type describeFlags struct {
-	namespace string
+	namespace    string
+	criticalPath bool
+	watch        bool
 }
 
 func NewDescribeExecutionCommand() *cobra.Command {
@@ -51,6 +61,8 @@ func NewDescribeExecutionCommand() *cobra.Command {
 	}
 
 	command.Flags().StringVarP(&describeFlags.namespace, "namespace", "n", "default", "workflow execution namespace")
+	command.Flags().BoolVar(&describeFlags.criticalPath, "critical-path", false, "print the critical path of the execution, the chain of jobs that decides how long it runs.")
+	command.Flags().BoolVarP(&describeFlags.watch, "watch", "w", false, "refresh the description with the progress of every task as the execution changes, until it completes.")
 
 	return command
 }
@@ -77,7 +89,30 @@ func DescribeWorkflow(cmd *cobra.Command, args []string, describeFlags *describe
 		ExitWithError(err)
 	}
 
-	DescribeExecution(exec)
+	if !describeFlags.watch {
+		DescribeExecution(os.Stdout, exec)
+		if describeFlags.criticalPath {
+			DescribeCriticalPath(os.Stdout, exec, time.Now())
+		}
+		return
+	}
+
+	options := metav1.ListOptions{FieldSelector: fields.OneTermEqualSelector("metadata.name", executionName).String()}
+	keep := func(exec *execv1alpha1.Execution) bool { return true }
+	err = watchExecutions(geneClient, namespace, options, keep, func(executions []execv1alpha1.Execution) {
+		buf := &bytes.Buffer{}
+		for i := range executions {
+			DescribeExecution(buf, &executions[i])
+			if describeFlags.criticalPath {
+				DescribeCriticalPath(buf, &executions[i], time.Now())
+			}
+			DescribeProgress(buf, &executions[i], time.Now())
+		}
+		refreshScreen(buf)
+	})
+	if err != nil {
+		ExitWithError(err)
+	}
 }
 
 func FindExecutionTask(exec *execv1alpha1.Execution, name string) *execv1alpha1.Task {
@@ -90,7 +125,7 @@ func FindExecutionTask(exec *execv1alpha1.Execution, name string) *execv1alpha1.
 	panic("can not get task for " + name)
 }
 
-func DescribeExecution(exec *execv1alpha1.Execution) {
+func DescribeExecution(out io.Writer, exec *execv1alpha1.Execution) {
 	buf := &bytes.Buffer{}
 	tabWriter := newTabWriter(buf)
 	writer := NewExecutionWriter(tabWriter)
@@ -107,6 +142,13 @@ func DescribeExecution(exec *execv1alpha1.Execution) {
 	}
 	writer.Write(0, "Phase:\t%s\n", phase)
 
+	if len(exec.Spec.OnExit) != 0 {
+		writer.Write(0, "OnExit:\t%s\n", strings.Join(exec.Spec.OnExit, ","))
+	}
+	if len(exec.Status.DAGPhase) != 0 {
+		writer.Write(0, "DAG Phase:\t%s\n", exec.Status.DAGPhase)
+	}
+
 	if len(exec.Status.Message) != 0 {
 		writer.Write(0, "Message:\t%s\n", exec.Status.Message)
 	}
@@ -116,19 +158,35 @@ func DescribeExecution(exec *execv1alpha1.Execution) {
 		status[task.Name] = make([]execv1alpha1.VertexStatus, 0)
 	}
 
-	for key, vertex := range exec.Status.Vertices {
-		// we use . to construct job name.
-		items := strings.Split(key, ".")
-		taskName := items[len(items)-2]
-		if _, ok := status[taskName]; !ok {
-			status[taskName] = make([]execv1alpha1.VertexStatus, 0)
+	subWorkflows := make([]execv1alpha1.VertexStatus, 0)
+	for _, vertex := range exec.Status.Vertices {
+		// the status of nested workflows is rolled up into DAG vertices.
+		if vertex.Type == execv1alpha1.DAGVertexType {
+			subWorkflows = append(subWorkflows, vertex)
+			continue
+		}
+		if _, ok := status[vertex.Task]; !ok {
+			status[vertex.Task] = make([]execv1alpha1.VertexStatus, 0)
+		}
+		status[vertex.Task] = append(status[vertex.Task], vertex)
+	}
+
+	if len(subWorkflows) != 0 {
+		sort.Slice(subWorkflows, func(i, j int) bool {
+			return subWorkflows[i].Name < subWorkflows[j].Name
+		})
+		writer.Write(0, "sub workflows:\n")
+		writer.Write(1, "Name\tPhase\tMessage\n")
+		writer.Write(1, "----\t-----\t-------\n")
+		for _, vertex := range subWorkflows {
+			writer.Write(1, "%v\t%v\t%v\n", vertex.Name, vertex.Phase, vertex.Message)
 		}
-		status[taskName] = append(status[taskName], vertex)
 	}
 
 	writer.Write(0, "workflow:\n")
 
-	for taskName, vertices := range status {
+	for _, taskName := range describedTasks(exec, status) {
+		vertices := status[taskName]
 		task := FindExecutionTask(exec, taskName)
 		totalJob := len(task.CommandSet)
 		var succeedJob, failedJob, runningJob, errorJob int
@@ -166,7 +224,37 @@ func DescribeExecution(exec *execv1alpha1.Execution) {
 
 	tabWriter.Flush()
 	str := string(buf.String())
-	fmt.Fprintf(os.Stdout, "%s\n", str)
+	fmt.Fprintf(out, "%s\n", str)
+}
+
+// describedTasks returns the names of the tasks of the vertex status in the
+// order of the tasks of the execution, and the jobs of every task in the
+// order of their index and attempt.
+func describedTasks(exec *execv1alpha1.Execution, status map[string][]execv1alpha1.VertexStatus) []string {
+	names := make([]string, 0, len(status))
+	for _, task := range exec.Spec.Tasks {
+		names = append(names, task.Name)
+	}
+	others := make([]string, 0)
+	for name := range status {
+		if taskPosition(exec, name) < 0 {
+			others = append(others, name)
+		}
+	}
+	sort.Strings(others)
+
+	for _, vertices := range status {
+		sort.Slice(vertices, func(i, j int) bool {
+			if vertices[i].Index != vertices[j].Index {
+				return vertices[i].Index < vertices[j].Index
+			}
+			if vertices[i].Attempt != vertices[j].Attempt {
+				return vertices[i].Attempt < vertices[j].Attempt
+			}
+			return vertices[i].Name < vertices[j].Name
+		})
+	}
+	return append(names, others...)
 }
 
 func newTabWriter(buf *bytes.Buffer) *tabwriter.Writer {
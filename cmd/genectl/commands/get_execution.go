@@ -19,6 +19,7 @@ package commands
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"sort"
 	"text/tabwriter"
@@ -46,13 +47,17 @@ var getExecutionExample = `
 		genectl get execution --all-namespaces –phase Running,Succeeded
 
 		# List executions in exec-system namespace that are running or succeeded in yaml output format.
-		genectl get execution -n exec-system –phase Running,Succeeded`
+		genectl get execution -n exec-system –phase Running,Succeeded
+
+		# Refresh the list with the progress and elapsed time of an execution until it completes.
+		genectl get execution my-exec --watch`
 
 type getExecutionFlags struct {
 	namespace     string
 	allNamespaces bool
 	phases        []string
 	output        string
+	watch         bool
 }
 
 func NewGetExecutionCommand() *cobra.Command {
@@ -70,6 +75,7 @@ func NewGetExecutionCommand() *cobra.Command {
 	command.Flags().StringSliceVar(&getExecutionFlags.phases, "phase", getExecutionFlags.phases, fmt.Sprintf("A comma-separated list for workflow execution phase. Available values: %v. This flag unset means 'list all phase workflow'", getAllPhases()))
 	command.Flags().BoolVar(&getExecutionFlags.allNamespaces, "all-namespaces", getExecutionFlags.allNamespaces, "If present, list execution across all namespaces.")
 	command.Flags().StringVarP(&getExecutionFlags.output, "output", "o", "wide", "Output format. One of: json|yaml|wide, default wide")
+	command.Flags().BoolVarP(&getExecutionFlags.watch, "watch", "w", false, "refresh the list with the progress and elapsed time of the executions as they change, until they complete. Only for wide output.")
 
 	return command
 }
@@ -89,6 +95,9 @@ func getExecution(cmd *cobra.Command, args []string, getExecutionFlags *getExecu
 	}
 
 	namespace := getExecutionFlags.namespace
+	if getExecutionFlags.watch && getExecutionFlags.output != "wide" {
+		ExitWithError(fmt.Errorf("watch only supports the wide output"))
+	}
 
 	// get exec client
 	geneClient, err := client.GetGeneClient(cmd)
@@ -135,7 +144,39 @@ func getExecution(cmd *cobra.Command, args []string, getExecutionFlags *getExecu
 		executions = filterExecList
 	}
 
-	PrintExecutionList(executions, getExecutionFlags.output)
+	if !getExecutionFlags.watch {
+		PrintExecutionList(executions, getExecutionFlags.output)
+		return
+	}
+
+	// watch the executions of the namespace, only the given ones if any.
+	names := make(map[string]bool, len(execNames))
+	for _, name := range execNames {
+		names[name] = true
+	}
+	if len(execNames) == 0 && getExecutionFlags.allNamespaces {
+		namespace = metav1.NamespaceAll
+	}
+	keep := func(exec *execv1alpha1.Execution) bool {
+		return len(names) == 0 || names[exec.Name]
+	}
+	err = watchExecutions(geneClient, namespace, metav1.ListOptions{}, keep, func(executions []execv1alpha1.Execution) {
+		filtered := make([]execv1alpha1.Execution, 0, len(executions))
+		for _, exec := range executions {
+			if len(getExecutionFlags.phases) != 0 && !checkPhase(string(exec.Status.Phase), getExecutionFlags.phases) {
+				continue
+			}
+			filtered = append(filtered, exec)
+		}
+		sort.Stable(PhaseOrder(filtered))
+
+		buf := &bytes.Buffer{}
+		PrintExecutionProgress(buf, filtered, time.Now())
+		refreshScreen(buf)
+	})
+	if err != nil {
+		ExitWithError(err)
+	}
 }
 
 func PrintExecutionList(execList []execv1alpha1.Execution, output string) {
@@ -168,12 +209,38 @@ func PrintExecutionListWide(execList []execv1alpha1.Execution) {
 	fmt.Fprintf(os.Stdout, "%s\n", str)
 }
 
+// PrintExecutionProgress prints the executions with a progress bar of their
+// jobs and their elapsed time.
+func PrintExecutionProgress(w io.Writer, execList []execv1alpha1.Execution, now time.Time) {
+	out := new(tabwriter.Writer)
+	buf := &bytes.Buffer{}
+	out.Init(buf, 0, 8, 2, ' ', 0)
+	fmt.Fprint(out, "Name\tAge\tPhase\tProgress\tElapsed\tMessage\n")
+	fmt.Fprint(out, "----\t---\t-----\t--------\t-------\t-------\n")
+
+	for _, exec := range execList {
+		finished, total := 0, 0
+		for _, task := range executionProgress(&exec) {
+			finished += task.finished
+			total += task.total
+		}
+		age := duration.HumanDuration(now.Sub(exec.CreationTimestamp.Time))
+		progress := fmt.Sprintf("%s %d/%d", progressBar(finished, total), finished, total)
+		fmt.Fprintf(out, "%v\t%v\t%v\t%v\t%v\t%v\n", exec.Name, age, exec.Status.Phase, progress, elapsedTime(&exec, now), exec.Status.Message)
+	}
+
+	out.Flush()
+	fmt.Fprintf(w, "%s\n", buf.String())
+}
+
 func getAllPhases() []execv1alpha1.VertexPhase {
 	return []execv1alpha1.VertexPhase{
 		execv1alpha1.VertexRunning,
 		execv1alpha1.VertexSucceeded,
 		execv1alpha1.VertexFailed,
 		execv1alpha1.VertexError,
+		execv1alpha1.VertexRetried,
+		execv1alpha1.VertexSkipped,
 	}
 }
 
@@ -0,0 +1,298 @@
+/*
+Copyright 2019 The Kubegene Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"kubegene.io/kubegene/cmd/genectl/client"
+	execv1alpha1 "kubegene.io/kubegene/pkg/apis/gene/v1alpha1"
+	"kubegene.io/kubegene/pkg/dag"
+	"kubegene.io/kubegene/pkg/parser"
+)
+
+var graphExample = `
+		# Print the job DAG of a workflow in Graphviz DOT format.
+		genectl graph workflow.yaml --input UserInputs.json | dot -Tpng -o workflow.png
+
+		# Print the task DAG of an execution in Mermaid format, colored by the phase of the tasks.
+		genectl graph execution my-exec -n gene-system --level task -o mermaid
+
+		# Print the job DAG of an execution in JSON format.
+		genectl graph execution my-exec -o json`
+
+// pendingPhase is the phase of the vertices whose jobs have not started.
+const pendingPhase = "Pending"
+
+// phaseColors are the colors of the vertices of every phase in DOT and Mermaid.
+var phaseColors = map[string]string{
+	pendingPhase:                         "#ffffff",
+	string(execv1alpha1.VertexRunning):   "#87cefa",
+	string(execv1alpha1.VertexSucceeded): "#90ee90",
+	string(execv1alpha1.VertexFailed):    "#f08080",
+	string(execv1alpha1.VertexError):     "#ffa500",
+	string(execv1alpha1.VertexRetried):   "#d3d3d3",
+	string(execv1alpha1.VertexSkipped):   "#f5f5f5",
+}
+
+type graphFlags struct {
+	namespace string
+	input     string
+	output    string
+	level     string
+}
+
+func NewGraphCommand() *cobra.Command {
+	var graphFlags graphFlags
+
+	var command = &cobra.Command{
+		Use:     "graph (FILENAME | execution NAME) [flags]",
+		Short:   "print the DAG of a workflow or an execution",
+		Args:    cobra.RangeArgs(1, 2),
+		Example: graphExample,
+		Run: func(cmd *cobra.Command, args []string) {
+			Graph(cmd, args, &graphFlags)
+		},
+	}
+
+	command.Flags().StringVarP(&graphFlags.namespace, "namespace", "n", "default", "workflow execution namespace")
+	command.Flags().StringVar(&graphFlags.input, "input", "", "the input json file path of the workflow.")
+	command.Flags().StringVarP(&graphFlags.output, "output", "o", "dot", "Output format. One of: dot|mermaid|json, default dot")
+	command.Flags().StringVar(&graphFlags.level, "level", "job", "the vertices of the DAG. One of: job|task, default job")
+	command.Flags().String("tool-repo", ToolDir, "directory or URL to tool repository, if it is a URL, it must point to tool file. If it is \"cluster\", the tools are resolved against the Tool and ClusterTool resources in the cluster.")
+	command.Flags().String("workflow-repo", WorkflowDir, "directory to workflow repository, the catalog of a sub workflow is looked up in it.")
+
+	return command
+}
+
+func Graph(cmd *cobra.Command, args []string, graphFlags *graphFlags) {
+	if graphFlags.level != "job" && graphFlags.level != "task" {
+		ExitWithError(fmt.Errorf("unsupported level: %v", graphFlags.level))
+	}
+	switch graphFlags.output {
+	case "dot", "mermaid", "json":
+	default:
+		ExitWithError(fmt.Errorf("unsupported format: %v", graphFlags.output))
+	}
+
+	var exec *execv1alpha1.Execution
+	if len(args) == 2 {
+		if args[0] != "execution" && args[0] != "executions" {
+			ExitWithError(fmt.Errorf("first args of graph execution must be `execution` or `executions`"))
+		}
+		geneClient, err := client.GetGeneClient(cmd)
+		if err != nil {
+			ExitWithError(err)
+		}
+		exec, err = geneClient.ExecutionV1alpha1().Executions(graphFlags.namespace).Get(args[1], metav1.GetOptions{})
+		if err != nil {
+			ExitWithError(err)
+		}
+	} else {
+		inputs, err := readInputJson(graphFlags.input)
+		if err != nil {
+			ExitWithError(fmt.Errorf("read input json file %s failed: %v", graphFlags.input, err))
+		}
+		exec = workflowExecution(cmd, args[0], inputs)
+	}
+
+	view := newGraphView(exec, graphFlags.level == "task")
+	switch graphFlags.output {
+	case "dot":
+		view.writeDOT(os.Stdout)
+	case "mermaid":
+		view.writeMermaid(os.Stdout)
+	case "json":
+		view.writeJSON(os.Stdout)
+	}
+}
+
+// workflowExecution returns the execution of the workflow file without
+// submitting it, its tasks are sorted by name to print the same graph for
+// the same workflow.
+func workflowExecution(cmd *cobra.Command, workflowPath string, inputs map[string]interface{}) *execv1alpha1.Execution {
+	workflow := LoadWorkflow(cmd, workflowPath, inputs)
+	exec, err := parser.TransWorkflow2Execution(workflow)
+	if err != nil {
+		ExitWithError(err)
+	}
+	sort.Slice(exec.Spec.Tasks, func(i, j int) bool {
+		return exec.Spec.Tasks[i].Name < exec.Spec.Tasks[j].Name
+	})
+	return exec
+}
+
+// graphNode is a vertex of the DAG, a job or a task.
+type graphNode struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+	Task  string `json:"task"`
+	// Index is the index of the job within its task, -1 for a task and for
+	// a dynamic task that has not been expanded.
+	Index int    `json:"index"`
+	Phase string `json:"phase"`
+}
+
+// graphEdge is a dependency, the To node runs after the From node.
+type graphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// graphView is the DAG of an execution to print.
+type graphView struct {
+	Name  string      `json:"name"`
+	Nodes []graphNode `json:"nodes"`
+	Edges []graphEdge `json:"edges"`
+}
+
+// newGraphView returns the DAG of the execution, built the same way as the
+// controller does. A dynamic task is a node of its own as it is before it is
+// expanded. If byTask is true, the jobs of every task are merged into a node.
+func newGraphView(exec *execv1alpha1.Execution, byTask bool) *graphView {
+	g, err := dag.NewGraph(exec)
+	if err != nil {
+		ExitWithError(err)
+	}
+	vertices := g.Vertices()
+	statuses := lastAttempts(exec)
+
+	view := &graphView{Name: exec.Name, Nodes: []graphNode{}, Edges: []graphEdge{}}
+	ids := make([]string, len(vertices))
+	seenNodes := make(map[string]bool)
+	for i, vertex := range vertices {
+		task := dag.JobTask(vertex.Data.Job)
+		node := graphNode{ID: vertex.Data.Job.Name, Task: task, Index: -1}
+		switch {
+		case byTask:
+			node.ID, node.Label = task, task
+			node.Phase = aggregatePhase(statuses[task], len(FindExecutionTask(exec, task).CommandSet))
+		case vertex.IsDynamic():
+			node.Label = task + "/*"
+			node.Phase = aggregatePhase(statuses[task], 0)
+		default:
+			node.Index = dag.JobIndex(vertex.Data.Job)
+			node.Label = task + "/" + strconv.Itoa(node.Index)
+			node.Phase = pendingPhase
+			if status, ok := statuses[task][int32(node.Index)]; ok {
+				node.Phase = aggregatePhase(map[int32]execv1alpha1.VertexStatus{status.Index: status}, 1)
+			}
+		}
+		ids[i] = node.ID
+		if !seenNodes[node.ID] {
+			seenNodes[node.ID] = true
+			view.Nodes = append(view.Nodes, node)
+		}
+	}
+
+	seenEdges := make(map[graphEdge]bool)
+	for i := range vertices {
+		for _, child := range g.FindChildren(i) {
+			edge := graphEdge{From: ids[i], To: ids[child]}
+			if edge.From == edge.To || seenEdges[edge] {
+				continue
+			}
+			seenEdges[edge] = true
+			view.Edges = append(view.Edges, edge)
+		}
+	}
+	return view
+}
+
+// aggregatePhase returns the phase of a group of jobs from the status of
+// their last attempts. It is failed or error if any job is, succeeded if at
+// least total jobs have run and all succeeded, and pending if none started.
+func aggregatePhase(statuses map[int32]execv1alpha1.VertexStatus, total int) string {
+	if len(statuses) == 0 {
+		return pendingPhase
+	}
+	counts := make(map[execv1alpha1.VertexPhase]int)
+	for _, status := range statuses {
+		counts[status.Phase]++
+	}
+	switch {
+	case counts[execv1alpha1.VertexFailed] != 0:
+		return string(execv1alpha1.VertexFailed)
+	case counts[execv1alpha1.VertexError] != 0:
+		return string(execv1alpha1.VertexError)
+	case counts[execv1alpha1.VertexSkipped] != 0:
+		// a skipped task has no job.
+		return string(execv1alpha1.VertexSkipped)
+	case counts[execv1alpha1.VertexSucceeded] == len(statuses) && len(statuses) >= total:
+		return string(execv1alpha1.VertexSucceeded)
+	default:
+		return string(execv1alpha1.VertexRunning)
+	}
+}
+
+// writeDOT prints the DAG in Graphviz DOT format.
+func (v *graphView) writeDOT(out io.Writer) {
+	fmt.Fprintf(out, "digraph %q {\n", v.Name)
+	fmt.Fprintf(out, "  rankdir=LR;\n")
+	fmt.Fprintf(out, "  node [shape=box, style=\"rounded,filled\"];\n")
+	for _, node := range v.Nodes {
+		fmt.Fprintf(out, "  %q [label=%q, fillcolor=%q, tooltip=%q];\n", node.ID, node.Label, phaseColors[node.Phase], node.Phase)
+	}
+	for _, edge := range v.Edges {
+		fmt.Fprintf(out, "  %q -> %q;\n", edge.From, edge.To)
+	}
+	fmt.Fprintf(out, "}\n")
+}
+
+// writeMermaid prints the DAG as a Mermaid flowchart. The node ids of Mermaid
+// can not contain dots, the nodes are numbered instead.
+func (v *graphView) writeMermaid(out io.Writer) {
+	ids := make(map[string]string, len(v.Nodes))
+	phases := make(map[string][]string)
+	fmt.Fprintf(out, "graph LR\n")
+	for i, node := range v.Nodes {
+		id := "n" + strconv.Itoa(i)
+		ids[node.ID] = id
+		phases[node.Phase] = append(phases[node.Phase], id)
+		fmt.Fprintf(out, "  %s[\"%s\"]\n", id, strings.Replace(node.Label, "\"", "#quot;", -1))
+	}
+	for _, edge := range v.Edges {
+		fmt.Fprintf(out, "  %s --> %s\n", ids[edge.From], ids[edge.To])
+	}
+
+	names := make([]string, 0, len(phases))
+	for phase := range phases {
+		names = append(names, phase)
+	}
+	sort.Strings(names)
+	for _, phase := range names {
+		fmt.Fprintf(out, "  classDef %s fill:%s,stroke:#333\n", phase, phaseColors[phase])
+		fmt.Fprintf(out, "  class %s %s\n", strings.Join(phases[phase], ","), phase)
+	}
+}
+
+// writeJSON prints the nodes and the edges of the DAG in JSON format.
+func (v *graphView) writeJSON(out io.Writer) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		ExitWithError(err)
+	}
+	fmt.Fprintf(out, "%s\n", data)
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2019 The Kubegene Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commands
+
+import (
+	"bytes"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	execv1alpha1 "kubegene.io/kubegene/pkg/apis/gene/v1alpha1"
+)
+
+func newGraphExecution() *execv1alpha1.Execution {
+	return &execv1alpha1.Execution{
+		ObjectMeta: metav1.ObjectMeta{Name: "exec", Namespace: "default"},
+		Spec: execv1alpha1.ExecutionSpec{
+			Tasks: []execv1alpha1.Task{
+				{Name: "split", Type: execv1alpha1.JobTaskType, Image: "split", CommandSet: []string{"split 0", "split 1"}},
+				{Name: "align", Type: execv1alpha1.JobTaskType, Image: "bwa", CommandSet: []string{"bwa 0", "bwa 1"},
+					Dependents: []execv1alpha1.Dependent{{Target: "split", Type: execv1alpha1.DependTypeIterate}}},
+				{Name: "merge", Type: execv1alpha1.JobTaskType, Image: "samtools", CommandSet: []string{"merge"},
+					Dependents: []execv1alpha1.Dependent{{Target: "align", Type: execv1alpha1.DependTypeWhole}}},
+			},
+		},
+		Status: execv1alpha1.ExecutionStatus{
+			Vertices: map[string]execv1alpha1.VertexStatus{
+				"split-0": {Name: "exec.split.0", Type: execv1alpha1.JobVertexType, Task: "split", Index: 0, Phase: execv1alpha1.VertexSucceeded},
+				"split-1": {Name: "exec.split.1", Type: execv1alpha1.JobVertexType, Task: "split", Index: 1, Phase: execv1alpha1.VertexSucceeded},
+				"align-0": {Name: "exec.align.0", Type: execv1alpha1.JobVertexType, Task: "align", Index: 0, Phase: execv1alpha1.VertexRunning},
+			},
+		},
+	}
+}
+
+func TestGraphViewDOT(t *testing.T) {
+	expected := `digraph "exec" {
+  rankdir=LR;
+  node [shape=box, style="rounded,filled"];
+  "exec.split.0" [label="split/0", fillcolor="#90ee90", tooltip="Succeeded"];
+  "exec.split.1" [label="split/1", fillcolor="#90ee90", tooltip="Succeeded"];
+  "exec.align.0" [label="align/0", fillcolor="#87cefa", tooltip="Running"];
+  "exec.align.1" [label="align/1", fillcolor="#ffffff", tooltip="Pending"];
+  "exec.merge.0" [label="merge/0", fillcolor="#ffffff", tooltip="Pending"];
+  "exec.split.0" -> "exec.align.0";
+  "exec.split.1" -> "exec.align.1";
+  "exec.align.0" -> "exec.merge.0";
+  "exec.align.1" -> "exec.merge.0";
+}
+`
+	buf := &bytes.Buffer{}
+	newGraphView(newGraphExecution(), false).writeDOT(buf)
+	if buf.String() != expected {
+		t.Errorf("expected DOT:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
+
+func TestGraphViewMermaid(t *testing.T) {
+	expected := `graph LR
+  n0["split"]
+  n1["align"]
+  n2["merge"]
+  n0 --> n1
+  n1 --> n2
+  classDef Pending fill:#ffffff,stroke:#333
+  class n2 Pending
+  classDef Running fill:#87cefa,stroke:#333
+  class n1 Running
+  classDef Succeeded fill:#90ee90,stroke:#333
+  class n0 Succeeded
+`
+	buf := &bytes.Buffer{}
+	newGraphView(newGraphExecution(), true).writeMermaid(buf)
+	if buf.String() != expected {
+		t.Errorf("expected Mermaid:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
+
+func TestGraphViewJSON(t *testing.T) {
+	expected := `{
+  "name": "exec",
+  "nodes": [
+    {
+      "id": "split",
+      "label": "split",
+      "task": "split",
+      "index": -1,
+      "phase": "Succeeded"
+    },
+    {
+      "id": "align",
+      "label": "align",
+      "task": "align",
+      "index": -1,
+      "phase": "Running"
+    },
+    {
+      "id": "merge",
+      "label": "merge",
+      "task": "merge",
+      "index": -1,
+      "phase": "Pending"
+    }
+  ],
+  "edges": [
+    {
+      "from": "split",
+      "to": "align"
+    },
+    {
+      "from": "align",
+      "to": "merge"
+    }
+  ]
+}
+`
+	buf := &bytes.Buffer{}
+	newGraphView(newGraphExecution(), true).writeJSON(buf)
+	if buf.String() != expected {
+		t.Errorf("expected JSON:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
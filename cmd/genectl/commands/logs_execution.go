@@ -0,0 +1,366 @@
+/*
+Copyright 2019 The Kubegene Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"kubegene.io/kubegene/cmd/genectl/client"
+	execv1alpha1 "kubegene.io/kubegene/pkg/apis/gene/v1alpha1"
+	execclientset "kubegene.io/kubegene/pkg/client/clientset/versioned"
+	"kubegene.io/kubegene/pkg/dag"
+	geneutil "kubegene.io/kubegene/pkg/util"
+)
+
+var logsExecutionExample = `
+		# Print the logs of all the jobs of an execution.
+		genectl logs execution my-exec -n gene-system
+
+		# Print the last 20 lines of the logs of the second job of task align.
+		genectl logs execution my-exec --task align --index 1 --tail 20
+
+		# Stream the logs of the jobs of task align, including the jobs started later,
+		# until the execution completes.
+		genectl logs execution my-exec --task align -f
+
+		# Print the logs of the previous container of the jobs that have restarted.
+		genectl logs execution my-exec --task align --previous`
+
+// followCheckPeriod is how often the execution is checked for completion
+// while following the logs of its jobs.
+const followCheckPeriod = 5 * time.Second
+
+type logsFlags struct {
+	namespace string
+	task      string
+	index     int
+	follow    bool
+	tail      int64
+	previous  bool
+}
+
+func NewLogsCommand() *cobra.Command {
+	var logsFlags logsFlags
+
+	var command = &cobra.Command{
+		Use:     "logs execution NAME [flags]",
+		Short:   "print the logs of the jobs of an execution",
+		Args:    cobra.ExactArgs(2),
+		Example: logsExecutionExample,
+		Run: func(cmd *cobra.Command, args []string) {
+			LogsExecution(cmd, args, &logsFlags)
+		},
+	}
+
+	command.Flags().StringVarP(&logsFlags.namespace, "namespace", "n", "default", "workflow execution namespace")
+	command.Flags().StringVar(&logsFlags.task, "task", "", "only print the logs of the jobs of the task.")
+	command.Flags().IntVar(&logsFlags.index, "index", -1, "only print the logs of the job with the index within the task, requires --task.")
+	command.Flags().BoolVarP(&logsFlags.follow, "follow", "f", false, "specify if the logs should be streamed, the logs of the jobs started later are streamed until the execution completes.")
+	command.Flags().Int64Var(&logsFlags.tail, "tail", -1, "lines of recent log of every job to display, defaults to -1 showing all log lines.")
+	command.Flags().BoolVarP(&logsFlags.previous, "previous", "p", false, "print the logs of the previous container of the jobs if they have restarted.")
+
+	return command
+}
+
+func LogsExecution(cmd *cobra.Command, args []string, logsFlags *logsFlags) {
+	if args[0] != "execution" && args[0] != "executions" {
+		ExitWithError(fmt.Errorf("first args of logs execution must be `execution` or `executions` "))
+	}
+	executionName := args[1]
+	if len(executionName) == 0 {
+		ExitWithError(fmt.Errorf("executionName can not be empty"))
+	}
+	if logsFlags.index >= 0 && len(logsFlags.task) == 0 {
+		ExitWithError(fmt.Errorf("--index requires --task"))
+	}
+	namespace := logsFlags.namespace
+
+	geneClient, err := client.GetGeneClient(cmd)
+	if err != nil {
+		ExitWithError(err)
+	}
+	kubeClient, err := client.GetKubeClient(cmd)
+	if err != nil {
+		ExitWithError(err)
+	}
+
+	exec, err := geneClient.ExecutionV1alpha1().Executions(namespace).Get(executionName, metav1.GetOptions{})
+	if err != nil {
+		ExitWithError(err)
+	}
+	if len(logsFlags.task) != 0 && taskPosition(exec, logsFlags.task) < 0 {
+		ExitWithError(fmt.Errorf("execution %s has no task %s", executionName, logsFlags.task))
+	}
+
+	// the jobs and their pods are labeled with the execution, the task and
+	// the index of the job.
+	selector := labels.Set{dag.ExecutionLabel: dag.LabelValue(executionName)}
+	if len(logsFlags.task) != 0 {
+		selector[dag.TaskLabel] = dag.LabelValue(logsFlags.task)
+	}
+	if logsFlags.index >= 0 {
+		selector[dag.IndexLabel] = strconv.Itoa(logsFlags.index)
+	}
+	options := metav1.ListOptions{LabelSelector: selector.String()}
+	podList, err := kubeClient.CoreV1().Pods(namespace).List(options)
+	if err != nil {
+		ExitWithError(err)
+	}
+	// the jobs of a running execution may be started later when following.
+	completed := geneutil.IsExecutionCompleted(exec)
+	if len(podList.Items) == 0 && (!logsFlags.follow || completed) {
+		ExitWithError(fmt.Errorf("no job of execution %s has been started", executionName))
+	}
+	pods := podList.Items
+	sortPods(exec, pods)
+
+	out := &logWriter{out: os.Stdout}
+	if !logsFlags.follow {
+		for i := range pods {
+			printPodLogs(kubeClient, &pods[i], logsFlags, out)
+		}
+		return
+	}
+
+	// the logs of all the jobs are streamed together.
+	follower := newPodFollower(func(pod *v1.Pod) {
+		printPodLogs(kubeClient, pod, logsFlags, out)
+	})
+	for i := range pods {
+		follower.follow(&pods[i])
+	}
+	if !completed {
+		if err := followPods(kubeClient, geneClient, exec, options, follower); err != nil {
+			fmt.Fprintf(os.Stderr, "stop following the jobs of execution %s: %v\n", executionName, err)
+		}
+	}
+	follower.wg.Wait()
+}
+
+// podFollower streams the logs of the pods of the jobs of an execution as the
+// pods run, the logs of every pod are streamed once.
+type podFollower struct {
+	sync.Mutex
+	wg       sync.WaitGroup
+	streamed map[string]bool
+	stream   func(pod *v1.Pod)
+}
+
+func newPodFollower(stream func(pod *v1.Pod)) *podFollower {
+	return &podFollower{streamed: make(map[string]bool), stream: stream}
+}
+
+// follow starts streaming the logs of the pod once its containers have
+// started, the logs of a pending pod can not be read yet.
+func (f *podFollower) follow(pod *v1.Pod) {
+	if pod.Status.Phase == v1.PodPending {
+		return
+	}
+	f.Lock()
+	defer f.Unlock()
+	if f.streamed[pod.Name] {
+		return
+	}
+	f.streamed[pod.Name] = true
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		f.stream(pod)
+	}()
+}
+
+// watch follows the pods of the events of the watcher. It checks whether the
+// execution has completed on every tick, and returns once it has or the
+// watch ends.
+func (f *podFollower) watch(watcher watch.Interface, tick <-chan time.Time, completed func() (bool, error)) (bool, error) {
+	for {
+		select {
+		case event, ok := <-watcher.ResultChan():
+			if !ok {
+				return false, nil
+			}
+			pod, ok := event.Object.(*v1.Pod)
+			if !ok {
+				// the watch failed, such as the resource version is too old.
+				return false, nil
+			}
+			if event.Type == watch.Added || event.Type == watch.Modified {
+				f.follow(pod)
+			}
+		case <-tick:
+			if done, err := completed(); err != nil || done {
+				return done, err
+			}
+		}
+	}
+}
+
+// followPods follows the pods that match the list options, including the pods
+// of the jobs started later, until the execution has completed.
+func followPods(kubeClient kubernetes.Interface, geneClient execclientset.Interface, exec *execv1alpha1.Execution, options metav1.ListOptions, follower *podFollower) error {
+	ticker := time.NewTicker(followCheckPeriod)
+	defer ticker.Stop()
+	completed := func() (bool, error) {
+		current, err := geneClient.ExecutionV1alpha1().Executions(exec.Namespace).Get(exec.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		return geneutil.IsExecutionCompleted(current), nil
+	}
+
+	for {
+		podList, err := kubeClient.CoreV1().Pods(exec.Namespace).List(options)
+		if err != nil {
+			return err
+		}
+		for i := range podList.Items {
+			follower.follow(&podList.Items[i])
+		}
+
+		watchOptions := options
+		watchOptions.ResourceVersion = podList.ResourceVersion
+		watcher, err := kubeClient.CoreV1().Pods(exec.Namespace).Watch(watchOptions)
+		if err != nil {
+			return err
+		}
+		done, err := follower.watch(watcher, ticker.C, completed)
+		watcher.Stop()
+		if err != nil || done {
+			return err
+		}
+		// the watch has expired, list the pods again.
+	}
+}
+
+// taskPosition returns the position of the task in the execution, or -1 if
+// the execution has no such task.
+func taskPosition(exec *execv1alpha1.Execution, name string) int {
+	for i, task := range exec.Spec.Tasks {
+		if task.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// sortPods sorts the pods of the jobs of the execution in the order of their
+// tasks in the execution, then their index and attempt, then creation time.
+func sortPods(exec *execv1alpha1.Execution, pods []v1.Pod) {
+	labelInt := func(pod *v1.Pod, key string) int {
+		value, _ := strconv.Atoi(pod.Labels[key])
+		return value
+	}
+	sort.SliceStable(pods, func(i, j int) bool {
+		a, b := &pods[i], &pods[j]
+		if x, y := taskPosition(exec, podTask(a)), taskPosition(exec, podTask(b)); x != y {
+			return x < y
+		}
+		if x, y := labelInt(a, dag.IndexLabel), labelInt(b, dag.IndexLabel); x != y {
+			return x < y
+		}
+		if x, y := labelInt(a, dag.AttemptLabel), labelInt(b, dag.AttemptLabel); x != y {
+			return x < y
+		}
+		return a.CreationTimestamp.Before(&b.CreationTimestamp)
+	})
+}
+
+// podTask returns the name of the task of the job of the pod from its
+// annotations, the label may be shortened.
+func podTask(pod *v1.Pod) string {
+	if name, ok := pod.Annotations[dag.TaskLabel]; ok {
+		return name
+	}
+	return pod.Labels[dag.TaskLabel]
+}
+
+// logPrefix returns the prefix of the log lines of the pod, the task and the
+// index of its job, and the attempt of the job of a loop.
+func logPrefix(pod *v1.Pod) string {
+	prefix := podTask(pod) + "/" + pod.Labels[dag.IndexLabel]
+	if attempt := pod.Labels[dag.AttemptLabel]; len(attempt) != 0 && attempt != "0" {
+		prefix += " attempt " + attempt
+	}
+	return "[" + prefix + "] "
+}
+
+// logWriter writes the log lines of the pods that are streamed together, a
+// line is never mixed up with the lines of the other pods.
+type logWriter struct {
+	sync.Mutex
+	out io.Writer
+}
+
+func (w *logWriter) writeLine(prefix, line string) {
+	w.Lock()
+	defer w.Unlock()
+	fmt.Fprint(w.out, prefix+line)
+}
+
+// printPodLogs prints the logs of the job container of the pod with the log
+// prefix of the pod. A pod whose logs can not be read, such as a pod that
+// has not started yet, is reported to stderr.
+func printPodLogs(kubeClient kubernetes.Interface, pod *v1.Pod, logsFlags *logsFlags, out *logWriter) {
+	options := &v1.PodLogOptions{
+		Follow:   logsFlags.follow,
+		Previous: logsFlags.previous,
+	}
+	if len(pod.Spec.Containers) != 0 {
+		options.Container = pod.Spec.Containers[0].Name
+	}
+	if logsFlags.tail >= 0 {
+		options.TailLines = &logsFlags.tail
+	}
+
+	prefix := logPrefix(pod)
+	stream, err := kubeClient.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, options).Stream()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%scan not get the logs of pod %s: %v\n", prefix, pod.Name, err)
+		return
+	}
+	defer stream.Close()
+
+	reader := bufio.NewReader(stream)
+	for {
+		line, err := reader.ReadString('\n')
+		if len(line) != 0 {
+			if line[len(line)-1] != '\n' {
+				line += "\n"
+			}
+			out.writeLine(prefix, line)
+		}
+		if err != nil {
+			if err != io.EOF {
+				fmt.Fprintf(os.Stderr, "%sread the logs of pod %s error: %v\n", prefix, pod.Name, err)
+			}
+			return
+		}
+	}
+}
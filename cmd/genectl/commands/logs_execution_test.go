@@ -0,0 +1,149 @@
+/*
+Copyright 2019 The Kubegene Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commands
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+
+	"kubegene.io/kubegene/pkg/dag"
+)
+
+func newLogsTestPod(name, task, index, attempt string, phase v1.PodPhase) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			Labels:    map[string]string{dag.TaskLabel: task, dag.IndexLabel: index, dag.AttemptLabel: attempt},
+		},
+		Status: v1.PodStatus{Phase: phase},
+	}
+}
+
+func TestSortPods(t *testing.T) {
+	exec := newGraphExecution()
+	pods := []v1.Pod{
+		*newLogsTestPod("merge-0", "merge", "0", "0", v1.PodRunning),
+		*newLogsTestPod("align-1", "align", "1", "0", v1.PodRunning),
+		*newLogsTestPod("split-1", "split", "1", "0", v1.PodSucceeded),
+		*newLogsTestPod("align-0-1", "align", "0", "1", v1.PodRunning),
+		*newLogsTestPod("align-0-0", "align", "0", "0", v1.PodFailed),
+		*newLogsTestPod("split-0", "split", "0", "0", v1.PodSucceeded),
+	}
+	sortPods(exec, pods)
+
+	expected := []string{"split-0", "split-1", "align-0-0", "align-0-1", "align-1", "merge-0"}
+	for i, pod := range pods {
+		if pod.Name != expected[i] {
+			t.Errorf("%d: expected pod %s, got %s", i, expected[i], pod.Name)
+		}
+	}
+}
+
+func TestLogPrefix(t *testing.T) {
+	pod := newLogsTestPod("align-0", "align", "0", "0", v1.PodRunning)
+	if prefix := logPrefix(pod); prefix != "[align/0] " {
+		t.Errorf("expected prefix [align/0], got %q", prefix)
+	}
+
+	// the task name in the annotation wins over the shortened label.
+	pod = newLogsTestPod("align-2", "align-with-a-long-na", "2", "3", v1.PodRunning)
+	pod.Annotations = map[string]string{dag.TaskLabel: "align-with-a-long-name"}
+	if prefix := logPrefix(pod); prefix != "[align-with-a-long-name/2 attempt 3] " {
+		t.Errorf("expected prefix with the attempt, got %q", prefix)
+	}
+}
+
+func TestLogWriter(t *testing.T) {
+	var buf bytes.Buffer
+	out := &logWriter{out: &buf}
+
+	var wg sync.WaitGroup
+	for _, prefix := range []string{"[split/0] ", "[split/1] "} {
+		wg.Add(1)
+		go func(prefix string) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				out.writeLine(prefix, "line\n")
+			}
+		}(prefix)
+	}
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 200 {
+		t.Fatalf("expected 200 lines, got %d", len(lines))
+	}
+	for _, line := range lines {
+		if line != "[split/0] line" && line != "[split/1] line" {
+			t.Errorf("expected lines not to be mixed up, got %q", line)
+		}
+	}
+}
+
+func TestPodFollower(t *testing.T) {
+	var mu sync.Mutex
+	streamed := make([]string, 0)
+	follower := newPodFollower(func(pod *v1.Pod) {
+		mu.Lock()
+		defer mu.Unlock()
+		streamed = append(streamed, pod.Name)
+	})
+
+	// a pending pod is streamed once it runs.
+	follower.follow(newLogsTestPod("split-0", "split", "0", "0", v1.PodPending))
+	follower.follow(newLogsTestPod("split-1", "split", "1", "0", v1.PodRunning))
+
+	watcher := watch.NewFake()
+	tick := make(chan time.Time)
+	// the execution completes on the second check.
+	checks := 0
+	result := make(chan bool)
+	go func() {
+		done, _ := follower.watch(watcher, tick, func() (bool, error) {
+			checks++
+			return checks == 2, nil
+		})
+		result <- done
+	}()
+	watcher.Modify(newLogsTestPod("split-0", "split", "0", "0", v1.PodRunning))
+	watcher.Modify(newLogsTestPod("split-1", "split", "1", "0", v1.PodSucceeded))
+	// the pod of a job started later.
+	watcher.Add(newLogsTestPod("align-0", "align", "0", "0", v1.PodRunning))
+	tick <- time.Now()
+	tick <- time.Now()
+	if done := <-result; !done {
+		t.Errorf("expected the follower to return once the execution completed")
+	}
+	follower.wg.Wait()
+
+	if len(streamed) != 3 {
+		t.Fatalf("expected the logs of 3 pods to be streamed once, got %v", streamed)
+	}
+	for _, name := range []string{"split-0", "split-1", "align-0"} {
+		if !follower.streamed[name] {
+			t.Errorf("expected the logs of pod %s to be streamed", name)
+		}
+	}
+}
@@ -34,6 +34,9 @@ var Home = os.Getenv("HOME")
 // default tool repository directory
 var ToolDir = filepath.Join(Home, Kubegene, "tools")
 
+// default workflow repository directory
+var WorkflowDir = filepath.Join(Home, Kubegene, "workflows")
+
 // NewCommand returns a new instance of an gcs command
 func NewCommand() *cobra.Command {
 	var command = &cobra.Command{
@@ -50,6 +53,9 @@ func NewCommand() *cobra.Command {
 	command.AddCommand(NewDeleteCommand())
 	command.AddCommand(NewDescribeExecutionCommand())
 	command.AddCommand(NewGetExecutionCommand())
+	command.AddCommand(NewGraphCommand())
+	command.AddCommand(NewLogsCommand())
+	command.AddCommand(NewToolCommand())
 	command.AddCommand(NewVersionCommand())
 
 	return command
@@ -37,7 +37,8 @@ func NewSubCommand() *cobra.Command {
 		},
 	}
 
-	command.PersistentFlags().String("tool-repo", ToolDir, "directory or URL to tool repository, if it is a URL, it must point to tool file.")
+	command.PersistentFlags().String("tool-repo", ToolDir, "directory or URL to tool repository, if it is a URL, it must point to tool file. If it is \"cluster\", the tools are resolved against the Tool and ClusterTool resources in the cluster.")
+	command.PersistentFlags().String("workflow-repo", WorkflowDir, "directory to workflow repository, the catalog of a sub workflow is looked up in it.")
 	command.PersistentFlags().BoolVarP(&subOptions.dryRun, "dry-run", "", false, "If true, display results but do not submit workflow")
 
 	command.AddCommand(NewSubJobCommand())
@@ -22,13 +22,17 @@ import (
 	"github.com/spf13/cobra"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 
 	"bytes"
 	"github.com/renstrom/dedent"
 	"html/template"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"kubegene.io/kubegene/cmd/genectl/client"
-	"kubegene.io/kubegene/cmd/genectl/parser"
 	"kubegene.io/kubegene/cmd/genectl/util"
+	execv1alpha1 "kubegene.io/kubegene/pkg/apis/gene/v1alpha1"
+	"kubegene.io/kubegene/pkg/cron"
+	"kubegene.io/kubegene/pkg/parser"
 )
 
 var submitSuccessMessage = template.Must(template.New("message").Parse(dedent.Dedent(`
@@ -47,26 +51,48 @@ var submitSuccessMessage = template.Must(template.New("message").Parse(dedent.De
 
 		`)))
 
+var submitCronSuccessMessage = template.Must(template.New("message").Parse(dedent.Dedent(`
+		The workflow has been submitted successfully! And the cron execution {{ .name }} has been created.
+		It creates an execution on the schedule "{{ .schedule }}".
+		Your can use the follow command to query the executions it created.
+
+			genectl get execution -n {{ .namespace }}
+
+		`)))
+
 var subWorkflowExample = `
 		# Submit a workflow from a file with specify UserInputs"
-		gcs sub workflow wf.yaml --input UserInputs.json`
+		gcs sub workflow wf.yaml --input UserInputs.json
+
+		# Run a workflow at 2:00 every day, and skip the run if the previous one is still running
+		genectl sub workflow wf.yaml --schedule "0 2 * * *" --concurrency-policy Forbid
+
+		# Submit a workflow whose jobs go before the other jobs in the cluster queue
+		genectl sub workflow wf.yaml --priority 100`
 
 type workflowFlags struct {
-	input string
+	input             string
+	schedule          string
+	concurrencyPolicy string
+	priority          int32
 }
 
 func NewSubWorkflowCommand() *cobra.Command {
 	var workflowFlags workflowFlags
 	var command = &cobra.Command{
-		Use:   "workflow FILENAME ...",
-		Short: "submit a workflow",
-		Args:  cobra.ExactArgs(1),
+		Use:     "workflow FILENAME ...",
+		Short:   "submit a workflow",
+		Example: subWorkflowExample,
+		Args:    cobra.ExactArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
 			SubWorkflow(cmd, args, &workflowFlags)
 		},
 	}
 
 	command.Flags().StringVar(&workflowFlags.input, "input", "", "the input json file path.")
+	command.Flags().StringVar(&workflowFlags.schedule, "schedule", "", "create a cron execution that runs the workflow on the schedule in cron format, e.g. \"0 2 * * *\".")
+	command.Flags().StringVar(&workflowFlags.concurrencyPolicy, "concurrency-policy", string(execv1alpha1.AllowConcurrent), "how to treat the concurrent runs of a scheduled workflow, one of Allow, Forbid and Replace.")
+	command.Flags().Int32Var(&workflowFlags.priority, "priority", 0, "the priority of the jobs of the execution in the cluster queue, the jobs with a higher priority are created first. It is capped by the max priority of the namespace set by the cluster admin.")
 
 	return command
 }
@@ -78,16 +104,53 @@ func SubWorkflow(cmd *cobra.Command, args []string, workflowFlags *workflowFlags
 		ExitWithError(fmt.Errorf("read input json file %s failed: %v", workflowFlags.input, err))
 	}
 
-	ProcessWorkflow(cmd, args[0], inputs)
+	if len(workflowFlags.schedule) != 0 {
+		if err := validateSchedule(workflowFlags.schedule, workflowFlags.concurrencyPolicy); err != nil {
+			ExitWithError(err)
+		}
+	}
+	execution := BuildExecution(cmd, args[0], inputs)
+	if execution == nil {
+		return
+	}
+	execution.Spec.Priority = workflowFlags.priority
+
+	if len(workflowFlags.schedule) == 0 {
+		SubmitExecution(cmd, execution)
+		return
+	}
+	SubmitCronExecution(cmd, execution, workflowFlags.schedule, execv1alpha1.ConcurrencyPolicy(workflowFlags.concurrencyPolicy))
 }
 
+// ProcessWorkflow builds the execution of the workflow and submits it.
 func ProcessWorkflow(cmd *cobra.Command, workflowPath string, inputs map[string]interface{}) {
-	// fetch all usable tools.
-	tools, err := parser.FetchTools(cmd)
+	execution := BuildExecution(cmd, workflowPath, inputs)
+	if execution == nil {
+		return
+	}
+	SubmitExecution(cmd, execution)
+}
+
+// BuildExecution validates and instantiates the workflow, and returns its
+// execution. It prints the workflow and returns nil for a dry run.
+func BuildExecution(cmd *cobra.Command, workflowPath string, inputs map[string]interface{}) *execv1alpha1.Execution {
+	workflow := LoadWorkflow(cmd, workflowPath, inputs)
+	if util.GetFlagBool(cmd, "dry-run") {
+		util.PrintYAML(workflow)
+		return nil
+	}
+
+	// trans workflow to execution
+	execution, err := parser.TransWorkflow2Execution(workflow)
 	if err != nil {
 		ExitWithError(err)
 	}
+	return execution
+}
 
+// LoadWorkflow reads, validates and instantiates the workflow with the
+// tools of the tool repo and the nested workflows of the workflow repo.
+func LoadWorkflow(cmd *cobra.Command, workflowPath string, inputs map[string]interface{}) *parser.Workflow {
 	// read workflow
 	data, err := ioutil.ReadFile(workflowPath)
 	if err != nil {
@@ -108,22 +171,27 @@ func ProcessWorkflow(cmd *cobra.Command, workflowPath string, inputs map[string]
 		PrintErrList(errList)
 		os.Exit(1)
 	}
-	// instantiate workflow
-	err = parser.InstantiateWorkflow(workflow, inputs, tools)
+	// load nested workflows
+	errList = parser.LoadSubWorkflows(workflow, filepath.Dir(workflowPath), util.GetFlagString(cmd, "workflow-repo"))
+	if len(errList) > 0 {
+		PrintErrList(errList)
+		os.Exit(1)
+	}
+	// fetch all usable tools.
+	tools, err := fetchTools(cmd, workflow, inputs)
 	if err != nil {
 		ExitWithError(err)
 	}
-	if util.GetFlagBool(cmd, "dry-run") {
-		util.PrintYAML(workflow)
-		return
-	}
-
-	// trans workflow to execution
-	execution, err := parser.TransWorkflow2Execution(workflow)
+	// instantiate workflow
+	err = parser.InstantiateWorkflow(workflow, inputs, tools)
 	if err != nil {
 		ExitWithError(err)
 	}
+	return workflow
+}
 
+// SubmitExecution creates the execution.
+func SubmitExecution(cmd *cobra.Command, execution *execv1alpha1.Execution) {
 	// get exec client
 	geneClient, err := client.GetGeneClient(cmd)
 	if err != nil {
@@ -146,6 +214,60 @@ func ProcessWorkflow(cmd *cobra.Command, workflowPath string, inputs map[string]
 	fmt.Println(msg.String())
 }
 
+// SubmitCronExecution creates a cron execution that creates the execution
+// on the schedule.
+func SubmitCronExecution(cmd *cobra.Command, execution *execv1alpha1.Execution, schedule string, policy execv1alpha1.ConcurrencyPolicy) {
+	cronExec := &execv1alpha1.CronExecution{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      execution.Name,
+			Namespace: execution.Namespace,
+		},
+		Spec: execv1alpha1.CronExecutionSpec{
+			Schedule:          schedule,
+			ConcurrencyPolicy: policy,
+			ExecutionTemplate: execv1alpha1.ExecutionTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      execution.Labels,
+					Annotations: execution.Annotations,
+				},
+				Spec: execution.Spec,
+			},
+		},
+	}
+
+	geneClient, err := client.GetGeneClient(cmd)
+	if err != nil {
+		ExitWithError(err)
+	}
+
+	newCronExec, err := geneClient.ExecutionV1alpha1().CronExecutions(cronExec.Namespace).Create(cronExec)
+	if err != nil {
+		ExitWithError(fmt.Errorf("submit cron execution error: %v", err))
+	}
+
+	ctx := map[string]string{
+		"name":      newCronExec.GetName(),
+		"namespace": newCronExec.GetNamespace(),
+		"schedule":  schedule,
+	}
+
+	var msg bytes.Buffer
+	submitCronSuccessMessage.Execute(&msg, ctx)
+	fmt.Println(msg.String())
+}
+
+func validateSchedule(schedule, policy string) error {
+	if _, err := cron.Parse(schedule); err != nil {
+		return fmt.Errorf("schedule %q is not valid: %v", schedule, err)
+	}
+	switch execv1alpha1.ConcurrencyPolicy(policy) {
+	case execv1alpha1.AllowConcurrent, execv1alpha1.ForbidConcurrent, execv1alpha1.ReplaceConcurrent:
+		return nil
+	default:
+		return fmt.Errorf("concurrency policy %s is not supported, it should be one of Allow, Forbid and Replace", policy)
+	}
+}
+
 func readInputJson(inputFile string) (map[string]interface{}, error) {
 	if inputFile == "" {
 		return nil, nil
@@ -0,0 +1,334 @@
+/*
+Copyright 2019 The Kubegene Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"kubegene.io/kubegene/cmd/genectl/client"
+	"kubegene.io/kubegene/cmd/genectl/util"
+	execv1alpha1 "kubegene.io/kubegene/pkg/apis/gene/v1alpha1"
+	execclientset "kubegene.io/kubegene/pkg/client/clientset/versioned"
+	"kubegene.io/kubegene/pkg/parser"
+)
+
+var toolExample = `
+		# List the tools in the default namespace and the cluster tools.
+		genectl tool list
+
+		# Show a tool by name:version or by resource name.
+		genectl tool get broadinstitute/gatk:4.0.2.0
+
+		# Create the cluster tools from the tool files of a tool repository.
+		genectl tool create -f ~/kubegene/tools --cluster
+
+		# Delete a tool in the gene-system namespace.
+		genectl tool delete nginx:latest -n gene-system`
+
+type toolFlags struct {
+	namespace string
+	cluster   bool
+	output    string
+	filename  string
+}
+
+func NewToolCommand() *cobra.Command {
+	var toolFlags toolFlags
+
+	var command = &cobra.Command{
+		Use:     "tool",
+		Short:   "manage the tools in the cluster",
+		Example: toolExample,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.HelpFunc()(cmd, args)
+		},
+	}
+
+	command.PersistentFlags().StringVarP(&toolFlags.namespace, "namespace", "n", "default", "namespace of the tools")
+	command.PersistentFlags().BoolVar(&toolFlags.cluster, "cluster", false, "If present, manage the cluster tools that can be used in all namespaces.")
+
+	listCommand := &cobra.Command{
+		Use:   "list",
+		Short: "list the tools",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			ListTools(cmd, &toolFlags)
+		},
+	}
+	listCommand.Flags().StringVarP(&toolFlags.output, "output", "o", "wide", "Output format. One of: json|yaml|wide, default wide")
+
+	getCommand := &cobra.Command{
+		Use:   "get NAME...",
+		Short: "show the tools by name:version or resource name",
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			GetTools(cmd, args, &toolFlags)
+		},
+	}
+	getCommand.Flags().StringVarP(&toolFlags.output, "output", "o", "yaml", "Output format. One of: json|yaml|wide, default yaml")
+
+	createCommand := &cobra.Command{
+		Use:   "create -f FILENAME",
+		Short: "create the tools from a tool file or a directory of tool files",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			CreateTools(cmd, &toolFlags)
+		},
+	}
+	createCommand.Flags().StringVarP(&toolFlags.filename, "filename", "f", "", "tool file or directory of tool files")
+
+	deleteCommand := &cobra.Command{
+		Use:   "delete NAME...",
+		Short: "delete the tools by name:version or resource name",
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			DeleteTools(cmd, args, &toolFlags)
+		},
+	}
+
+	command.AddCommand(listCommand, getCommand, createCommand, deleteCommand)
+	return command
+}
+
+// toolEntry is a tool or a cluster tool to print.
+type toolEntry struct {
+	Kind      string                `json:"kind"`
+	Name      string                `json:"name"`
+	Namespace string                `json:"namespace,omitempty"`
+	Spec      execv1alpha1.ToolSpec `json:"spec"`
+}
+
+func ListTools(cmd *cobra.Command, toolFlags *toolFlags) {
+	geneClient, err := client.GetGeneClient(cmd)
+	if err != nil {
+		ExitWithError(err)
+	}
+
+	entries := make([]toolEntry, 0)
+	clusterTools, err := geneClient.ExecutionV1alpha1().ClusterTools().List(metav1.ListOptions{})
+	if err != nil {
+		ExitWithError(fmt.Errorf("list cluster tools error: %v", err))
+	}
+	for _, tool := range clusterTools.Items {
+		entries = append(entries, toolEntry{Kind: "ClusterTool", Name: tool.Name, Spec: tool.Spec})
+	}
+	if !toolFlags.cluster {
+		tools, err := geneClient.ExecutionV1alpha1().Tools(toolFlags.namespace).List(metav1.ListOptions{})
+		if err != nil {
+			ExitWithError(fmt.Errorf("list tools error: %v", err))
+		}
+		for _, tool := range tools.Items {
+			entries = append(entries, toolEntry{Kind: "Tool", Name: tool.Name, Namespace: tool.Namespace, Spec: tool.Spec})
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Spec.Name != entries[j].Spec.Name {
+			return entries[i].Spec.Name < entries[j].Spec.Name
+		}
+		return entries[i].Spec.Version < entries[j].Spec.Version
+	})
+	PrintToolList(entries, toolFlags.output)
+}
+
+func GetTools(cmd *cobra.Command, args []string, toolFlags *toolFlags) {
+	geneClient, err := client.GetGeneClient(cmd)
+	if err != nil {
+		ExitWithError(err)
+	}
+
+	entries := make([]toolEntry, 0, len(args))
+	for _, arg := range args {
+		name := toolResourceName(arg)
+		if toolFlags.cluster {
+			tool, err := geneClient.ExecutionV1alpha1().ClusterTools().Get(name, metav1.GetOptions{})
+			if err != nil {
+				ExitWithError(err)
+			}
+			entries = append(entries, toolEntry{Kind: "ClusterTool", Name: tool.Name, Spec: tool.Spec})
+			continue
+		}
+		tool, err := geneClient.ExecutionV1alpha1().Tools(toolFlags.namespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			ExitWithError(err)
+		}
+		entries = append(entries, toolEntry{Kind: "Tool", Name: tool.Name, Namespace: tool.Namespace, Spec: tool.Spec})
+	}
+
+	PrintToolList(entries, toolFlags.output)
+}
+
+func CreateTools(cmd *cobra.Command, toolFlags *toolFlags) {
+	if len(toolFlags.filename) == 0 {
+		ExitWithError(fmt.Errorf("the tool file must be specified with -f"))
+	}
+	tools, err := readToolFiles(toolFlags.filename)
+	if err != nil {
+		ExitWithError(err)
+	}
+
+	geneClient, err := client.GetGeneClient(cmd)
+	if err != nil {
+		ExitWithError(err)
+	}
+
+	failed := false
+	for _, tool := range tools {
+		meta := metav1.ObjectMeta{Name: parser.ToolResourceName(tool.Name, tool.Version)}
+		spec := parser.TransTool2ToolSpec(tool)
+		if toolFlags.cluster {
+			_, err = geneClient.ExecutionV1alpha1().ClusterTools().Create(&execv1alpha1.ClusterTool{ObjectMeta: meta, Spec: spec})
+		} else {
+			meta.Namespace = toolFlags.namespace
+			_, err = geneClient.ExecutionV1alpha1().Tools(toolFlags.namespace).Create(&execv1alpha1.Tool{ObjectMeta: meta, Spec: spec})
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "create tool %s:%s error: %v\n", tool.Name, tool.Version, err)
+			failed = true
+			continue
+		}
+		fmt.Printf("tool %s:%s created as %s\n", tool.Name, tool.Version, meta.Name)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func DeleteTools(cmd *cobra.Command, args []string, toolFlags *toolFlags) {
+	geneClient, err := client.GetGeneClient(cmd)
+	if err != nil {
+		ExitWithError(err)
+	}
+
+	for _, arg := range args {
+		name := toolResourceName(arg)
+		if toolFlags.cluster {
+			err = geneClient.ExecutionV1alpha1().ClusterTools().Delete(name, &metav1.DeleteOptions{})
+		} else {
+			err = geneClient.ExecutionV1alpha1().Tools(toolFlags.namespace).Delete(name, &metav1.DeleteOptions{})
+		}
+		if err != nil {
+			ExitWithError(err)
+		}
+		fmt.Printf("delete tool %v successfully\n", name)
+	}
+}
+
+func PrintToolList(entries []toolEntry, output string) {
+	switch output {
+	case "wide":
+		PrintToolListWide(entries)
+	case "json":
+		util.PrintJSON(entries)
+	case "yaml":
+		util.PrintYAML(entries)
+	default:
+		fmt.Printf("unsupported format: %v\n", output)
+	}
+}
+
+func PrintToolListWide(entries []toolEntry) {
+	out := new(tabwriter.Writer)
+	buf := &bytes.Buffer{}
+	out.Init(buf, 0, 8, 2, ' ', 0)
+	fmt.Fprint(out, "Tool\tKind\tName\tImage\tType\n")
+	fmt.Fprint(out, "----\t----\t----\t-----\t----\n")
+
+	for _, entry := range entries {
+		fmt.Fprintf(out, "%v:%v\t%v\t%v\t%v\t%v\n", entry.Spec.Name, entry.Spec.Version, entry.Kind, entry.Name, entry.Spec.Image, entry.Spec.Type)
+	}
+
+	out.Flush()
+	fmt.Fprintf(os.Stdout, "%s\n", buf.String())
+}
+
+// toolResourceName returns the resource name of a tool given by name:version
+// or by the resource name itself.
+func toolResourceName(arg string) string {
+	if index := strings.LastIndex(arg, ":"); index != -1 {
+		return parser.ToolResourceName(arg[:index], arg[index+1:])
+	}
+	return arg
+}
+
+// readToolFiles reads the tools from a tool file or a directory of tool files.
+func readToolFiles(path string) ([]parser.Tool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{path}
+	if info.IsDir() {
+		if files, err = parser.GetAllToolFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	tools := make([]parser.Tool, 0)
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("read tool file %s error: %v", file, err)
+		}
+		fileTools, err := parser.ParseTool(data)
+		if err != nil {
+			return nil, fmt.Errorf("tool file %s: %v", file, err)
+		}
+		tools = append(tools, fileTools...)
+	}
+	return tools, nil
+}
+
+// fetchTools returns the usable tools of the workflow. If the tool repo is
+// "cluster", they are the cluster tools and the tools in the namespace of the
+// execution.
+func fetchTools(cmd *cobra.Command, workflow *parser.Workflow, inputs map[string]interface{}) (map[string]parser.Tool, error) {
+	if util.GetFlagString(cmd, "tool-repo") != parser.ClusterToolRepo {
+		return parser.LoadTools(util.GetFlagString(cmd, "tool-repo"))
+	}
+
+	mergedInputs, err := parser.MergeInputs(workflow.Inputs, inputs)
+	if err != nil {
+		return nil, err
+	}
+	geneClient, err := client.GetGeneClient(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return fetchClusterTools(geneClient, parser.GetExecutionNamespace(mergedInputs))
+}
+
+func fetchClusterTools(geneClient execclientset.Interface, namespace string) (map[string]parser.Tool, error) {
+	clusterTools, err := geneClient.ExecutionV1alpha1().ClusterTools().List(metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("list cluster tools error: %v", err)
+	}
+	tools, err := geneClient.ExecutionV1alpha1().Tools(namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("list tools in namespace %s error: %v", namespace, err)
+	}
+	return parser.TransToolResources2Map(clusterTools.Items, tools.Items), nil
+}
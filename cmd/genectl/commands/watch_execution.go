@@ -0,0 +1,236 @@
+/*
+Copyright 2019 The Kubegene Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	execv1alpha1 "kubegene.io/kubegene/pkg/apis/gene/v1alpha1"
+	execclientset "kubegene.io/kubegene/pkg/client/clientset/versioned"
+	geneutil "kubegene.io/kubegene/pkg/util"
+)
+
+// progressBarWidth is the number of the characters of a progress bar.
+const progressBarWidth = 20
+
+// watchExecutions renders the executions that match the list options and are
+// kept by keep every time one of them changes, and every second to refresh
+// the elapsed time. It returns once all the executions have completed.
+func watchExecutions(geneClient execclientset.Interface, namespace string, options metav1.ListOptions, keep func(*execv1alpha1.Execution) bool, render func([]execv1alpha1.Execution)) error {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		list, err := geneClient.ExecutionV1alpha1().Executions(namespace).List(options)
+		if err != nil {
+			return fmt.Errorf("list execution error: %v", err)
+		}
+		executions := make(map[string]execv1alpha1.Execution, len(list.Items))
+		for _, exec := range list.Items {
+			if keep(&exec) {
+				executions[exec.Namespace+"/"+exec.Name] = exec
+			}
+		}
+		render(sortedExecutions(executions))
+		if executionsCompleted(executions) {
+			return nil
+		}
+
+		watchOptions := options
+		watchOptions.ResourceVersion = list.ResourceVersion
+		watcher, err := geneClient.ExecutionV1alpha1().Executions(namespace).Watch(watchOptions)
+		if err != nil {
+			return fmt.Errorf("watch execution error: %v", err)
+		}
+		completed := followExecutions(watcher, ticker, executions, keep, render)
+		watcher.Stop()
+		if completed {
+			return nil
+		}
+		// the watch has expired, list the executions again.
+	}
+}
+
+// followExecutions applies the events of the watcher to the executions and
+// renders them, until they have completed or the watch ends.
+func followExecutions(watcher watch.Interface, ticker *time.Ticker, executions map[string]execv1alpha1.Execution, keep func(*execv1alpha1.Execution) bool, render func([]execv1alpha1.Execution)) bool {
+	for {
+		select {
+		case event, ok := <-watcher.ResultChan():
+			if !ok {
+				return false
+			}
+			exec, ok := event.Object.(*execv1alpha1.Execution)
+			if !ok {
+				// the watch failed, such as the resource version is too old.
+				return false
+			}
+			if !keep(exec) {
+				continue
+			}
+			key := exec.Namespace + "/" + exec.Name
+			switch event.Type {
+			case watch.Added, watch.Modified:
+				executions[key] = *exec
+			case watch.Deleted:
+				delete(executions, key)
+			}
+			render(sortedExecutions(executions))
+			if executionsCompleted(executions) {
+				return true
+			}
+		case <-ticker.C:
+			render(sortedExecutions(executions))
+		}
+	}
+}
+
+// sortedExecutions returns the executions in the order of their namespace and name.
+func sortedExecutions(executions map[string]execv1alpha1.Execution) []execv1alpha1.Execution {
+	keys := make([]string, 0, len(executions))
+	for key := range executions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	sorted := make([]execv1alpha1.Execution, 0, len(keys))
+	for _, key := range keys {
+		sorted = append(sorted, executions[key])
+	}
+	return sorted
+}
+
+// executionsCompleted returns whether there are executions and all of them
+// have completed.
+func executionsCompleted(executions map[string]execv1alpha1.Execution) bool {
+	if len(executions) == 0 {
+		return false
+	}
+	for _, exec := range executions {
+		if !geneutil.IsExecutionCompleted(&exec) {
+			return false
+		}
+	}
+	return true
+}
+
+// refreshScreen clears the terminal and writes the content of the buffer.
+func refreshScreen(buf *bytes.Buffer) {
+	fmt.Fprint(os.Stdout, "\033[H\033[2J"+buf.String())
+}
+
+// taskProgress is the number of the finished jobs of a task.
+type taskProgress struct {
+	name     string
+	finished int
+	total    int
+}
+
+// executionProgress returns the progress of every task of the execution. A
+// job has finished if its last attempt has, and the jobs of a dynamic task
+// are known once they have been started. All the jobs of a skipped task have
+// finished.
+func executionProgress(exec *execv1alpha1.Execution) []taskProgress {
+	jobs := lastAttempts(exec)
+	progress := make([]taskProgress, 0, len(exec.Spec.Tasks))
+	for _, task := range exec.Spec.Tasks {
+		item := taskProgress{name: task.Name, total: len(task.CommandSet)}
+		if len(jobs[task.Name]) > item.total {
+			item.total = len(jobs[task.Name])
+		}
+		for _, status := range jobs[task.Name] {
+			switch status.Phase {
+			case execv1alpha1.VertexSucceeded, execv1alpha1.VertexFailed, execv1alpha1.VertexError:
+				item.finished++
+			case execv1alpha1.VertexSkipped:
+				item.finished = item.total
+			}
+		}
+		progress = append(progress, item)
+	}
+	return progress
+}
+
+// lastAttempts returns the status of the last attempt of every job of every
+// task of the execution, keyed by the task and the index of the job.
+func lastAttempts(exec *execv1alpha1.Execution) map[string]map[int32]execv1alpha1.VertexStatus {
+	jobs := make(map[string]map[int32]execv1alpha1.VertexStatus)
+	for _, status := range exec.Status.Vertices {
+		if status.Type == execv1alpha1.DAGVertexType {
+			continue
+		}
+		if jobs[status.Task] == nil {
+			jobs[status.Task] = make(map[int32]execv1alpha1.VertexStatus)
+		}
+		if last, ok := jobs[status.Task][status.Index]; !ok || status.Attempt >= last.Attempt {
+			jobs[status.Task][status.Index] = status
+		}
+	}
+	return jobs
+}
+
+// progressBar returns a bar of the finished part of the total.
+func progressBar(finished, total int) string {
+	filled := 0
+	if total > 0 {
+		filled = finished * progressBarWidth / total
+	}
+	return "[" + strings.Repeat("#", filled) + strings.Repeat(".", progressBarWidth-filled) + "]"
+}
+
+// elapsedTime returns how long the execution has been running, until now if
+// it has not finished.
+func elapsedTime(exec *execv1alpha1.Execution, now time.Time) time.Duration {
+	if exec.Status.StartedAt.IsZero() {
+		return 0
+	}
+	end := now
+	if !exec.Status.FinishedAt.IsZero() {
+		end = exec.Status.FinishedAt.Time
+	}
+	if end.Before(exec.Status.StartedAt.Time) {
+		return 0
+	}
+	return end.Sub(exec.Status.StartedAt.Time).Round(time.Second)
+}
+
+// DescribeProgress prints the elapsed time of the execution and a progress
+// bar of the jobs of every task.
+func DescribeProgress(out io.Writer, exec *execv1alpha1.Execution, now time.Time) {
+	buf := &bytes.Buffer{}
+	tabWriter := newTabWriter(buf)
+	writer := NewExecutionWriter(tabWriter)
+
+	writer.Write(0, "Elapsed:\t%s\n", elapsedTime(exec, now))
+	writer.Write(0, "Progress:\n")
+	writer.Write(1, "Task\tProgress\tJobs\n")
+	writer.Write(1, "----\t--------\t----\n")
+	for _, task := range executionProgress(exec) {
+		writer.Write(1, "%s\t%s\t%d/%d\n", task.name, progressBar(task.finished, task.total), task.finished, task.total)
+	}
+
+	tabWriter.Flush()
+	fmt.Fprintf(out, "%s\n", buf.String())
+}
@@ -19,8 +19,11 @@ package app
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"reflect"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/golang/glog"
@@ -47,6 +50,7 @@ import (
 	execinformers "kubegene.io/kubegene/pkg/client/informers/externalversions"
 	"kubegene.io/kubegene/pkg/controller"
 	"kubegene.io/kubegene/pkg/util"
+	"kubegene.io/kubegene/pkg/webhook"
 )
 
 const (
@@ -94,18 +98,43 @@ func createClients(o *options.ExecutionOption) (clientset.Interface, clientset.I
 	return kubeClient, leaderElectionClient, geneClient, apiextentionsClient, nil
 }
 
-func installExecutionCRD(apiextensionsclient apiextensionsclient.Interface) error {
+// installCRDs ensures the custom resources of kubegene have been created.
+func installCRDs(apiextensionsclient apiextensionsclient.Interface) error {
+	if err := installCRD(apiextensionsclient, gene.ExecutionPlural, reflect.TypeOf(genev1alpha1.Execution{}).Name()); err != nil {
+		return err
+	}
+	if err := installCRD(apiextensionsclient, gene.CronExecutionPlural, reflect.TypeOf(genev1alpha1.CronExecution{}).Name()); err != nil {
+		return err
+	}
+	if err := installCRD(apiextensionsclient, gene.WorkflowTemplatePlural, reflect.TypeOf(genev1alpha1.WorkflowTemplate{}).Name()); err != nil {
+		return err
+	}
+	if err := installCRD(apiextensionsclient, gene.ToolPlural, reflect.TypeOf(genev1alpha1.Tool{}).Name()); err != nil {
+		return err
+	}
+	return installClusterCRD(apiextensionsclient, gene.ClusterToolPlural, reflect.TypeOf(genev1alpha1.ClusterTool{}).Name())
+}
+
+func installCRD(apiextensionsclient apiextensionsclient.Interface, plural, kind string) error {
+	return installScopedCRD(apiextensionsclient, plural, kind, apiextensionsv1beta1.NamespaceScoped)
+}
+
+func installClusterCRD(apiextensionsclient apiextensionsclient.Interface, plural, kind string) error {
+	return installScopedCRD(apiextensionsclient, plural, kind, apiextensionsv1beta1.ClusterScoped)
+}
+
+func installScopedCRD(apiextensionsclient apiextensionsclient.Interface, plural, kind string, scope apiextensionsv1beta1.ResourceScope) error {
 	crd := &apiextensionsv1beta1.CustomResourceDefinition{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: gene.ExecutionPlural + "." + gene.GroupName,
+			Name: plural + "." + gene.GroupName,
 		},
 		Spec: apiextensionsv1beta1.CustomResourceDefinitionSpec{
 			Group:   gene.GroupName,
 			Version: genev1alpha1.SchemeGroupVersion.Version,
-			Scope:   apiextensionsv1beta1.NamespaceScoped,
+			Scope:   scope,
 			Names: apiextensionsv1beta1.CustomResourceDefinitionNames{
-				Plural: gene.ExecutionPlural,
-				Kind:   reflect.TypeOf(genev1alpha1.Execution{}).Name(),
+				Plural: plural,
+				Kind:   kind,
 			},
 			Subresources: &apiextensionsv1beta1.CustomResourceSubresources{
 				Status: &apiextensionsv1beta1.CustomResourceSubresourceStatus{},
@@ -120,33 +149,157 @@ func installExecutionCRD(apiextensionsclient apiextensionsclient.Interface) erro
 	return nil
 }
 
+// createNotifier creates the notifier of the webhooks configured for the cluster.
+func createNotifier(o *options.ExecutionOption) (*webhook.Notifier, error) {
+	config := webhook.Config{
+		URLs:          o.WebhookURLs,
+		AllowedURLs:   o.WebhookAllowedURLs,
+		VertexFailure: o.WebhookVertexFailure,
+		Timeout:       o.WebhookTimeout,
+		Backoff:       webhook.DefaultBackoff,
+		Workers:       o.WebhookWorkers,
+	}
+	if o.WebhookRetries > 0 {
+		config.Backoff.Steps = o.WebhookRetries
+	}
+	if len(o.WebhookSecretFile) != 0 {
+		secret, err := ioutil.ReadFile(o.WebhookSecretFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read webhook secret: %v", err)
+		}
+		config.Secret = strings.TrimSpace(string(secret))
+	}
+	return webhook.NewNotifier(config), nil
+}
+
+// createFairShareConfig creates the configuration of the cluster queue of the
+// jobs, nil if the running jobs of the cluster are not limited.
+func createFairShareConfig(o *options.ExecutionOption) (*controller.FairShareConfig, error) {
+	if o.MaxRunningJobs <= 0 {
+		return nil, nil
+	}
+	namespaceWeights, err := parseWeights(o.NamespaceWeights)
+	if err != nil {
+		return nil, fmt.Errorf("invalid namespace weight: %v", err)
+	}
+	userWeights, err := parseWeights(o.UserWeights)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user weight: %v", err)
+	}
+	namespaceMaxPriorities, err := parsePriorities(o.NamespaceMaxPriorities)
+	if err != nil {
+		return nil, fmt.Errorf("invalid namespace max priority: %v", err)
+	}
+	return &controller.FairShareConfig{
+		MaxRunningJobs:         o.MaxRunningJobs,
+		NamespaceWeights:       namespaceWeights,
+		UserWeights:            userWeights,
+		TrustUserAnnotation:    o.TrustUserAnnotation,
+		MaxPriority:            o.MaxPriority,
+		NamespaceMaxPriorities: namespaceMaxPriorities,
+	}, nil
+}
+
+// parsePriorities parses the priorities in the form of name=priority.
+func parsePriorities(values []string) (map[string]int32, error) {
+	priorities := make(map[string]int32, len(values))
+	for _, value := range values {
+		parts := strings.SplitN(value, "=", 2)
+		if len(parts) != 2 || len(parts[0]) == 0 {
+			return nil, fmt.Errorf("%q is not in the form of name=priority", value)
+		}
+		priority, err := strconv.ParseInt(parts[1], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%q: priority must be an integer", value)
+		}
+		priorities[parts[0]] = int32(priority)
+	}
+	return priorities, nil
+}
+
+// parseWeights parses the weights in the form of name=weight.
+func parseWeights(values []string) (map[string]int, error) {
+	weights := make(map[string]int, len(values))
+	for _, value := range values {
+		parts := strings.SplitN(value, "=", 2)
+		if len(parts) != 2 || len(parts[0]) == 0 {
+			return nil, fmt.Errorf("%q is not in the form of name=weight", value)
+		}
+		weight, err := strconv.Atoi(parts[1])
+		if err != nil || weight <= 0 {
+			return nil, fmt.Errorf("%q: weight must be a positive integer", value)
+		}
+		weights[parts[0]] = weight
+	}
+	return weights, nil
+}
+
 func Run(o *options.ExecutionOption, stopCh <-chan struct{}) error {
 	kubeClient, leaderElectionClient, geneClient, apiextentionsClient, err := createClients(o)
 	if err != nil {
 		return err
 	}
 
-	// ensure execution resource has been created, if not, create it.
-	if err := installExecutionCRD(apiextentionsClient); err != nil {
+	// ensure execution resources have been created, if not, create them.
+	if err := installCRDs(apiextentionsClient); err != nil {
 		return err
 	}
 
+	notifier, err := createNotifier(o)
+	if err != nil {
+		return err
+	}
+
+	fairShare, err := createFairShareConfig(o)
+	if err != nil {
+		return err
+	}
+	if o.ConcurrentExecutionSyncs <= 0 || o.ConcurrentJobSyncs <= 0 || o.ConcurrentEventSyncs <= 0 {
+		return fmt.Errorf("the numbers of the concurrent syncs must be greater than 0")
+	}
+
 	sharedInformers := informers.NewSharedInformerFactory(kubeClient, o.ResyncPeriod)
 	geneInformer := execinformers.NewSharedInformerFactory(geneClient, o.ResyncPeriod)
 	eventRecorder := createRecorder(kubeClient)
 	parameter := &controller.ControllerParameters{
-		EventRecorder:     eventRecorder,
-		KubeClient:        kubeClient,
-		ExecutionClient:   geneClient.ExecutionV1alpha1(),
-		JobInformer:       sharedInformers.Batch().V1().Jobs(),
-		ExecutionInformer: geneInformer.Execution().V1alpha1().Executions(),
+		EventRecorder:        eventRecorder,
+		KubeClient:           kubeClient,
+		ExecutionClient:      geneClient.ExecutionV1alpha1(),
+		JobInformer:          sharedInformers.Batch().V1().Jobs(),
+		ExecutionInformer:    geneInformer.Execution().V1alpha1().Executions(),
+		Notifier:             notifier,
+		FairShare:            fairShare,
+		AllowHostPathVolumes: o.AllowHostPathVolumes,
+		RateLimiter: &controller.RateLimiterConfig{
+			BaseDelay: o.RateLimiterBaseDelay,
+			MaxDelay:  o.RateLimiterMaxDelay,
+			QPS:       o.RateLimiterQPS,
+			Burst:     o.RateLimiterBurst,
+		},
+
+		CronExecutionClient:   geneClient.ExecutionV1alpha1(),
+		CronExecutionInformer: geneInformer.Execution().V1alpha1().CronExecutions(),
+
+		WorkflowTemplateInformer: geneInformer.Execution().V1alpha1().WorkflowTemplates(),
+		ToolRepo:                 o.ToolRepo,
+		ToolInformer:             geneInformer.Execution().V1alpha1().Tools(),
+		ClusterToolInformer:      geneInformer.Execution().V1alpha1().ClusterTools(),
+	}
+	if o.ResourceQuotaAdmission {
+		parameter.ResourceQuotaInformer = sharedInformers.Core().V1().ResourceQuotas()
 	}
 
 	execCtrl := controller.NewExecutionController(parameter)
+	cronCtrl := controller.NewCronExecutionController(parameter)
 	run := func(ctx context.Context) {
 		go sharedInformers.Start(stopCh)
 		go geneInformer.Start(stopCh)
-		execCtrl.Run(1, stopCh)
+		go cronCtrl.Run(stopCh)
+		execCtrl.Run(controller.WorkerConfig{
+			ExecutionWorkers: o.ConcurrentExecutionSyncs,
+			JobWorkers:       o.ConcurrentJobSyncs,
+			EventWorkers:     o.ConcurrentEventSyncs,
+		}, stopCh)
 		<-stopCh
 	}
 
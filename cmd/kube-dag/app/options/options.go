@@ -20,6 +20,8 @@ import (
 	"time"
 
 	"github.com/spf13/pflag"
+
+	"kubegene.io/kubegene/pkg/webhook"
 )
 
 const (
@@ -27,6 +29,15 @@ const (
 	defaultKubeAPIBurst = 30
 )
 
+// the defaults of the rate limiters of the queues are the ones of the
+// default controller rate limiter.
+const (
+	defaultRateLimiterBaseDelay = 5 * time.Millisecond
+	defaultRateLimiterMaxDelay  = 1000 * time.Second
+	defaultRateLimiterQPS       = 10.0
+	defaultRateLimiterBurst     = 100
+)
+
 type ExecutionOption struct {
 	KubeConfig   string
 	KubeAPIQPS   float32
@@ -35,6 +46,67 @@ type ExecutionOption struct {
 	// the namespace of the lock object
 	LockObjectNamespace string
 	ResyncPeriod        time.Duration
+
+	// WebhookURLs are notified of the lifecycle events of every execution.
+	WebhookURLs []string
+	// WebhookAllowedURLs are the prefixes of the webhook URLs that the
+	// executions may set.
+	WebhookAllowedURLs []string
+	// WebhookSecretFile is the file of the key to sign the webhook payloads.
+	WebhookSecretFile string
+	// WebhookVertexFailure notifies every failed vertex of every execution.
+	WebhookVertexFailure bool
+	WebhookTimeout       time.Duration
+	WebhookRetries       int
+	// WebhookWorkers is the max number of webhook deliveries in progress.
+	WebhookWorkers int
+
+	// ToolRepo is the directory or URL of the tools used by the executions
+	// instantiated from the workflow templates. The Tool and ClusterTool
+	// resources are used if it is empty.
+	ToolRepo string
+
+	// ResourceQuotaAdmission holds the jobs back until they fit the resource
+	// quotas of their namespace, instead of creating jobs whose pods are
+	// rejected.
+	ResourceQuotaAdmission bool
+
+	// AllowHostPathVolumes allows the tasks to mount the directories of the
+	// nodes with hostPath volumes.
+	AllowHostPathVolumes bool
+
+	// MaxRunningJobs is the maximum number of running jobs of the cluster.
+	// The jobs of all the executions wait in a cluster queue and are created
+	// in fair-share order if it is greater than 0.
+	MaxRunningJobs int
+	// NamespaceWeights and UserWeights are the weights of the namespaces and
+	// the users in the cluster queue, in the form of name=weight.
+	NamespaceWeights []string
+	UserWeights      []string
+	// TrustUserAnnotation shares the running jobs of a namespace between the
+	// users of the user annotation of the executions, which must be set by an
+	// admission webhook. Otherwise the user of an execution is its namespace.
+	TrustUserAnnotation bool
+	// MaxPriority and NamespaceMaxPriorities are the max priorities of the
+	// executions in the cluster queue, the latter in the form of
+	// namespace=priority.
+	MaxPriority            int32
+	NamespaceMaxPriorities []string
+
+	// ConcurrentExecutionSyncs, ConcurrentJobSyncs and ConcurrentEventSyncs
+	// are the numbers of the workers of the execution, job and event queues.
+	// The workers sync different executions concurrently.
+	ConcurrentExecutionSyncs int
+	ConcurrentJobSyncs       int
+	ConcurrentEventSyncs     int
+
+	// RateLimiterBaseDelay and RateLimiterMaxDelay are the bounds of the
+	// exponential backoff of the items that fail, RateLimiterQPS and
+	// RateLimiterBurst limit the rate of the retries of every queue.
+	RateLimiterBaseDelay time.Duration
+	RateLimiterMaxDelay  time.Duration
+	RateLimiterQPS       float64
+	RateLimiterBurst     int
 }
 
 func NewExecutionOption() *ExecutionOption {
@@ -45,6 +117,20 @@ func NewExecutionOption() *ExecutionOption {
 		LeaderElect:         true,
 		LockObjectNamespace: "kube-system",
 		ResyncPeriod:        60 * time.Second,
+		WebhookTimeout:      10 * time.Second,
+		WebhookRetries:      5,
+		WebhookWorkers:      webhook.DefaultWorkers,
+
+		ResourceQuotaAdmission: true,
+
+		ConcurrentExecutionSyncs: 5,
+		ConcurrentJobSyncs:       5,
+		ConcurrentEventSyncs:     5,
+
+		RateLimiterBaseDelay: defaultRateLimiterBaseDelay,
+		RateLimiterMaxDelay:  defaultRateLimiterMaxDelay,
+		RateLimiterQPS:       defaultRateLimiterQPS,
+		RateLimiterBurst:     defaultRateLimiterBurst,
 	}
 }
 
@@ -59,4 +145,27 @@ func (o *ExecutionOption) AddFlags(fs *pflag.FlagSet) {
 	fs.DurationVar(&o.ResyncPeriod, "resyncPeriod", o.ResyncPeriod, "The period that should be used to re-sync the execution.")
 	fs.BoolVar(&o.LeaderElect, "leader-elect", o.LeaderElect, "Start a leader election client and gain leadership before executing the main loop.")
 	fs.StringVar(&o.LockObjectNamespace, "lock-object-namespace", o.LockObjectNamespace, "The namespace of the lock object.")
+	fs.StringSliceVar(&o.WebhookURLs, "webhook-url", o.WebhookURLs, "The webhook URL that the lifecycle events of every execution are posted to. Can be specified multiple times.")
+	fs.StringSliceVar(&o.WebhookAllowedURLs, "webhook-allowed-url", o.WebhookAllowedURLs, "The prefix of the webhook URLs that an execution may set in its spec or annotation, the URLs of the executions are ignored if none is given. Can be specified multiple times.")
+	fs.StringVar(&o.WebhookSecretFile, "webhook-secret-file", o.WebhookSecretFile, "Path to the file of the key to sign the payloads posted to the webhook URLs with HMAC-SHA256.")
+	fs.BoolVar(&o.WebhookVertexFailure, "webhook-vertex-failure", o.WebhookVertexFailure, "Post an event to the webhooks for every failed vertex.")
+	fs.DurationVar(&o.WebhookTimeout, "webhook-timeout", o.WebhookTimeout, "The timeout of a webhook request.")
+	fs.IntVar(&o.WebhookRetries, "webhook-retries", o.WebhookRetries, "The number of times a webhook delivery is tried before it is dropped.")
+	fs.IntVar(&o.WebhookWorkers, "webhook-workers", o.WebhookWorkers, "The max number of webhook deliveries in progress at once, the others are queued.")
+	fs.StringVar(&o.ToolRepo, "tool-repo", o.ToolRepo, "Directory or URL to the tool repository used to instantiate the executions from the workflow templates. If it is a URL, it must point to a tool file. If it is empty, the tools are resolved against the Tool and ClusterTool resources.")
+	fs.BoolVar(&o.ResourceQuotaAdmission, "resource-quota-admission", o.ResourceQuotaAdmission, "Hold the jobs back until they fit the resource quotas of their namespace and mark the executions as throttled.")
+	fs.BoolVar(&o.AllowHostPathVolumes, "allow-host-path-volumes", o.AllowHostPathVolumes, "Allow the tasks to mount the directories of the nodes with hostPath volumes. The executions with hostPath volumes are rejected otherwise.")
+	fs.IntVar(&o.MaxRunningJobs, "max-running-jobs", o.MaxRunningJobs, "The maximum number of running jobs of the cluster. The jobs are dispatched in fair-share order between the namespaces, the users and the executions. 0 means unlimited.")
+	fs.StringSliceVar(&o.NamespaceWeights, "namespace-weight", o.NamespaceWeights, "The weight of the share of a namespace in the cluster queue, in the form of namespace=weight. Can be specified multiple times.")
+	fs.StringSliceVar(&o.UserWeights, "user-weight", o.UserWeights, "The weight of the share of a user in the cluster queue, in the form of user=weight. Can be specified multiple times.")
+	fs.BoolVar(&o.TrustUserAnnotation, "trust-user-annotation", o.TrustUserAnnotation, "Share the running jobs of a namespace between the users of the kubegene.io/user annotation of the executions. Only enable it if an admission webhook sets the annotation to the user who creates the execution. Otherwise the user of an execution is its namespace.")
+	fs.Int32Var(&o.MaxPriority, "max-priority", o.MaxPriority, "The max priority of the executions in the cluster queue, a higher priority is lowered to it.")
+	fs.StringSliceVar(&o.NamespaceMaxPriorities, "namespace-max-priority", o.NamespaceMaxPriorities, "The max priority of the executions of a namespace in the cluster queue, in the form of namespace=priority. Can be specified multiple times.")
+	fs.IntVar(&o.ConcurrentExecutionSyncs, "concurrent-execution-syncs", o.ConcurrentExecutionSyncs, "The number of workers that sync the executions. Different executions are synced concurrently.")
+	fs.IntVar(&o.ConcurrentJobSyncs, "concurrent-job-syncs", o.ConcurrentJobSyncs, "The number of workers that sync the status of the jobs into their executions.")
+	fs.IntVar(&o.ConcurrentEventSyncs, "concurrent-event-syncs", o.ConcurrentEventSyncs, "The number of workers that create the jobs whose dependents have finished.")
+	fs.DurationVar(&o.RateLimiterBaseDelay, "rate-limiter-base-delay", o.RateLimiterBaseDelay, "The delay of the first retry of an item of the queues that failed, doubled on every retry.")
+	fs.DurationVar(&o.RateLimiterMaxDelay, "rate-limiter-max-delay", o.RateLimiterMaxDelay, "The maximum delay of the retries of an item of the queues that failed.")
+	fs.Float64Var(&o.RateLimiterQPS, "rate-limiter-qps", o.RateLimiterQPS, "The overall rate of the retries of the items of every queue.")
+	fs.IntVar(&o.RateLimiterBurst, "rate-limiter-burst", o.RateLimiterBurst, "The burst of the retries of the items of every queue.")
 }
@@ -17,6 +17,10 @@ limitations under the License.
 package gene
 
 const (
-	GroupName       = "execution.kubegene.io"
-	ExecutionPlural = "executions"
+	GroupName              = "execution.kubegene.io"
+	ExecutionPlural        = "executions"
+	CronExecutionPlural    = "cronexecutions"
+	WorkflowTemplatePlural = "workflowtemplates"
+	ToolPlural             = "tools"
+	ClusterToolPlural      = "clustertools"
 )
@@ -47,6 +47,14 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 	scheme.AddKnownTypes(SchemeGroupVersion,
 		&Execution{},
 		&ExecutionList{},
+		&CronExecution{},
+		&CronExecutionList{},
+		&WorkflowTemplate{},
+		&WorkflowTemplateList{},
+		&Tool{},
+		&ToolList{},
+		&ClusterTool{},
+		&ClusterToolList{},
 	)
 	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
 	return nil
@@ -32,6 +32,12 @@ const (
 	VertexSucceeded VertexPhase = "Succeeded"
 	VertexFailed    VertexPhase = "Failed"
 	VertexError     VertexPhase = "Error"
+	// VertexRetried is the phase of a failed iteration of a loop that has
+	// been retried by the next iteration.
+	VertexRetried VertexPhase = "Retried"
+	// VertexSkipped is the phase of a dynamic task whose condition is false,
+	// the task has no job.
+	VertexSkipped VertexPhase = "Skipped"
 )
 
 // TaskType is the type of a job
@@ -109,9 +115,108 @@ type ExecutionSpec struct {
 	// +optional
 	Tolerations []apiv1.Toleration `json:"tolerations,omitempty"`
 
+	// PodSettings are the settings of all pods of the workflow. They are able
+	// to be overridden by the settings specified in the task.
+	PodSettings `json:",inline"`
+
 	// Parallelism limits the max total parallel jobs that can execute at the same time in a workflow
 	// +optional
 	Parallelism *int64 `json:"parallelism,omitempty"`
+
+	// Priority is the priority of the jobs of the execution in the cluster
+	// queue of kube-dag. The jobs of the executions with a higher priority are
+	// created first when the running jobs of the cluster are limited. It is
+	// capped by the max priority of the namespace configured in kube-dag.
+	// +optional
+	Priority int32 `json:"priority,omitempty"`
+
+	// User is the user who submitted the execution as given by the
+	// submitter, for information only. The cluster queue does not trust it,
+	// it shares the running jobs between the users of the kubegene.io/user
+	// annotation set by an admission webhook, or else the namespaces.
+	// +optional
+	User string `json:"user,omitempty"`
+
+	// OnExit is the names of the tasks that run after all the other tasks
+	// have finished, whether the execution succeeded or failed. They are
+	// not a part of the DAG, ${workflow.phase} and ${workflow.failures} in
+	// their commands are replaced with the phase the DAG finished with and
+	// the names of the failed vertices.
+	// +optional
+	OnExit []string `json:"onExit,omitempty"`
+
+	// Webhook configures the notifications of the lifecycle of the execution.
+	// +optional
+	Webhook *Webhook `json:"webhook,omitempty"`
+
+	// WorkflowTemplateRef references the workflow template the tasks of the
+	// execution are instantiated from. It is used only if the tasks are empty,
+	// the controller fills in the tasks when the execution is created.
+	// +optional
+	WorkflowTemplateRef *WorkflowTemplateRef `json:"workflowTemplateRef,omitempty"`
+}
+
+// PodSettings are the settings of the pods of the tasks.
+type PodSettings struct {
+	// SecurityContext holds the pod-level security attributes, such as
+	// runAsUser and fsGroup.
+	// +optional
+	SecurityContext *apiv1.PodSecurityContext `json:"securityContext,omitempty"`
+
+	// ServiceAccountName is the name of the service account to run the pods.
+	// +optional
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+
+	// ImagePullSecrets is the secrets to pull the images. The secrets of the
+	// execution and the task are both used.
+	// +optional
+	ImagePullSecrets []apiv1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+
+	// PriorityClassName is the priority class of the pods.
+	// +optional
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+
+	// PodLabels are the extra labels of the pods. The labels of the task
+	// override the ones of the execution with the same key.
+	// +optional
+	PodLabels map[string]string `json:"podLabels,omitempty"`
+
+	// PodAnnotations are the extra annotations of the pods. The annotations of
+	// the task override the ones of the execution with the same key.
+	// +optional
+	PodAnnotations map[string]string `json:"podAnnotations,omitempty"`
+
+	// PodSpecPatch is a strategic merge patch of the pod spec in JSON or
+	// YAML, for the fields that are not supported otherwise. The patch of
+	// the execution is applied before the one of the task.
+	// +optional
+	PodSpecPatch string `json:"podSpecPatch,omitempty"`
+}
+
+// WorkflowTemplateRef references a workflow template in the namespace of
+// the execution.
+type WorkflowTemplateRef struct {
+	// Name is the name of the workflow template.
+	Name string `json:"name"`
+
+	// Arguments are the values of the inputs of the workflow, they override
+	// the default values of the inputs.
+	// +optional
+	Arguments map[string]interface{} `json:"arguments,omitempty"`
+}
+
+// Webhook defines where the lifecycle events of an execution are posted to.
+type Webhook struct {
+	// URLs are the addresses the events are posted to, in addition to the
+	// ones configured for the cluster. They must match the URLs allowed by
+	// the cluster, and the events posted to them are signed with the key
+	// of the cluster as well.
+	// +optional
+	URLs []string `json:"urls,omitempty"`
+
+	// VertexFailure also posts an event for every failed vertex.
+	// +optional
+	VertexFailure bool `json:"vertexFailure,omitempty"`
 }
 
 // Condition in Task
@@ -158,6 +263,27 @@ type Task struct {
 	// +optional
 	Resources ResourceRequirements `json:"resources,omitempty"`
 
+	// Env is the environment variables of the containers of the task.
+	// +optional
+	Env []apiv1.EnvVar `json:"env,omitempty"`
+
+	// EnvFrom is the sources to populate the environment variables of the
+	// containers of the task. The variables of Env take precedence.
+	// +optional
+	EnvFrom []apiv1.EnvFromSource `json:"envFrom,omitempty"`
+
+	// WorkingDir is the working directory of the containers of the task.
+	// +optional
+	WorkingDir string `json:"workingDir,omitempty"`
+
+	// ImagePullPolicy is the pull policy of the image. Defaults to IfNotPresent.
+	// +optional
+	ImagePullPolicy apiv1.PullPolicy `json:"imagePullPolicy,omitempty"`
+
+	// PodSettings are the settings of the pods of the task. They override
+	// the settings of the execution.
+	PodSettings `json:",inline"`
+
 	// Specifies the duration in seconds relative to the startTime that the job may be active
 	// before the system tries to terminate it; value must be positive integer
 	// +optional
@@ -181,6 +307,36 @@ type Task struct {
 	// The task will be executed only when condition satisfied
 	// +optional
 	Condition *Condition `json:"condition,omitempty"`
+
+	// Loop re-runs the task until the loop condition is met or the
+	// max iterations is reached. Every iteration is a separate job.
+	// +optional
+	Loop *Loop `json:"loop,omitempty"`
+
+	// SubWorkflow is the path of the nested workflow the task is expanded
+	// from, such as qc/align. The status of every nested workflow is rolled
+	// up into a vertex status of type DAG.
+	// +optional
+	SubWorkflow string `json:"subWorkflow,omitempty"`
+}
+
+// Loop defines how a task is re-run.
+type Loop struct {
+	// Until is the expected output of an iteration. The loop stops once
+	// the stdout of an iteration equals it. If empty, the loop stops at
+	// the first iteration that succeeds, failed iterations are retried.
+	// +optional
+	Until string `json:"until,omitempty"`
+
+	// MaxIterations is the max number of iterations of the loop.
+	MaxIterations int32 `json:"maxIterations"`
+
+	// Body are the other tasks that are re-run with the task in every
+	// iteration, such as the steps of a refinement pass. The task depends
+	// on all of them, and is the only one that the tasks out of the body
+	// can depend on.
+	// +optional
+	Body []string `json:"body,omitempty"`
 }
 
 // +k8s:openapi-gen=false
@@ -199,6 +355,47 @@ type ExecutionStatus struct {
 
 	// Vertices is a mapping between a vertex ID and the vertex's status.
 	Vertices map[string]VertexStatus `json:"vertices,omitempty"`
+	// DAGPhase is the phase the tasks of the workflow finished with. It is
+	// set before the exit handler tasks run, and the execution is marked
+	// with it once they have finished.
+	// +optional
+	DAGPhase VertexPhase `json:"dagPhase,omitempty"`
+
+	// ExpandedTasks are the commands of the dynamic tasks that have been
+	// expanded into their jobs, keyed by the task name, so that the jobs are
+	// restored once the graph is rebuilt. A skipped task has no command.
+	// +optional
+	ExpandedTasks map[string][]string `json:"expandedTasks,omitempty"`
+
+	// Conditions are the latest observations of the state of the execution.
+	// +optional
+	Conditions []ExecutionCondition `json:"conditions,omitempty"`
+}
+
+// ExecutionConditionType is the type of a condition of an execution.
+type ExecutionConditionType string
+
+const (
+	// ExecutionThrottled means the jobs of the execution are waiting for the
+	// resource quota of the namespace to free.
+	ExecutionThrottled ExecutionConditionType = "Throttled"
+)
+
+// ExecutionCondition is an observation of the state of an execution.
+type ExecutionCondition struct {
+	// Type of the condition.
+	Type ExecutionConditionType `json:"type"`
+	// Status of the condition, one of True, False, Unknown.
+	Status apiv1.ConditionStatus `json:"status"`
+	// LastTransitionTime is the last time the status changed.
+	// +optional
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+	// Reason is a brief CamelCase reason of the last transition.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// Message is a human readable message about the last transition.
+	// +optional
+	Message string `json:"message,omitempty"`
 }
 
 // CommandsIter defines command for workflows job. If both Vars and Vars_iter are specified,
@@ -235,6 +432,11 @@ type CommandsIter struct {
 	// sh /tmp/scripts/step1.splitfq.sh sample1 1 /tmp/data 2
 	// sh /tmp/scripts/step1.splitfq.sh sample2 1 /tmp/data 2
 	VarsIter []interface{} `json:"vars_iter,omitempty"`
+
+	// Zip combines the rows of VarsIter one by one instead of the full
+	// permutation, every row must have the same length.
+	// +optional
+	Zip bool `json:"zip,omitempty"`
 }
 
 type VertexStatus struct {
@@ -248,6 +450,18 @@ type VertexStatus struct {
 	// Type indicates type of vertex
 	Type VertexType `json:"type"`
 
+	// Task is the name of the task of the job of a job vertex.
+	// +optional
+	Task string `json:"task,omitempty"`
+
+	// Index is the index of the job of a job vertex within its task.
+	// +optional
+	Index int32 `json:"index,omitempty"`
+
+	// Attempt is the loop iteration of the job of a job vertex.
+	// +optional
+	Attempt int32 `json:"attempt,omitempty"`
+
 	// Phase a simple, high-level summary of where the vertex is in its lifecycle.
 	// Can be used as a state machine.
 	Phase VertexPhase `json:"phase,omitempty"`
@@ -268,15 +482,58 @@ type VertexStatus struct {
 type Volume struct {
 	MountPath string       `json:"mountPath"`
 	MountFrom VolumeSource `json:"mountFrom"`
+
+	// ReadOnly mounts the volume read-only.
+	// +optional
+	ReadOnly bool `json:"readOnly,omitempty"`
+
+	// SubPath is the path within the volume to mount instead of its root.
+	// +optional
+	SubPath string `json:"subPath,omitempty"`
 }
 
+// VolumeSource is the source of a volume, exactly one of the fields must be set.
 type VolumeSource struct {
-	Pvc string `json:"pvc"`
+	// Pvc is the name of a PersistentVolumeClaim.
+	// +optional
+	Pvc string `json:"pvc,omitempty"`
+
+	// EmptyDir is a node-local scratch directory that lives as long as the pod.
+	// +optional
+	EmptyDir *apiv1.EmptyDirVolumeSource `json:"emptyDir,omitempty"`
+
+	// HostPath is a directory of the node.
+	// +optional
+	HostPath *apiv1.HostPathVolumeSource `json:"hostPath,omitempty"`
+
+	// ConfigMap is a ConfigMap whose keys are mounted as files.
+	// +optional
+	ConfigMap *apiv1.ConfigMapVolumeSource `json:"configMap,omitempty"`
+
+	// Secret is a Secret whose keys are mounted as files.
+	// +optional
+	Secret *apiv1.SecretVolumeSource `json:"secret,omitempty"`
+
+	// NFS is an export of an NFS server.
+	// +optional
+	NFS *apiv1.NFSVolumeSource `json:"nfs,omitempty"`
 }
 
+// ResourceRequirements is the compute resources of the containers of a task.
 type ResourceRequirements struct {
+	// Memory is the memory request.
 	Memory resource.Quantity `json:"memory"`
-	Cpu    resource.Quantity `json:"cpu"`
+	// Cpu is the cpu request.
+	Cpu resource.Quantity `json:"cpu"`
+	// Requests are the requests of the other resources, such as
+	// ephemeral-storage and extended resources. Memory and Cpu take
+	// precedence over the memory and cpu in Requests.
+	// +optional
+	Requests apiv1.ResourceList `json:"requests,omitempty"`
+	// Limits are the limits of the resources. The limit of an extended
+	// resource defaults to its request.
+	// +optional
+	Limits apiv1.ResourceList `json:"limits,omitempty"`
 }
 
 type Dependent struct {
@@ -291,6 +548,262 @@ type Dependent struct {
 	Type DependType `json:"type,omitempty"`
 }
 
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// CronExecution creates executions from a template on a cron schedule.
+type CronExecution struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	// +optional
+	Spec CronExecutionSpec `json:"spec,omitempty"`
+	// +optional
+	Status CronExecutionStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// CronExecutionList is a collection of cron executions.
+type CronExecutionList struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	// Items is a list of cron executions.
+	Items []CronExecution `json:"items"`
+}
+
+// ConcurrencyPolicy describes how the executions of a cron execution are
+// handled when the previous ones are still running.
+type ConcurrencyPolicy string
+
+// ConcurrencyPolicy
+const (
+	// AllowConcurrent allows the executions to run concurrently.
+	AllowConcurrent ConcurrencyPolicy = "Allow"
+	// ForbidConcurrent skips the next run if the previous one is still running.
+	ForbidConcurrent ConcurrencyPolicy = "Forbid"
+	// ReplaceConcurrent deletes the running executions and creates a new one.
+	ReplaceConcurrent ConcurrencyPolicy = "Replace"
+)
+
+type CronExecutionSpec struct {
+	// Schedule is the schedule in the standard cron format, for example
+	// "0 2 * * *". The descriptors like @daily are supported too.
+	Schedule string `json:"schedule"`
+
+	// StartingDeadlineSeconds is the deadline in seconds for starting an
+	// execution if it misses its scheduled time. Missed runs are counted
+	// as failed ones.
+	// +optional
+	StartingDeadlineSeconds *int64 `json:"startingDeadlineSeconds,omitempty"`
+
+	// ConcurrencyPolicy specifies how to treat the concurrent executions.
+	// Default to Allow.
+	// +optional
+	ConcurrencyPolicy ConcurrencyPolicy `json:"concurrencyPolicy,omitempty"`
+
+	// Suspend tells the controller to suspend the subsequent executions,
+	// it does not apply to the executions that have started already.
+	// +optional
+	Suspend *bool `json:"suspend,omitempty"`
+
+	// SuccessfulExecutionsHistoryLimit is the number of the succeeded
+	// executions to retain. Default to 3.
+	// +optional
+	SuccessfulExecutionsHistoryLimit *int32 `json:"successfulExecutionsHistoryLimit,omitempty"`
+
+	// FailedExecutionsHistoryLimit is the number of the failed executions
+	// to retain. Default to 1.
+	// +optional
+	FailedExecutionsHistoryLimit *int32 `json:"failedExecutionsHistoryLimit,omitempty"`
+
+	// ExecutionTemplate is the template of the executions to create.
+	ExecutionTemplate ExecutionTemplateSpec `json:"executionTemplate"`
+}
+
+// ExecutionTemplateSpec describes the executions created from a template.
+type ExecutionTemplateSpec struct {
+	// Standard object's metadata of the executions created from this template.
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Spec is the specification of the executions.
+	Spec ExecutionSpec `json:"spec"`
+}
+
+type CronExecutionStatus struct {
+	// Active is the names of the running executions.
+	// +optional
+	Active []string `json:"active,omitempty"`
+
+	// LastScheduleTime is the last time an execution was scheduled.
+	// +optional
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+}
+
+// +genclient
+// +genclient:noStatus
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// WorkflowTemplate stores a workflow, so that executions can be instantiated
+// from it in the cluster.
+type WorkflowTemplate struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	// +optional
+	Spec WorkflowTemplateSpec `json:"spec,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// WorkflowTemplateList is a collection of workflow templates.
+type WorkflowTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	// Items is a list of workflow templates.
+	Items []WorkflowTemplate `json:"items"`
+}
+
+type WorkflowTemplateSpec struct {
+	// Workflow is the workflow definition in YAML, the same as the workflow
+	// file submitted by genectl. The catalog of a sub workflow is the name of
+	// another workflow template in the same namespace.
+	Workflow string `json:"workflow"`
+}
+
+// +genclient
+// +genclient:noStatus
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// Tool is a tool the jobs of the workflows in a namespace can use. It
+// overrides the cluster tool with the same name and version.
+type Tool struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	// +optional
+	Spec ToolSpec `json:"spec,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ToolList is a collection of tools.
+type ToolList struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	// Items is a list of tools.
+	Items []Tool `json:"items"`
+}
+
+// +genclient
+// +genclient:nonNamespaced
+// +genclient:noStatus
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ClusterTool is a tool the jobs of the workflows in all namespaces can use.
+type ClusterTool struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	// +optional
+	Spec ToolSpec `json:"spec,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ClusterToolList is a collection of cluster tools.
+type ClusterToolList struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	// Items is a list of cluster tools.
+	Items []ClusterTool `json:"items"`
+}
+
+// ToolSpec is the definition of a tool, the same as the tool file of a tool
+// repository. A job refers to a tool by name:version.
+type ToolSpec struct {
+	// Name is the name of the tool.
+	Name string `json:"name"`
+
+	// Version is the version of the tool.
+	Version string `json:"version"`
+
+	// Image is the docker image of the tool.
+	Image string `json:"image"`
+
+	// Command is the default command of the jobs using the tool that have
+	// no commands.
+	// +optional
+	Command string `json:"command,omitempty"`
+
+	// Type is the type of the tool.
+	// Deprecated: the type is not used.
+	// +optional
+	Type string `json:"type,omitempty"`
+
+	// Description describes what the tool is used for.
+	// +optional
+	Description string `json:"description,omitempty"`
+
+	// Resources is the default compute resources of the jobs using the tool,
+	// in the same format as the workflow, such as 2C and 4G.
+	// +optional
+	Resources ToolResources `json:"resources,omitempty"`
+
+	// Env is the default environment variables of the jobs using the tool.
+	// +optional
+	Env []apiv1.EnvVar `json:"env,omitempty"`
+
+	// WorkingDir is the default working directory of the jobs using the tool.
+	// +optional
+	WorkingDir string `json:"workingDir,omitempty"`
+
+	// ImagePullPolicy is the default pull policy of the image.
+	// +optional
+	ImagePullPolicy apiv1.PullPolicy `json:"imagePullPolicy,omitempty"`
+
+	// ImagePullSecrets is the names of the secrets to pull the image.
+	// +optional
+	ImagePullSecrets []string `json:"imagePullSecrets,omitempty"`
+
+	// CommandPrefix is prepended to every command of the jobs using the tool.
+	// +optional
+	CommandPrefix string `json:"commandPrefix,omitempty"`
+}
+
+// ToolResources is the default compute resources of a tool. The resources
+// at the top level are the requests.
+type ToolResources struct {
+	ToolResourceList `json:",inline"`
+	// Limits are the default limits of the resources.
+	// +optional
+	Limits *ToolResourceList `json:"limits,omitempty"`
+}
+
+// ToolResourceList is a set of compute resources of a tool.
+type ToolResourceList struct {
+	// +optional
+	Cpu string `json:"cpu,omitempty"`
+	// +optional
+	Memory string `json:"memory,omitempty"`
+	// EphemeralStorage is the local ephemeral storage, such as 50Gi.
+	// +optional
+	EphemeralStorage string `json:"ephemeralStorage,omitempty"`
+	// Extended is the extended resources keyed by the resource name, such
+	// as nvidia.com/gpu.
+	// +optional
+	Extended map[string]string `json:"extended,omitempty"`
+}
+
 // DeepCopyInto is an custom deepcopy function to deal with our use of the interface{} type
 func (i *CommandsIter) DeepCopyInto(out *CommandsIter) {
 
@@ -316,3 +829,16 @@ func (i *Condition) DeepCopyInto(out *Condition) {
 		panic(err)
 	}
 }
+
+// DeepCopyInto is an custom deepcopy function to deal with our use of the interface{} type
+func (i *WorkflowTemplateRef) DeepCopyInto(out *WorkflowTemplateRef) {
+
+	inBytes, err := json.Marshal(i)
+	if err != nil {
+		panic(err)
+	}
+	err = json.Unmarshal(inBytes, out)
+	if err != nil {
+		panic(err)
+	}
+}
@@ -27,7 +27,11 @@ import (
 
 type ExecutionV1alpha1Interface interface {
 	RESTClient() rest.Interface
+	ClusterToolsGetter
+	CronExecutionsGetter
 	ExecutionsGetter
+	ToolsGetter
+	WorkflowTemplatesGetter
 }
 
 // ExecutionV1alpha1Client is used to interact with features provided by the execution.kubegene.io group.
@@ -35,10 +39,26 @@ type ExecutionV1alpha1Client struct {
 	restClient rest.Interface
 }
 
+func (c *ExecutionV1alpha1Client) ClusterTools() ClusterToolInterface {
+	return newClusterTools(c)
+}
+
+func (c *ExecutionV1alpha1Client) CronExecutions(namespace string) CronExecutionInterface {
+	return newCronExecutions(c, namespace)
+}
+
 func (c *ExecutionV1alpha1Client) Executions(namespace string) ExecutionInterface {
 	return newExecutions(c, namespace)
 }
 
+func (c *ExecutionV1alpha1Client) Tools(namespace string) ToolInterface {
+	return newTools(c, namespace)
+}
+
+func (c *ExecutionV1alpha1Client) WorkflowTemplates(namespace string) WorkflowTemplateInterface {
+	return newWorkflowTemplates(c, namespace)
+}
+
 // NewForConfig creates a new ExecutionV1alpha1Client for the given config.
 func NewForConfig(c *rest.Config) (*ExecutionV1alpha1Client, error) {
 	config := *c
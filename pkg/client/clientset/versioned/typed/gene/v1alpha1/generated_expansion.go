@@ -18,4 +18,12 @@ limitations under the License.
 
 package v1alpha1
 
+type ClusterToolExpansion interface{}
+
+type CronExecutionExpansion interface{}
+
 type ExecutionExpansion interface{}
+
+type ToolExpansion interface{}
+
+type WorkflowTemplateExpansion interface{}
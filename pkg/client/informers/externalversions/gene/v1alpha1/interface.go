@@ -24,8 +24,16 @@ import (
 
 // Interface provides access to all the informers in this group version.
 type Interface interface {
+	// ClusterTools returns a ClusterToolInformer.
+	ClusterTools() ClusterToolInformer
+	// CronExecutions returns a CronExecutionInformer.
+	CronExecutions() CronExecutionInformer
 	// Executions returns a ExecutionInformer.
 	Executions() ExecutionInformer
+	// Tools returns a ToolInformer.
+	Tools() ToolInformer
+	// WorkflowTemplates returns a WorkflowTemplateInformer.
+	WorkflowTemplates() WorkflowTemplateInformer
 }
 
 type version struct {
@@ -39,7 +47,27 @@ func New(f internalinterfaces.SharedInformerFactory, namespace string, tweakList
 	return &version{factory: f, namespace: namespace, tweakListOptions: tweakListOptions}
 }
 
+// ClusterTools returns a ClusterToolInformer.
+func (v *version) ClusterTools() ClusterToolInformer {
+	return &clusterToolInformer{factory: v.factory, tweakListOptions: v.tweakListOptions}
+}
+
+// CronExecutions returns a CronExecutionInformer.
+func (v *version) CronExecutions() CronExecutionInformer {
+	return &cronExecutionInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
 // Executions returns a ExecutionInformer.
 func (v *version) Executions() ExecutionInformer {
 	return &executionInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
 }
+
+// Tools returns a ToolInformer.
+func (v *version) Tools() ToolInformer {
+	return &toolInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
+// WorkflowTemplates returns a WorkflowTemplateInformer.
+func (v *version) WorkflowTemplates() WorkflowTemplateInformer {
+	return &workflowTemplateInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
@@ -53,8 +53,16 @@ func (f *genericInformer) Lister() cache.GenericLister {
 func (f *sharedInformerFactory) ForResource(resource schema.GroupVersionResource) (GenericInformer, error) {
 	switch resource {
 	// Group=execution.kubegene.io, Version=v1alpha1
+	case v1alpha1.SchemeGroupVersion.WithResource("clustertools"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Execution().V1alpha1().ClusterTools().Informer()}, nil
+	case v1alpha1.SchemeGroupVersion.WithResource("cronexecutions"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Execution().V1alpha1().CronExecutions().Informer()}, nil
 	case v1alpha1.SchemeGroupVersion.WithResource("executions"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Execution().V1alpha1().Executions().Informer()}, nil
+	case v1alpha1.SchemeGroupVersion.WithResource("tools"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Execution().V1alpha1().Tools().Informer()}, nil
+	case v1alpha1.SchemeGroupVersion.WithResource("workflowtemplates"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Execution().V1alpha1().WorkflowTemplates().Informer()}, nil
 
 	}
 
@@ -18,6 +18,18 @@ limitations under the License.
 
 package v1alpha1
 
+// ClusterToolListerExpansion allows custom methods to be added to
+// ClusterToolLister.
+type ClusterToolListerExpansion interface{}
+
+// CronExecutionListerExpansion allows custom methods to be added to
+// CronExecutionLister.
+type CronExecutionListerExpansion interface{}
+
+// CronExecutionNamespaceListerExpansion allows custom methods to be added to
+// CronExecutionNamespaceLister.
+type CronExecutionNamespaceListerExpansion interface{}
+
 // ExecutionListerExpansion allows custom methods to be added to
 // ExecutionLister.
 type ExecutionListerExpansion interface{}
@@ -25,3 +37,19 @@ type ExecutionListerExpansion interface{}
 // ExecutionNamespaceListerExpansion allows custom methods to be added to
 // ExecutionNamespaceLister.
 type ExecutionNamespaceListerExpansion interface{}
+
+// ToolListerExpansion allows custom methods to be added to
+// ToolLister.
+type ToolListerExpansion interface{}
+
+// ToolNamespaceListerExpansion allows custom methods to be added to
+// ToolNamespaceLister.
+type ToolNamespaceListerExpansion interface{}
+
+// WorkflowTemplateListerExpansion allows custom methods to be added to
+// WorkflowTemplateLister.
+type WorkflowTemplateListerExpansion interface{}
+
+// WorkflowTemplateNamespaceListerExpansion allows custom methods to be added to
+// WorkflowTemplateNamespaceLister.
+type WorkflowTemplateNamespaceListerExpansion interface{}
@@ -18,9 +18,27 @@ package common
 
 import (
 	"encoding/json"
+	"fmt"
 	"strconv"
+	"strings"
 )
 
+// The variants provided to the exit handler tasks of an execution.
+const (
+	// ExitPhaseVariant is replaced with the phase the tasks of the
+	// execution finished with, Succeeded or Failed.
+	ExitPhaseVariant = "workflow.phase"
+	// ExitFailuresVariant is replaced with the names of the failed
+	// vertices separated by comma.
+	ExitFailuresVariant = "workflow.failures"
+)
+
+// HasExitVariant returns whether the template references one of the
+// variants provided to the exit handler tasks.
+func HasExitVariant(template string) bool {
+	return strings.Contains(template, "${"+ExitPhaseVariant+"}") || strings.Contains(template, "${"+ExitFailuresVariant+"}")
+}
+
 type Var []interface{}
 
 func AddVar(varIter []Var, rowCnt, rowNum int, vars Var, result *[]Var) {
@@ -53,6 +71,33 @@ func VarIter2Vars(varIter []Var) []Var {
 	return result
 }
 
+// ZipVars convert varIter to var by combining the rows one by one.
+//
+// example
+//
+//   varIter ---> [[1, 2], [3, 4], [5, 6]]
+//   result  ---> [[1, 3, 5], [2, 4, 6]]
+func ZipVars(varIter []Var) ([]Var, error) {
+	var result []Var
+	if len(varIter) == 0 {
+		return result, nil
+	}
+	length := len(varIter[0])
+	for i, row := range varIter {
+		if len(row) != length {
+			return nil, fmt.Errorf("the length of row %d is %d, but the length of row 0 is %d", i, len(row), length)
+		}
+	}
+	for i := 0; i < length; i++ {
+		vars := make([]interface{}, 0, len(varIter))
+		for _, row := range varIter {
+			vars = append(vars, row[i])
+		}
+		result = append(result, vars)
+	}
+	return result, nil
+}
+
 func ToString(i interface{}) string {
 	switch v := i.(type) {
 	case string:
@@ -127,3 +172,25 @@ func Iter2Array(base string, vars []Var) []string {
 	}
 	return result
 }
+
+// MergeStringMap returns a new map with the entries of both maps, the
+// entries of override win. It returns nil if both maps are empty.
+func MergeStringMap(base, override map[string]string) map[string]string {
+	if len(base) == 0 && len(override) == 0 {
+		return nil
+	}
+	merged := make(map[string]string, len(base)+len(override))
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range override {
+		merged[key] = value
+	}
+	return merged
+}
+
+// IsReservedLabel returns whether the label is set on the jobs and pods by
+// kubegene or kubernetes, so that it can not be set by the pod labels.
+func IsReservedLabel(key string) bool {
+	return key == "controller-uid" || key == "job-name" || strings.HasPrefix(key, "kubegene.io/")
+}
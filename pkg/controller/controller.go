@@ -21,14 +21,18 @@ import (
 	"time"
 
 	"github.com/golang/glog"
+	"golang.org/x/time/rate"
 	batch "k8s.io/api/batch/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
 	batchinformers "k8s.io/client-go/informers/batch/v1"
+	coreinformers "k8s.io/client-go/informers/core/v1"
 	clientset "k8s.io/client-go/kubernetes"
 	batchv1listers "k8s.io/client-go/listers/batch/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
@@ -37,8 +41,11 @@ import (
 	geneclientset "kubegene.io/kubegene/pkg/client/clientset/versioned/typed/gene/v1alpha1"
 	geneinformers "kubegene.io/kubegene/pkg/client/informers/externalversions/gene/v1alpha1"
 	genelisters "kubegene.io/kubegene/pkg/client/listers/gene/v1alpha1"
+	"kubegene.io/kubegene/pkg/dag"
+	"kubegene.io/kubegene/pkg/graph"
 	"kubegene.io/kubegene/pkg/util"
 	"kubegene.io/kubegene/pkg/version"
+	"kubegene.io/kubegene/pkg/webhook"
 )
 
 // controllerKind contains the schema.GroupVersionKind for this controller type.
@@ -51,6 +58,65 @@ type ControllerParameters struct {
 	ExecutionClient   geneclientset.ExecutionsGetter
 	JobInformer       batchinformers.JobInformer
 	ExecutionInformer geneinformers.ExecutionInformer
+	// CronExecutionClient and CronExecutionInformer are used by the
+	// CronExecutionController only.
+	CronExecutionClient   geneclientset.CronExecutionsGetter
+	CronExecutionInformer geneinformers.CronExecutionInformer
+	// WorkflowTemplateInformer is used to instantiate the executions from
+	// the workflow templates, optional.
+	WorkflowTemplateInformer geneinformers.WorkflowTemplateInformer
+	// ToolRepo is the directory or URL of the tools of the workflow templates.
+	// The tools are resolved against ToolInformer and ClusterToolInformer if
+	// it is empty.
+	ToolRepo            string
+	ToolInformer        geneinformers.ToolInformer
+	ClusterToolInformer geneinformers.ClusterToolInformer
+	// Notifier posts the lifecycle events of executions to the webhooks, optional.
+	Notifier *webhook.Notifier
+	// ResourceQuotaInformer is used to hold the jobs back until they fit the
+	// resource quotas of their namespace, optional.
+	ResourceQuotaInformer coreinformers.ResourceQuotaInformer
+	// FairShare dispatches the jobs of all the executions in fair-share order
+	// under a cluster concurrency limit, optional.
+	FairShare *FairShareConfig
+	// AllowHostPathVolumes allows the tasks to mount the directories of the
+	// nodes, the executions with hostPath volumes are rejected otherwise.
+	AllowHostPathVolumes bool
+	// RateLimiter configures the rate limiters of the queues, the default
+	// controller rate limiter is used if it is nil.
+	RateLimiter *RateLimiterConfig
+}
+
+// WorkerConfig holds the numbers of the workers of the queues of the
+// execution controller.
+type WorkerConfig struct {
+	// ExecutionWorkers sync the executions.
+	ExecutionWorkers int
+	// JobWorkers sync the status of the jobs into their executions.
+	JobWorkers int
+	// EventWorkers create the jobs whose dependents have finished.
+	EventWorkers int
+}
+
+// RateLimiterConfig configures the rate limiter of a queue. The items that
+// fail are retried with an exponential backoff from BaseDelay to MaxDelay,
+// and the items of the queue are retried at QPS with bursts of Burst.
+type RateLimiterConfig struct {
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	QPS       float64
+	Burst     int
+}
+
+// newRateLimiter returns a rate limiter of a queue configured by the config.
+func newRateLimiter(config *RateLimiterConfig) workqueue.RateLimiter {
+	if config == nil {
+		return workqueue.DefaultControllerRateLimiter()
+	}
+	return workqueue.NewMaxOfRateLimiter(
+		workqueue.NewItemExponentialFailureRateLimiter(config.BaseDelay, config.MaxDelay),
+		&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(config.QPS), config.Burst)},
+	)
 }
 
 type ExecutionController struct {
@@ -81,6 +147,17 @@ type ExecutionController struct {
 	execJobController *ExecutionJobController
 
 	execStatusUpdater ExecutionUpdater
+
+	notifier *webhook.Notifier
+
+	// allowHostPath allows the hostPath volumes of the tasks.
+	allowHostPath bool
+
+	// instantiator instantiates the executions from the workflow templates.
+	instantiator *TemplateInstantiator
+
+	// cacheSynced are the informers to wait for before syncing.
+	cacheSynced []cache.InformerSynced
 }
 
 func NewExecutionController(p *ControllerParameters) *ExecutionController {
@@ -92,14 +169,17 @@ func NewExecutionController(p *ControllerParameters) *ExecutionController {
 		execSynced:    p.ExecutionInformer.Informer().HasSynced,
 		jobLister:     p.JobInformer.Lister(),
 		jobSynced:     p.JobInformer.Informer().HasSynced,
-		execQueue:     workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "execution"),
-		jobQueue:      workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "execution-job"),
-		eventQueue:    workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "job-event"),
+		execQueue:     workqueue.NewNamedRateLimitingQueue(newRateLimiter(p.RateLimiter), "execution"),
+		jobQueue:      workqueue.NewNamedRateLimitingQueue(newRateLimiter(p.RateLimiter), "execution-job"),
+		eventQueue:    workqueue.NewNamedRateLimitingQueue(newRateLimiter(p.RateLimiter), "job-event"),
+		notifier:      p.Notifier,
+		allowHostPath: p.AllowHostPathVolumes,
 	}
 
 	p.ExecutionInformer.Informer().AddEventHandler(
 		cache.ResourceEventHandlerFuncs{
 			AddFunc:    func(obj interface{}) { controller.enqueueObj(controller.execQueue, obj) },
+			UpdateFunc: controller.updateExecution,
 			DeleteFunc: func(obj interface{}) { controller.enqueueObj(controller.execQueue, obj) },
 		},
 	)
@@ -116,14 +196,40 @@ func NewExecutionController(p *ControllerParameters) *ExecutionController {
 	controller.syncExecHandler = controller.syncExecution
 	controller.execGraphBuilder = NewGraphBuilder()
 	controller.execStatusUpdater = NewExecutionStatusUpdater(p.ExecutionClient)
+	if p.Notifier != nil {
+		controller.execStatusUpdater = NewNotifyingUpdater(controller.execStatusUpdater, p.Notifier)
+	}
+	controller.cacheSynced = []cache.InformerSynced{controller.execSynced, controller.jobSynced}
+	if p.WorkflowTemplateInformer != nil {
+		controller.instantiator = NewTemplateInstantiator(p.WorkflowTemplateInformer.Lister(), p.ToolRepo,
+			p.ToolInformer.Lister(), p.ClusterToolInformer.Lister())
+		controller.cacheSynced = append(controller.cacheSynced, p.WorkflowTemplateInformer.Informer().HasSynced,
+			p.ToolInformer.Informer().HasSynced, p.ClusterToolInformer.Informer().HasSynced)
+		p.WorkflowTemplateInformer.Informer().AddEventHandler(
+			cache.ResourceEventHandlerFuncs{
+				AddFunc:    controller.enqueueTemplateExecutions,
+				UpdateFunc: func(old, cur interface{}) { controller.enqueueTemplateExecutions(cur) },
+			},
+		)
+	}
+	var quotaLister corelisters.ResourceQuotaLister
+	if p.ResourceQuotaInformer != nil {
+		quotaLister = p.ResourceQuotaInformer.Lister()
+		controller.cacheSynced = append(controller.cacheSynced, p.ResourceQuotaInformer.Informer().HasSynced)
+	}
 	controller.execJobController = NewExecutionJobController(p.KubeClient, controller.jobLister, controller.execLister,
-		controller.eventQueue, controller.execGraphBuilder, controller.execStatusUpdater)
+		quotaLister, controller.eventQueue, controller.execGraphBuilder, controller.execStatusUpdater,
+		controller.eventRecorder)
+	if p.FairShare != nil && p.FairShare.MaxRunningJobs > 0 {
+		controller.execJobController.fairQueue = newFairQueue(*p.FairShare, controller.execLister)
+	}
 
 	return controller
 }
 
 // Run the main goroutine responsible for watching and syncing executions.
-func (c *ExecutionController) Run(workers int, stopCh <-chan struct{}) {
+// The jobs of different executions are synced concurrently by the workers.
+func (c *ExecutionController) Run(workers WorkerConfig, stopCh <-chan struct{}) {
 	defer utilruntime.HandleCrash()
 	defer c.execQueue.ShutDown()
 	defer c.jobQueue.ShutDown()
@@ -131,16 +237,18 @@ func (c *ExecutionController) Run(workers int, stopCh <-chan struct{}) {
 	glog.Infof("Starting execution controller with version %s", version.GetVersion())
 	defer glog.Infof("Shutting down execution controller")
 
-	if !cache.WaitForCacheSync(stopCh, c.execSynced, c.jobSynced) {
+	if !cache.WaitForCacheSync(stopCh, c.cacheSynced...) {
 		glog.Errorf("Cannot sync caches")
 		return
 	}
 
 	// start asynchronous go routine processing event queue.
-	go c.execJobController.Run(workers, stopCh)
+	go c.execJobController.Run(workers.EventWorkers, stopCh)
 
-	for i := 0; i < workers; i++ {
+	for i := 0; i < workers.ExecutionWorkers; i++ {
 		go wait.Until(c.execWorker, time.Second, stopCh)
+	}
+	for i := 0; i < workers.JobWorkers; i++ {
 		go wait.Until(c.jobWorker, time.Second, stopCh)
 	}
 
@@ -243,6 +351,10 @@ func (c *ExecutionController) syncJob(key string) (bool, error) {
 		return true, nil
 	}
 
+	// the jobs of an execution are synced one at a time.
+	c.execGraphBuilder.LockExecution(util.KeyOf(exec))
+	defer c.execGraphBuilder.UnlockExecution(util.KeyOf(exec))
+
 	graph := c.execGraphBuilder.GetGraph(util.KeyOf(exec))
 	if graph == nil {
 		// The execution has been running but the graph has been deleted.
@@ -259,9 +371,15 @@ func (c *ExecutionController) syncJob(key string) (bool, error) {
 		return true, nil
 	}
 
+	exitJob := dag.IsExitTask(exec, dag.JobTask(job))
+
 	// find the vertex in the graph.
-	vertex := graph.FindVertexByName(job.Name)
+	vertex := vertexOfJob(graph, job)
 	if vertex == nil {
+		// the exit handler is started again after the graph is rebuilt.
+		if exitJob && len(exec.Status.DAGPhase) != 0 {
+			return false, fmt.Errorf("exit handler of execution %s has not been started", util.KeyOf(exec))
+		}
 		util.MarkExecutionError(exec, fmt.Errorf(missVertexMessage))
 		// Ask api server to update etcd data.
 		// if update error, we will retry it next sync.
@@ -277,7 +395,7 @@ func (c *ExecutionController) syncJob(key string) (bool, error) {
 
 	// in case missing the running event, following status set will cause panic
 	if util.GetVertexStatus(exec, job.Name) == nil {
-		vertexStatus := util.InitializeVertexStatus(job.Name, genev1alpha1.VertexRunning, vertexRunningMessage, vertex.Children)
+		vertexStatus := jobVertexStatus(job, vertex)
 		if exec.Status.Vertices == nil {
 			exec.Status.Vertices = make(map[string]genev1alpha1.VertexStatus)
 		}
@@ -286,11 +404,43 @@ func (c *ExecutionController) syncJob(key string) (bool, error) {
 
 	switch jobConditionType {
 	case batch.JobFailed:
+		// A loop that runs until success just starts the next iteration,
+		// the failed iteration is not a failure of the execution. The jobs
+		// of the body of an iteration that has been retried may fail later.
+		if !exitJob && len(exec.Status.DAGPhase) == 0 && vertex.IsLoop() &&
+			(dag.JobAttempt(job) < vertex.GetLoopIteration() || shouldRetryLoop(vertex)) {
+			util.MarkVertexRetried(exec, job.Name, message)
+			if err = c.execStatusUpdater.UpdateExecutionStatus(exec, sharedExec); err != nil {
+				glog.V(3).Infof("update execution %s status error: %#v", key, err)
+				return false, err
+			}
+			if dag.JobAttempt(job) == vertex.GetLoopIteration() {
+				event := Event{Type: LoopNext, Name: vertex.Loop().Data.Job.Name, Attempt: dag.JobAttempt(job), Key: util.KeyOf(exec)}
+				c.eventQueue.Add(event)
+			}
+			return true, nil
+		}
+
 		// Job is failed, mark the vertex as failed.
 		util.MarkVertexFailed(exec, job.Name, message)
 
+		// A failed exit handler fails the execution once all of them have finished.
+		if exitJob {
+			graph.MarkFinished(vertex)
+			finishExitHandler(exec, graph)
+			if err = c.execStatusUpdater.UpdateExecutionStatus(exec, sharedExec); err != nil {
+				glog.V(3).Infof("update execution %s status error: %#v", key, err)
+				return false, err
+			}
+			return true, nil
+		}
+
 		// Job is failed, the execution is marked failed and will not retry.
-		util.MarkExecutionFailed(exec, message)
+		// The exit handlers wait for the jobs that are still running, the
+		// last of them to finish starts the exit handlers.
+		finishDAG(exec, genev1alpha1.VertexFailed, message)
+		startExit := len(exec.Status.DAGPhase) != 0
+		rollUpSubWorkflows(exec, graph)
 
 		// Ask api server to update etcd data.
 		if err = c.execStatusUpdater.UpdateExecutionStatus(exec, sharedExec); err != nil {
@@ -298,32 +448,73 @@ func (c *ExecutionController) syncJob(key string) (bool, error) {
 			return false, err
 		}
 
+		if startExit {
+			c.eventQueue.Add(Event{Type: ExitHandler, Key: util.KeyOf(exec)})
+		}
+
 		return true, nil
 
 	case batch.JobComplete:
-		// if vertex is dynamic just increment the succ count
-		// if success count == to dynamic job count then made the
-		// vertex finished flag tru so that then only other depend jobs can start
-		if vertex.IsDynamic() {
-			vertex.IncDynamicJobSuccCnt()
-			if vertex.GetDynamicJobCnt() == vertex.GetDynamicSuccJobCnt() {
-				// the vertex has been finished.
-				vertex.Data.Finished = true
+		if exitJob {
+			graph.MarkFinished(vertex)
+			util.MarkVertexSuccess(exec, job.Name, "success")
+			finishExitHandler(exec, graph)
+			if err = c.execStatusUpdater.UpdateExecutionStatus(exec, sharedExec); err != nil {
+				glog.V(3).Infof("update execution %s status error: %#v", key, err)
+				return false, err
 			}
-		} else {
-			// the vertex has been finished.
-			vertex.Data.Finished = true
+			return true, nil
 		}
-		// Mark the vertex as success.
+
 		if len(message) == 0 {
 			message = "success"
 		}
+
+		// the jobs of a dynamic vertex are vertices of their own once expanded,
+		// only the vertices of a loop are shared by the jobs of all its iterations.
+		if vertex.IsLoop() {
+			// the iteration has been handled, or it has been retried and
+			// the job of its body has succeeded after that.
+			if vertex.IsFinished() || dag.JobAttempt(job) != vertex.GetLoopIteration() {
+				if status := util.GetVertexStatus(exec, job.Name); status.Phase == genev1alpha1.VertexSucceeded {
+					return true, nil
+				}
+				util.MarkVertexSuccess(exec, job.Name, message)
+				if err = c.execStatusUpdater.UpdateExecutionStatus(exec, sharedExec); err != nil {
+					glog.V(3).Infof("update execution %s status error: %#v", key, err)
+					return false, err
+				}
+				return true, nil
+			}
+			vertex.SetLoopSucceeded(dag.JobAttempt(job))
+			// the loop finishes with the job of its loop task, the other
+			// vertices of the body start the vertices that depend on them.
+			if vertex.Loop() == vertex {
+				finished, err := c.execJobController.isLoopFinished(vertex, job)
+				if err != nil {
+					return false, err
+				}
+				if finished {
+					finishLoop(graph, vertex)
+				}
+			}
+		} else {
+			// the vertex has been finished.
+			graph.MarkFinished(vertex)
+			// The number of successful vertex plus 1.
+			graph.PlusNumOfSuccess()
+		}
+		// Mark the vertex as success.
 		util.MarkVertexSuccess(exec, job.Name, message)
-		// The number of successful vertex plus 1.
-		graph.PlusNumOfSuccess()
-		if graph.GetNumOfSuccess() == (graph.VertexCount + graph.DynamicJobCnt) {
+		rollUpSubWorkflows(exec, graph)
+		dagFinished := len(exec.Status.DAGPhase) != 0
+		// a job that finishes after the DAG has failed may be the last one
+		// the exit handlers wait for.
+		startExit := dagFinished
+		if !dagFinished && graph.AllSucceeded() {
 			// All of the vertex has been successful, then mark the execution as successful.
-			util.MarkExecutionSuccess(exec, executionSuccessMessage)
+			startExit = finishDAG(exec, genev1alpha1.VertexSucceeded, executionSuccessMessage)
+			dagFinished = true
 		}
 
 		// Ask api server to update etcd data.
@@ -332,22 +523,19 @@ func (c *ExecutionController) syncJob(key string) (bool, error) {
 			return false, err
 		}
 
-		if graph.GetNumOfSuccess() != (graph.VertexCount + graph.DynamicJobCnt) {
-			// if vertex is dynamic we can add JobAfterEvent once completing all the
-			// k8s jobs related to the dynamic job
-			if vertex.IsDynamic() {
-				glog.V(3).Infof("dyanmic job vertex.GetDynamicJobCnt(): %v", vertex.GetDynamicJobCnt())
-				glog.V(3).Infof("dyanmic job vertex.GetDynamicSuccJobCnt(): %v", vertex.GetDynamicSuccJobCnt())
-
-				if vertex.GetDynamicJobCnt() == vertex.GetDynamicSuccJobCnt() {
-					// add execution to event queue to trigger running.
-					event := Event{Type: JobsAfter, Name: vertex.Data.Job.Name, Key: util.KeyOf(exec)}
-					c.eventQueue.Add(event)
-				}
+		if startExit {
+			c.eventQueue.Add(Event{Type: ExitHandler, Key: util.KeyOf(exec)})
+		}
 
+		// no more jobs are started after the DAG has finished.
+		if !dagFinished {
+			// a loop starts its next iteration until it has finished.
+			if vertex.Loop() == vertex && !vertex.IsFinished() {
+				event := Event{Type: LoopNext, Name: vertex.Data.Job.Name, Attempt: dag.JobAttempt(job), Key: util.KeyOf(exec)}
+				c.eventQueue.Add(event)
 			} else {
 				// add execution to event queue to trigger running.
-				event := Event{Type: JobsAfter, Name: job.Name, Key: util.KeyOf(exec)}
+				event := Event{Type: JobsAfter, Name: vertex.Data.Job.Name, Key: util.KeyOf(exec)}
 				c.eventQueue.Add(event)
 			}
 		}
@@ -359,12 +547,13 @@ func (c *ExecutionController) syncJob(key string) (bool, error) {
 
 		// usually a add event can approach here and mark the vertex as running.
 		if util.GetVertexStatus(exec, job.Name) == nil {
-			vertexStatus := util.InitializeVertexStatus(job.Name, genev1alpha1.VertexRunning, vertexRunningMessage, vertex.Children)
+			vertexStatus := jobVertexStatus(job, vertex)
 			if exec.Status.Vertices == nil {
 				exec.Status.Vertices = make(map[string]genev1alpha1.VertexStatus)
 			}
 			exec.Status.Vertices[vertexStatus.ID] = vertexStatus
 		}
+		rollUpSubWorkflows(exec, graph)
 		// If the phase of execution is unset, and we have watch job that belongs to
 		// this execution, then mark the phase of execution running.
 		if len(exec.Status.Phase) == 0 {
@@ -380,6 +569,29 @@ func (c *ExecutionController) syncJob(key string) (bool, error) {
 	}
 }
 
+// vertexOfJob returns the vertex of the job in the graph, the jobs of a loop
+// share the vertex of their task.
+func vertexOfJob(g *graph.Graph, job *batch.Job) *graph.Vertex {
+	if vertex := g.FindVertexByName(job.Name); vertex != nil {
+		return vertex
+	}
+	vertex := g.FindVertexByName(dag.DynamicVertexName(dag.JobExecution(job), dag.JobTask(job)))
+	if vertex == nil || !vertex.IsLoop() {
+		return nil
+	}
+	return vertex
+}
+
+// jobVertexStatus returns the status of a running job vertex, with the task,
+// index and attempt of the job from its labels.
+func jobVertexStatus(job *batch.Job, vertex *graph.Vertex) genev1alpha1.VertexStatus {
+	status := util.InitializeVertexStatus(job.Name, genev1alpha1.VertexRunning, vertexRunningMessage, vertex.GetChildren())
+	status.Task = dag.JobTask(job)
+	status.Index = int32(dag.JobIndex(job))
+	status.Attempt = int32(dag.JobAttempt(job))
+	return status
+}
+
 // syncExecution will sync the execution with the given key.
 // This function is not meant to be invoked concurrently with the same key.
 func (c *ExecutionController) syncExecution(key string) error {
@@ -394,10 +606,16 @@ func (c *ExecutionController) syncExecution(key string) error {
 		return err
 	}
 
+	c.execGraphBuilder.LockExecution(key)
+	defer c.execGraphBuilder.UnlockExecution(key)
+
 	execution, err := c.execLister.Executions(namespace).Get(name)
 	if errors.IsNotFound(err) {
 		glog.V(2).Infof("execution %v has been deleted", key)
 		c.execGraphBuilder.DeleteGraph(key)
+		if c.notifier != nil {
+			c.notifier.Forget(key)
+		}
 		return nil
 	}
 	if err != nil {
@@ -407,7 +625,15 @@ func (c *ExecutionController) syncExecution(key string) error {
 	// Deep-copy otherwise we are mutating our cache.
 	exec := execution.DeepCopy()
 
-	if err := ValidateExecution(exec); err != nil {
+	if needInstantiation(exec) {
+		return c.instantiateExecution(exec, execution)
+	}
+
+	err = ValidateExecution(exec)
+	if err == nil && !c.allowHostPath {
+		err = validateNoHostPath(exec)
+	}
+	if err != nil {
 		util.MarkExecutionError(exec, err)
 		c.execStatusUpdater.UpdateExecutionStatus(exec, execution)
 		return err
@@ -416,7 +642,11 @@ func (c *ExecutionController) syncExecution(key string) error {
 	graph := c.execGraphBuilder.GetGraph(key)
 	if graph == nil {
 		glog.V(2).Infof("generate graph for execution %v", key)
-		c.execGraphBuilder.AddGraph(exec)
+		if err := c.execGraphBuilder.AddGraph(exec); err != nil {
+			util.MarkExecutionError(exec, err)
+			c.execStatusUpdater.UpdateExecutionStatus(exec, execution)
+			return err
+		}
 	}
 
 	// add execution to event queue to trigger running
@@ -426,6 +656,66 @@ func (c *ExecutionController) syncExecution(key string) error {
 	return nil
 }
 
+// updateExecution syncs the execution once its tasks have been instantiated
+// from the workflow template.
+func (c *ExecutionController) updateExecution(old, cur interface{}) {
+	oldExec := old.(*genev1alpha1.Execution)
+	curExec := cur.(*genev1alpha1.Execution)
+	if needInstantiation(oldExec) && !needInstantiation(curExec) {
+		c.enqueueObj(c.execQueue, curExec)
+	}
+}
+
+// instantiateExecution fills in the tasks of the execution from its workflow
+// template and updates it. The execution is synced again once the update has
+// been observed. An execution whose workflow template does not exist yet
+// waits for it and is synced again by enqueueTemplateExecutions.
+func (c *ExecutionController) instantiateExecution(exec, original *genev1alpha1.Execution) error {
+	if util.IsExecutionCompleted(exec) {
+		return nil
+	}
+	key := util.KeyOf(exec)
+	if c.instantiator == nil {
+		util.MarkExecutionError(exec, fmt.Errorf("workflow templates are not supported"))
+		c.execStatusUpdater.UpdateExecutionStatus(exec, original)
+		return nil
+	}
+
+	err := c.instantiator.Instantiate(exec)
+	if errors.IsNotFound(err) {
+		glog.V(2).Infof("execution %s waits for workflow template: %v", key, err)
+		return nil
+	}
+	if err != nil {
+		glog.Errorf("instantiate execution %s error: %v", key, err)
+		util.MarkExecutionError(exec, err)
+		c.execStatusUpdater.UpdateExecutionStatus(exec, original)
+		return nil
+	}
+
+	glog.V(2).Infof("instantiated %d tasks of execution %s from workflow template %s", len(exec.Spec.Tasks), key, exec.Spec.WorkflowTemplateRef.Name)
+	if _, err := c.execClient.Executions(exec.Namespace).Update(exec); err != nil {
+		return fmt.Errorf("update execution %s error: %v", key, err)
+	}
+	return nil
+}
+
+// enqueueTemplateExecutions enqueues the executions that wait to be
+// instantiated from the workflow template.
+func (c *ExecutionController) enqueueTemplateExecutions(obj interface{}) {
+	template := obj.(*genev1alpha1.WorkflowTemplate)
+	executions, err := c.execLister.Executions(template.Namespace).List(labels.Everything())
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("list executions of workflow template %s error: %v", util.KeyOf(template), err))
+		return
+	}
+	for _, exec := range executions {
+		if needInstantiation(exec) && exec.Spec.WorkflowTemplateRef.Name == template.Name {
+			c.enqueueObj(c.execQueue, exec)
+		}
+	}
+}
+
 // enqueueObj adds execution or job to given work queue.
 func (c *ExecutionController) enqueueObj(queue workqueue.Interface, obj interface{}) {
 	// Beware of "xxx deleted" events
@@ -441,6 +731,9 @@ func (c *ExecutionController) enqueueObj(queue workqueue.Interface, obj interfac
 
 func (c *ExecutionController) addJob(obj interface{}) {
 	job := obj.(*batch.Job)
+	if fairQueue := c.execJobController.fairQueue; fairQueue != nil {
+		fairQueue.observeJob(job)
+	}
 	if job.DeletionTimestamp != nil {
 		// on a restart of the controller controller, it's possible a new pod shows up in a state that
 		// is already pending deletion. Prevent the pod from being a creation observation.
@@ -480,6 +773,9 @@ func (c *ExecutionController) deleteJob(obj interface{}) {
 			return
 		}
 	}
+	if fairQueue := c.execJobController.fairQueue; fairQueue != nil {
+		fairQueue.forgetJob(job)
+	}
 
 	controllerRef := metav1.GetControllerOf(job)
 	if controllerRef == nil {
@@ -502,6 +798,9 @@ func (c *ExecutionController) updateJob(old, cur interface{}) {
 		// Two different versions of the same job will always have different RVs.
 		return
 	}
+	if fairQueue := c.execJobController.fairQueue; fairQueue != nil {
+		fairQueue.observeJob(curJob)
+	}
 	if curJob.DeletionTimestamp != nil {
 		c.deleteJob(curJob)
 		return
@@ -0,0 +1,464 @@
+/*
+Copyright 2019 The Kubegene Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/golang/glog"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+
+	genev1alpha1 "kubegene.io/kubegene/pkg/apis/gene/v1alpha1"
+	geneclientset "kubegene.io/kubegene/pkg/client/clientset/versioned/typed/gene/v1alpha1"
+	genelisters "kubegene.io/kubegene/pkg/client/listers/gene/v1alpha1"
+	"kubegene.io/kubegene/pkg/cron"
+	"kubegene.io/kubegene/pkg/util"
+)
+
+var cronExecKind = genev1alpha1.SchemeGroupVersion.WithKind("CronExecution")
+
+const (
+	// CronExecutionLabel is the label of an execution with the name of the
+	// cron execution that created it.
+	CronExecutionLabel = "kubegene.io/cron-execution"
+	// ScheduledTimeAnnotation is the annotation of an execution with the
+	// time it was scheduled at.
+	ScheduledTimeAnnotation = "kubegene.io/scheduled-time"
+
+	// the max length of a cron execution name, so that the names of the
+	// executions created from it are valid.
+	maxCronExecutionNameLength = 52
+
+	defaultSuccessfulExecutionsHistoryLimit = 3
+	defaultFailedExecutionsHistoryLimit     = 1
+
+	// maxMissedScheduleTimes is the max number of the missed schedule times
+	// to walk through, like the cron job controller of kubernetes.
+	maxMissedScheduleTimes = 100
+
+	// nextScheduleDelay is added to the time until the next schedule time,
+	// so that the cron execution is not synced right before it.
+	nextScheduleDelay = 100 * time.Millisecond
+)
+
+// CronExecutionController creates executions from cron executions on their
+// schedules.
+type CronExecutionController struct {
+	eventRecorder record.EventRecorder
+
+	execClient geneclientset.ExecutionsGetter
+	cronClient geneclientset.CronExecutionsGetter
+
+	execLister genelisters.ExecutionLister
+	execSynced cache.InformerSynced
+	cronLister genelisters.CronExecutionLister
+	cronSynced cache.InformerSynced
+
+	// queue holds the keys of the cron executions to sync, a cron execution
+	// is added back at its next schedule time.
+	queue workqueue.RateLimitingInterface
+
+	// now returns the current time, it is replaced in tests.
+	now func() time.Time
+}
+
+func NewCronExecutionController(p *ControllerParameters) *CronExecutionController {
+	controller := &CronExecutionController{
+		eventRecorder: p.EventRecorder,
+		execClient:    p.ExecutionClient,
+		cronClient:    p.CronExecutionClient,
+		execLister:    p.ExecutionInformer.Lister(),
+		execSynced:    p.ExecutionInformer.Informer().HasSynced,
+		cronLister:    p.CronExecutionInformer.Lister(),
+		cronSynced:    p.CronExecutionInformer.Informer().HasSynced,
+		queue:         workqueue.NewNamedRateLimitingQueue(newRateLimiter(p.RateLimiter), "cron-execution"),
+		now:           time.Now,
+	}
+
+	p.CronExecutionInformer.Informer().AddEventHandler(
+		cache.ResourceEventHandlerFuncs{
+			AddFunc:    controller.enqueueCronExecution,
+			UpdateFunc: func(old, cur interface{}) { controller.enqueueCronExecution(cur) },
+			DeleteFunc: controller.enqueueCronExecution,
+		},
+	)
+
+	// the active executions are refreshed once they finish.
+	p.ExecutionInformer.Informer().AddEventHandler(
+		cache.ResourceEventHandlerFuncs{
+			AddFunc:    controller.enqueueOwner,
+			UpdateFunc: func(old, cur interface{}) { controller.enqueueOwner(cur) },
+			DeleteFunc: controller.enqueueOwner,
+		},
+	)
+
+	return controller
+}
+
+// Run syncs the cron executions when they or their executions change, and
+// at their schedule times.
+func (c *CronExecutionController) Run(stopCh <-chan struct{}) {
+	defer utilruntime.HandleCrash()
+	defer c.queue.ShutDown()
+
+	glog.Infof("Starting cron execution controller")
+	defer glog.Infof("Shutting down cron execution controller")
+
+	if !cache.WaitForCacheSync(stopCh, c.execSynced, c.cronSynced) {
+		glog.Errorf("Cannot sync caches")
+		return
+	}
+
+	go wait.Until(c.worker, time.Second, stopCh)
+
+	<-stopCh
+}
+
+func (c *CronExecutionController) worker() {
+	for c.processNextItem() {
+	}
+}
+
+func (c *CronExecutionController) processNextItem() bool {
+	key, quit := c.queue.Get()
+	if quit {
+		return false
+	}
+	defer c.queue.Done(key)
+
+	if err := c.sync(key.(string)); err != nil {
+		utilruntime.HandleError(fmt.Errorf("error syncing cron execution %v: %v", key, err))
+		c.queue.AddRateLimited(key)
+		return true
+	}
+	c.queue.Forget(key)
+	return true
+}
+
+func (c *CronExecutionController) enqueueCronExecution(obj interface{}) {
+	if unknown, ok := obj.(cache.DeletedFinalStateUnknown); ok && unknown.Obj != nil {
+		obj = unknown.Obj
+	}
+	c.queue.Add(util.KeyOf(obj))
+}
+
+// enqueueOwner enqueues the cron execution that controls the execution.
+func (c *CronExecutionController) enqueueOwner(obj interface{}) {
+	if unknown, ok := obj.(cache.DeletedFinalStateUnknown); ok && unknown.Obj != nil {
+		obj = unknown.Obj
+	}
+	execution, ok := obj.(*genev1alpha1.Execution)
+	if !ok {
+		return
+	}
+	ref := metav1.GetControllerOf(execution)
+	if ref == nil || ref.Kind != cronExecKind.Kind || ref.APIVersion != cronExecKind.GroupVersion().String() {
+		return
+	}
+	c.queue.Add(execution.Namespace + "/" + ref.Name)
+}
+
+// sync syncs the cron execution with the key, and adds it back to the queue
+// at its next schedule time.
+func (c *CronExecutionController) sync(key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+	cronExec, err := c.cronLister.CronExecutions(namespace).Get(name)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := c.syncCronExecution(cronExec.DeepCopy()); err != nil {
+		return err
+	}
+	if next, ok := nextScheduleTime(cronExec, c.now()); ok {
+		c.queue.AddAfter(key, next.Sub(c.now())+nextScheduleDelay)
+	}
+	return nil
+}
+
+// syncCronExecution refreshes the active executions of the cron execution,
+// removes the finished executions beyond the history limits, and creates
+// an execution if a scheduled time has come.
+func (c *CronExecutionController) syncCronExecution(cronExec *genev1alpha1.CronExecution) error {
+	if cronExec.DeletionTimestamp != nil {
+		return nil
+	}
+	key := util.KeyOf(cronExec)
+	now := c.now()
+
+	executions, err := c.execLister.Executions(cronExec.Namespace).List(labels.Everything())
+	if err != nil {
+		return err
+	}
+	active := make([]*genev1alpha1.Execution, 0)
+	finished := make([]*genev1alpha1.Execution, 0)
+	for _, execution := range executions {
+		if !metav1.IsControlledBy(execution, cronExec) || execution.DeletionTimestamp != nil {
+			continue
+		}
+		if util.IsExecutionCompleted(execution) {
+			finished = append(finished, execution)
+		} else {
+			active = append(active, execution)
+		}
+	}
+
+	oldStatus := cronExec.Status.DeepCopy()
+	cronExec.Status.Active = executionNames(active)
+	defer func() {
+		if reflect.DeepEqual(oldStatus, &cronExec.Status) {
+			return
+		}
+		if _, err := c.cronClient.CronExecutions(cronExec.Namespace).UpdateStatus(cronExec); err != nil {
+			glog.Errorf("Update status of cron execution %s error: %v", key, err)
+		}
+	}()
+
+	c.cleanupFinishedExecutions(cronExec, finished)
+
+	if err := ValidateCronExecution(cronExec); err != nil {
+		c.eventRecorder.Eventf(cronExec, v1.EventTypeWarning, "InvalidCronExecution", "%v", err)
+		return nil
+	}
+	if cronExec.Spec.Suspend != nil && *cronExec.Spec.Suspend {
+		glog.V(4).Infof("Cron execution %s is suspended", key)
+		return nil
+	}
+
+	schedule, _ := cron.Parse(cronExec.Spec.Schedule)
+	scheduledTime, err := mostRecentScheduleTime(cronExec, schedule, now)
+	if err != nil {
+		c.eventRecorder.Eventf(cronExec, v1.EventTypeWarning, "TooManyMissedTimes", "%v", err)
+		return nil
+	}
+	if scheduledTime.IsZero() {
+		return nil
+	}
+
+	if len(active) != 0 {
+		switch cronExec.Spec.ConcurrencyPolicy {
+		case genev1alpha1.ForbidConcurrent:
+			glog.V(2).Infof("Cron execution %s skips the run at %s, because %d executions are running", key, scheduledTime, len(active))
+			return nil
+		case genev1alpha1.ReplaceConcurrent:
+			for _, execution := range active {
+				err := c.execClient.Executions(execution.Namespace).Delete(execution.Name, &metav1.DeleteOptions{})
+				if err != nil && !errors.IsNotFound(err) {
+					c.eventRecorder.Eventf(cronExec, v1.EventTypeWarning, "FailedDelete", "Deleted execution %s error: %v", execution.Name, err)
+					return err
+				}
+				c.eventRecorder.Eventf(cronExec, v1.EventTypeNormal, "SuccessfulDelete", "Deleted execution %s", execution.Name)
+			}
+			cronExec.Status.Active = nil
+		}
+	}
+
+	execution := newExecutionFromTemplate(cronExec, scheduledTime)
+	_, err = c.execClient.Executions(execution.Namespace).Create(execution)
+	if err != nil && !errors.IsAlreadyExists(err) {
+		c.eventRecorder.Eventf(cronExec, v1.EventTypeWarning, "FailedCreate", "Created execution error: %v", err)
+		return err
+	}
+	if err == nil {
+		c.eventRecorder.Eventf(cronExec, v1.EventTypeNormal, "SuccessfulCreate", "Created execution %s", execution.Name)
+	}
+
+	cronExec.Status.Active = append(cronExec.Status.Active, execution.Name)
+	cronExec.Status.LastScheduleTime = &metav1.Time{Time: scheduledTime}
+	return nil
+}
+
+// cleanupFinishedExecutions deletes the oldest succeeded and failed
+// executions beyond the history limits.
+func (c *CronExecutionController) cleanupFinishedExecutions(cronExec *genev1alpha1.CronExecution, finished []*genev1alpha1.Execution) {
+	succeeded := make([]*genev1alpha1.Execution, 0)
+	failed := make([]*genev1alpha1.Execution, 0)
+	for _, execution := range finished {
+		if execution.Status.Phase == genev1alpha1.VertexSucceeded {
+			succeeded = append(succeeded, execution)
+		} else {
+			failed = append(failed, execution)
+		}
+	}
+
+	successfulLimit := int32(defaultSuccessfulExecutionsHistoryLimit)
+	if cronExec.Spec.SuccessfulExecutionsHistoryLimit != nil {
+		successfulLimit = *cronExec.Spec.SuccessfulExecutionsHistoryLimit
+	}
+	failedLimit := int32(defaultFailedExecutionsHistoryLimit)
+	if cronExec.Spec.FailedExecutionsHistoryLimit != nil {
+		failedLimit = *cronExec.Spec.FailedExecutionsHistoryLimit
+	}
+
+	for _, execution := range oldExecutions(succeeded, successfulLimit) {
+		c.deleteFinishedExecution(cronExec, execution)
+	}
+	for _, execution := range oldExecutions(failed, failedLimit) {
+		c.deleteFinishedExecution(cronExec, execution)
+	}
+}
+
+func (c *CronExecutionController) deleteFinishedExecution(cronExec *genev1alpha1.CronExecution, execution *genev1alpha1.Execution) {
+	err := c.execClient.Executions(execution.Namespace).Delete(execution.Name, &metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		glog.Errorf("Delete execution %s of cron execution %s error: %v", execution.Name, util.KeyOf(cronExec), err)
+		return
+	}
+	glog.V(2).Infof("Deleted finished execution %s of cron execution %s", execution.Name, util.KeyOf(cronExec))
+}
+
+// oldExecutions returns the executions beyond the limit, the oldest first.
+func oldExecutions(executions []*genev1alpha1.Execution, limit int32) []*genev1alpha1.Execution {
+	if limit < 0 || int32(len(executions)) <= limit {
+		return nil
+	}
+	sort.Slice(executions, func(i, j int) bool {
+		if !executions[i].Status.FinishedAt.Equal(&executions[j].Status.FinishedAt) {
+			return executions[i].Status.FinishedAt.Before(&executions[j].Status.FinishedAt)
+		}
+		return executions[i].Name < executions[j].Name
+	})
+	return executions[:int32(len(executions))-limit]
+}
+
+// mostRecentScheduleTime returns the latest scheduled time that has come
+// since the last schedule time. The times before the starting deadline are
+// skipped. It gives up if more than maxMissedScheduleTimes have been missed.
+func mostRecentScheduleTime(cronExec *genev1alpha1.CronExecution, schedule *cron.Schedule, now time.Time) (time.Time, error) {
+	earliest := cronExec.CreationTimestamp.Time
+	if cronExec.Status.LastScheduleTime != nil {
+		earliest = cronExec.Status.LastScheduleTime.Time
+	}
+	if cronExec.Spec.StartingDeadlineSeconds != nil {
+		deadline := now.Add(-time.Duration(*cronExec.Spec.StartingDeadlineSeconds) * time.Second)
+		if deadline.After(earliest) {
+			earliest = deadline
+		}
+	}
+
+	var latest time.Time
+	count := 0
+	for t := schedule.Next(earliest); !t.IsZero() && !t.After(now); t = schedule.Next(t) {
+		latest = t
+		count++
+		if count > maxMissedScheduleTimes {
+			return time.Time{}, fmt.Errorf("too many missed start times (> %d), set or decrease startingDeadlineSeconds or check clock skew", maxMissedScheduleTimes)
+		}
+	}
+	return latest, nil
+}
+
+// nextScheduleTime returns the next schedule time of the cron execution
+// after now, or false if it is not going to be scheduled.
+func nextScheduleTime(cronExec *genev1alpha1.CronExecution, now time.Time) (time.Time, bool) {
+	if cronExec.DeletionTimestamp != nil || (cronExec.Spec.Suspend != nil && *cronExec.Spec.Suspend) {
+		return time.Time{}, false
+	}
+	schedule, err := cron.Parse(cronExec.Spec.Schedule)
+	if err != nil {
+		return time.Time{}, false
+	}
+	next := schedule.Next(now)
+	return next, !next.IsZero()
+}
+
+// newExecutionFromTemplate returns the execution of the cron execution
+// scheduled at the time. Its name is decided by the scheduled time, so that
+// an execution is never created twice for the same time.
+func newExecutionFromTemplate(cronExec *genev1alpha1.CronExecution, scheduledTime time.Time) *genev1alpha1.Execution {
+	template := cronExec.Spec.ExecutionTemplate.DeepCopy()
+
+	labels := template.Labels
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	labels[CronExecutionLabel] = cronExec.Name
+
+	annotations := template.Annotations
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[ScheduledTimeAnnotation] = scheduledTime.UTC().Format(time.RFC3339)
+
+	return &genev1alpha1.Execution{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            cronExec.Name + "-" + strconv.FormatInt(scheduledTime.Unix()/60, 10),
+			Namespace:       cronExec.Namespace,
+			Labels:          labels,
+			Annotations:     annotations,
+			OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(cronExec, cronExecKind)},
+		},
+		Spec: template.Spec,
+	}
+}
+
+func executionNames(executions []*genev1alpha1.Execution) []string {
+	if len(executions) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(executions))
+	for _, execution := range executions {
+		names = append(names, execution.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ValidateCronExecution validates the schedule and the policies of the
+// cron execution.
+func ValidateCronExecution(cronExec *genev1alpha1.CronExecution) error {
+	if msgs := validation.IsDNS1123Label(cronExec.Name); len(msgs) > 0 {
+		return fmt.Errorf("name is not valid %v", msgs)
+	}
+	if len(cronExec.Name) > maxCronExecutionNameLength {
+		return fmt.Errorf("name must be no more than %d characters", maxCronExecutionNameLength)
+	}
+	if _, err := cron.Parse(cronExec.Spec.Schedule); err != nil {
+		return fmt.Errorf("schedule %q is not valid: %v", cronExec.Spec.Schedule, err)
+	}
+	switch cronExec.Spec.ConcurrencyPolicy {
+	case "", genev1alpha1.AllowConcurrent, genev1alpha1.ForbidConcurrent, genev1alpha1.ReplaceConcurrent:
+	default:
+		return fmt.Errorf("concurrency policy %s is not supported", cronExec.Spec.ConcurrencyPolicy)
+	}
+	if cronExec.Spec.StartingDeadlineSeconds != nil && *cronExec.Spec.StartingDeadlineSeconds < 0 {
+		return fmt.Errorf("startingDeadlineSeconds must be greater than or equal to 0")
+	}
+	template := cronExec.Spec.ExecutionTemplate.Spec
+	if len(template.Tasks) == 0 && template.WorkflowTemplateRef == nil {
+		return fmt.Errorf("tasks or workflowTemplateRef of execution template must be specified")
+	}
+	return nil
+}
@@ -0,0 +1,357 @@
+/*
+Copyright 2019 The Kubegene Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+
+	genev1alpha1 "kubegene.io/kubegene/pkg/apis/gene/v1alpha1"
+	"kubegene.io/kubegene/pkg/client/clientset/versioned/fake"
+	genelisters "kubegene.io/kubegene/pkg/client/listers/gene/v1alpha1"
+	"kubegene.io/kubegene/pkg/cron"
+)
+
+func mustParseTime(value string) time.Time {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func newCronExecution(policy genev1alpha1.ConcurrencyPolicy) *genev1alpha1.CronExecution {
+	return &genev1alpha1.CronExecution{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "nightly",
+			Namespace:         "default",
+			UID:               "cron-uid",
+			CreationTimestamp: metav1.Time{Time: mustParseTime("2019-03-01T00:00:00Z")},
+		},
+		Spec: genev1alpha1.CronExecutionSpec{
+			Schedule:          "0 2 * * *",
+			ConcurrencyPolicy: policy,
+			ExecutionTemplate: genev1alpha1.ExecutionTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "gene"}},
+				Spec: genev1alpha1.ExecutionSpec{
+					Tasks: []genev1alpha1.Task{{Name: "align", Image: "bwa", CommandSet: []string{"align"}}},
+				},
+			},
+		},
+	}
+}
+
+func newCronChild(cronExec *genev1alpha1.CronExecution, name string, phase genev1alpha1.VertexPhase, finishedAt string) *genev1alpha1.Execution {
+	exec := &genev1alpha1.Execution{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       cronExec.Namespace,
+			OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(cronExec, cronExecKind)},
+		},
+		Status: genev1alpha1.ExecutionStatus{Phase: phase},
+	}
+	if len(finishedAt) != 0 {
+		exec.Status.FinishedAt = metav1.Time{Time: mustParseTime(finishedAt)}
+	}
+	return exec
+}
+
+func newTestCronController(cronExec *genev1alpha1.CronExecution, executions []*genev1alpha1.Execution, now string) (*CronExecutionController, *fake.Clientset) {
+	objects := []runtime.Object{cronExec}
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, execution := range executions {
+		indexer.Add(execution)
+		objects = append(objects, execution)
+	}
+	client := fake.NewSimpleClientset(objects...)
+	controller := &CronExecutionController{
+		eventRecorder: record.NewFakeRecorder(10),
+		execClient:    client.ExecutionV1alpha1(),
+		cronClient:    client.ExecutionV1alpha1(),
+		execLister:    genelisters.NewExecutionLister(indexer),
+		now:           func() time.Time { return mustParseTime(now) },
+	}
+	return controller, client
+}
+
+func actionsOf(client *fake.Clientset) []string {
+	actions := make([]string, 0)
+	for _, action := range client.Actions() {
+		actions = append(actions, action.GetVerb()+"/"+action.GetResource().Resource)
+	}
+	return actions
+}
+
+func TestSyncCronExecution(t *testing.T) {
+	testCases := []struct {
+		name          string
+		policy        genev1alpha1.ConcurrencyPolicy
+		running       bool
+		now           string
+		expectActions []string
+		expectActive  []string
+	}{
+		{
+			name:          "not scheduled",
+			now:           "2019-03-01T01:00:00Z",
+			expectActions: []string{},
+		},
+		{
+			name:          "scheduled",
+			now:           "2019-03-01T02:00:05Z",
+			expectActions: []string{"create/executions", "update/cronexecutions"},
+			expectActive:  []string{"nightly-25856760"},
+		},
+		{
+			name:          "allow concurrent",
+			policy:        genev1alpha1.AllowConcurrent,
+			running:       true,
+			now:           "2019-03-01T02:00:05Z",
+			expectActions: []string{"create/executions", "update/cronexecutions"},
+			expectActive:  []string{"nightly-1", "nightly-25856760"},
+		},
+		{
+			name:          "forbid concurrent",
+			policy:        genev1alpha1.ForbidConcurrent,
+			running:       true,
+			now:           "2019-03-01T02:00:05Z",
+			expectActions: []string{},
+			expectActive:  []string{"nightly-1"},
+		},
+		{
+			name:          "replace concurrent",
+			policy:        genev1alpha1.ReplaceConcurrent,
+			running:       true,
+			now:           "2019-03-01T02:00:05Z",
+			expectActions: []string{"delete/executions", "create/executions", "update/cronexecutions"},
+			expectActive:  []string{"nightly-25856760"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		cronExec := newCronExecution(testCase.policy)
+		executions := make([]*genev1alpha1.Execution, 0)
+		if testCase.running {
+			executions = append(executions, newCronChild(cronExec, "nightly-1", genev1alpha1.VertexRunning, ""))
+			cronExec.Status.Active = []string{"nightly-1"}
+		}
+		controller, client := newTestCronController(cronExec, executions, testCase.now)
+
+		if err := controller.syncCronExecution(cronExec.DeepCopy()); err != nil {
+			t.Errorf("%s: unexpected error: %v", testCase.name, err)
+			continue
+		}
+		if actions := actionsOf(client); !reflect.DeepEqual(actions, testCase.expectActions) {
+			t.Errorf("%s: expected actions %v, got %v", testCase.name, testCase.expectActions, actions)
+		}
+		if len(testCase.expectActive) == 0 {
+			continue
+		}
+		updated, err := client.ExecutionV1alpha1().CronExecutions("default").Get("nightly", metav1.GetOptions{})
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", testCase.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(updated.Status.Active, testCase.expectActive) {
+			t.Errorf("%s: expected active %v, got %v", testCase.name, testCase.expectActive, updated.Status.Active)
+		}
+	}
+}
+
+func TestSyncCronExecutionCreatesFromTemplate(t *testing.T) {
+	cronExec := newCronExecution(genev1alpha1.AllowConcurrent)
+	controller, client := newTestCronController(cronExec, nil, "2019-03-01T02:00:05Z")
+	if err := controller.syncCronExecution(cronExec.DeepCopy()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	exec, err := client.ExecutionV1alpha1().Executions("default").Get("nightly-25856760", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !metav1.IsControlledBy(exec, cronExec) {
+		t.Errorf("expected execution to be controlled by the cron execution")
+	}
+	if exec.Labels["app"] != "gene" || exec.Labels[CronExecutionLabel] != "nightly" {
+		t.Errorf("unexpected labels %v", exec.Labels)
+	}
+	if exec.Annotations[ScheduledTimeAnnotation] != "2019-03-01T02:00:00Z" {
+		t.Errorf("unexpected annotations %v", exec.Annotations)
+	}
+	if !reflect.DeepEqual(exec.Spec, cronExec.Spec.ExecutionTemplate.Spec) {
+		t.Errorf("expected spec %v, got %v", cronExec.Spec.ExecutionTemplate.Spec, exec.Spec)
+	}
+}
+
+func TestCleanupFinishedExecutions(t *testing.T) {
+	cronExec := newCronExecution(genev1alpha1.AllowConcurrent)
+	var one int32 = 1
+	cronExec.Spec.SuccessfulExecutionsHistoryLimit = &one
+	cronExec.Status.LastScheduleTime = &metav1.Time{Time: mustParseTime("2019-03-03T02:00:00Z")}
+	executions := []*genev1alpha1.Execution{
+		newCronChild(cronExec, "nightly-1", genev1alpha1.VertexSucceeded, "2019-03-01T03:00:00Z"),
+		newCronChild(cronExec, "nightly-2", genev1alpha1.VertexSucceeded, "2019-03-02T03:00:00Z"),
+		newCronChild(cronExec, "nightly-3", genev1alpha1.VertexFailed, "2019-03-03T03:00:00Z"),
+	}
+	controller, client := newTestCronController(cronExec, executions, "2019-03-03T04:00:00Z")
+	if err := controller.syncCronExecution(cronExec.DeepCopy()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := client.ExecutionV1alpha1().Executions("default").Get("nightly-1", metav1.GetOptions{}); err == nil {
+		t.Errorf("expected the oldest succeeded execution to be deleted")
+	}
+	for _, name := range []string{"nightly-2", "nightly-3"} {
+		if _, err := client.ExecutionV1alpha1().Executions("default").Get(name, metav1.GetOptions{}); err != nil {
+			t.Errorf("expected execution %s to be retained, got %v", name, err)
+		}
+	}
+}
+
+func TestMostRecentScheduleTime(t *testing.T) {
+	schedule, _ := cron.Parse("0 * * * *")
+	cronExec := newCronExecution(genev1alpha1.AllowConcurrent)
+
+	scheduled, err := mostRecentScheduleTime(cronExec, schedule, mustParseTime("2019-03-01T05:30:00Z"))
+	if err != nil || !scheduled.Equal(mustParseTime("2019-03-01T05:00:00Z")) {
+		t.Errorf("expected 05:00, got %s with %v", scheduled, err)
+	}
+
+	// too many missed times are not walked through.
+	if _, err := mostRecentScheduleTime(cronExec, schedule, mustParseTime("2019-03-06T00:00:00Z")); err == nil {
+		t.Errorf("expected an error for 120 missed times")
+	}
+
+	// the times before the starting deadline are skipped.
+	var deadline int64 = 600
+	cronExec.Spec.StartingDeadlineSeconds = &deadline
+	scheduled, err = mostRecentScheduleTime(cronExec, schedule, mustParseTime("2019-03-06T00:30:00Z"))
+	if err != nil || !scheduled.IsZero() {
+		t.Errorf("expected no time within the deadline, got %s with %v", scheduled, err)
+	}
+}
+
+func TestSyncCronExecutionTooManyMissedTimes(t *testing.T) {
+	cronExec := newCronExecution(genev1alpha1.AllowConcurrent)
+	controller, client := newTestCronController(cronExec, nil, "2019-08-01T02:00:05Z")
+	if err := controller.syncCronExecution(cronExec.DeepCopy()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if actions := actionsOf(client); len(actions) != 0 {
+		t.Errorf("expected no actions, got %v", actions)
+	}
+	recorder := controller.eventRecorder.(*record.FakeRecorder)
+	select {
+	case event := <-recorder.Events:
+		if !strings.Contains(event, "TooManyMissedTimes") {
+			t.Errorf("unexpected event %s", event)
+		}
+	default:
+		t.Errorf("expected an event for the missed times")
+	}
+}
+
+func TestCronExecutionQueue(t *testing.T) {
+	cronExec := newCronExecution(genev1alpha1.AllowConcurrent)
+	cronIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	cronIndexer.Add(cronExec)
+	controller, _ := newTestCronController(cronExec, nil, "2019-03-01T01:00:00Z")
+	controller.cronLister = genelisters.NewCronExecutionLister(cronIndexer)
+	controller.queue = workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	defer controller.queue.ShutDown()
+
+	// an execution enqueues the cron execution that controls it.
+	controller.enqueueOwner(newCronChild(cronExec, "nightly-1", genev1alpha1.VertexSucceeded, ""))
+	controller.enqueueOwner(&genev1alpha1.Execution{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "default"}})
+	if controller.queue.Len() != 1 {
+		t.Fatalf("expected 1 key in the queue, got %d", controller.queue.Len())
+	}
+	key, _ := controller.queue.Get()
+	if key != "default/nightly" {
+		t.Errorf("expected key default/nightly, got %v", key)
+	}
+	controller.queue.Done(key)
+
+	// the cron execution is synced again at its next schedule time.
+	next, ok := nextScheduleTime(cronExec, mustParseTime("2019-03-01T01:00:00Z"))
+	if !ok || !next.Equal(mustParseTime("2019-03-01T02:00:00Z")) {
+		t.Errorf("expected the next schedule time 02:00, got %s", next)
+	}
+	suspend := true
+	cronExec.Spec.Suspend = &suspend
+	if _, ok := nextScheduleTime(cronExec, mustParseTime("2019-03-01T01:00:00Z")); ok {
+		t.Errorf("expected a suspended cron execution not to be scheduled")
+	}
+	if err := controller.sync("default/nightly"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := controller.sync("default/deleted"); err != nil {
+		t.Errorf("expected a deleted cron execution to be ignored, got %v", err)
+	}
+}
+
+func TestValidateCronExecution(t *testing.T) {
+	testCases := []struct {
+		name   string
+		modify func(cronExec *genev1alpha1.CronExecution)
+		expErr bool
+	}{
+		{name: "valid", modify: func(cronExec *genev1alpha1.CronExecution) {}},
+		{
+			name:   "invalid schedule",
+			modify: func(cronExec *genev1alpha1.CronExecution) { cronExec.Spec.Schedule = "0 2 * *" },
+			expErr: true,
+		},
+		{
+			name:   "invalid policy",
+			modify: func(cronExec *genev1alpha1.CronExecution) { cronExec.Spec.ConcurrencyPolicy = "Queue" },
+			expErr: true,
+		},
+		{
+			name: "long name",
+			modify: func(cronExec *genev1alpha1.CronExecution) {
+				cronExec.Name = "a-very-long-name-of-the-cron-execution-that-is-not-allowed"
+			},
+			expErr: true,
+		},
+		{
+			name:   "empty tasks",
+			modify: func(cronExec *genev1alpha1.CronExecution) { cronExec.Spec.ExecutionTemplate.Spec.Tasks = nil },
+			expErr: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		cronExec := newCronExecution(genev1alpha1.ForbidConcurrent)
+		testCase.modify(cronExec)
+		err := ValidateCronExecution(cronExec)
+		if testCase.expErr && err == nil {
+			t.Errorf("%s: expected error, but got nil", testCase.name)
+		}
+		if !testCase.expErr && err != nil {
+			t.Errorf("%s: unexpected error: %v", testCase.name, err)
+		}
+	}
+}
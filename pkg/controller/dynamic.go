@@ -0,0 +1,397 @@
+/*
+Copyright 2019 The Kubegene Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/golang/glog"
+	batch "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	genev1alpha1 "kubegene.io/kubegene/pkg/apis/gene/v1alpha1"
+	"kubegene.io/kubegene/pkg/common"
+	"kubegene.io/kubegene/pkg/dag"
+	"kubegene.io/kubegene/pkg/graph"
+	"kubegene.io/kubegene/pkg/util"
+)
+
+// dependTypeOf returns the type of the dependency of a task on the target task.
+// whole wins if the task depends on the target more than once.
+func dependTypeOf(execution *genev1alpha1.Execution, taskName, target string) genev1alpha1.DependType {
+	dependType := genev1alpha1.DependType("")
+	for _, task := range execution.Spec.Tasks {
+		if task.Name != taskName {
+			continue
+		}
+		for _, dependent := range task.Dependents {
+			if dependent.Target != target {
+				continue
+			}
+			if dependent.Type == genev1alpha1.DependTypeIterate && len(dependType) == 0 {
+				dependType = genev1alpha1.DependTypeIterate
+			} else {
+				dependType = genev1alpha1.DependTypeWhole
+			}
+		}
+	}
+	if len(dependType) == 0 {
+		return genev1alpha1.DependTypeWhole
+	}
+	return dependType
+}
+
+// connectIterate returns whether a job of a task with iterate dependency should
+// be connected to the job of the target task, only the jobs of the same index are.
+// A dynamic vertex has no index yet, so it is connected to all jobs until it is expanded.
+func connectIterate(dependent, vertex *graph.Vertex) bool {
+	if dependent.IsDynamic() || vertex.IsDynamic() {
+		return true
+	}
+	return dag.JobIndex(dependent.Data.Job) == dag.JobIndex(vertex.Data.Job)
+}
+
+// resultTargets returns the tasks whose result is used by the task through
+// get_result or check_result function.
+func resultTargets(task *genev1alpha1.Task) []string {
+	targets := make([]string, 0)
+	if task.CommandsIter != nil {
+		for _, vars := range task.CommandsIter.VarsIter {
+			if v, ok := vars.([]interface{}); ok && len(v) == 3 && v[0] == "get_result" {
+				targets = append(targets, common.ToString(v[1]))
+			}
+		}
+	}
+	if task.Condition != nil {
+		if v, ok := task.Condition.Condition.([]interface{}); ok && len(v) == 3 && v[0] == "check_result" {
+			targets = append(targets, common.ToString(v[1]))
+		}
+	}
+	return targets
+}
+
+// isTaskFinished returns whether all the vertices of a task have finished.
+func isTaskFinished(g *graph.Graph, taskName string) bool {
+	for _, vertex := range g.Vertices() {
+		if dag.JobTask(vertex.Data.Job) == taskName && !vertex.IsFinished() {
+			return false
+		}
+	}
+	return true
+}
+
+// canExpand returns whether a dynamic vertex can be expanded. It can when the
+// tasks whose result it uses have finished, and every job it depends on has
+// finished or will be depended on one by one after expanded.
+func canExpand(g *graph.Graph, execution *genev1alpha1.Execution, vertex *graph.Vertex) bool {
+	// the vertex has been expanded.
+	if !g.HasVertex(vertex) {
+		return false
+	}
+
+	task := vertex.Data.DynamicJob
+	for _, dependent := range g.FindDependentVertices(vertex) {
+		if dependent.IsFinished() {
+			continue
+		}
+		if dependent.IsDynamic() {
+			return false
+		}
+		if dependTypeOf(execution, task.Name, dag.JobTask(dependent.Data.Job)) != genev1alpha1.DependTypeIterate {
+			return false
+		}
+	}
+
+	for _, target := range resultTargets(task) {
+		if !isTaskFinished(g, target) {
+			return false
+		}
+	}
+	return true
+}
+
+// taskJobNames returns the names of the k8s jobs of a task in index order.
+// The job of a loop task is the job of its last iteration.
+func taskJobNames(g *graph.Graph, taskName string) []string {
+	var vertices []*graph.Vertex
+	for _, vertex := range g.Vertices() {
+		if dag.JobTask(vertex.Data.Job) != taskName {
+			continue
+		}
+		// a dynamic task that has not been expanded has no job.
+		if vertex.IsDynamic() && !vertex.IsLoop() {
+			continue
+		}
+		vertices = append(vertices, vertex)
+	}
+	sort.Slice(vertices, func(i, j int) bool {
+		return dag.JobIndex(vertices[i].Data.Job) < dag.JobIndex(vertices[j].Data.Job)
+	})
+
+	names := make([]string, 0, len(vertices))
+	for _, vertex := range vertices {
+		name := vertex.Data.Job.Name
+		if vertex.IsLoop() {
+			name = dag.JobName(dag.JobExecution(vertex.Data.Job), taskName, vertex.GetLoopIteration())
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// getTaskResults returns the results of all the jobs of a task in index order.
+func (e *ExecutionJobController) getTaskResults(g *graph.Graph, execution *genev1alpha1.Execution, taskName string) ([]string, error) {
+	names := taskJobNames(g, taskName)
+	results := make([]string, 0, len(names))
+	for _, name := range names {
+		job := &batch.Job{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: execution.Namespace}}
+		result, err := e.getJobResult(job)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// expandDynamicVertex decides the commands of a dynamic vertex, from the result
+// of the dependent job for get_result or the condition for check_result, and
+// expands the vertex into the vertices of its jobs.
+func (e *ExecutionJobController) expandDynamicVertex(g *graph.Graph, vertex *graph.Vertex, key string) error {
+	namespace, name, _ := cache.SplitMetaNamespaceKey(key)
+	execution, err := e.executionLister.Executions(namespace).Get(name)
+	if err != nil {
+		glog.Errorf("Get execution %s error: %v", key, err)
+		return err
+	}
+
+	if !canExpand(g, execution, vertex) {
+		return nil
+	}
+
+	task := vertex.Data.DynamicJob
+	if task.Condition != nil {
+		glog.V(2).Infof(" conditional based job condition:%v", task.Condition)
+		flag, err := e.evalConditionResult(vertex, g, execution)
+		if err != nil {
+			return fmt.Errorf("evalConditionResult failed : %v", err)
+		}
+		if !flag {
+			// if the condition or check_result validation is false then
+			// the task is skipped, it is expanded into no job so that the
+			// other jobs will continue or the execution will complete.
+			glog.V(2).Infof("condition of task %s is false, skip it", task.Name)
+			execution, err = e.recordExpansion(execution, vertex, nil)
+			if err != nil {
+				return err
+			}
+			return e.expandVertex(g, execution, vertex, nil, key)
+		}
+	}
+
+	commands := task.CommandSet
+	if task.CommandsIter != nil {
+		targets := resultTargets(task)
+		if len(targets) == 0 {
+			return fmt.Errorf("no get_result function found in task %s", task.Name)
+		}
+		// get the results of the dependent tasks
+		results := make(map[string][]string, len(targets))
+		for _, target := range targets {
+			result, err := e.getTaskResults(g, execution, target)
+			if err != nil {
+				return fmt.Errorf("getJobResult failed : %v", err)
+			}
+			results[target] = result
+		}
+		varsIter, err := evalJobResult(results, task.CommandsIter.VarsIter)
+		if err != nil {
+			glog.V(2).Infof("Error in evalJobResult execution job name %q , . Error: %v", task.Name, err)
+			return err
+		}
+		vars := common.VarIter2Vars(varsIter)
+		if task.CommandsIter.Zip {
+			vars, err = common.ZipVars(varsIter)
+			if err != nil {
+				return fmt.Errorf("zip vars_iter of task %s failed: %v", task.Name, err)
+			}
+		}
+		// generate all commands.
+		commands = common.Iter2Array(task.CommandsIter.Command, vars)
+	}
+	glog.V(2).Infof("expand dynamic task %s with commands %v", task.Name, commands)
+
+	execution, err = e.recordExpansion(execution, vertex, commands)
+	if err != nil {
+		return err
+	}
+	return e.expandVertex(g, execution, vertex, commands, key)
+}
+
+// recordExpansion keeps the commands of an expanded dynamic task in the status
+// of the execution and returns the updated execution. A task without command
+// has been skipped by its condition.
+func (e *ExecutionJobController) recordExpansion(execution *genev1alpha1.Execution, vertex *graph.Vertex, commands []string) (*genev1alpha1.Execution, error) {
+	task := vertex.Data.DynamicJob
+	exec := execution.DeepCopy()
+	if exec.Status.ExpandedTasks == nil {
+		exec.Status.ExpandedTasks = make(map[string][]string)
+	}
+	// an empty list is kept in the status, a nil one is dropped by the patch.
+	exec.Status.ExpandedTasks[task.Name] = append([]string{}, commands...)
+	if len(commands) == 0 && task.Condition != nil {
+		status := util.InitializeVertexStatus(vertex.Data.Job.Name, genev1alpha1.VertexSkipped, "condition is false", nil)
+		status.Task = task.Name
+		status.FinishedAt = status.StartedAt
+		if exec.Status.Vertices == nil {
+			exec.Status.Vertices = make(map[string]genev1alpha1.VertexStatus)
+		}
+		exec.Status.Vertices[status.ID] = status
+	}
+	if err := e.execUpdater.UpdateExecutionStatus(exec, execution); err != nil {
+		return nil, err
+	}
+	return exec, nil
+}
+
+// restoreDynamicVertices replaces the dynamic vertices of a rebuilt graph with
+// the vertices of the jobs they have been expanded into, from the commands of
+// the expanded tasks in the execution status.
+func restoreDynamicVertices(g *graph.Graph, execution *genev1alpha1.Execution) error {
+	for _, vertex := range g.Vertices() {
+		if !vertex.IsDynamic() || vertex.IsLoop() {
+			continue
+		}
+		task := vertex.Data.DynamicJob.Name
+		commands, ok := execution.Status.ExpandedTasks[task]
+		if !ok {
+			continue
+		}
+		if _, _, err := replaceDynamicVertex(g, execution, vertex, commands); err != nil {
+			return err
+		}
+		glog.V(2).Infof("restore dynamic task %s with %d jobs", task, len(commands))
+	}
+	return nil
+}
+
+// replaceDynamicVertex replaces a dynamic vertex with a vertex for every command,
+// connected to the dependents and the children of the dynamic vertex. It
+// returns the new vertices and the children.
+func replaceDynamicVertex(g *graph.Graph, execution *genev1alpha1.Execution, vertex *graph.Vertex, commands []string) ([]*graph.Vertex, []*graph.Vertex, error) {
+	task := vertex.Data.DynamicJob
+
+	vertices := make([]*graph.Vertex, 0, len(commands))
+	for index, command := range commands {
+		// make up k8s job resource
+		job, err := dag.NewJob(dag.JobName(execution.Name, task.Name, index), command, execution, task, index, 0)
+		if err != nil {
+			return nil, nil, err
+		}
+		jobInfo := graph.NewJobInfo(job, false, task.Type, nil)
+		vertices = append(vertices, graph.NewVertex(jobInfo, false))
+	}
+
+	for _, dependent := range g.FindDependentVertices(vertex) {
+		dependType := dependTypeOf(execution, task.Name, dag.JobTask(dependent.Data.Job))
+		for _, v := range vertices {
+			if dependType == genev1alpha1.DependTypeWhole || connectIterate(dependent, v) {
+				dependent.AddChild(v)
+			}
+		}
+	}
+	children := vertex.GetChildren()
+	for _, child := range children {
+		dependType := dependTypeOf(execution, dag.JobTask(child.Data.Job), task.Name)
+		for _, v := range vertices {
+			if dependType == genev1alpha1.DependTypeWhole || connectIterate(v, child) {
+				v.AddChild(child)
+			}
+		}
+	}
+	g.ReplaceVertex(vertex, vertices)
+	return vertices, children, nil
+}
+
+// expandVertex replaces a dynamic vertex with a vertex for every command, then
+// starts the new vertices that are ready. The execution must have the
+// expansion recorded in its status, it is the base of the status updates.
+func (e *ExecutionJobController) expandVertex(g *graph.Graph, execution *genev1alpha1.Execution, vertex *graph.Vertex, commands []string, key string) error {
+	vertices, children, err := replaceDynamicVertex(g, execution, vertex, commands)
+	if err != nil {
+		return e.markExecutionError(execution, err)
+	}
+
+	// an expanded vertex may have no job, and a child that iterates over it may
+	// have nothing to iterate over, so the children may be ready as well.
+	if len(vertices) == 0 {
+		if err := e.markExecutionSuccessIfFinished(g, execution); err != nil {
+			return err
+		}
+	}
+	ready := append(vertices, children...)
+	for _, v := range ready {
+		if v.IsDynamic() && !v.IsLoop() {
+			continue
+		}
+		dependents := g.FindDependentVertices(v)
+		if len(dependents) == 0 {
+			e.queue.Add(Event{Type: NewAdded, Key: key})
+			continue
+		}
+		if g.DependentsFinished(v) {
+			e.queue.Add(Event{Type: JobsAfter, Name: dependents[0].Data.Job.Name, Key: key})
+		}
+	}
+
+	// the dynamic children may be expanded now.
+	for _, v := range ready {
+		for _, child := range v.GetChildren() {
+			if child.IsDynamic() && !child.IsLoop() {
+				if err := e.expandDynamicVertex(g, child, key); err != nil {
+					return err
+				}
+			}
+		}
+		if v.IsDynamic() && !v.IsLoop() {
+			if err := e.expandDynamicVertex(g, v, key); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// markExecutionSuccessIfFinished marks the execution as successful if all the
+// vertices of the graph have finished.
+func (e *ExecutionJobController) markExecutionSuccessIfFinished(g *graph.Graph, execution *genev1alpha1.Execution) error {
+	if !g.AllSucceeded() {
+		return nil
+	}
+	exec := execution.DeepCopy()
+	startExit := finishDAG(exec, genev1alpha1.VertexSucceeded, executionSuccessMessage)
+	if err := e.execUpdater.UpdateExecutionStatus(exec, execution); err != nil {
+		return err
+	}
+	if startExit {
+		e.queue.Add(Event{Type: ExitHandler, Key: util.KeyOf(exec)})
+	}
+	return nil
+}
@@ -0,0 +1,332 @@
+/*
+Copyright 2019 The Kubegene Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+
+	genev1alpha1 "kubegene.io/kubegene/pkg/apis/gene/v1alpha1"
+	genelisters "kubegene.io/kubegene/pkg/client/listers/gene/v1alpha1"
+	"kubegene.io/kubegene/pkg/common"
+	"kubegene.io/kubegene/pkg/dag"
+	"kubegene.io/kubegene/pkg/graph"
+)
+
+// newChunkExecution returns split --> align chunk i --> sort chunk i --> merge,
+// align is expanded from the result of split at runtime.
+func newChunkExecution() *genev1alpha1.Execution {
+	getResult := []interface{}{"get_result", "split", "\n"}
+	return &genev1alpha1.Execution{
+		ObjectMeta: metav1.ObjectMeta{Name: "exec", Namespace: "default"},
+		Spec: genev1alpha1.ExecutionSpec{
+			Tasks: []genev1alpha1.Task{
+				{Name: "split", CommandSet: []string{"split"}},
+				{
+					Name:         "align",
+					CommandsIter: &genev1alpha1.CommandsIter{Command: "align ${1}", VarsIter: []interface{}{getResult}},
+					Dependents:   []genev1alpha1.Dependent{{Target: "split", Type: genev1alpha1.DependTypeWhole}},
+				},
+				{
+					Name:       "sort",
+					CommandSet: []string{"sort 0", "sort 1", "sort 2"},
+					Dependents: []genev1alpha1.Dependent{{Target: "align", Type: genev1alpha1.DependTypeIterate}},
+				},
+				{
+					Name:       "merge",
+					CommandSet: []string{"merge"},
+					Dependents: []genev1alpha1.Dependent{{Target: "sort", Type: genev1alpha1.DependTypeWhole}},
+				},
+			},
+		},
+	}
+}
+
+func childNames(vertex *graph.Vertex) []string {
+	names := make([]string, 0)
+	for _, child := range vertex.Children {
+		names = append(names, child.Data.Job.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestNewGraphIterateDynamic(t *testing.T) {
+	exec := newChunkExecution()
+	g, err := dag.NewGraph(exec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	align := g.FindVertexByName("exec.align.")
+	expect := []string{"exec.sort.0", "exec.sort.1", "exec.sort.2"}
+	if names := childNames(align); len(names) != 3 || names[0] != expect[0] || names[2] != expect[2] {
+		t.Errorf("expected children %v, got %v", expect, names)
+	}
+	if canExpand(g, exec, align) {
+		t.Errorf("expected align not to be expanded before split finished")
+	}
+	g.FindVertexByName("exec.split.0").Data.Finished = true
+	if !canExpand(g, exec, align) {
+		t.Errorf("expected align to be expanded after split finished")
+	}
+}
+
+func TestExpandVertex(t *testing.T) {
+	exec := newChunkExecution()
+	g, err := dag.NewGraph(exec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	defer queue.ShutDown()
+	e := &ExecutionJobController{queue: queue}
+
+	split := g.FindVertexByName("exec.split.0")
+	split.Data.Finished = true
+	align := g.FindVertexByName("exec.align.")
+
+	// split only output two chunks.
+	if err := e.expandVertex(g, exec, align, []string{"align a", "align b"}, "default/exec"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if g.VertexCount != 7 || g.HasVertex(align) {
+		t.Fatalf("expected 7 vertices without the dynamic one, got %d", g.VertexCount)
+	}
+	if names := childNames(split); len(names) != 2 || names[0] != "exec.align.0" || names[1] != "exec.align.1" {
+		t.Errorf("expected split children [exec.align.0 exec.align.1], got %v", names)
+	}
+	for i, name := range []string{"exec.align.0", "exec.align.1"} {
+		vertex := g.FindVertexByName(name)
+		if vertex == nil || vertex.IsDynamic() {
+			t.Fatalf("expected static vertex %s", name)
+		}
+		if names := childNames(vertex); len(names) != 1 || names[0] != dag.JobName("exec", "sort", dag.JobIndex(vertex.Data.Job)) {
+			t.Errorf("%d: expected %s children [exec.sort.%d], got %v", i, name, i, names)
+		}
+	}
+	// sort chunk 2 has nothing to iterate over, it depends on nothing.
+	if dependents := g.FindDependentVertices(g.FindVertexByName("exec.sort.2")); len(dependents) != 0 {
+		t.Errorf("expected exec.sort.2 without dependents, got %d", len(dependents))
+	}
+
+	// the new align jobs are ready to start after split, and sort chunk 2 is a root now.
+	expectEvents := map[Event]bool{
+		{Type: JobsAfter, Name: "exec.split.0", Key: "default/exec"}: true,
+		{Type: NewAdded, Key: "default/exec"}:                        true,
+	}
+	if queue.Len() != len(expectEvents) {
+		t.Fatalf("expected %d events, got %d", len(expectEvents), queue.Len())
+	}
+	for queue.Len() > 0 {
+		item, _ := queue.Get()
+		if !expectEvents[item.(Event)] {
+			t.Errorf("unexpected event %v", item)
+		}
+		queue.Done(item)
+	}
+}
+
+func TestRestoreDynamicVertices(t *testing.T) {
+	exec := newChunkExecution()
+	exec.Status.ExpandedTasks = map[string][]string{"align": {"align a", "align b"}}
+	gb := NewGraphBuilder()
+	if err := gb.AddGraph(exec); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	g := gb.GetGraph("default/exec")
+	if g.VertexCount != 7 || g.FindVertexByName("exec.align.") != nil {
+		t.Fatalf("expected 7 vertices without the dynamic one, got %d", g.VertexCount)
+	}
+	for i, name := range []string{"exec.align.0", "exec.align.1"} {
+		vertex := g.FindVertexByName(name)
+		if vertex == nil {
+			t.Fatalf("expected vertex %s to be restored", name)
+		}
+		if names := childNames(vertex); len(names) != 1 || names[0] != dag.JobName("exec", "sort", i) {
+			t.Errorf("expected %s children [exec.sort.%d], got %v", name, i, names)
+		}
+	}
+
+	// a skipped task is restored without job.
+	exec.Status.ExpandedTasks = map[string][]string{"align": {}}
+	if err := gb.AddGraph(exec); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if g := gb.GetGraph("default/exec"); g.VertexCount != 5 || g.FindVertexByName("exec.align.") != nil {
+		t.Errorf("expected 5 vertices without the skipped one, got %d", g.VertexCount)
+	}
+}
+
+func TestRecordExpansion(t *testing.T) {
+	exec := newChunkExecution()
+	exec.Spec.Tasks[1].Condition = &genev1alpha1.Condition{Condition: []interface{}{"check_result", "split", "yes"}}
+	g, err := dag.NewGraph(exec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updater := &fakeExecutionUpdater{}
+	e := &ExecutionJobController{execUpdater: updater}
+
+	recorded, err := e.recordExpansion(exec, g.FindVertexByName("exec.align."), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(updater.updated) != 1 || updater.updated[0] != recorded {
+		t.Fatalf("expected the recorded execution to be updated, got %d updates", len(updater.updated))
+	}
+	status := updater.updated[0].Status
+	if commands, ok := status.ExpandedTasks["align"]; !ok || commands == nil || len(commands) != 0 {
+		t.Errorf("expected align to be expanded without command, got %v", status.ExpandedTasks)
+	}
+	vertex, ok := status.Vertices["exec.align."]
+	if !ok || vertex.Phase != genev1alpha1.VertexSkipped || vertex.Task != "align" {
+		t.Errorf("expected align to be skipped, got %v", vertex)
+	}
+}
+
+func TestExpandVertexFinished(t *testing.T) {
+	exec := newChunkExecution()
+	// split --> align, align is skipped after split has succeeded, so the
+	// execution finishes in the sync that expands align.
+	exec.Spec.Tasks[1].Condition = &genev1alpha1.Condition{Condition: []interface{}{false}}
+	exec.Spec.Tasks = exec.Spec.Tasks[:2]
+	g, err := dag.NewGraph(exec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	split := g.FindVertexByName("exec.split.0")
+	g.MarkFinished(split)
+	g.PlusNumOfSuccess()
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	indexer.Add(exec)
+	queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	defer queue.ShutDown()
+	updater := &fakeExecutionUpdater{}
+	e := &ExecutionJobController{
+		executionLister: genelisters.NewExecutionLister(indexer),
+		execUpdater:     updater,
+		queue:           queue,
+	}
+
+	if err := e.expandDynamicVertex(g, g.FindVertexByName("exec.align."), "default/exec"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(updater.updated) != 2 {
+		t.Fatalf("expected the expansion and the success to be updated, got %d updates", len(updater.updated))
+	}
+	// the success is patched on top of the recorded expansion.
+	if updater.originals[1] != updater.updated[0] {
+		t.Errorf("expected the success to be based on the recorded expansion")
+	}
+	status := updater.updated[1].Status
+	if status.Phase != genev1alpha1.VertexSucceeded {
+		t.Errorf("expected the execution to succeed, got %q", status.Phase)
+	}
+	if _, ok := status.ExpandedTasks["align"]; !ok {
+		t.Errorf("expected align to stay expanded, got %v", status.ExpandedTasks)
+	}
+	if vertex, ok := status.Vertices["exec.align."]; !ok || vertex.Phase != genev1alpha1.VertexSkipped {
+		t.Errorf("expected align to stay skipped, got %v", vertex)
+	}
+}
+
+func TestDependTypeOf(t *testing.T) {
+	exec := newChunkExecution()
+	testCases := []struct {
+		task, target string
+		expect       genev1alpha1.DependType
+	}{
+		{task: "align", target: "split", expect: genev1alpha1.DependTypeWhole},
+		{task: "sort", target: "align", expect: genev1alpha1.DependTypeIterate},
+		{task: "merge", target: "split", expect: genev1alpha1.DependTypeWhole},
+	}
+	for i, testCase := range testCases {
+		if got := dependTypeOf(exec, testCase.task, testCase.target); got != testCase.expect {
+			t.Errorf("%d: expected %s, got %s", i, testCase.expect, got)
+		}
+	}
+}
+
+func TestTaskJobNames(t *testing.T) {
+	exec := newChunkExecution()
+	for i := 0; i < 11; i++ {
+		exec.Spec.Tasks[0].CommandSet = append(exec.Spec.Tasks[0].CommandSet, "split")
+	}
+	g, err := dag.NewGraph(exec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := taskJobNames(g, "split")
+	if len(names) != 12 || names[1] != "exec.split.1" || names[2] != "exec.split.2" || names[11] != "exec.split.11" {
+		t.Errorf("expected split jobs in index order, got %v", names)
+	}
+	// the dynamic align task has no job before expanded.
+	if names := taskJobNames(g, "align"); len(names) != 0 {
+		t.Errorf("expected no align jobs, got %v", names)
+	}
+}
+
+func TestEvalJobResult(t *testing.T) {
+	results := map[string][]string{
+		"split": {"a\nb\n", "c\n"},
+		"index": {"i0", "i1"},
+	}
+	testCases := []struct {
+		vars      []interface{}
+		expect    []common.Var
+		expectErr bool
+	}{
+		{
+			vars:   []interface{}{[]interface{}{"get_result", "split", "\n"}},
+			expect: []common.Var{{"a", "b", "c"}},
+		},
+		{
+			vars:   []interface{}{[]interface{}{"get_result", "index", ""}, []interface{}{1, 2}},
+			expect: []common.Var{{"i0", "i1"}, {1, 2}},
+		},
+		{
+			vars:      []interface{}{[]interface{}{"get_result", "merge", ""}},
+			expectErr: true,
+		},
+	}
+	for i, testCase := range testCases {
+		result, err := evalJobResult(results, testCase.vars)
+		if testCase.expectErr {
+			if err == nil {
+				t.Errorf("%d: expect error, but got nil", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d: unexpected error: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, testCase.expect) {
+			t.Errorf("%d: expected %v, got %v", i, testCase.expect, result)
+		}
+	}
+}
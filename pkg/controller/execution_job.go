@@ -19,7 +19,6 @@ package controller
 import (
 	"fmt"
 	"io/ioutil"
-	"strconv"
 	"strings"
 	"time"
 
@@ -33,9 +32,12 @@ import (
 	"k8s.io/apimachinery/pkg/util/wait"
 	clientset "k8s.io/client-go/kubernetes"
 	batchv1listers "k8s.io/client-go/listers/batch/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
 
+	genev1alpha1 "kubegene.io/kubegene/pkg/apis/gene/v1alpha1"
 	genelisters "kubegene.io/kubegene/pkg/client/listers/gene/v1alpha1"
 	"kubegene.io/kubegene/pkg/common"
 	"kubegene.io/kubegene/pkg/graph"
@@ -55,14 +57,22 @@ const (
 	NewAdded EventType = "NewAdded"
 	// start execute jobs that depend on a job
 	JobsAfter EventType = "JobsAfter"
+	// start the next iteration of a loop task
+	LoopNext EventType = "LoopNext"
+	// start the exit handler tasks after the DAG has finished
+	ExitHandler EventType = "ExitHandler"
 )
 
 var ExceedParallelismError = fmt.Errorf("running jobs have reached the execution parallelism limit")
 
 type Event struct {
 	Type EventType
-	// job name, if Type is `NewAdded`, it can be not specified.
+	// name of the vertex of the job, if Type is `NewAdded`, it can be not specified.
+	// the jobs of a loop share the vertex of their task, the name is the
+	// vertex of the loop task if Type is `LoopNext`.
 	Name string
+	// Attempt is the loop iteration that has finished if Type is `LoopNext`.
+	Attempt int
 	// Execution key
 	Key string
 }
@@ -74,24 +84,40 @@ type ExecutionJobController struct {
 	queue            workqueue.RateLimitingInterface
 	execGraphBuilder *GraphBuilder
 	execUpdater      ExecutionUpdater
+	eventRecorder    record.EventRecorder
+	// quotaTracker accounts the resource quotas before the jobs are created,
+	// nil if the quotas are not accounted.
+	quotaTracker *quotaTracker
+	// fairQueue dispatches the jobs of all the executions under the cluster
+	// concurrency limit, nil if the running jobs are not limited.
+	fairQueue *fairQueue
 }
 
+// NewExecutionJobController returns a ExecutionJobController. The quotaLister
+// is optional, the resource quotas are not accounted if it is nil.
 func NewExecutionJobController(
 	kubeClient clientset.Interface,
 	jobLister batchv1listers.JobLister,
 	executionLister genelisters.ExecutionLister,
+	quotaLister corelisters.ResourceQuotaLister,
 	eventQueue workqueue.RateLimitingInterface,
 	execGraphBuilder *GraphBuilder,
 	execUpdater ExecutionUpdater,
+	eventRecorder record.EventRecorder,
 ) *ExecutionJobController {
-	return &ExecutionJobController{
+	controller := &ExecutionJobController{
 		queue:            eventQueue,
 		kubeClient:       kubeClient,
 		jobLister:        jobLister,
 		executionLister:  executionLister,
 		execGraphBuilder: execGraphBuilder,
 		execUpdater:      execUpdater,
+		eventRecorder:    eventRecorder,
 	}
+	if quotaLister != nil {
+		controller.quotaTracker = newQuotaTracker(quotaLister, jobLister)
+	}
+	return controller
 }
 
 func (e *ExecutionJobController) Run(workers int, stopCh <-chan struct{}) {
@@ -136,6 +162,16 @@ func (e *ExecutionJobController) processNextWorkItem() bool {
 		e.queue.AddAfter(item, 10*time.Second)
 		return true
 	}
+	if err == ExceedQuotaError {
+		glog.V(2).Infof("Jobs of %s exceeded the resource quota, retry after 10s", event.Key)
+		e.queue.AddAfter(item, 10*time.Second)
+		return true
+	}
+	if err == ExceedConcurrencyError {
+		glog.V(2).Infof("Jobs of %s are waiting in the cluster queue, retry after 10s", event.Key)
+		e.queue.AddAfter(item, 10*time.Second)
+		return true
+	}
 
 	utilruntime.HandleError(fmt.Errorf("%v failed with : %v", event, err))
 	// since we failed, we should requeue the item to work on later.  This method will add a backoff
@@ -147,6 +183,10 @@ func (e *ExecutionJobController) processNextWorkItem() bool {
 }
 
 func (e *ExecutionJobController) syncHandler(event Event) error {
+	// the events of an execution are handled one at a time.
+	e.execGraphBuilder.LockExecution(event.Key)
+	defer e.execGraphBuilder.UnlockExecution(event.Key)
+
 	graph := e.execGraphBuilder.GetGraph(event.Key)
 	if graph == nil {
 		glog.V(2).Infof("graph of execution %s does not exist", event.Key)
@@ -157,96 +197,99 @@ func (e *ExecutionJobController) syncHandler(event Event) error {
 	case NewAdded:
 		glog.V(2).Infof("execution %v start running.", event.Key)
 
+		// the DAG has finished before the graph is rebuilt.
+		if e.isDAGFinished(event.Key) {
+			return e.startExitHandler(graph, event.Key)
+		}
+
 		rootVertexs := graph.GetRootVertex()
 		for _, rootVertex := range rootVertexs {
-			if e.shouldStartJob(event.Key, rootVertex.Data.Job) {
-				// root vertex, create job
-				if err := e.createJob(rootVertex.Data.Job); err != nil {
-					key := util.KeyOf(rootVertex.Data.Job)
-					return fmt.Errorf("create job %s error: %v", key, err)
-				}
-			} else {
-				return ExceedParallelismError
+			if err := e.startVertex(graph, rootVertex, event.Key); err != nil {
+				return err
 			}
 		}
 	case JobsAfter:
 		glog.V(2).Infof("job %v has run successfully.", event.Name)
 
 		vertex := graph.FindVertexByName(event.Name)
-		for _, child := range vertex.Children {
-			allDependentsFinished := true
-
-			dependents := graph.FindDependentsByName(child.Data.Job.Name)
-			for _, dependent := range dependents {
-				childVertex := graph.FindVertex(dependent)
-				if !childVertex.Data.Finished {
-					allDependentsFinished = false
-					break
-				}
+		if vertex == nil {
+			return nil
+		}
+		// the children may be changed when a dynamic child is expanded,
+		// range over the children before that.
+		for _, child := range vertex.GetChildren() {
+			if err := e.startVertex(graph, child, event.Key); err != nil {
+				return err
 			}
-			if allDependentsFinished {
-				glog.V(2).Infof("all dependent of job %v has run successfully, start running.", child.Data.Job.Name)
-
-				if child.Data.DynamicJob != nil {
-					// flag setting true to handle if Condition is nil
-					flag := true
-					var err error
-					if child.Data.DynamicJob.Condition != nil {
-						glog.V(2).Infof(" conditional based job condition:%v", child.Data.DynamicJob.Condition)
-						flag, err = e.evalConditionResult(vertex.Data.Job, child, graph, event.Key)
-						if err != nil {
-							return fmt.Errorf("evalConditionResult failed : %v", err)
-						}
-						if !flag {
-							// if the condition or check_result validation is false
-							// then we don't create the k8s job and make the job is Finished true
-							// so that other jobs will continue or execution will complete
-							glog.V(2).Infof(" The final condition is false")
-							child.Data.Finished = true
-							continue
-						}
-					}
+		}
+	case LoopNext:
+		glog.V(2).Infof("loop job %v has finished, start next iteration.", event.Name)
 
-					if len(child.Data.DynamicJob.CommandSet) > 0 && child.Data.DynamicJob.CommandsIter == nil {
+		vertex := graph.FindVertexByName(event.Name)
+		if vertex == nil || !vertex.IsLoop() {
+			return nil
+		}
+		// the next iteration has already been started.
+		if event.Attempt != vertex.GetLoopIteration() {
+			return nil
+		}
+		// start the vertices of the loop that depend on none of the others,
+		// the others are started once the jobs they depend on have succeeded.
+		for _, loopVertex := range loopVertices(graph, vertex) {
+			if err := e.startLoopVertex(graph, loopVertex, event.Key, event.Attempt+1); err != nil {
+				return err
+			}
+		}
+		vertex.IncLoopIteration()
+	case ExitHandler:
+		return e.startExitHandler(graph, event.Key)
+	}
+	return nil
+}
 
-						// construct the dynamic jobs based on conditional branch result
-						err := e.createDynamicJobsBasedOnConditionalChk(child, graph, event.Key)
-						if err != nil {
-							return fmt.Errorf("createDynamicJobsBasedOnConditionalChk failed : %v", err)
-						}
-						continue
-					}
+// isDAGFinished returns whether the DAG of the execution has finished and
+// the exit handlers are running.
+func (e *ExecutionJobController) isDAGFinished(key string) bool {
+	namespace, name, _ := cache.SplitMetaNamespaceKey(key)
+	execution, err := e.executionLister.Executions(namespace).Get(name)
+	if err != nil {
+		return false
+	}
+	return len(execution.Status.DAGPhase) != 0
+}
 
-					if child.Data.DynamicJob.CommandsIter != nil {
-
-						// get the result of the dependent job
-						result, err := e.getJobResult(vertex.Data.Job)
-						if err != nil {
-							return fmt.Errorf("getJobResult failed : %v", err)
-						}
-						// construct the dynamic job based on get_result
-						err = e.createDynamicJob(child, result, graph, event.Key)
-						if err != nil {
-							return fmt.Errorf("createDynamicJob failed : %v", err)
-						}
-						continue
-					}
+// startVertex starts the job of a vertex once all of its dependents have finished.
+// A dynamic vertex is expanded into the vertices of its jobs instead.
+func (e *ExecutionJobController) startVertex(g *graph.Graph, vertex *graph.Vertex, key string) error {
+	if vertex.IsFinished() {
+		return nil
+	}
+	if vertex.IsDynamic() && !vertex.IsLoop() {
+		return e.expandDynamicVertex(g, vertex, key)
+	}
 
-				}
-				if e.shouldStartJob(event.Key, child.Data.Job) {
-					if err := e.createJob(child.Data.Job); err != nil {
-						key := util.KeyOf(child.Data.Job)
-						return fmt.Errorf("create job %s error: %v", key, err)
-					}
-				} else {
-					return ExceedParallelismError
-				}
-			}
+	if vertex.IsLoop() {
+		return e.startLoopVertex(g, vertex, key, vertex.GetLoopIteration())
+	}
+
+	if !g.DependentsFinished(vertex) {
+		return nil
+	}
+	glog.V(2).Infof("all dependent of job %v has run successfully, start running.", vertex.Data.Job.Name)
+
+	if !e.shouldStartJob(key, vertex.Data.Job) {
+		return ExceedParallelismError
+	}
+	if err := e.createJob(vertex.Data.Job); err != nil {
+		if isWaitingError(err) {
+			return err
 		}
+		return fmt.Errorf("create job %s error: %v", util.KeyOf(vertex.Data.Job), err)
 	}
 	return nil
 }
-func (e *ExecutionJobController) evalConditionResult(dependJob *batch.Job, vertex *graph.Vertex, graph *graph.Graph, key string) (bool, error) {
+
+func (e *ExecutionJobController) evalConditionResult(vertex *graph.Vertex, graph *graph.Graph, execution *genev1alpha1.Execution) (bool, error) {
 
 	glog.Infof("In evalConditionResult condition:%v", vertex.Data.DynamicJob.Condition.Condition)
 
@@ -262,11 +305,13 @@ func (e *ExecutionJobController) evalConditionResult(dependJob *batch.Job, verte
 			parentJobName := v[1].(string)
 			exp := v[2].(string)
 			glog.Infof("In evalConditionResult jobName: %s exp:%s", parentJobName, exp)
-			// get the result of the dependent job
-			result, err := e.getJobResult(dependJob)
+			// get the result of the dependent task, the results of
+			// all its jobs are joined by line.
+			results, err := e.getTaskResults(graph, execution, parentJobName)
 			if err != nil {
 				return false, fmt.Errorf("getJobResult failed in evalConditionResult: %v", err)
 			}
+			result := strings.Join(results, "\n")
 
 			if exp == result {
 				return true, nil
@@ -280,7 +325,11 @@ func (e *ExecutionJobController) evalConditionResult(dependJob *batch.Job, verte
 
 	return false, fmt.Errorf("In evalConditionResult Invalid condition %v", vertex.Data.DynamicJob.Condition.Condition)
 }
-func evalJobResult(jobResult string, vars []interface{}) ([]common.Var, error) {
+
+// evalJobResult replaces the get_result functions in vars with the results
+// of the tasks. Without a separator every job of the task gives one item,
+// otherwise the result of every job is split by the separator.
+func evalJobResult(jobResults map[string][]string, vars []interface{}) ([]common.Var, error) {
 	result := make([]common.Var, 0, len(vars))
 	glog.Infof("In evalJobResult vars:%v", vars)
 
@@ -292,21 +341,26 @@ func evalJobResult(jobResult string, vars []interface{}) ([]common.Var, error) {
 			parentJobName := v[1].(string)
 			sep := v[2].(string)
 
-			if sep == "" {
-				temp := []interface{}{jobResult}
-				result = append(result, temp)
-			} else {
-				var strslice []interface{}
-				for _, str := range strings.Split(jobResult, sep) {
+			jobResult, ok := jobResults[parentJobName]
+			if !ok {
+				return nil, fmt.Errorf("the result of job %s is not found", parentJobName)
+			}
+
+			var strslice []interface{}
+			for _, res := range jobResult {
+				if sep == "" {
+					strslice = append(strslice, res)
+					continue
+				}
+				for _, str := range strings.Split(res, sep) {
 					if str != "" {
 						strslice = append(strslice, str)
 					}
 				}
-				glog.Infof("In evalJobResult strslice:%v", strslice)
-
-				result = append(result, strslice)
 			}
-			glog.Infof("In evalJobResult jobName: %s sep:%s", parentJobName, sep)
+			glog.Infof("In evalJobResult jobName: %s sep:%s strslice:%v", parentJobName, sep, strslice)
+
+			result = append(result, strslice)
 		} else {
 			//except get_result other parameters need to be appended
 			result = append(result, v)
@@ -315,122 +369,128 @@ func evalJobResult(jobResult string, vars []interface{}) ([]common.Var, error) {
 	glog.Infof("In evalJobResult result: %v", result)
 	return result, nil
 }
-func ConvertVars(vars []interface{}) []common.Var {
-	result := make([]common.Var, 0, len(vars))
 
-	for _, v := range vars {
-		res := make([]interface{}, 0)
-		res = append(res, v)
-		result = append(result, res)
+// createJob creates the job if it does not exist. It returns
+// ExceedConcurrencyError if the job has to wait for its turn in the cluster
+// queue. It returns ExceedQuotaError if the job does not fit the resource
+// quotas of the namespace, and marks the execution of the job as throttled
+// until the jobs fit again.
+func (e *ExecutionJobController) createJob(job *batch.Job) error {
+	_, err := e.jobLister.Jobs(job.Namespace).Get(job.Name)
+	// job has been already created
+	if !errors.IsNotFound(err) {
+		return nil
 	}
-	return result
-}
 
-func (e *ExecutionJobController) createDynamicJobsBasedOnConditionalChk(vertex *graph.Vertex, graph *graph.Graph, key string) error {
-	glog.V(2).Infof(" The condition based job which has normal commands")
-	task := vertex.Data.DynamicJob
+	if e.fairQueue != nil {
+		admitted, err := e.fairQueue.admit(job)
+		if err != nil {
+			return err
+		}
+		if !admitted {
+			glog.V(4).Infof("job %s is waiting in the cluster queue", util.KeyOf(job))
+			return ExceedConcurrencyError
+		}
+	}
 
-	// update this task in the execution struct then call the Update()
-	namespace, name, _ := cache.SplitMetaNamespaceKey(key)
-	execution, err := e.executionLister.Executions(namespace).Get(name)
-	if err != nil {
-		glog.Errorf("Get execution %s error: %v", key, err)
-		return err
-	}
-	//set the dynamic job Count of this vertex
-	vertex.SetDynamicJobCnt(len(task.CommandSet))
-
-	// create all the jobs here
-	jobNamePrefix := execution.Name + Separator + task.Name + Separator
-	for index, command := range task.CommandSet {
-		jobName := jobNamePrefix + strconv.Itoa(index)
-		// make up k8s job resource
-		job := newJob(jobName, command, execution, task)
-
-		if err := e.createJob(job); err != nil {
-			glog.Errorf("createJob failed error: %v", err)
-			key := util.KeyOf(job)
-			return fmt.Errorf("create job %s error: %v", key, err)
+	if e.quotaTracker != nil {
+		message, err := e.quotaTracker.tryReserve(job)
+		if err != nil {
+			e.releaseFairQueue(job)
+			return err
+		}
+		if len(message) != 0 {
+			glog.V(2).Infof("job %s is throttled: %s", util.KeyOf(job), message)
+			e.releaseFairQueue(job)
+			e.markThrottled(job, true, message)
+			return ExceedQuotaError
 		}
 	}
-	//set the dynamic job Count of this vertex
-	// -1 because already one vertex  related to dynamic job is considered as one job
-	graph.AddDynamicJobCnt(len(task.CommandSet) - 1)
 
-	return nil
+	_, err = e.kubeClient.BatchV1().Jobs(job.Namespace).Create(job)
+	if err != nil && e.quotaTracker != nil {
+		e.quotaTracker.release(job)
+	}
+	if err != nil && !errors.IsAlreadyExists(err) {
+		e.releaseFairQueue(job)
+	}
+	if err != nil && errors.IsAlreadyExists(err) {
+		return nil
+	}
+	if isQuotaExceededError(err) {
+		glog.V(2).Infof("job %s is throttled: %v", util.KeyOf(job), err)
+		e.markThrottled(job, true, err.Error())
+		return ExceedQuotaError
+	}
+	if err == nil {
+		e.markThrottled(job, false, "")
+	}
+
+	return err
 }
 
-func (e *ExecutionJobController) createDynamicJob(vertex *graph.Vertex, jobResult string, graph *graph.Graph, key string) error {
+// releaseFairQueue releases the slot of a job that failed to be created.
+func (e *ExecutionJobController) releaseFairQueue(job *batch.Job) {
+	if e.fairQueue != nil {
+		e.fairQueue.release(job)
+	}
+}
 
-	task := vertex.Data.DynamicJob
+// isWaitingError returns whether the job has to wait for the cluster queue or
+// the resource quotas before it is created again.
+func isWaitingError(err error) bool {
+	return err == ExceedQuotaError || err == ExceedConcurrencyError
+}
 
-	glog.V(2).Infof("vertex.Data.DynamicJob.CommandsIter.VarsIter : %#v", vertex.Data.DynamicJob.CommandsIter.VarsIter)
-	varsIter, err := evalJobResult(jobResult, vertex.Data.DynamicJob.CommandsIter.VarsIter)
+// markThrottled sets the Throttled condition of the execution of the job. The
+// condition is only added once a job of the execution has been throttled. The
+// message of the condition is kept the same while the execution is throttled,
+// the usage of the quota is recorded in an event instead.
+func (e *ExecutionJobController) markThrottled(job *batch.Job, throttled bool, message string) {
+	controllerRef := metav1.GetControllerOf(job)
+	if controllerRef == nil {
+		return
+	}
+	execution, err := e.executionLister.Executions(job.Namespace).Get(controllerRef.Name)
 	if err != nil {
-		glog.V(2).Infof("Error in evalJobResult execution job name %q , . Error: %v", task.Name, err)
-		return err
+		glog.Errorf("Get execution of job %s error: %v", util.KeyOf(job), err)
+		return
 	}
-	glog.V(2).Infof("evalJobResult output varsIter: %v", varsIter)
-
-	// convert varsIter to var
-	iterVars := common.VarIter2Vars(varsIter)
-
-	glog.V(2).Infof(" In createDynamicJob iterVars: %v", iterVars)
-
-	command := vertex.Data.DynamicJob.CommandsIter.Command
-
-	glog.V(2).Infof(" In createDynamicJob command: %v", command)
-
-	// generate all commands.
-	iterCommands := common.Iter2Array(command, iterVars)
-
-	task.CommandSet = iterCommands
-
-	glog.V(2).Infof("final commandset task.CommandSet %v ", task.CommandSet)
 
-	// update this task in the execution struct then call the Update()
-	namespace, name, _ := cache.SplitMetaNamespaceKey(key)
-	execution, err := e.executionLister.Executions(namespace).Get(name)
-	if err != nil {
-		glog.Errorf("Get execution %s error: %v", key, err)
-		return err
-	}
-	//set the dynamic job Count of this vertex
-	vertex.SetDynamicJobCnt(len(task.CommandSet))
-
-	// create all the jobs here
-	jobNamePrefix := execution.Name + Separator + task.Name + Separator
-	for index, command := range task.CommandSet {
-		jobName := jobNamePrefix + strconv.Itoa(index)
-		// make up k8s job resource
-		job := newJob(jobName, command, execution, task)
-
-		if err := e.createJob(job); err != nil {
-			glog.Errorf("createJob failed error: %v", err)
-			key := util.KeyOf(job)
-			return fmt.Errorf("create job %s error: %v", key, err)
+	condition := genev1alpha1.ExecutionCondition{
+		Type:    genev1alpha1.ExecutionThrottled,
+		Status:  v1.ConditionTrue,
+		Reason:  "QuotaExceeded",
+		Message: ExceedQuotaError.Error(),
+	}
+	if throttled {
+		e.eventRecorder.Eventf(execution, v1.EventTypeWarning, "QuotaExceeded", "%s", message)
+	}
+	if !throttled {
+		existing := util.GetExecutionCondition(execution, genev1alpha1.ExecutionThrottled)
+		if existing == nil || existing.Status != v1.ConditionTrue {
+			return
 		}
+		condition.Status = v1.ConditionFalse
+		condition.Reason = "QuotaAvailable"
+		condition.Message = "jobs fit the resource quota of the namespace"
 	}
-	//set the dynamic job Count of this vertex
-	// -1 because already one vertex  related to dynamic job is considered as one job
-	graph.AddDynamicJobCnt(len(task.CommandSet) - 1)
 
-	return nil
-}
-
-func (e *ExecutionJobController) createJob(job *batch.Job) error {
-	_, err := e.jobLister.Jobs(job.Namespace).Get(job.Name)
-	// job has been already created
-	if !errors.IsNotFound(err) {
-		return nil
+	exec := execution.DeepCopy()
+	if !util.SetExecutionCondition(exec, condition) {
+		return
 	}
-
-	_, err = e.kubeClient.BatchV1().Jobs(job.Namespace).Create(job)
-	if err != nil && errors.IsAlreadyExists(err) {
-		return nil
+	if err := e.execUpdater.UpdateExecutionStatus(exec, execution); err != nil {
+		glog.Errorf("Update throttled condition of execution %s error: %v", util.KeyOf(execution), err)
 	}
+}
 
-	return err
+// markExecutionError marks the execution with an error that retrying does not
+// fix, such as a job that can not be built from its task.
+func (e *ExecutionJobController) markExecutionError(execution *genev1alpha1.Execution, err error) error {
+	exec := execution.DeepCopy()
+	util.MarkExecutionError(exec, err)
+	return e.execUpdater.UpdateExecutionStatus(exec, execution)
 }
 
 func (e *ExecutionJobController) getJobResult(job *batch.Job) (string, error) {
@@ -533,7 +593,9 @@ func (e *ExecutionJobController) shouldStartJob(key string, job *batch.Job) bool
 		return false
 	}
 	if execution.Spec.Parallelism != nil {
-		jobs, err := e.getActiveJobsForExecution(job.Namespace, labels.Set(job.Labels).AsSelector())
+		// the jobs of the execution, not only the jobs of the task of the job.
+		selector := labels.SelectorFromSet(labels.Set{"controller-uid": string(execution.UID)})
+		jobs, err := e.getActiveJobsForExecution(job.Namespace, selector)
 		if err != nil {
 			glog.Errorf("Get active jobs for execution %s error: %v", key, err)
 			return false
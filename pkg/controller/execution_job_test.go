@@ -0,0 +1,62 @@
+/*
+Copyright 2019 The Kubegene Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	batchv1listers "k8s.io/client-go/listers/batch/v1"
+	"k8s.io/client-go/tools/cache"
+
+	genev1alpha1 "kubegene.io/kubegene/pkg/apis/gene/v1alpha1"
+	genelisters "kubegene.io/kubegene/pkg/client/listers/gene/v1alpha1"
+	"kubegene.io/kubegene/pkg/dag"
+)
+
+func TestStartVertexParallelism(t *testing.T) {
+	parallelism := int64(2)
+	exec := &genev1alpha1.Execution{
+		ObjectMeta: metav1.ObjectMeta{Name: "exec", Namespace: "default", UID: "exec-uid"},
+		Spec: genev1alpha1.ExecutionSpec{
+			Parallelism: &parallelism,
+			Tasks: []genev1alpha1.Task{
+				{Name: "call", Type: genev1alpha1.JobTaskType, Image: "gatk", CommandSet: []string{"a", "b", "c"}},
+			},
+		},
+	}
+	g, err := dag.NewGraph(exec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	execIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	execIndexer.Add(exec)
+	jobIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	// the jobs of the first two commands are running.
+	for _, vertex := range g.Vertices()[:2] {
+		jobIndexer.Add(vertex.Data.Job)
+	}
+	e := &ExecutionJobController{
+		jobLister:       batchv1listers.NewJobLister(jobIndexer),
+		executionLister: genelisters.NewExecutionLister(execIndexer),
+	}
+
+	if err := e.startVertex(g, g.Vertices()[2], "default/exec"); err != ExceedParallelismError {
+		t.Errorf("expected %v, got %v", ExceedParallelismError, err)
+	}
+}
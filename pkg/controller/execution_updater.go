@@ -29,6 +29,7 @@ import (
 	genev1alpha1 "kubegene.io/kubegene/pkg/apis/gene/v1alpha1"
 	geneclientset "kubegene.io/kubegene/pkg/client/clientset/versioned/typed/gene/v1alpha1"
 	"kubegene.io/kubegene/pkg/util"
+	"kubegene.io/kubegene/pkg/webhook"
 )
 
 // DefaultRetry is a default retry backoff settings when retrying API calls
@@ -147,6 +148,26 @@ func (esu *executionUpdater) UpdateExecution(modified *genev1alpha1.Execution, o
 
 	return err
 }
+
+// NewNotifyingUpdater returns a ExecutionUpdater that posts the lifecycle events of a
+// Execution to the webhooks after its Status has been updated.
+func NewNotifyingUpdater(updater ExecutionUpdater, notifier *webhook.Notifier) ExecutionUpdater {
+	return &notifyingUpdater{ExecutionUpdater: updater, notifier: notifier}
+}
+
+type notifyingUpdater struct {
+	ExecutionUpdater
+	notifier *webhook.Notifier
+}
+
+func (nu *notifyingUpdater) UpdateExecutionStatus(modified *genev1alpha1.Execution, original *genev1alpha1.Execution) error {
+	if err := nu.ExecutionUpdater.UpdateExecutionStatus(modified, original); err != nil {
+		return err
+	}
+	nu.notifier.Notify(modified, original)
+	return nil
+}
+
 func preparePatchBytesForExecutionStatus(modifiedExec *genev1alpha1.Execution, originExec *genev1alpha1.Execution) ([]byte, error) {
 	origin, err := json.Marshal(originExec)
 	if err != nil {
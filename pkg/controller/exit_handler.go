@@ -0,0 +1,181 @@
+/*
+Copyright 2019 The Kubegene Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/golang/glog"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+
+	genev1alpha1 "kubegene.io/kubegene/pkg/apis/gene/v1alpha1"
+	"kubegene.io/kubegene/pkg/common"
+	"kubegene.io/kubegene/pkg/dag"
+	"kubegene.io/kubegene/pkg/graph"
+	"kubegene.io/kubegene/pkg/util"
+)
+
+// failedVertices returns the names of the failed job vertices of the
+// execution, except the exit handlers.
+func failedVertices(execution *genev1alpha1.Execution) []string {
+	names := make([]string, 0)
+	for _, status := range execution.Status.Vertices {
+		if status.Type == genev1alpha1.DAGVertexType || status.Phase != genev1alpha1.VertexFailed {
+			continue
+		}
+		if dag.IsExitTask(execution, status.Task) {
+			continue
+		}
+		names = append(names, status.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// finishDAG marks the execution with the phase the DAG finished with. If
+// the execution has exit handlers, the phase is recorded and the execution
+// keeps running until they have finished. It returns whether the exit
+// handlers should be started.
+func finishDAG(execution *genev1alpha1.Execution, phase genev1alpha1.VertexPhase, message string) bool {
+	if len(execution.Spec.OnExit) == 0 {
+		util.MarkExecutionPhase(execution, phase, message)
+		return false
+	}
+	// the DAG has finished already.
+	if len(execution.Status.DAGPhase) != 0 {
+		return false
+	}
+	execution.Status.DAGPhase = phase
+	util.MarkExecutionPhase(execution, genev1alpha1.VertexRunning, message)
+	return true
+}
+
+// finishExitHandler marks the execution with the phase the DAG finished
+// with once all the exit handler vertices have finished. The execution is
+// marked failed if any of them failed.
+func finishExitHandler(execution *genev1alpha1.Execution, g *graph.Graph) {
+	failed := make([]string, 0)
+	for _, vertex := range g.Vertices() {
+		if !dag.IsExitTask(execution, dag.JobTask(vertex.Data.Job)) {
+			continue
+		}
+		if !vertex.IsFinished() {
+			return
+		}
+		status := util.GetVertexStatus(execution, vertex.Data.Job.Name)
+		if status != nil && status.Phase == genev1alpha1.VertexFailed {
+			failed = append(failed, vertex.Data.Job.Name)
+		}
+	}
+
+	phase, message := execution.Status.DAGPhase, execution.Status.Message
+	if len(failed) != 0 {
+		phase = genev1alpha1.VertexFailed
+		message = fmt.Sprintf("exit handler %s failed", strings.Join(failed, ","))
+	}
+	util.MarkExecutionPhase(execution, phase, message)
+}
+
+// runningDAGJobs returns the number of the running jobs of the execution,
+// except the exit handlers.
+func (e *ExecutionJobController) runningDAGJobs(execution *genev1alpha1.Execution) (int, error) {
+	selector := labels.SelectorFromSet(labels.Set{"controller-uid": string(execution.UID)})
+	jobs, err := e.getActiveJobsForExecution(execution.Namespace, selector)
+	if err != nil {
+		return 0, err
+	}
+	running := 0
+	for _, job := range jobs {
+		if !dag.IsExitTask(execution, dag.JobTask(job)) {
+			running++
+		}
+	}
+	return running, nil
+}
+
+// startExitHandler adds the vertices of the exit handler tasks to the graph
+// and creates their jobs. The variants of the exit handlers are replaced
+// with the phase the DAG finished with and the names of the failed vertices.
+func (e *ExecutionJobController) startExitHandler(g *graph.Graph, key string) error {
+	namespace, name, _ := cache.SplitMetaNamespaceKey(key)
+	execution, err := e.executionLister.Executions(namespace).Get(name)
+	if err != nil {
+		glog.Errorf("Get execution %s error: %v", key, err)
+		return err
+	}
+	if util.IsExecutionCompleted(execution) {
+		return nil
+	}
+	// retry until the phase of the DAG has been synced to the lister.
+	if len(execution.Status.DAGPhase) == 0 {
+		return fmt.Errorf("the DAG of execution %s has not finished", key)
+	}
+	// a failed job finishes the DAG while the other jobs may be running, the
+	// exit handlers are started once the last of them has finished.
+	running, err := e.runningDAGJobs(execution)
+	if err != nil {
+		return err
+	}
+	if running != 0 {
+		glog.V(2).Infof("execution %s waits for %d running jobs before the exit handler", key, running)
+		return nil
+	}
+
+	data := map[string]string{
+		common.ExitPhaseVariant:    string(execution.Status.DAGPhase),
+		common.ExitFailuresVariant: strings.Join(failedVertices(execution), ","),
+	}
+
+	vertices := make([]*graph.Vertex, 0)
+	for _, task := range execution.Spec.Tasks {
+		if !dag.IsExitTask(execution, task.Name) {
+			continue
+		}
+		task := task
+		dag.SetTaskDefaults(execution, &task)
+		for index, command := range task.CommandSet {
+			name := dag.JobName(execution.Name, task.Name, index)
+			if g.FindVertexByName(name) != nil {
+				continue
+			}
+			job, err := dag.NewJob(name, common.ReplaceVariant(command, data), execution, &task, index, 0)
+			if err != nil {
+				return e.markExecutionError(execution, err)
+			}
+			jobInfo := graph.NewJobInfo(job, false, task.Type, nil)
+			vertices = append(vertices, graph.NewVertex(jobInfo, false))
+		}
+	}
+	g.AppendVertices(vertices)
+
+	glog.V(2).Infof("execution %s finished with %s, start exit handler", key, execution.Status.DAGPhase)
+	for _, vertex := range g.Vertices() {
+		if !dag.IsExitTask(execution, dag.JobTask(vertex.Data.Job)) || vertex.IsFinished() {
+			continue
+		}
+		if err := e.createJob(vertex.Data.Job); err != nil {
+			if isWaitingError(err) {
+				return err
+			}
+			return fmt.Errorf("create job %s error: %v", util.KeyOf(vertex.Data.Job), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+/*
+Copyright 2019 The Kubegene Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	batch "k8s.io/api/batch/v1"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	batchv1listers "k8s.io/client-go/listers/batch/v1"
+	"k8s.io/client-go/tools/cache"
+
+	genev1alpha1 "kubegene.io/kubegene/pkg/apis/gene/v1alpha1"
+	genelisters "kubegene.io/kubegene/pkg/client/listers/gene/v1alpha1"
+	"kubegene.io/kubegene/pkg/dag"
+	"kubegene.io/kubegene/pkg/graph"
+	"kubegene.io/kubegene/pkg/util"
+)
+
+func newExitHandlerExecution() *genev1alpha1.Execution {
+	return &genev1alpha1.Execution{
+		ObjectMeta: metav1.ObjectMeta{Name: "exec", Namespace: "default"},
+		Spec: genev1alpha1.ExecutionSpec{
+			Tasks: []genev1alpha1.Task{
+				{Name: "align", CommandSet: []string{"align 0", "align 1"}},
+				{Name: "cleanup", CommandSet: []string{"cleanup ${workflow.phase}"}},
+			},
+			OnExit: []string{"cleanup"},
+		},
+		Status: genev1alpha1.ExecutionStatus{
+			Vertices: make(map[string]genev1alpha1.VertexStatus),
+		},
+	}
+}
+
+func TestNewGraphWithoutExitHandler(t *testing.T) {
+	g, err := dag.NewGraph(newExitHandlerExecution())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.VertexCount != 2 || g.FindVertexByName("exec.cleanup.0") != nil {
+		t.Errorf("expected 2 vertices without the exit handler, got %d", g.VertexCount)
+	}
+}
+
+func TestFinishDAG(t *testing.T) {
+	exec := newExitHandlerExecution()
+	if !finishDAG(exec, genev1alpha1.VertexFailed, "align failed") {
+		t.Errorf("expected exit handler to be started")
+	}
+	if exec.Status.Phase != genev1alpha1.VertexRunning || exec.Status.DAGPhase != genev1alpha1.VertexFailed {
+		t.Errorf("expected running execution with failed DAG, got %s and %s", exec.Status.Phase, exec.Status.DAGPhase)
+	}
+	// the exit handler is started only once.
+	if finishDAG(exec, genev1alpha1.VertexFailed, "align failed") {
+		t.Errorf("expected exit handler not to be started again")
+	}
+
+	exec.Spec.OnExit = nil
+	exec.Status.DAGPhase = ""
+	if finishDAG(exec, genev1alpha1.VertexSucceeded, executionSuccessMessage) {
+		t.Errorf("expected no exit handler to be started")
+	}
+	if exec.Status.Phase != genev1alpha1.VertexSucceeded {
+		t.Errorf("expected succeeded execution, got %s", exec.Status.Phase)
+	}
+}
+
+func TestFailedVertices(t *testing.T) {
+	exec := newExitHandlerExecution()
+	for name, task := range map[string]string{
+		"exec.align.1":   "align",
+		"exec.align.0":   "align",
+		"exec.cleanup.0": "cleanup",
+	} {
+		status := util.InitializeVertexStatus(name, genev1alpha1.VertexFailed, "", nil)
+		status.Task = task
+		exec.Status.Vertices[status.ID] = status
+	}
+
+	expect := []string{"exec.align.0", "exec.align.1"}
+	if got := failedVertices(exec); !reflect.DeepEqual(got, expect) {
+		t.Errorf("expected failed vertices %v, got %v", expect, got)
+	}
+}
+
+func TestFinishExitHandler(t *testing.T) {
+	exec := newExitHandlerExecution()
+	exec.Spec.Tasks[1].CommandSet = append(exec.Spec.Tasks[1].CommandSet, "report")
+	g, err := dag.NewGraph(exec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	finishDAG(exec, genev1alpha1.VertexSucceeded, executionSuccessMessage)
+
+	vertices := make([]*graph.Vertex, 0)
+	for index, name := range []string{"exec.cleanup.0", "exec.cleanup.1"} {
+		job, err := dag.NewJob(name, "", exec, &exec.Spec.Tasks[1], index, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		vertices = append(vertices, graph.NewVertex(graph.NewJobInfo(job, false, genev1alpha1.JobTaskType, nil), false))
+		status := util.InitializeVertexStatus(name, genev1alpha1.VertexRunning, "", nil)
+		exec.Status.Vertices[status.ID] = status
+	}
+	g.AppendVertices(vertices)
+
+	vertices[0].Data.Finished = true
+	util.MarkVertexSuccess(exec, "exec.cleanup.0", "success")
+	finishExitHandler(exec, g)
+	if util.IsExecutionCompleted(exec) {
+		t.Fatalf("expected execution to wait for all exit handlers")
+	}
+
+	vertices[1].Data.Finished = true
+	util.MarkVertexFailed(exec, "exec.cleanup.1", "failed")
+	finishExitHandler(exec, g)
+	if exec.Status.Phase != genev1alpha1.VertexFailed {
+		t.Errorf("expected failed execution for failed exit handler, got %s", exec.Status.Phase)
+	}
+}
+
+func TestStartExitHandlerWaitsForRunningJobs(t *testing.T) {
+	exec := newExitHandlerExecution()
+	exec.UID = "exec-uid"
+	exec.Status.Phase = genev1alpha1.VertexRunning
+	exec.Status.DAGPhase = genev1alpha1.VertexFailed
+	g, err := dag.NewGraph(exec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	execIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	execIndexer.Add(exec)
+	jobIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	// the job of align 0 failed, the one of align 1 is running.
+	failed := g.FindVertexByName("exec.align.0").Data.Job.DeepCopy()
+	failed.Status.Conditions = []batch.JobCondition{{Type: batch.JobFailed, Status: v1.ConditionTrue}}
+	jobIndexer.Add(failed)
+	jobIndexer.Add(g.FindVertexByName("exec.align.1").Data.Job)
+	e := &ExecutionJobController{
+		jobLister:       batchv1listers.NewJobLister(jobIndexer),
+		executionLister: genelisters.NewExecutionLister(execIndexer),
+	}
+
+	if running, err := e.runningDAGJobs(exec); err != nil || running != 1 {
+		t.Errorf("expected 1 running job, got %d with %v", running, err)
+	}
+	if err := e.startExitHandler(g, "default/exec"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if g.FindVertexByName("exec.cleanup.0") != nil {
+		t.Errorf("expected the exit handler to wait for the running job")
+	}
+}
@@ -0,0 +1,575 @@
+/*
+Copyright 2019 The Kubegene Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"container/heap"
+	"container/list"
+	"fmt"
+	"sync"
+	"time"
+
+	batch "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	genev1alpha1 "kubegene.io/kubegene/pkg/apis/gene/v1alpha1"
+	genelisters "kubegene.io/kubegene/pkg/client/listers/gene/v1alpha1"
+	"kubegene.io/kubegene/pkg/util"
+)
+
+// ExceedConcurrencyError is returned when a job waits in the cluster queue
+// for the running jobs of the cluster to drop below the concurrency limit.
+var ExceedConcurrencyError = fmt.Errorf("running jobs have reached the cluster concurrency limit")
+
+const (
+	// pendingJobTTL is how long a job stays in the cluster queue without
+	// being retried. The jobs are retried every 10s while they wait, the jobs
+	// that are not retried anymore belong to executions that have been
+	// deleted or are held back by their execution parallelism.
+	pendingJobTTL = 30 * time.Second
+
+	// dispatchedJobTTL is how long a dispatched job is counted as running
+	// before it shows up in the job informer.
+	dispatchedJobTTL = 30 * time.Second
+)
+
+// UserAnnotation is the annotation of an execution with the user who created
+// it. The controller can not tell who created an execution, the annotation is
+// only trusted if an admission webhook of the cluster sets it and overwrites
+// the value given by the user.
+const UserAnnotation = "kubegene.io/user"
+
+// FairShareConfig configures the cluster queue of the jobs.
+type FairShareConfig struct {
+	// MaxRunningJobs is the maximum number of running jobs of the cluster,
+	// the queue is disabled if it is not greater than 0.
+	MaxRunningJobs int
+	// NamespaceWeights and UserWeights are the weights of the share of the
+	// running jobs of the namespaces and the users, 1 if not specified.
+	NamespaceWeights map[string]int
+	UserWeights      map[string]int
+	// TrustUserAnnotation shares the running jobs of a namespace between the
+	// users of the UserAnnotation of the executions. If it is false, the user
+	// of an execution is its namespace.
+	TrustUserAnnotation bool
+	// MaxPriority is the max priority of the executions of the namespaces
+	// that are not in NamespaceMaxPriorities, a higher priority is lowered
+	// to it.
+	MaxPriority            int32
+	NamespaceMaxPriorities map[string]int32
+}
+
+// queuedJob is a job waiting in the cluster queue, or a running job.
+type queuedJob struct {
+	key       string
+	namespace string
+	user      string
+	execution string
+	priority  int32
+	enqueued  time.Time
+	lastSeen  time.Time
+	// admitted is the time the job was dispatched.
+	admitted time.Time
+	// node is the leaf of a waiting job in the tournament, and element is
+	// the element of a waiting or dispatched job in the list it is in.
+	node    *fairNode
+	element *list.Element
+}
+
+// fairShareUsage is the number of running jobs of the namespaces, the users
+// and the executions.
+type fairShareUsage struct {
+	total      int
+	namespaces map[string]int
+	users      map[string]int
+	executions map[string]int
+}
+
+func newFairShareUsage() *fairShareUsage {
+	return &fairShareUsage{
+		namespaces: make(map[string]int),
+		users:      make(map[string]int),
+		executions: make(map[string]int),
+	}
+}
+
+func (u *fairShareUsage) add(namespace, user, execution string) {
+	u.total++
+	u.namespaces[namespace]++
+	u.users[user]++
+	u.executions[execution]++
+}
+
+func (u *fairShareUsage) remove(namespace, user, execution string) {
+	u.total--
+	decrement(u.namespaces, namespace)
+	decrement(u.users, user)
+	decrement(u.executions, execution)
+}
+
+func decrement(counts map[string]int, name string) {
+	if counts[name] <= 1 {
+		delete(counts, name)
+		return
+	}
+	counts[name]--
+}
+
+// fairNode is a node of the tournament of the waiting jobs. The children of
+// the root are the namespaces, then come the users of every namespace, the
+// executions of every user and the waiting jobs of every execution. The
+// children of a node are a heap ordered by their best waiting jobs, so the
+// best waiting job of the cluster is found in O(log n).
+type fairNode struct {
+	name   string
+	level  int
+	parent *fairNode
+	// index is the position of the node in the heap of its parent, -1 while
+	// the node is taken out of it.
+	index    int
+	children []*fairNode
+	// names are the children keyed by their names.
+	names map[string]*fairNode
+	// job is the waiting job of a leaf.
+	job *queuedJob
+}
+
+const (
+	rootLevel = iota
+	namespaceLevel
+	userLevel
+	executionLevel
+	jobLevel
+)
+
+// best returns the best waiting job under the node.
+func (n *fairNode) best() *queuedJob {
+	for n.job == nil {
+		n = n.children[0]
+	}
+	return n.job
+}
+
+// fairHeap orders the children of a node by their best waiting jobs given
+// the running jobs of the cluster.
+type fairHeap struct {
+	queue *fairQueue
+	node  *fairNode
+}
+
+func (h fairHeap) Len() int { return len(h.node.children) }
+
+func (h fairHeap) Less(i, j int) bool {
+	return h.queue.less(h.node.children[i].best(), h.node.children[j].best(), h.queue.usage)
+}
+
+func (h fairHeap) Swap(i, j int) {
+	children := h.node.children
+	children[i], children[j] = children[j], children[i]
+	children[i].index = i
+	children[j].index = j
+}
+
+func (h fairHeap) Push(x interface{}) {
+	child := x.(*fairNode)
+	child.index = len(h.node.children)
+	h.node.children = append(h.node.children, child)
+}
+
+func (h fairHeap) Pop() interface{} {
+	children := h.node.children
+	child := children[len(children)-1]
+	children[len(children)-1] = nil
+	h.node.children = children[:len(children)-1]
+	child.index = -1
+	return child
+}
+
+// fairQueue dispatches the jobs of all the executions under a cluster
+// concurrency limit. The waiting jobs are ordered by the priority of their
+// execution, then by the share of the running jobs of their namespace, of
+// their user and of their execution, and then by the time they were queued.
+type fairQueue struct {
+	sync.Mutex
+	config          FairShareConfig
+	executionLister genelisters.ExecutionLister
+	// pending are the waiting jobs keyed by the job key, pendingList orders
+	// them by the time they were last seen and root is their tournament.
+	pending     map[string]*queuedJob
+	pendingList *list.List
+	root        *fairNode
+	// userNodes are the nodes of every user in the namespaces.
+	userNodes map[string]map[*fairNode]bool
+	// dispatched are the jobs that have been given a slot keyed by the job
+	// key, until they show up in the job informer. dispatchedList orders
+	// them by the time they were admitted.
+	dispatched     map[string]*queuedJob
+	dispatchedList *list.List
+	// running are the unfinished jobs of the executions keyed by the job
+	// key, they are kept up to date from the events of the job informer.
+	running map[string]*queuedJob
+	// usage counts the running and the dispatched jobs.
+	usage *fairShareUsage
+	now   func() time.Time
+}
+
+func newFairQueue(config FairShareConfig, executionLister genelisters.ExecutionLister) *fairQueue {
+	return &fairQueue{
+		config:          config,
+		executionLister: executionLister,
+		pending:         make(map[string]*queuedJob),
+		pendingList:     list.New(),
+		root:            &fairNode{level: rootLevel, index: -1, names: make(map[string]*fairNode)},
+		userNodes:       make(map[string]map[*fairNode]bool),
+		dispatched:      make(map[string]*queuedJob),
+		dispatchedList:  list.New(),
+		running:         make(map[string]*queuedJob),
+		usage:           newFairShareUsage(),
+		now:             time.Now,
+	}
+}
+
+// userOf returns the user the running jobs of the execution are accounted to.
+func (q *fairQueue) userOf(execution *genev1alpha1.Execution) string {
+	if q.config.TrustUserAnnotation {
+		if user := execution.Annotations[UserAnnotation]; len(user) != 0 {
+			return user
+		}
+	}
+	return execution.Namespace
+}
+
+// priorityOf returns the priority of the execution capped by the max
+// priority of its namespace.
+func (q *fairQueue) priorityOf(execution *genev1alpha1.Execution) int32 {
+	maxPriority, ok := q.config.NamespaceMaxPriorities[execution.Namespace]
+	if !ok {
+		maxPriority = q.config.MaxPriority
+	}
+	if execution.Spec.Priority > maxPriority {
+		return maxPriority
+	}
+	return execution.Spec.Priority
+}
+
+// admit queues the job and returns whether it is its turn to be created.
+// The jobs that are not admitted must be retried until they are, the jobs
+// that are admitted are counted as running until they show up in the job
+// informer or are released.
+func (q *fairQueue) admit(job *batch.Job) (bool, error) {
+	controllerRef := metav1.GetControllerOf(job)
+	if controllerRef == nil {
+		return true, nil
+	}
+
+	q.Lock()
+	defer q.Unlock()
+
+	now := q.now()
+	key := util.KeyOf(job)
+	if entry, ok := q.dispatched[key]; ok {
+		entry.admitted = now
+		q.dispatchedList.MoveToBack(entry.element)
+		return true, nil
+	}
+	q.expire(now)
+
+	entry, ok := q.pending[key]
+	if ok {
+		entry.lastSeen = now
+		q.pendingList.MoveToBack(entry.element)
+	} else {
+		execution, err := q.executionLister.Executions(job.Namespace).Get(controllerRef.Name)
+		if err != nil {
+			return false, err
+		}
+		q.addPending(&queuedJob{
+			key:       key,
+			namespace: job.Namespace,
+			user:      q.userOf(execution),
+			execution: util.KeyOf(execution),
+			priority:  q.priorityOf(execution),
+			enqueued:  now,
+			lastSeen:  now,
+		})
+	}
+
+	q.grant(now)
+	_, ok = q.dispatched[key]
+	return ok, nil
+}
+
+// grant hands the free slots out to the waiting jobs in the fair-share order.
+// The jobs that get a slot are admitted when they are retried.
+func (q *fairQueue) grant(now time.Time) {
+	for q.usage.total < q.config.MaxRunningJobs && len(q.root.children) > 0 {
+		next := q.root.best()
+		q.removePending(next)
+		q.dispatch(next, now)
+	}
+}
+
+// release removes a dispatched job that failed to be created from the
+// running jobs. The job is queued again when it is retried.
+func (q *fairQueue) release(job *batch.Job) {
+	q.Lock()
+	defer q.Unlock()
+	if entry, ok := q.dispatched[util.KeyOf(job)]; ok {
+		q.undispatch(entry)
+	}
+}
+
+// observeJob accounts a job added or updated in the job informer, the job is
+// running until it finishes or is deleted.
+func (q *fairQueue) observeJob(job *batch.Job) {
+	controllerRef := metav1.GetControllerOf(job)
+	if controllerRef == nil || controllerRef.Kind != execKind.Kind {
+		return
+	}
+	if job.DeletionTimestamp != nil || util.IsJobFinished(job) {
+		q.forgetJob(job)
+		return
+	}
+
+	q.Lock()
+	defer q.Unlock()
+	key := util.KeyOf(job)
+	if _, ok := q.running[key]; ok {
+		return
+	}
+	// a dispatched job is already counted in the usage.
+	if entry, ok := q.dispatched[key]; ok {
+		delete(q.dispatched, key)
+		q.dispatchedList.Remove(entry.element)
+		entry.element = nil
+		q.running[key] = entry
+		return
+	}
+	entry := &queuedJob{key: key, namespace: job.Namespace, execution: job.Namespace + "/" + controllerRef.Name}
+	entry.user = job.Namespace
+	if execution, err := q.executionLister.Executions(job.Namespace).Get(controllerRef.Name); err == nil {
+		entry.user = q.userOf(execution)
+	}
+	q.running[key] = entry
+	q.changeUsage(entry, true)
+}
+
+// forgetJob stops accounting a job that has finished or has been deleted.
+func (q *fairQueue) forgetJob(job *batch.Job) {
+	q.Lock()
+	defer q.Unlock()
+	key := util.KeyOf(job)
+	if entry, ok := q.dispatched[key]; ok {
+		q.undispatch(entry)
+	}
+	if entry, ok := q.running[key]; ok {
+		delete(q.running, key)
+		q.changeUsage(entry, false)
+	}
+}
+
+// expire drops the waiting jobs that are not retried anymore and the
+// dispatched jobs that did not show up in the job informer in time.
+func (q *fairQueue) expire(now time.Time) {
+	for front := q.pendingList.Front(); front != nil; front = q.pendingList.Front() {
+		entry := front.Value.(*queuedJob)
+		if now.Sub(entry.lastSeen) <= pendingJobTTL {
+			break
+		}
+		q.removePending(entry)
+	}
+	for front := q.dispatchedList.Front(); front != nil; front = q.dispatchedList.Front() {
+		entry := front.Value.(*queuedJob)
+		if now.Sub(entry.admitted) <= dispatchedJobTTL {
+			break
+		}
+		q.undispatch(entry)
+	}
+}
+
+// addPending queues a waiting job. The job is seen last, so it goes to the
+// back of the pending list.
+func (q *fairQueue) addPending(entry *queuedJob) {
+	namespace := q.child(q.root, entry.namespace, namespaceLevel)
+	user := q.child(namespace, entry.user, userLevel)
+	execution := q.child(user, entry.execution, executionLevel)
+	nodes := []*fairNode{namespace, user, execution}
+	q.takeOut(nodes)
+	entry.node = &fairNode{name: entry.key, level: jobLevel, parent: execution, index: -1, job: entry}
+	q.putBack(append(nodes, entry.node))
+
+	q.pending[entry.key] = entry
+	entry.element = q.pendingList.PushBack(entry)
+}
+
+// removePending takes a waiting job out of the queue.
+func (q *fairQueue) removePending(entry *queuedJob) {
+	execution := entry.node.parent
+	user := execution.parent
+	nodes := []*fairNode{user.parent, user, execution}
+	q.takeOut(append(nodes, entry.node))
+	q.putBack(nodes)
+	entry.node = nil
+
+	delete(q.pending, entry.key)
+	q.pendingList.Remove(entry.element)
+	entry.element = nil
+}
+
+// dispatch gives a slot to a waiting job taken out of the queue.
+func (q *fairQueue) dispatch(entry *queuedJob, now time.Time) {
+	entry.admitted = now
+	q.dispatched[entry.key] = entry
+	entry.element = q.dispatchedList.PushBack(entry)
+	q.changeUsage(entry, true)
+}
+
+// undispatch takes the slot of a dispatched job back.
+func (q *fairQueue) undispatch(entry *queuedJob) {
+	delete(q.dispatched, entry.key)
+	q.dispatchedList.Remove(entry.element)
+	entry.element = nil
+	q.changeUsage(entry, false)
+}
+
+// changeUsage adds or removes a running job of the namespace, the user and
+// the execution of the job. The nodes ordered by their running jobs are taken
+// out of their heaps while the usage changes and are put back after.
+func (q *fairQueue) changeUsage(entry *queuedJob, add bool) {
+	var namespaces, users, executions []*fairNode
+	namespace := q.root.names[entry.namespace]
+	if namespace != nil {
+		namespaces = append(namespaces, namespace)
+		if user := namespace.names[entry.user]; user != nil {
+			if execution := user.names[entry.execution]; execution != nil {
+				executions = append(executions, execution)
+			}
+		}
+	}
+	// a trusted user may have executions in several namespaces.
+	for user := range q.userNodes[entry.user] {
+		users = append(users, user)
+		if user.parent != namespace {
+			namespaces = append(namespaces, user.parent)
+		}
+	}
+	nodes := append(append(namespaces, users...), executions...)
+
+	q.takeOut(nodes)
+	if add {
+		q.usage.add(entry.namespace, entry.user, entry.execution)
+	} else {
+		q.usage.remove(entry.namespace, entry.user, entry.execution)
+	}
+	q.putBack(nodes)
+}
+
+// child returns the child of the node with the name, a new child is not in
+// the heap of the node until it is put back.
+func (q *fairQueue) child(node *fairNode, name string, level int) *fairNode {
+	if child, ok := node.names[name]; ok {
+		return child
+	}
+	child := &fairNode{name: name, level: level, parent: node, index: -1, names: make(map[string]*fairNode)}
+	node.names[name] = child
+	if level == userLevel {
+		if q.userNodes[name] == nil {
+			q.userNodes[name] = make(map[*fairNode]bool)
+		}
+		q.userNodes[name][child] = true
+	}
+	return child
+}
+
+// takeOut takes the nodes out of the heaps of their parents. The parents must
+// come before their children, so that every heap stays ordered while the best
+// jobs of the nodes change.
+func (q *fairQueue) takeOut(nodes []*fairNode) {
+	for _, node := range nodes {
+		if node.index >= 0 {
+			heap.Remove(fairHeap{q, node.parent}, node.index)
+		}
+	}
+}
+
+// putBack puts the nodes back in the heaps of their parents in the reverse
+// order. The nodes left without waiting jobs are dropped.
+func (q *fairQueue) putBack(nodes []*fairNode) {
+	for i := len(nodes) - 1; i >= 0; i-- {
+		node := nodes[i]
+		if node.index >= 0 {
+			continue
+		}
+		if node.job == nil && len(node.children) == 0 {
+			delete(node.parent.names, node.name)
+			if node.level == userLevel {
+				delete(q.userNodes[node.name], node)
+				if len(q.userNodes[node.name]) == 0 {
+					delete(q.userNodes, node.name)
+				}
+			}
+			continue
+		}
+		heap.Push(fairHeap{q, node.parent}, node)
+	}
+}
+
+// less returns whether the job a goes before the job b given the running
+// jobs of the cluster.
+func (q *fairQueue) less(a, b *queuedJob, usage *fairShareUsage) bool {
+	if a.priority != b.priority {
+		return a.priority > b.priority
+	}
+	if a.namespace != b.namespace {
+		if c := compareShares(usage.namespaces[a.namespace], weightOf(q.config.NamespaceWeights, a.namespace),
+			usage.namespaces[b.namespace], weightOf(q.config.NamespaceWeights, b.namespace)); c != 0 {
+			return c < 0
+		}
+	}
+	if a.user != b.user {
+		if c := compareShares(usage.users[a.user], weightOf(q.config.UserWeights, a.user),
+			usage.users[b.user], weightOf(q.config.UserWeights, b.user)); c != 0 {
+			return c < 0
+		}
+	}
+	if a.execution != b.execution && usage.executions[a.execution] != usage.executions[b.execution] {
+		return usage.executions[a.execution] < usage.executions[b.execution]
+	}
+	if !a.enqueued.Equal(b.enqueued) {
+		return a.enqueued.Before(b.enqueued)
+	}
+	return a.key < b.key
+}
+
+// compareShares compares the running jobs per weight of two shares.
+func compareShares(runningA, weightA, runningB, weightB int) int {
+	a, b := runningA*weightB, runningB*weightA
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func weightOf(weights map[string]int, name string) int {
+	if weight, ok := weights[name]; ok && weight > 0 {
+		return weight
+	}
+	return 1
+}
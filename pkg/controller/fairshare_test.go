@@ -0,0 +1,358 @@
+/*
+Copyright 2019 The Kubegene Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	batch "k8s.io/api/batch/v1"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	genev1alpha1 "kubegene.io/kubegene/pkg/apis/gene/v1alpha1"
+	genelisters "kubegene.io/kubegene/pkg/client/listers/gene/v1alpha1"
+	"kubegene.io/kubegene/pkg/dag"
+)
+
+type fairShareTestExec struct {
+	namespace, name, user string
+	priority              int32
+}
+
+func newFairShareTestJob(exec *genev1alpha1.Execution, name string) *batch.Job {
+	task := &genev1alpha1.Task{Name: "call", Type: genev1alpha1.JobTaskType, Image: "gatk"}
+	job, _ := dag.NewJob(exec.Name+".call."+name, "gatk", exec, task, 0, 0)
+	return job
+}
+
+// newTestFairQueue returns a queue with the running jobs of the executions.
+// The user of an execution is set in both its spec and its user annotation,
+// only the annotation is trusted if the config says so.
+func newTestFairQueue(config FairShareConfig, execs []fairShareTestExec, running map[string]int) (*fairQueue, map[string]*genev1alpha1.Execution) {
+	execIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	executions := make(map[string]*genev1alpha1.Execution)
+	for _, e := range execs {
+		exec := &genev1alpha1.Execution{
+			ObjectMeta: metav1.ObjectMeta{Name: e.name, Namespace: e.namespace, Annotations: map[string]string{UserAnnotation: e.user}},
+			Spec:       genev1alpha1.ExecutionSpec{User: e.user, Priority: e.priority},
+		}
+		execIndexer.Add(exec)
+		executions[e.name] = exec
+	}
+	queue := newFairQueue(config, genelisters.NewExecutionLister(execIndexer))
+	for _, e := range execs {
+		for i := 0; i < running[e.name]; i++ {
+			queue.observeJob(newFairShareTestJob(executions[e.name], "running"+string(rune('a'+i))))
+		}
+	}
+	return queue, executions
+}
+
+func TestFairQueueAdmit(t *testing.T) {
+	testCases := []struct {
+		name    string
+		config  FairShareConfig
+		execs   []fairShareTestExec
+		running map[string]int
+		// queued are the executions of the jobs in the order they are queued.
+		queued []string
+		// admitted are the executions whose jobs are admitted.
+		admitted []string
+	}{
+		{
+			name:     "the cluster is full",
+			config:   FairShareConfig{MaxRunningJobs: 2},
+			execs:    []fairShareTestExec{{"default", "a", "alice", 0}},
+			running:  map[string]int{"a": 2},
+			queued:   []string{"a"},
+			admitted: nil,
+		},
+		{
+			name:   "the namespace with less running jobs goes first",
+			config: FairShareConfig{MaxRunningJobs: 3},
+			execs: []fairShareTestExec{
+				{"genomics", "a", "alice", 0},
+				{"research", "b", "bob", 0},
+			},
+			running:  map[string]int{"a": 2},
+			queued:   []string{"a", "b"},
+			admitted: []string{"b"},
+		},
+		{
+			name:   "the namespace weights scale the shares",
+			config: FairShareConfig{MaxRunningJobs: 4, NamespaceWeights: map[string]int{"genomics": 3}},
+			execs: []fairShareTestExec{
+				{"genomics", "a", "alice", 0},
+				{"research", "b", "bob", 0},
+			},
+			running:  map[string]int{"a": 2, "b": 1},
+			queued:   []string{"b", "a"},
+			admitted: []string{"a"},
+		},
+		{
+			name:   "the users share the namespace",
+			config: FairShareConfig{MaxRunningJobs: 3, TrustUserAnnotation: true},
+			execs: []fairShareTestExec{
+				{"default", "a", "alice", 0},
+				{"default", "b", "alice", 0},
+				{"default", "c", "bob", 0},
+			},
+			running:  map[string]int{"a": 1, "b": 1},
+			queued:   []string{"a", "c"},
+			admitted: []string{"c"},
+		},
+		{
+			name:   "the user annotation is not trusted by default",
+			config: FairShareConfig{MaxRunningJobs: 4},
+			execs: []fairShareTestExec{
+				{"default", "a", "alice", 0},
+				{"default", "b", "alice", 0},
+				{"default", "c", "bob", 0},
+			},
+			running:  map[string]int{"a": 1, "b": 1, "c": 1},
+			queued:   []string{"a", "c"},
+			admitted: []string{"a"},
+		},
+		{
+			name:   "the priority goes before the share",
+			config: FairShareConfig{MaxRunningJobs: 3, MaxPriority: 10},
+			execs: []fairShareTestExec{
+				{"genomics", "a", "alice", 10},
+				{"research", "b", "bob", 0},
+			},
+			running:  map[string]int{"a": 2},
+			queued:   []string{"b", "a"},
+			admitted: []string{"a"},
+		},
+		{
+			name:   "the priority is capped by the max priority of the namespace",
+			config: FairShareConfig{MaxRunningJobs: 3, NamespaceMaxPriorities: map[string]int32{"research": 5}},
+			execs: []fairShareTestExec{
+				{"genomics", "a", "alice", 10},
+				{"research", "b", "bob", 10},
+			},
+			running:  map[string]int{"a": 2},
+			queued:   []string{"a", "b"},
+			admitted: []string{"b"},
+		},
+		{
+			name:   "the free slots are handed out in order",
+			config: FairShareConfig{MaxRunningJobs: 2},
+			execs: []fairShareTestExec{
+				{"default", "a", "alice", 0},
+				{"default", "b", "bob", 0},
+				{"default", "c", "carol", 0},
+			},
+			queued:   []string{"a", "b", "c"},
+			admitted: []string{"a", "b"},
+		},
+	}
+
+	for _, tc := range testCases {
+		queue, executions := newTestFairQueue(tc.config, tc.execs, tc.running)
+		now := time.Now()
+		queue.now = func() time.Time { return now }
+
+		var jobs []*batch.Job
+		for _, name := range tc.queued {
+			// queue every job first, then retry them as the controller does.
+			job := newFairShareTestJob(executions[name], "pending")
+			jobs = append(jobs, job)
+			queue.Lock()
+			queue.addPending(&queuedJob{
+				key:       job.Namespace + "/" + job.Name,
+				namespace: job.Namespace,
+				user:      queue.userOf(executions[name]),
+				execution: job.Namespace + "/" + name,
+				priority:  queue.priorityOf(executions[name]),
+				enqueued:  now,
+				lastSeen:  now,
+			})
+			queue.Unlock()
+			now = now.Add(time.Second)
+		}
+
+		var admitted []string
+		for i, job := range jobs {
+			ok, err := queue.admit(job)
+			if err != nil {
+				t.Fatalf("%s: unexpected error: %v", tc.name, err)
+			}
+			if ok {
+				admitted = append(admitted, tc.queued[i])
+			}
+		}
+		if len(admitted) != len(tc.admitted) {
+			t.Errorf("%s: expected admitted %v, got %v", tc.name, tc.admitted, admitted)
+			continue
+		}
+		for i := range admitted {
+			if admitted[i] != tc.admitted[i] {
+				t.Errorf("%s: expected admitted %v, got %v", tc.name, tc.admitted, admitted)
+				break
+			}
+		}
+	}
+}
+
+func TestFairQueueDispatched(t *testing.T) {
+	queue, executions := newTestFairQueue(FairShareConfig{MaxRunningJobs: 1},
+		[]fairShareTestExec{{"default", "a", "alice", 0}}, nil)
+	now := time.Now()
+	queue.now = func() time.Time { return now }
+
+	first := newFairShareTestJob(executions["a"], "0")
+	second := newFairShareTestJob(executions["a"], "1")
+	if ok, _ := queue.admit(first); !ok {
+		t.Fatalf("expected the first job to be admitted")
+	}
+	// the dispatched job is counted as running before it shows up in the informer.
+	if ok, _ := queue.admit(second); ok {
+		t.Errorf("expected the second job to wait for the dispatched job")
+	}
+
+	// a released job frees its slot.
+	queue.release(first)
+	if ok, _ := queue.admit(second); !ok {
+		t.Errorf("expected the second job to be admitted after the first is released")
+	}
+
+	// the dispatched job expires if it never shows up in the informer.
+	now = now.Add(dispatchedJobTTL + time.Second)
+	if ok, _ := queue.admit(first); !ok {
+		t.Errorf("expected the first job to be admitted after the dispatched job expired")
+	}
+}
+
+func TestFairQueueObserveJob(t *testing.T) {
+	queue, executions := newTestFairQueue(FairShareConfig{MaxRunningJobs: 1},
+		[]fairShareTestExec{{"default", "a", "alice", 0}}, nil)
+
+	first := newFairShareTestJob(executions["a"], "0")
+	second := newFairShareTestJob(executions["a"], "1")
+	if ok, _ := queue.admit(first); !ok {
+		t.Fatalf("expected the first job to be admitted")
+	}
+	// the dispatched job is running once it shows up in the informer.
+	queue.observeJob(first)
+	queue.observeJob(first)
+	if len(queue.dispatched) != 0 || queue.usage.total != 1 {
+		t.Errorf("expected 1 running job and no dispatched job, got %d and %d", queue.usage.total, len(queue.dispatched))
+	}
+	if ok, _ := queue.admit(second); ok {
+		t.Errorf("expected the second job to wait for the running job")
+	}
+
+	// a finished job frees its slot.
+	finished := first.DeepCopy()
+	finished.Status.Conditions = []batch.JobCondition{{Type: batch.JobComplete, Status: v1.ConditionTrue}}
+	queue.observeJob(finished)
+	if queue.usage.total != 0 || len(queue.usage.executions) != 0 {
+		t.Errorf("expected no running job, got %d of %v", queue.usage.total, queue.usage.executions)
+	}
+	if ok, _ := queue.admit(second); !ok {
+		t.Errorf("expected the second job to be admitted after the first finished")
+	}
+
+	// a deleted job frees its slot.
+	queue.observeJob(second)
+	queue.forgetJob(second)
+	if queue.usage.total != 0 || len(queue.running) != 0 {
+		t.Errorf("expected no running job, got %d", queue.usage.total)
+	}
+}
+
+func TestFairQueueGrantOrder(t *testing.T) {
+	var execs []fairShareTestExec
+	running := make(map[string]int)
+	for i := 0; i < 12; i++ {
+		name := string(rune('a' + i))
+		// the users have executions in several namespaces.
+		execs = append(execs, fairShareTestExec{
+			namespace: []string{"default", "research", "prod"}[i%3],
+			name:      name,
+			user:      []string{"alice", "bob"}[i%2],
+			priority:  int32(i % 4 / 3),
+		})
+		running[name] = i % 3
+	}
+	config := FairShareConfig{
+		MaxRunningJobs:      100,
+		NamespaceWeights:    map[string]int{"research": 2},
+		UserWeights:         map[string]int{"bob": 3},
+		TrustUserAnnotation: true,
+		MaxPriority:         1,
+	}
+	queue, executions := newTestFairQueue(config, execs, running)
+	now := time.Now()
+	for i := 0; i < 60; i++ {
+		exec := executions[execs[i*7%len(execs)].name]
+		key := fmt.Sprintf("%s/%s.call.%d", exec.Namespace, exec.Name, i)
+		queue.addPending(&queuedJob{
+			key:       key,
+			namespace: exec.Namespace,
+			user:      queue.userOf(exec),
+			execution: exec.Namespace + "/" + exec.Name,
+			priority:  queue.priorityOf(exec),
+			enqueued:  now.Add(time.Duration(i%5) * time.Second),
+			lastSeen:  now,
+		})
+	}
+
+	// the expected order picks the best waiting job among all of them for
+	// every slot.
+	usage := newFairShareUsage()
+	for _, entry := range queue.running {
+		usage.add(entry.namespace, entry.user, entry.execution)
+	}
+	candidates := make(map[string]*queuedJob)
+	for key, entry := range queue.pending {
+		candidates[key] = entry
+	}
+	var expected []string
+	for len(candidates) > 0 {
+		var next *queuedJob
+		for _, candidate := range candidates {
+			if next == nil || queue.less(candidate, next, usage) {
+				next = candidate
+			}
+		}
+		expected = append(expected, next.key)
+		delete(candidates, next.key)
+		usage.add(next.namespace, next.user, next.execution)
+	}
+
+	queue.grant(now)
+	var granted []string
+	for element := queue.dispatchedList.Front(); element != nil; element = element.Next() {
+		granted = append(granted, element.Value.(*queuedJob).key)
+	}
+	if len(granted) != len(expected) {
+		t.Fatalf("expected %d granted jobs, got %d", len(expected), len(granted))
+	}
+	for i := range expected {
+		if granted[i] != expected[i] {
+			t.Fatalf("%d: expected job %s to be granted, got %s", i, expected[i], granted[i])
+		}
+	}
+	if len(queue.pending) != 0 || len(queue.root.children) != 0 || len(queue.userNodes) != 0 {
+		t.Errorf("expected no waiting job to be left in the queue")
+	}
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2019 The Kubegene Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestLockExecution(t *testing.T) {
+	gb := NewGraphBuilder()
+
+	// the syncs of an execution are serialized.
+	var wg sync.WaitGroup
+	running, maxRunning := 0, 0
+	var mu sync.Mutex
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			gb.LockExecution("default/exec")
+			defer gb.UnlockExecution("default/exec")
+			mu.Lock()
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			mu.Unlock()
+			mu.Lock()
+			running--
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	if maxRunning != 1 {
+		t.Errorf("expected the syncs of an execution to be serialized, got %d at once", maxRunning)
+	}
+
+	// different executions are not blocked by each other.
+	gb.LockExecution("default/exec")
+	done := make(chan struct{})
+	go func() {
+		gb.LockExecution("default/other")
+		gb.UnlockExecution("default/other")
+		close(done)
+	}()
+	<-done
+	gb.UnlockExecution("default/exec")
+
+	if len(gb.execLocks) != 0 {
+		t.Errorf("expected the locks to be removed, got %v", gb.execLocks)
+	}
+}
@@ -0,0 +1,185 @@
+/*
+Copyright 2019 The Kubegene Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/golang/glog"
+	batch "k8s.io/api/batch/v1"
+	"k8s.io/client-go/tools/cache"
+
+	genev1alpha1 "kubegene.io/kubegene/pkg/apis/gene/v1alpha1"
+	"kubegene.io/kubegene/pkg/common"
+	"kubegene.io/kubegene/pkg/dag"
+	"kubegene.io/kubegene/pkg/graph"
+	"kubegene.io/kubegene/pkg/util"
+)
+
+// createLoopJob creates the job of the given iteration of a loop vertex.
+// ${iteration} in the command of the task will be replaced with the index.
+func (e *ExecutionJobController) createLoopJob(vertex *graph.Vertex, key string, iteration int) error {
+	task := vertex.Data.DynamicJob
+
+	namespace, name, _ := cache.SplitMetaNamespaceKey(key)
+	execution, err := e.executionLister.Executions(namespace).Get(name)
+	if err != nil {
+		glog.Errorf("Get execution %s error: %v", key, err)
+		return err
+	}
+
+	command := common.ReplaceVariant(task.CommandSet[0], map[string]string{"iteration": strconv.Itoa(iteration)})
+	job, err := dag.NewJob(dag.JobName(execution.Name, task.Name, iteration), command, execution, task, 0, iteration)
+	if err != nil {
+		return e.markExecutionError(execution, err)
+	}
+
+	glog.V(2).Infof("create iteration %d of loop task %s", iteration, task.Name)
+	if err := e.createJob(job); err != nil {
+		if isWaitingError(err) {
+			return err
+		}
+		return fmt.Errorf("create job %s error: %v", util.KeyOf(job), err)
+	}
+
+	// the iteration is kept in the execution status, so that the loop is
+	// restored from it once the graph is rebuilt.
+	if util.GetVertexStatus(execution, job.Name) != nil {
+		return nil
+	}
+	exec := execution.DeepCopy()
+	status := jobVertexStatus(job, vertex)
+	if exec.Status.Vertices == nil {
+		exec.Status.Vertices = make(map[string]genev1alpha1.VertexStatus)
+	}
+	exec.Status.Vertices[status.ID] = status
+	return e.execUpdater.UpdateExecutionStatus(exec, execution)
+}
+
+// startLoopVertex starts the job of the given iteration of a vertex of a loop
+// once the vertices out of the loop it depends on have finished and the jobs
+// of the iteration of the vertices of the loop it depends on have succeeded.
+func (e *ExecutionJobController) startLoopVertex(g *graph.Graph, vertex *graph.Vertex, key string, iteration int) error {
+	if vertex.IsLoopSucceeded(iteration) {
+		return nil
+	}
+	for _, dependent := range g.FindDependentVertices(vertex) {
+		if dependent.Loop() == vertex.Loop() {
+			if !dependent.IsLoopSucceeded(iteration) {
+				return nil
+			}
+		} else if !dependent.IsFinished() {
+			return nil
+		}
+	}
+	glog.V(2).Infof("all dependent of loop job %v has run successfully, start iteration %d.", vertex.Data.Job.Name, iteration)
+
+	if !e.shouldStartJob(key, vertex.Data.Job) {
+		return ExceedParallelismError
+	}
+	if err := e.createLoopJob(vertex, key, iteration); err != nil {
+		if isWaitingError(err) {
+			return err
+		}
+		return fmt.Errorf("createLoopJob failed : %v", err)
+	}
+	return nil
+}
+
+// loopVertices returns the vertex of the loop task and the vertices of its body.
+func loopVertices(g *graph.Graph, loop *graph.Vertex) []*graph.Vertex {
+	vertices := make([]*graph.Vertex, 0)
+	for _, vertex := range g.Vertices() {
+		if vertex.Loop() == loop {
+			vertices = append(vertices, vertex)
+		}
+	}
+	return vertices
+}
+
+// finishLoop marks the vertices of the loop as finished once the loop task
+// has finished, every vertex is counted as a job of the graph that has succeeded.
+func finishLoop(g *graph.Graph, loop *graph.Vertex) {
+	for _, vertex := range loopVertices(g, loop) {
+		if vertex.IsFinished() {
+			continue
+		}
+		g.MarkFinished(vertex)
+		g.PlusNumOfSuccess()
+	}
+}
+
+// isLoopFinished checks whether a loop should stop after the job of the
+// given iteration of the loop task has run successfully.
+func (e *ExecutionJobController) isLoopFinished(vertex *graph.Vertex, job *batch.Job) (bool, error) {
+	loop := vertex.Loop().Data.DynamicJob.Loop
+	if vertex.GetLoopIteration()+1 >= int(loop.MaxIterations) {
+		glog.V(2).Infof("loop task %s reached max iterations %d", vertex.Data.DynamicJob.Name, loop.MaxIterations)
+		return true, nil
+	}
+	// loop until the iteration succeeds.
+	if len(loop.Until) == 0 {
+		return true, nil
+	}
+
+	result, err := e.getJobResult(job)
+	if err != nil {
+		return false, fmt.Errorf("getJobResult failed in isLoopFinished: %v", err)
+	}
+
+	return result == loop.Until, nil
+}
+
+// restoreLoopIterations restores the current iterations of the loops of a
+// rebuilt graph from the attempts of their jobs in the execution status, and
+// the vertices of the loops whose jobs of the current iteration have succeeded.
+// The iterations before the current one have been handled.
+func restoreLoopIterations(g *graph.Graph, execution *genev1alpha1.Execution) {
+	for _, loop := range g.Vertices() {
+		if loop.Loop() != loop {
+			continue
+		}
+		vertices := loopVertices(g, loop)
+		tasks := make(map[string]bool, len(vertices))
+		for _, vertex := range vertices {
+			tasks[vertex.Data.DynamicJob.Name] = true
+		}
+		iteration := 0
+		for _, status := range execution.Status.Vertices {
+			if status.Type != genev1alpha1.DAGVertexType && tasks[status.Task] && int(status.Attempt) > iteration {
+				iteration = int(status.Attempt)
+			}
+		}
+		loop.SetLoopIteration(iteration)
+
+		for _, vertex := range vertices {
+			name := dag.JobName(execution.Name, vertex.Data.DynamicJob.Name, iteration)
+			if status := util.GetVertexStatus(execution, name); status != nil && status.Phase == genev1alpha1.VertexSucceeded {
+				vertex.SetLoopSucceeded(iteration)
+			}
+		}
+		glog.V(2).Infof("restore loop task %s at iteration %d", loop.Data.DynamicJob.Name, iteration)
+	}
+}
+
+// shouldRetryLoop checks whether a failed iteration of a loop should be
+// retried instead of failing the execution.
+func shouldRetryLoop(vertex *graph.Vertex) bool {
+	loop := vertex.Loop().Data.DynamicJob.Loop
+	return len(loop.Until) == 0 && vertex.GetLoopIteration()+1 < int(loop.MaxIterations)
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2019 The Kubegene Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	genev1alpha1 "kubegene.io/kubegene/pkg/apis/gene/v1alpha1"
+	"kubegene.io/kubegene/pkg/dag"
+	"kubegene.io/kubegene/pkg/graph"
+	"kubegene.io/kubegene/pkg/util"
+)
+
+func TestShouldRetryLoop(t *testing.T) {
+	newLoopVertex := func(until string, max int32) *graph.Vertex {
+		task := &genev1alpha1.Task{Name: "a", Loop: &genev1alpha1.Loop{Until: until, MaxIterations: max}}
+		return graph.NewVertex(graph.NewJobInfo(nil, false, genev1alpha1.JobTaskType, task), true)
+	}
+
+	vertex := newLoopVertex("", 2)
+	if !shouldRetryLoop(vertex) {
+		t.Errorf("expected failed first iteration to be retried")
+	}
+	vertex.IncLoopIteration()
+	if shouldRetryLoop(vertex) {
+		t.Errorf("expected failed last iteration not to be retried")
+	}
+
+	vertex = newLoopVertex("COMPLETE", 2)
+	if shouldRetryLoop(vertex) {
+		t.Errorf("expected failed iteration of output loop not to be retried")
+	}
+}
+
+func TestRestoreLoopIterations(t *testing.T) {
+	exec := &genev1alpha1.Execution{
+		ObjectMeta: metav1.ObjectMeta{Name: "exec", Namespace: "default"},
+		Spec: genev1alpha1.ExecutionSpec{
+			Tasks: []genev1alpha1.Task{
+				{Name: "refine", CommandSet: []string{"refine"}},
+				{
+					Name:       "check",
+					CommandSet: []string{"check"},
+					Dependents: []genev1alpha1.Dependent{{Target: "refine", Type: genev1alpha1.DependTypeWhole}},
+					Loop:       &genev1alpha1.Loop{Until: "DONE", MaxIterations: 5, Body: []string{"refine"}},
+				},
+			},
+		},
+		Status: genev1alpha1.ExecutionStatus{Vertices: make(map[string]genev1alpha1.VertexStatus)},
+	}
+	for _, job := range []struct {
+		task    string
+		attempt int
+		phase   genev1alpha1.VertexPhase
+	}{
+		{"refine", 0, genev1alpha1.VertexSucceeded},
+		{"check", 0, genev1alpha1.VertexSucceeded},
+		{"refine", 1, genev1alpha1.VertexSucceeded},
+		{"check", 1, genev1alpha1.VertexRunning},
+	} {
+		status := util.InitializeVertexStatus(dag.JobName(exec.Name, job.task, job.attempt), job.phase, "", nil)
+		status.Task = job.task
+		status.Attempt = int32(job.attempt)
+		exec.Status.Vertices[status.ID] = status
+	}
+
+	g, err := dag.NewGraph(exec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restoreLoopIterations(g, exec)
+
+	check := g.FindVertexByName(dag.DynamicVertexName(exec.Name, "check"))
+	refine := g.FindVertexByName(dag.DynamicVertexName(exec.Name, "refine"))
+	if check == nil || refine == nil || refine.Loop() != check {
+		t.Fatalf("expected refine to be in the body of loop check, got %v and %v", refine, check)
+	}
+	// the body shares the iteration of the loop task.
+	if check.GetLoopIteration() != 1 || refine.GetLoopIteration() != 1 {
+		t.Fatalf("expected the loop to be restored at iteration 1, got %d", check.GetLoopIteration())
+	}
+	if !refine.IsLoopSucceeded(1) || check.IsLoopSucceeded(1) {
+		t.Errorf("expected only the job of refine to have succeeded in iteration 1")
+	}
+	// the vertices of the loop are counted once the loop has finished.
+	finishLoop(g, check)
+	if !refine.IsFinished() || !check.IsFinished() || !g.AllSucceeded() {
+		t.Errorf("expected the loop to be finished, got %d jobs succeeded of %d", g.GetNumOfSuccess(), g.VertexCount)
+	}
+}
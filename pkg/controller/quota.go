@@ -0,0 +1,332 @@
+/*
+Copyright 2019 The Kubegene Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	batch "k8s.io/api/batch/v1"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	batchv1listers "k8s.io/client-go/listers/batch/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+)
+
+// ExceedQuotaError is returned when a job does not fit the resource quotas of
+// its namespace, the job is created once the quotas have enough capacity.
+var ExceedQuotaError = fmt.Errorf("jobs have exhausted the resource quota of the namespace")
+
+const (
+	// quotaReservationTTL is how long the resources of a created job are
+	// reserved at most. The used resources of the quotas in the lister count
+	// the pods of the job only once the informer has synced them, the
+	// reservation prevents the jobs created in the meantime from exceeding
+	// the quotas.
+	quotaReservationTTL = 30 * time.Second
+
+	// resourceJobs is the object count quota of the jobs.
+	resourceJobs v1.ResourceName = "count/jobs.batch"
+)
+
+// quotaReservation is the resources reserved for a created job.
+type quotaReservation struct {
+	podSpec *v1.PodSpec
+	usage   v1.ResourceList
+	expires time.Time
+	// quotaVersions are the resource versions of the quotas when the job
+	// was reserved, keyed by the quota name.
+	quotaVersions map[string]string
+}
+
+// quotaTracker accounts the resource quotas of the namespaces before the jobs
+// are created, so that jobs wait for the quotas to free instead of having
+// their pods rejected.
+type quotaTracker struct {
+	sync.Mutex
+	quotaLister corelisters.ResourceQuotaLister
+	jobLister   batchv1listers.JobLister
+	// reservations are the reservations of the created jobs of every
+	// namespace keyed by the job name.
+	reservations map[string]map[string]quotaReservation
+	now          func() time.Time
+}
+
+func newQuotaTracker(quotaLister corelisters.ResourceQuotaLister, jobLister batchv1listers.JobLister) *quotaTracker {
+	return &quotaTracker{
+		quotaLister:  quotaLister,
+		jobLister:    jobLister,
+		reservations: make(map[string]map[string]quotaReservation),
+		now:          time.Now,
+	}
+}
+
+// tryReserve reserves the resources of the job if it fits the quotas of its
+// namespace. Otherwise it returns a message telling which quota is exceeded.
+func (t *quotaTracker) tryReserve(job *batch.Job) (string, error) {
+	quotas, err := t.quotaLister.ResourceQuotas(job.Namespace).List(labels.Everything())
+	if err != nil {
+		return "", err
+	}
+	// check the quotas in a stable order so that the message is stable.
+	sort.Slice(quotas, func(i, j int) bool { return quotas[i].Name < quotas[j].Name })
+
+	t.Lock()
+	defer t.Unlock()
+
+	now := t.now()
+	reservations := t.reservations[job.Namespace]
+	for name, reservation := range reservations {
+		if now.After(reservation.expires) {
+			delete(reservations, name)
+		}
+	}
+
+	podSpec := &job.Spec.Template.Spec
+	usage := jobResourceUsage(job)
+	quotaVersions := make(map[string]string, len(quotas))
+	for _, quota := range quotas {
+		quotaVersions[quota.Name] = quota.ResourceVersion
+		if !quotaMatchesPod(quota, podSpec) {
+			continue
+		}
+		// the reservations that the used resources of the quota count already.
+		counted := make(map[string]bool)
+		for name, reservation := range reservations {
+			counted[name] = t.isCounted(job.Namespace, name, reservation, quota)
+		}
+		hard := quota.Status.Hard
+		if len(hard) == 0 {
+			// the quota controller has not synced the quota yet.
+			hard = quota.Spec.Hard
+		}
+		for _, name := range sortedResourceNames(hard) {
+			requested, ok := usage[name]
+			if !ok || (name == resourceJobs && quotaHasScopes(quota)) {
+				continue
+			}
+			used := quota.Status.Used[name].DeepCopy()
+			for reserved, reservation := range reservations {
+				if !counted[reserved] && quotaMatchesPod(quota, reservation.podSpec) {
+					used.Add(reservation.usage[name])
+				}
+			}
+			total := used.DeepCopy()
+			total.Add(requested)
+			limit := hard[name]
+			if total.Cmp(limit) > 0 {
+				return fmt.Sprintf("job %s exceeds quota %s: requested %s=%s, used %s=%s, limited %s=%s",
+					job.Name, quota.Name, name, requested.String(), name, used.String(), name, limit.String()), nil
+			}
+		}
+	}
+
+	if reservations == nil {
+		reservations = make(map[string]quotaReservation)
+		t.reservations[job.Namespace] = reservations
+	}
+	reservations[job.Name] = quotaReservation{
+		podSpec:       podSpec,
+		usage:         usage,
+		expires:       now.Add(quotaReservationTTL),
+		quotaVersions: quotaVersions,
+	}
+	return "", nil
+}
+
+// isCounted returns whether the used resources of the quota count the reserved
+// job. The api server counts the pods in the quota when it admits them, so the
+// quota counts the job once its pods have been created and the quota in the
+// lister has been updated since the job was reserved.
+func (t *quotaTracker) isCounted(namespace, name string, reservation quotaReservation, quota *v1.ResourceQuota) bool {
+	if t.jobLister == nil || reservation.quotaVersions[quota.Name] == quota.ResourceVersion {
+		return false
+	}
+	job, err := t.jobLister.Jobs(namespace).Get(name)
+	if err != nil {
+		return false
+	}
+	return job.Status.Active+job.Status.Succeeded+job.Status.Failed > 0
+}
+
+// release releases the resources reserved for a job that is not created.
+func (t *quotaTracker) release(job *batch.Job) {
+	t.Lock()
+	defer t.Unlock()
+	delete(t.reservations[job.Namespace], job.Name)
+}
+
+// jobResourceUsage returns the usage of the job in the resource names of the
+// quotas, the pods of the job run in parallel.
+func jobResourceUsage(job *batch.Job) v1.ResourceList {
+	pods := int64(1)
+	if job.Spec.Parallelism != nil {
+		pods = int64(*job.Spec.Parallelism)
+	}
+
+	usage := v1.ResourceList{}
+	for name, quantity := range podResourceUsage(&job.Spec.Template.Spec) {
+		total := resource.Quantity{Format: quantity.Format}
+		for i := int64(0); i < pods; i++ {
+			total.Add(quantity)
+		}
+		usage[name] = total
+	}
+	usage[v1.ResourcePods] = *resource.NewQuantity(pods, resource.DecimalSI)
+	usage[resourceJobs] = *resource.NewQuantity(1, resource.DecimalSI)
+	return usage
+}
+
+// podResourceUsage returns the usage of a pod in the resource names of the
+// quotas, such as requests.cpu and limits.memory. The request of a resource
+// defaults to its limit like the defaulting of the api server, and the init
+// containers count with the maximum of them as they run one by one.
+func podResourceUsage(podSpec *v1.PodSpec) v1.ResourceList {
+	requests, limits := v1.ResourceList{}, v1.ResourceList{}
+	for _, container := range podSpec.Containers {
+		containerRequests, containerLimits := containerResources(container)
+		addResourceList(requests, containerRequests)
+		addResourceList(limits, containerLimits)
+	}
+	for _, container := range podSpec.InitContainers {
+		containerRequests, containerLimits := containerResources(container)
+		maxResourceList(requests, containerRequests)
+		maxResourceList(limits, containerLimits)
+	}
+
+	usage := v1.ResourceList{}
+	for name, quantity := range requests {
+		usage[v1.ResourceName(v1.DefaultResourceRequestsPrefix+string(name))] = quantity
+		if name == v1.ResourceCPU || name == v1.ResourceMemory || name == v1.ResourceEphemeralStorage {
+			usage[name] = quantity
+		}
+	}
+	for name, quantity := range limits {
+		usage[v1.ResourceName("limits."+string(name))] = quantity
+	}
+	return usage
+}
+
+func containerResources(container v1.Container) (v1.ResourceList, v1.ResourceList) {
+	requests := v1.ResourceList{}
+	for name, quantity := range container.Resources.Limits {
+		requests[name] = quantity
+	}
+	for name, quantity := range container.Resources.Requests {
+		requests[name] = quantity
+	}
+	return requests, container.Resources.Limits
+}
+
+func addResourceList(list, other v1.ResourceList) {
+	for name, quantity := range other {
+		total := list[name]
+		total.Add(quantity)
+		list[name] = total
+	}
+}
+
+func maxResourceList(list, other v1.ResourceList) {
+	for name, quantity := range other {
+		if current, ok := list[name]; !ok || quantity.Cmp(current) > 0 {
+			list[name] = quantity
+		}
+	}
+}
+
+func sortedResourceNames(list v1.ResourceList) []v1.ResourceName {
+	names := make([]v1.ResourceName, 0, len(list))
+	for name := range list {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
+
+func quotaHasScopes(quota *v1.ResourceQuota) bool {
+	return len(quota.Spec.Scopes) != 0 || (quota.Spec.ScopeSelector != nil && len(quota.Spec.ScopeSelector.MatchExpressions) != 0)
+}
+
+// quotaMatchesPod returns whether the scopes of the quota match the pod.
+func quotaMatchesPod(quota *v1.ResourceQuota, podSpec *v1.PodSpec) bool {
+	for _, scope := range quota.Spec.Scopes {
+		selector := v1.ScopedResourceSelectorRequirement{ScopeName: scope, Operator: v1.ScopeSelectorOpExists}
+		if !scopeMatchesPod(selector, podSpec) {
+			return false
+		}
+	}
+	if quota.Spec.ScopeSelector != nil {
+		for _, selector := range quota.Spec.ScopeSelector.MatchExpressions {
+			if !scopeMatchesPod(selector, podSpec) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func scopeMatchesPod(selector v1.ScopedResourceSelectorRequirement, podSpec *v1.PodSpec) bool {
+	switch selector.ScopeName {
+	case v1.ResourceQuotaScopeTerminating:
+		return podSpec.ActiveDeadlineSeconds != nil
+	case v1.ResourceQuotaScopeNotTerminating:
+		return podSpec.ActiveDeadlineSeconds == nil
+	case v1.ResourceQuotaScopeBestEffort:
+		return isBestEffort(podSpec)
+	case v1.ResourceQuotaScopeNotBestEffort:
+		return !isBestEffort(podSpec)
+	case v1.ResourceQuotaScopePriorityClass:
+		switch selector.Operator {
+		case v1.ScopeSelectorOpIn:
+			return sets.NewString(selector.Values...).Has(podSpec.PriorityClassName)
+		case v1.ScopeSelectorOpNotIn:
+			return !sets.NewString(selector.Values...).Has(podSpec.PriorityClassName)
+		case v1.ScopeSelectorOpDoesNotExist:
+			return len(podSpec.PriorityClassName) == 0
+		default:
+			return len(podSpec.PriorityClassName) != 0
+		}
+	}
+	return false
+}
+
+// isBestEffort returns whether no container of the pod has any requests or
+// limits of cpu and memory.
+func isBestEffort(podSpec *v1.PodSpec) bool {
+	for _, container := range append(append([]v1.Container{}, podSpec.Containers...), podSpec.InitContainers...) {
+		for _, list := range []v1.ResourceList{container.Resources.Requests, container.Resources.Limits} {
+			for name := range list {
+				if name == v1.ResourceCPU || name == v1.ResourceMemory {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// isQuotaExceededError returns whether the api server rejects a request
+// because of the resource quotas.
+func isQuotaExceededError(err error) bool {
+	return errors.IsForbidden(err) && strings.Contains(err.Error(), "exceeded quota")
+}
@@ -0,0 +1,286 @@
+/*
+Copyright 2019 The Kubegene Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	batch "k8s.io/api/batch/v1"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	batchv1listers "k8s.io/client-go/listers/batch/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+
+	genev1alpha1 "kubegene.io/kubegene/pkg/apis/gene/v1alpha1"
+	genelisters "kubegene.io/kubegene/pkg/client/listers/gene/v1alpha1"
+	"kubegene.io/kubegene/pkg/dag"
+	"kubegene.io/kubegene/pkg/util"
+)
+
+func newQuotaTestJob(name, cpu string) *batch.Job {
+	exec := &genev1alpha1.Execution{
+		ObjectMeta: metav1.ObjectMeta{Name: "exec", Namespace: "default"},
+	}
+	task := &genev1alpha1.Task{
+		Name:      "call",
+		Type:      genev1alpha1.JobTaskType,
+		Image:     "gatk",
+		Resources: genev1alpha1.ResourceRequirements{Cpu: resource.MustParse(cpu)},
+	}
+	job, _ := dag.NewJob(name, "gatk", exec, task, 0, 0)
+	return job
+}
+
+func newTestQuotaTracker(quotas ...*v1.ResourceQuota) (*quotaTracker, cache.Indexer, cache.Indexer) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, quota := range quotas {
+		indexer.Add(quota)
+	}
+	jobIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	tracker := newQuotaTracker(corelisters.NewResourceQuotaLister(indexer), batchv1listers.NewJobLister(jobIndexer))
+	return tracker, indexer, jobIndexer
+}
+
+func TestPodResourceUsage(t *testing.T) {
+	podSpec := &v1.PodSpec{
+		Containers: []v1.Container{
+			{Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("1")},
+				Limits:   v1.ResourceList{v1.ResourceMemory: resource.MustParse("2Gi")},
+			}},
+			{Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("500m"), "nvidia.com/gpu": resource.MustParse("1")},
+			}},
+		},
+		InitContainers: []v1.Container{
+			{Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("2")},
+			}},
+		},
+	}
+
+	usage := podResourceUsage(podSpec)
+	expected := map[v1.ResourceName]string{
+		"requests.cpu":            "2",
+		"cpu":                     "2",
+		"requests.memory":         "2Gi",
+		"memory":                  "2Gi",
+		"limits.memory":           "2Gi",
+		"requests.nvidia.com/gpu": "1",
+	}
+	if len(usage) != len(expected) {
+		t.Errorf("expected usage %v, got %v", expected, usage)
+	}
+	for name, value := range expected {
+		if quantity := usage[name]; quantity.Cmp(resource.MustParse(value)) != 0 {
+			t.Errorf("expected %s %s, got %s", name, value, quantity.String())
+		}
+	}
+}
+
+func TestQuotaTrackerTryReserve(t *testing.T) {
+	quota := &v1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: "research", Namespace: "default"},
+		Status: v1.ResourceQuotaStatus{
+			Hard: v1.ResourceList{"requests.cpu": resource.MustParse("4"), v1.ResourcePods: resource.MustParse("10")},
+			Used: v1.ResourceList{"requests.cpu": resource.MustParse("2"), v1.ResourcePods: resource.MustParse("2")},
+		},
+	}
+	tracker, _, _ := newTestQuotaTracker(quota)
+	now := time.Now()
+	tracker.now = func() time.Time { return now }
+
+	for _, name := range []string{"exec.call.0", "exec.call.1"} {
+		message, err := tracker.tryReserve(newQuotaTestJob(name, "1"))
+		if err != nil || len(message) != 0 {
+			t.Fatalf("expected job %s to fit the quota, got %q, %v", name, message, err)
+		}
+	}
+
+	// the reservations of the created jobs exhaust the quota.
+	message, err := tracker.tryReserve(newQuotaTestJob("exec.call.2", "1"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(message, "research") || !strings.Contains(message, "requests.cpu") {
+		t.Errorf("expected the quota research to be exceeded, got %q", message)
+	}
+
+	tracker.release(newQuotaTestJob("exec.call.1", "1"))
+	if message, _ := tracker.tryReserve(newQuotaTestJob("exec.call.2", "1")); len(message) != 0 {
+		t.Errorf("expected the job to fit after a reservation is released, got %q", message)
+	}
+
+	// the reservations expire once the quota controller has counted the pods.
+	now = now.Add(quotaReservationTTL + time.Second)
+	if message, _ := tracker.tryReserve(newQuotaTestJob("exec.call.3", "2")); len(message) != 0 {
+		t.Errorf("expected the job to fit after the reservations expired, got %q", message)
+	}
+}
+
+func TestQuotaMatchesPod(t *testing.T) {
+	deadline := int64(60)
+	testCases := []struct {
+		name    string
+		spec    v1.ResourceQuotaSpec
+		podSpec v1.PodSpec
+		expect  bool
+	}{
+		{name: "no scopes", expect: true},
+		{
+			name:   "not terminating",
+			spec:   v1.ResourceQuotaSpec{Scopes: []v1.ResourceQuotaScope{v1.ResourceQuotaScopeNotTerminating}},
+			expect: true,
+		},
+		{
+			name:    "terminating",
+			spec:    v1.ResourceQuotaSpec{Scopes: []v1.ResourceQuotaScope{v1.ResourceQuotaScopeTerminating}},
+			podSpec: v1.PodSpec{ActiveDeadlineSeconds: &deadline},
+			expect:  true,
+		},
+		{
+			name:   "best effort",
+			spec:   v1.ResourceQuotaSpec{Scopes: []v1.ResourceQuotaScope{v1.ResourceQuotaScopeNotBestEffort}},
+			expect: false,
+		},
+		{
+			name: "priority class in",
+			spec: v1.ResourceQuotaSpec{ScopeSelector: &v1.ScopeSelector{MatchExpressions: []v1.ScopedResourceSelectorRequirement{
+				{ScopeName: v1.ResourceQuotaScopePriorityClass, Operator: v1.ScopeSelectorOpIn, Values: []string{"high"}},
+			}}},
+			podSpec: v1.PodSpec{PriorityClassName: "low"},
+			expect:  false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		quota := &v1.ResourceQuota{Spec: testCase.spec}
+		if got := quotaMatchesPod(quota, &testCase.podSpec); got != testCase.expect {
+			t.Errorf("%s: expected %v, got %v", testCase.name, testCase.expect, got)
+		}
+	}
+}
+
+type fakeExecutionUpdater struct {
+	updated   []*genev1alpha1.Execution
+	originals []*genev1alpha1.Execution
+}
+
+func (f *fakeExecutionUpdater) UpdateExecutionStatus(modified, original *genev1alpha1.Execution) error {
+	f.updated = append(f.updated, modified)
+	f.originals = append(f.originals, original)
+	return nil
+}
+
+func (f *fakeExecutionUpdater) UpdateExecution(modified, original *genev1alpha1.Execution) error {
+	f.updated = append(f.updated, modified)
+	return nil
+}
+
+func TestQuotaTrackerCountedReservation(t *testing.T) {
+	quota := &v1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: "research", Namespace: "default", ResourceVersion: "1"},
+		Status: v1.ResourceQuotaStatus{
+			Hard: v1.ResourceList{"requests.cpu": resource.MustParse("4")},
+			Used: v1.ResourceList{"requests.cpu": resource.MustParse("1")},
+		},
+	}
+	tracker, quotaIndexer, jobIndexer := newTestQuotaTracker(quota)
+
+	job := newQuotaTestJob("exec.call.0", "2")
+	if message, _ := tracker.tryReserve(job); len(message) != 0 {
+		t.Fatalf("expected the job to fit the quota, got %q", message)
+	}
+
+	// the job has not started any pod, it is not counted by the quota yet.
+	updated := quota.DeepCopy()
+	updated.ResourceVersion = "2"
+	quotaIndexer.Update(updated)
+	jobIndexer.Add(job)
+	if message, _ := tracker.tryReserve(newQuotaTestJob("exec.call.1", "2")); len(message) == 0 {
+		t.Fatalf("expected the reservation to exhaust the quota")
+	}
+
+	// the quota counts the pods of the job, the reservation is not counted twice.
+	started := job.DeepCopy()
+	started.Status.Active = 1
+	jobIndexer.Update(started)
+	updated = updated.DeepCopy()
+	updated.ResourceVersion = "3"
+	updated.Status.Used["requests.cpu"] = resource.MustParse("3")
+	quotaIndexer.Update(updated)
+	if message, _ := tracker.tryReserve(newQuotaTestJob("exec.call.1", "1")); len(message) != 0 {
+		t.Errorf("expected the job to fit once the quota counts the reservation, got %q", message)
+	}
+}
+
+func TestMarkThrottled(t *testing.T) {
+	execution := &genev1alpha1.Execution{
+		ObjectMeta: metav1.ObjectMeta{Name: "exec", Namespace: "default", UID: "uid"},
+	}
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	indexer.Add(execution)
+	updater := &fakeExecutionUpdater{}
+	recorder := record.NewFakeRecorder(10)
+	e := &ExecutionJobController{
+		executionLister: genelisters.NewExecutionLister(indexer),
+		execUpdater:     updater,
+		eventRecorder:   recorder,
+	}
+	job, err := dag.NewJob("exec.call.0", "gatk", execution, &genev1alpha1.Task{Name: "call"}, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// an execution that has never been throttled is not updated.
+	e.markThrottled(job, false, "")
+	if len(updater.updated) != 0 {
+		t.Fatalf("expected no update, got %d", len(updater.updated))
+	}
+
+	e.markThrottled(job, true, "used requests.cpu=1")
+	if len(updater.updated) != 1 {
+		t.Fatalf("expected the execution to be updated, got %d updates", len(updater.updated))
+	}
+	throttled := updater.updated[0]
+	condition := util.GetExecutionCondition(throttled, genev1alpha1.ExecutionThrottled)
+	if condition == nil || condition.Status != v1.ConditionTrue || condition.Message != ExceedQuotaError.Error() {
+		t.Fatalf("expected a true throttled condition, got %v", condition)
+	}
+	if event := <-recorder.Events; !strings.Contains(event, "used requests.cpu=1") {
+		t.Errorf("expected the usage of the quota in an event, got %q", event)
+	}
+
+	// the condition is not updated again while the usage changes.
+	indexer.Update(throttled)
+	e.markThrottled(job, true, "used requests.cpu=2")
+	if len(updater.updated) != 1 {
+		t.Fatalf("expected the condition to stay the same, got %d updates", len(updater.updated))
+	}
+	<-recorder.Events
+
+	e.markThrottled(job, false, "")
+	condition = util.GetExecutionCondition(updater.updated[len(updater.updated)-1], genev1alpha1.ExecutionThrottled)
+	if len(updater.updated) != 2 || condition.Status != v1.ConditionFalse {
+		t.Errorf("expected the throttled condition to be false, got %v", condition)
+	}
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2019 The Kubegene Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	genev1alpha1 "kubegene.io/kubegene/pkg/apis/gene/v1alpha1"
+	"kubegene.io/kubegene/pkg/dag"
+	"kubegene.io/kubegene/pkg/graph"
+	"kubegene.io/kubegene/pkg/util"
+)
+
+// subWorkflowVertexName returns the name of the vertex status of a nested workflow.
+// e.g. the nested workflow qc/align of execution exec is exec.qc/align. The path
+// is kept as is, a job name can not contain a slash.
+func subWorkflowVertexName(execName, path string) string {
+	return execName + dag.Separator + path
+}
+
+// subWorkflowTasks returns the tasks of every nested workflow, including the tasks
+// of its own nested workflows.
+func subWorkflowTasks(exec *genev1alpha1.Execution) map[string]map[string]bool {
+	members := make(map[string]map[string]bool)
+	for _, task := range exec.Spec.Tasks {
+		if len(task.SubWorkflow) == 0 {
+			continue
+		}
+		items := strings.Split(task.SubWorkflow, "/")
+		for i := range items {
+			path := strings.Join(items[:i+1], "/")
+			if members[path] == nil {
+				members[path] = make(map[string]bool)
+			}
+			members[path][task.Name] = true
+		}
+	}
+	return members
+}
+
+// rollUpSubWorkflows updates the DAG vertex status of every nested workflow
+// according to the status of the jobs of its tasks.
+func rollUpSubWorkflows(exec *genev1alpha1.Execution, g *graph.Graph) {
+	members := subWorkflowTasks(exec)
+	if len(members) == 0 {
+		return
+	}
+
+	// a task is finished only if all of its vertices are finished.
+	finished := make(map[string]bool)
+	for _, vertex := range g.Vertices() {
+		task := dag.JobTask(vertex.Data.Job)
+		if _, ok := finished[task]; !ok {
+			finished[task] = true
+		}
+		if !vertex.IsFinished() {
+			finished[task] = false
+		}
+	}
+
+	for path, tasks := range members {
+		var phase genev1alpha1.VertexPhase
+		var startedAt metav1.Time
+		children := make([]string, 0)
+		failed, errored := false, false
+		for _, vertex := range exec.Status.Vertices {
+			if vertex.Type == genev1alpha1.DAGVertexType || !tasks[vertex.Task] {
+				continue
+			}
+			children = append(children, vertex.ID)
+			if startedAt.IsZero() || vertex.StartedAt.Before(&startedAt) {
+				startedAt = vertex.StartedAt
+			}
+			switch vertex.Phase {
+			case genev1alpha1.VertexFailed:
+				failed = true
+			case genev1alpha1.VertexError:
+				errored = true
+			}
+		}
+		// no job of the nested workflow has been started.
+		if len(children) == 0 {
+			continue
+		}
+
+		finishedCnt := 0
+		for task := range tasks {
+			if finished[task] {
+				finishedCnt++
+			}
+		}
+
+		switch {
+		case failed:
+			phase = genev1alpha1.VertexFailed
+		case errored:
+			phase = genev1alpha1.VertexError
+		case finishedCnt == len(tasks):
+			phase = genev1alpha1.VertexSucceeded
+		case util.IsExecutionCompleted(exec):
+			// the execution stopped before the nested workflow finished.
+			phase = exec.Status.Phase
+		default:
+			phase = genev1alpha1.VertexRunning
+		}
+		sort.Strings(children)
+
+		name := subWorkflowVertexName(exec.Name, path)
+		status := util.GetVertexStatus(exec, name)
+		if status == nil {
+			status = &genev1alpha1.VertexStatus{
+				ID:        util.VertexId(name),
+				Name:      name,
+				Type:      genev1alpha1.DAGVertexType,
+				StartedAt: startedAt,
+			}
+		}
+		status.Children = children
+		exec.Status.Vertices[status.ID] = *status
+		util.MarkVertexPhase(exec, name, phase, fmt.Sprintf("%d/%d tasks finished", finishedCnt, len(tasks)))
+	}
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2019 The Kubegene Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	genev1alpha1 "kubegene.io/kubegene/pkg/apis/gene/v1alpha1"
+	"kubegene.io/kubegene/pkg/dag"
+	"kubegene.io/kubegene/pkg/util"
+)
+
+func newSubWorkflowExecution() *genev1alpha1.Execution {
+	return &genev1alpha1.Execution{
+		ObjectMeta: metav1.ObjectMeta{Name: "exec", Namespace: "default"},
+		Spec: genev1alpha1.ExecutionSpec{
+			Tasks: []genev1alpha1.Task{
+				{Name: "prepare", CommandSet: []string{"prepare"}},
+				{Name: "align-bwa", CommandSet: []string{"bwa"}, SubWorkflow: "align"},
+				{Name: "align-qc-fastqc", CommandSet: []string{"fastqc"}, SubWorkflow: "align/qc"},
+			},
+		},
+		Status: genev1alpha1.ExecutionStatus{
+			Vertices: make(map[string]genev1alpha1.VertexStatus),
+		},
+	}
+}
+
+func TestRollUpSubWorkflows(t *testing.T) {
+	exec := newSubWorkflowExecution()
+	g, err := dag.NewGraph(exec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	markJob := func(name string, phase genev1alpha1.VertexPhase) {
+		vertex := g.FindVertexByName(name)
+		status := util.InitializeVertexStatus(name, phase, "", nil)
+		status.Task = dag.JobTask(vertex.Data.Job)
+		exec.Status.Vertices[status.ID] = status
+		if phase == genev1alpha1.VertexSucceeded {
+			vertex.Data.Finished = true
+		}
+	}
+	expectPhase := func(name string, phase genev1alpha1.VertexPhase) {
+		status := util.GetVertexStatus(exec, name)
+		if status == nil {
+			if len(phase) != 0 {
+				t.Errorf("expected %s phase %s, but not found", name, phase)
+			}
+			return
+		}
+		if status.Type != genev1alpha1.DAGVertexType {
+			t.Errorf("expected %s type %s, got %s", name, genev1alpha1.DAGVertexType, status.Type)
+		}
+		if status.Phase != phase {
+			t.Errorf("expected %s phase %s, got %s", name, phase, status.Phase)
+		}
+	}
+
+	markJob("exec.prepare.0", genev1alpha1.VertexSucceeded)
+	rollUpSubWorkflows(exec, g)
+	expectPhase("exec.align", "")
+	expectPhase("exec.align/qc", "")
+
+	markJob("exec.align-bwa.0", genev1alpha1.VertexSucceeded)
+	rollUpSubWorkflows(exec, g)
+	expectPhase("exec.align", genev1alpha1.VertexRunning)
+	expectPhase("exec.align/qc", "")
+
+	markJob("exec.align-qc-fastqc.0", genev1alpha1.VertexRunning)
+	rollUpSubWorkflows(exec, g)
+	expectPhase("exec.align", genev1alpha1.VertexRunning)
+	expectPhase("exec.align/qc", genev1alpha1.VertexRunning)
+
+	markJob("exec.align-qc-fastqc.0", genev1alpha1.VertexSucceeded)
+	rollUpSubWorkflows(exec, g)
+	expectPhase("exec.align", genev1alpha1.VertexSucceeded)
+	expectPhase("exec.align/qc", genev1alpha1.VertexSucceeded)
+}
+
+func TestRollUpSubWorkflowsFailed(t *testing.T) {
+	exec := newSubWorkflowExecution()
+	g, err := dag.NewGraph(exec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status := util.InitializeVertexStatus("exec.align-qc-fastqc.0", genev1alpha1.VertexFailed, "", nil)
+	status.Task = "align-qc-fastqc"
+	exec.Status.Vertices[status.ID] = status
+	rollUpSubWorkflows(exec, g)
+
+	for _, name := range []string{"exec.align", "exec.align/qc"} {
+		status := util.GetVertexStatus(exec, name)
+		if status == nil || status.Phase != genev1alpha1.VertexFailed {
+			t.Errorf("expected %s to be failed, got %v", name, status)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+/*
+Copyright 2019 The Kubegene Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+
+	genev1alpha1 "kubegene.io/kubegene/pkg/apis/gene/v1alpha1"
+	genelisters "kubegene.io/kubegene/pkg/client/listers/gene/v1alpha1"
+	"kubegene.io/kubegene/pkg/parser"
+)
+
+// needInstantiation returns whether the tasks of the execution should be
+// instantiated from its workflow template.
+func needInstantiation(execution *genev1alpha1.Execution) bool {
+	return execution.Spec.WorkflowTemplateRef != nil && len(execution.Spec.Tasks) == 0
+}
+
+// TemplateInstantiator instantiates the executions that reference workflow
+// templates in the cluster, the same as genectl does for the workflow files.
+type TemplateInstantiator struct {
+	templateLister genelisters.WorkflowTemplateLister
+	// loadTools loads the tools the jobs of the workflows in the namespace use.
+	loadTools func(namespace string) (map[string]parser.Tool, error)
+}
+
+// NewTemplateInstantiator returns an instantiator that resolves the tools
+// against the tool repo, or against the Tool and ClusterTool resources if the
+// tool repo is empty or "cluster".
+func NewTemplateInstantiator(templateLister genelisters.WorkflowTemplateLister, toolRepo string,
+	toolLister genelisters.ToolLister, clusterToolLister genelisters.ClusterToolLister) *TemplateInstantiator {
+	instantiator := &TemplateInstantiator{templateLister: templateLister}
+	if len(toolRepo) != 0 && toolRepo != parser.ClusterToolRepo {
+		instantiator.loadTools = cachedToolLoader(toolRepo, parser.LoadTools)
+		return instantiator
+	}
+
+	instantiator.loadTools = func(namespace string) (map[string]parser.Tool, error) {
+		clusterTools, err := clusterToolLister.List(labels.Everything())
+		if err != nil {
+			return nil, err
+		}
+		tools, err := toolLister.Tools(namespace).List(labels.Everything())
+		if err != nil {
+			return nil, err
+		}
+		return parser.TransToolResources2Map(derefClusterTools(clusterTools), derefTools(tools)), nil
+	}
+	return instantiator
+}
+
+// cachedToolLoader loads the tools of the tool repo on first use and reuses
+// them for the later instantiations, since loading a remote tool repo is a
+// download. A failed load is not cached, the next instantiation retries it.
+func cachedToolLoader(toolRepo string, load func(toolRepo string) (map[string]parser.Tool, error)) func(string) (map[string]parser.Tool, error) {
+	var mutex sync.Mutex
+	var tools map[string]parser.Tool
+	return func(namespace string) (map[string]parser.Tool, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if tools != nil {
+			return tools, nil
+		}
+		loaded, err := load(toolRepo)
+		if err != nil {
+			return nil, err
+		}
+		tools = loaded
+		return tools, nil
+	}
+}
+
+func derefClusterTools(clusterTools []*genev1alpha1.ClusterTool) []genev1alpha1.ClusterTool {
+	out := make([]genev1alpha1.ClusterTool, 0, len(clusterTools))
+	for _, clusterTool := range clusterTools {
+		out = append(out, *clusterTool)
+	}
+	return out
+}
+
+func derefTools(tools []*genev1alpha1.Tool) []genev1alpha1.Tool {
+	out := make([]genev1alpha1.Tool, 0, len(tools))
+	for _, tool := range tools {
+		out = append(out, *tool)
+	}
+	return out
+}
+
+// Instantiate merges the arguments with the inputs of the workflow template,
+// expands the jobs, resolves their tools, and fills in the tasks of the
+// execution. The other fields of the execution spec are kept, and the resolved
+// tools are recorded in the annotations of the execution.
+func (t *TemplateInstantiator) Instantiate(execution *genev1alpha1.Execution) error {
+	ref := execution.Spec.WorkflowTemplateRef
+	template, err := t.templateLister.WorkflowTemplates(execution.Namespace).Get(ref.Name)
+	if errors.IsNotFound(err) {
+		// the caller retries until the template is created.
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("get workflow template %s error: %v", ref.Name, err)
+	}
+
+	workflow, err := parser.UnmarshalWorkflow([]byte(template.Spec.Workflow))
+	if err != nil {
+		return fmt.Errorf("workflow template %s: %v", ref.Name, err)
+	}
+	parser.SetDefaultWorkflow(workflow)
+	if errList := parser.ValidateWorkflow(workflow); len(errList) > 0 {
+		return errorListToError(ref.Name, errList)
+	}
+	loader := templateLoader(t.templateLister, execution.Namespace)
+	if errList := parser.LoadSubWorkflowsWith(workflow, "", loader); len(errList) > 0 {
+		return errorListToError(ref.Name, errList)
+	}
+
+	tools, err := t.loadTools(execution.Namespace)
+	if err != nil {
+		return err
+	}
+	if err := parser.InstantiateWorkflow(workflow, ref.Arguments, tools); err != nil {
+		return fmt.Errorf("instantiate workflow template %s error: %v", ref.Name, err)
+	}
+	instance, err := parser.TransWorkflow2Execution(workflow)
+	if err != nil {
+		return fmt.Errorf("instantiate workflow template %s error: %v", ref.Name, err)
+	}
+
+	for key, value := range instance.Annotations {
+		if execution.Annotations == nil {
+			execution.Annotations = make(map[string]string)
+		}
+		execution.Annotations[key] = value
+	}
+	execution.Spec.Tasks = instance.Spec.Tasks
+	execution.Spec.OnExit = instance.Spec.OnExit
+	if execution.Spec.Parallelism == nil {
+		execution.Spec.Parallelism = instance.Spec.Parallelism
+	}
+	return nil
+}
+
+// templateLoader loads the nested workflows from the workflow templates in
+// the namespace, the catalog of a sub workflow is the name of a template.
+func templateLoader(templateLister genelisters.WorkflowTemplateLister, namespace string) parser.WorkflowLoader {
+	return func(sub *parser.SubWorkflow, baseDir string) (string, []byte, error) {
+		if len(sub.Catalog) == 0 {
+			return "", nil, fmt.Errorf("path %s is not supported in a workflow template, use catalog instead", sub.Path)
+		}
+		template, err := templateLister.WorkflowTemplates(namespace).Get(sub.Catalog)
+		if err != nil {
+			return "", nil, fmt.Errorf("get workflow template %s error: %v", sub.Catalog, err)
+		}
+		return sub.Catalog, []byte(template.Spec.Workflow), nil
+	}
+}
+
+func errorListToError(templateName string, errList parser.ErrorList) error {
+	messages := make([]string, 0, len(errList))
+	for _, err := range errList {
+		messages = append(messages, err.Error())
+	}
+	return fmt.Errorf("workflow template %s is not valid: %s", templateName, strings.Join(messages, "; "))
+}
@@ -0,0 +1,268 @@
+/*
+Copyright 2019 The Kubegene Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+
+	genev1alpha1 "kubegene.io/kubegene/pkg/apis/gene/v1alpha1"
+	"kubegene.io/kubegene/pkg/client/clientset/versioned/fake"
+	genelisters "kubegene.io/kubegene/pkg/client/listers/gene/v1alpha1"
+	"kubegene.io/kubegene/pkg/parser"
+)
+
+const mainWorkflow = `
+version: genecontainer_0_1
+inputs:
+  prefix:
+    default: out
+    type: string
+  sample:
+    default: sample1
+    type: string
+workflow:
+  prepare:
+    tool: nginx:latest
+    commands_iter:
+      command: echo ${prefix}-${1}
+      vars_iter:
+        - range(0, 2)
+  align:
+    sub_workflow:
+      catalog: align
+      inputs:
+        sample: ${sample}
+    depends:
+      - target: prepare
+        type: whole
+`
+
+const alignWorkflow = `
+version: genecontainer_0_1
+inputs:
+  sample:
+    type: string
+workflow:
+  bwa:
+    tool: nginx:latest
+    commands:
+      - bwa ${sample}
+`
+
+func newTestInstantiator(templates map[string]string) *TemplateInstantiator {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for name, workflow := range templates {
+		indexer.Add(&genev1alpha1.WorkflowTemplate{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       genev1alpha1.WorkflowTemplateSpec{Workflow: workflow},
+		})
+	}
+	return &TemplateInstantiator{
+		templateLister: genelisters.NewWorkflowTemplateLister(indexer),
+		loadTools: func(namespace string) (map[string]parser.Tool, error) {
+			return map[string]parser.Tool{
+				"nginx:latest": {Name: "nginx", Version: "latest", Image: "nginx:latest", Type: "basic"},
+			}, nil
+		},
+	}
+}
+
+func newTemplateExecution(name string, arguments map[string]interface{}) *genev1alpha1.Execution {
+	return &genev1alpha1.Execution{
+		ObjectMeta: metav1.ObjectMeta{Name: "exec", Namespace: "default"},
+		Spec: genev1alpha1.ExecutionSpec{
+			NodeSelector:        map[string]string{"disk": "ssd"},
+			WorkflowTemplateRef: &genev1alpha1.WorkflowTemplateRef{Name: name, Arguments: arguments},
+		},
+	}
+}
+
+func TestInstantiate(t *testing.T) {
+	instantiator := newTestInstantiator(map[string]string{"main": mainWorkflow, "align": alignWorkflow})
+	exec := newTemplateExecution("main", map[string]interface{}{"prefix": "result", "sample": "NA12878"})
+	if !needInstantiation(exec) {
+		t.Fatalf("expected the execution to be instantiated")
+	}
+
+	if err := instantiator.Instantiate(exec); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if needInstantiation(exec) {
+		t.Errorf("expected the execution not to be instantiated again")
+	}
+
+	commands := make(map[string][]string)
+	for _, task := range exec.Spec.Tasks {
+		commands[task.Name] = task.CommandSet
+	}
+	expect := map[string][]string{
+		"prepare":   {"echo result-0", "echo result-1"},
+		"align-bwa": {"bwa NA12878"},
+	}
+	if !reflect.DeepEqual(commands, expect) {
+		t.Errorf("expected commands %v, got %v", expect, commands)
+	}
+	if exec.Spec.Parallelism == nil {
+		t.Errorf("expected default parallelism")
+	}
+	if _, ok := exec.Annotations[parser.ResolvedToolsAnnotation]; !ok {
+		t.Errorf("expected the resolved tools to be recorded, got annotations %v", exec.Annotations)
+	}
+	if exec.Spec.NodeSelector["disk"] != "ssd" {
+		t.Errorf("expected the node selector of the execution to be kept, got %v", exec.Spec.NodeSelector)
+	}
+}
+
+func TestInstantiateError(t *testing.T) {
+	invalid := strings.Replace(mainWorkflow, "tool: nginx:latest", "tool: nginx", 1)
+	withPath := strings.Replace(mainWorkflow, "catalog: align", "path: align.yaml", 1)
+	instantiator := newTestInstantiator(map[string]string{
+		"invalid": invalid,
+		"path":    withPath,
+		"no-sub":  strings.Replace(mainWorkflow, "catalog: align", "catalog: missing", 1),
+	})
+
+	exec := newTemplateExecution("missing", nil)
+	if err := instantiator.Instantiate(exec); !errors.IsNotFound(err) {
+		t.Errorf("expected not found error for missing template, got %v", err)
+	}
+
+	for _, name := range []string{"invalid", "path", "no-sub"} {
+		exec := newTemplateExecution(name, nil)
+		err := instantiator.Instantiate(exec)
+		if err == nil || errors.IsNotFound(err) {
+			t.Errorf("%s: expected error, got %v", name, err)
+		}
+		if len(exec.Spec.Tasks) != 0 {
+			t.Errorf("%s: expected no tasks, got %d", name, len(exec.Spec.Tasks))
+		}
+	}
+}
+
+func TestInstantiateAfterTemplateCreated(t *testing.T) {
+	exec := newTemplateExecution("main", nil)
+	execIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	execIndexer.Add(exec)
+	templateIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	instantiator := newTestInstantiator(nil)
+	instantiator.templateLister = genelisters.NewWorkflowTemplateLister(templateIndexer)
+	client := fake.NewSimpleClientset(exec)
+	controller := &ExecutionController{
+		execClient:       client.ExecutionV1alpha1(),
+		execLister:       genelisters.NewExecutionLister(execIndexer),
+		execQueue:        workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+		instantiator:     instantiator,
+		execGraphBuilder: NewGraphBuilder(),
+	}
+	defer controller.execQueue.ShutDown()
+
+	// the execution waits for its workflow template.
+	if err := controller.syncExecution("default/exec"); err != nil {
+		t.Fatal(err)
+	}
+	if actions := actionsOf(client); len(actions) != 0 {
+		t.Fatalf("expected no actions before the template is created, got %v", actions)
+	}
+
+	for name, workflow := range map[string]string{"main": mainWorkflow, "align": alignWorkflow} {
+		template := &genev1alpha1.WorkflowTemplate{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       genev1alpha1.WorkflowTemplateSpec{Workflow: workflow},
+		}
+		templateIndexer.Add(template)
+		controller.enqueueTemplateExecutions(template)
+	}
+	if controller.execQueue.Len() != 1 {
+		t.Fatalf("expected the execution to be enqueued once, got %d", controller.execQueue.Len())
+	}
+	key, _ := controller.execQueue.Get()
+	if err := controller.syncExecution(key.(string)); err != nil {
+		t.Fatal(err)
+	}
+	if actions := actionsOf(client); !reflect.DeepEqual(actions, []string{"update/executions"}) {
+		t.Errorf("expected the instantiated execution to be updated, got %v", actions)
+	}
+}
+
+func TestInstantiateWithToolResources(t *testing.T) {
+	templateIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	templateIndexer.Add(&genev1alpha1.WorkflowTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "align", Namespace: "default"},
+		Spec:       genev1alpha1.WorkflowTemplateSpec{Workflow: alignWorkflow},
+	})
+	toolIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	toolIndexer.Add(&genev1alpha1.Tool{
+		ObjectMeta: metav1.ObjectMeta{Name: "nginx-latest", Namespace: "default"},
+		Spec:       genev1alpha1.ToolSpec{Name: "nginx", Version: "latest", Image: "registry/nginx:default"},
+	})
+	toolIndexer.Add(&genev1alpha1.Tool{
+		ObjectMeta: metav1.ObjectMeta{Name: "nginx-latest", Namespace: "other"},
+		Spec:       genev1alpha1.ToolSpec{Name: "nginx", Version: "latest", Image: "registry/nginx:other"},
+	})
+	clusterToolIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	clusterToolIndexer.Add(&genev1alpha1.ClusterTool{
+		ObjectMeta: metav1.ObjectMeta{Name: "nginx-latest"},
+		Spec:       genev1alpha1.ToolSpec{Name: "nginx", Version: "latest", Image: "registry/nginx:cluster"},
+	})
+
+	instantiator := NewTemplateInstantiator(genelisters.NewWorkflowTemplateLister(templateIndexer), "",
+		genelisters.NewToolLister(toolIndexer), genelisters.NewClusterToolLister(clusterToolIndexer))
+	exec := newTemplateExecution("align", map[string]interface{}{"sample": "NA12878"})
+	if err := instantiator.Instantiate(exec); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if image := exec.Spec.Tasks[0].Image; image != "registry/nginx:default" {
+		t.Errorf("expected the tool in the namespace of the execution, got image %s", image)
+	}
+}
+
+func TestCachedToolLoader(t *testing.T) {
+	loads := 0
+	fail := true
+	loader := cachedToolLoader("http://tools", func(toolRepo string) (map[string]parser.Tool, error) {
+		loads++
+		if fail {
+			return nil, fmt.Errorf("download error")
+		}
+		return map[string]parser.Tool{"nginx:latest": {Name: "nginx", Version: "latest"}}, nil
+	})
+
+	if _, err := loader("default"); err == nil {
+		t.Fatalf("expected the load error")
+	}
+	fail = false
+	for _, namespace := range []string{"default", "other"} {
+		tools, err := loader(namespace)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(tools) != 1 {
+			t.Errorf("expected 1 tool, got %d", len(tools))
+		}
+	}
+	if loads != 2 {
+		t.Errorf("expected the failed load to be retried and the tools to be cached, got %d loads", loads)
+	}
+}
@@ -18,9 +18,16 @@ package controller
 
 import (
 	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/util/validation"
 
 	genev1alpha1 "kubegene.io/kubegene/pkg/apis/gene/v1alpha1"
+	"kubegene.io/kubegene/pkg/common"
+	"kubegene.io/kubegene/pkg/dag"
 )
 
 // ValidateExecution accepts a execution and performs validation against it. If lint is specified as
@@ -36,18 +43,35 @@ func ValidateExecution(execution *genev1alpha1.Execution) error {
 	if len(execution.Spec.Tasks) == 0 {
 		return fmt.Errorf("tasks of execution must not be empty")
 	}
+	if err := validatePodSettings(execution.Spec.PodSettings); err != nil {
+		return err
+	}
 	if err := validateTasks(execution.Spec.Tasks); err != nil {
 		return err
 	}
-	if ok := validateNoCycle(execution); !ok {
-		return fmt.Errorf("dependents of execution exist cycle")
+	if err := validateOnExit(execution); err != nil {
+		return err
 	}
-	return nil
+	if err := validateLoopBodies(execution); err != nil {
+		return err
+	}
+	if err := validateWebhook(execution.Spec.Webhook); err != nil {
+		return err
+	}
+	return validateGraph(execution)
 }
 
-func validateNoCycle(execution *genev1alpha1.Execution) bool {
-	graph := newGraph(execution)
-	return graph.IsDAG()
+// validateGraph checks that the jobs of the execution can be built and that
+// the dependents of its tasks have no cycle.
+func validateGraph(execution *genev1alpha1.Execution) error {
+	graph, err := dag.NewGraph(execution)
+	if err != nil {
+		return err
+	}
+	if !graph.IsDAG() {
+		return fmt.Errorf("dependents of execution exist cycle")
+	}
+	return nil
 }
 
 func validateTasks(tasks []genev1alpha1.Task) error {
@@ -85,12 +109,261 @@ func validateTask(task genev1alpha1.Task, tasks []genev1alpha1.Task) error {
 	if task.Type != genev1alpha1.JobTaskType && task.Type != genev1alpha1.SparkTaskType {
 		return fmt.Errorf("wrong task type: %s", task.Type)
 	}
+	switch task.ImagePullPolicy {
+	case "", v1.PullAlways, v1.PullIfNotPresent, v1.PullNever:
+	default:
+		return fmt.Errorf("task imagePullPolicy %s is not valid", task.ImagePullPolicy)
+	}
+	if err := validateResources(task.Resources); err != nil {
+		return fmt.Errorf("task %s: %v", task.Name, err)
+	}
+	if err := validatePodSettings(task.PodSettings); err != nil {
+		return fmt.Errorf("task %s: %v", task.Name, err)
+	}
+	for name, volume := range task.Volumes {
+		if err := validateVolume(name, volume); err != nil {
+			return fmt.Errorf("task %s: %v", task.Name, err)
+		}
+	}
 	if len(task.Dependents) != 0 {
 		if err := validateDependents(task.Name, task.Dependents, tasks); err != nil {
 			return err
 		}
 	}
+	if task.Loop != nil {
+		if err := validateLoop(task); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
 
+func validateVolume(name string, volume genev1alpha1.Volume) error {
+	if len(volume.MountPath) == 0 {
+		return fmt.Errorf("volume %s mountPath must not be empty", name)
+	}
+	if sub := path.Clean(volume.SubPath); path.IsAbs(sub) || sub == ".." || strings.HasPrefix(sub, "../") {
+		return fmt.Errorf("volume %s subPath must be a relative path within the volume", name)
+	}
+	source := volume.MountFrom
+	sources := 0
+	for _, set := range []bool{len(source.Pvc) != 0, source.EmptyDir != nil, source.HostPath != nil,
+		source.ConfigMap != nil, source.Secret != nil, source.NFS != nil} {
+		if set {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return fmt.Errorf("volume %s must have exactly one source", name)
+	}
+	return nil
+}
+
+// validateNoHostPath rejects the hostPath volumes of the tasks, which give the
+// pods access to the nodes unless they are allowed by the controller.
+func validateNoHostPath(execution *genev1alpha1.Execution) error {
+	for _, task := range execution.Spec.Tasks {
+		for name, volume := range task.Volumes {
+			if volume.MountFrom.HostPath != nil {
+				return fmt.Errorf("task %s: hostPath volume %s is not allowed", task.Name, name)
+			}
+		}
+	}
+	return nil
+}
+
+func validateResources(res genev1alpha1.ResourceRequirements) error {
+	for _, list := range []v1.ResourceList{res.Requests, res.Limits} {
+		for name, quantity := range list {
+			if msgs := validation.IsQualifiedName(string(name)); len(msgs) > 0 {
+				return fmt.Errorf("resource name %s is not valid %v", name, msgs)
+			}
+			if quantity.Sign() < 0 {
+				return fmt.Errorf("resource %s must be greater than or equal to 0", name)
+			}
+		}
+	}
+	if res.Cpu.Sign() < 0 || res.Memory.Sign() < 0 {
+		return fmt.Errorf("resources cpu and memory must be greater than or equal to 0")
+	}
+
+	requests := dag.ResourceRequirementsOf(res).Requests
+	for name, limit := range res.Limits {
+		request, ok := requests[name]
+		if !ok {
+			continue
+		}
+		if limit.Cmp(request) < 0 {
+			return fmt.Errorf("resource %s limit %s must be greater than or equal to the request %s", name, limit.String(), request.String())
+		}
+		if dag.IsExtendedResourceName(name) && limit.Cmp(request) != 0 {
+			return fmt.Errorf("extended resource %s limit %s must equal the request %s", name, limit.String(), request.String())
+		}
+	}
+	return nil
+}
+
+func validatePodSettings(settings genev1alpha1.PodSettings) error {
+	if len(settings.ServiceAccountName) != 0 {
+		if msgs := validation.IsDNS1123Subdomain(settings.ServiceAccountName); len(msgs) > 0 {
+			return fmt.Errorf("serviceAccountName %s is not valid %v", settings.ServiceAccountName, msgs)
+		}
+	}
+	for key, value := range settings.PodLabels {
+		if msgs := validation.IsQualifiedName(key); len(msgs) > 0 {
+			return fmt.Errorf("pod label key %s is not valid %v", key, msgs)
+		}
+		if common.IsReservedLabel(key) {
+			return fmt.Errorf("pod label key %s is reserved", key)
+		}
+		if msgs := validation.IsValidLabelValue(value); len(msgs) > 0 {
+			return fmt.Errorf("pod label value %s is not valid %v", value, msgs)
+		}
+	}
+	for key := range settings.PodAnnotations {
+		if msgs := validation.IsQualifiedName(strings.ToLower(key)); len(msgs) > 0 {
+			return fmt.Errorf("pod annotation key %s is not valid %v", key, msgs)
+		}
+	}
+	if err := dag.ApplyPodSpecPatch(&v1.PodSpec{}, settings.PodSpecPatch); err != nil {
+		return err
+	}
+	return nil
+}
+
+func validateLoop(task genev1alpha1.Task) error {
+	if task.Loop.MaxIterations <= 0 {
+		return fmt.Errorf("%s: loop maxIterations must be greater than 0", task.Name)
+	}
+	if len(task.CommandSet) != 1 || task.CommandsIter != nil {
+		return fmt.Errorf("%s: loop task must have exactly one command", task.Name)
+	}
+	if task.Condition != nil {
+		return fmt.Errorf("%s: loop task must not have condition", task.Name)
+	}
+	return nil
+}
+
+// validateLoopBodies validates the bodies of the loop tasks. Every task of a
+// body runs one job in every iteration, the loop task must depend on all of
+// them and the tasks out of the body must only depend on the loop task.
+func validateLoopBodies(execution *genev1alpha1.Execution) error {
+	tasks := make(map[string]genev1alpha1.Task, len(execution.Spec.Tasks))
+	for _, task := range execution.Spec.Tasks {
+		tasks[task.Name] = task
+	}
+	// the loop tasks keyed by the tasks of their bodies.
+	loops := make(map[string]string)
+	for _, task := range execution.Spec.Tasks {
+		if task.Loop == nil {
+			continue
+		}
+		for _, name := range task.Loop.Body {
+			body, ok := tasks[name]
+			if !ok || name == task.Name {
+				return fmt.Errorf("%s: loop body task %s not exist", task.Name, name)
+			}
+			if loop, ok := loops[name]; ok {
+				return fmt.Errorf("%s: loop body task %s is in the body of loop %s", task.Name, name, loop)
+			}
+			if len(body.CommandSet) != 1 || body.CommandsIter != nil || body.Condition != nil || body.Loop != nil {
+				return fmt.Errorf("%s: loop body task %s must have exactly one command", task.Name, name)
+			}
+			if dag.IsExitTask(execution, name) {
+				return fmt.Errorf("%s: loop body task %s is an exit handler task", task.Name, name)
+			}
+			loops[name] = task.Name
+		}
+	}
+
+	// the body tasks that the other tasks of the loop depend on.
+	depended := make(map[string]bool, len(loops))
+	for _, task := range execution.Spec.Tasks {
+		for _, dependent := range task.Dependents {
+			loop, ok := loops[dependent.Target]
+			if !ok {
+				continue
+			}
+			if task.Name != loop && loops[task.Name] != loop {
+				return fmt.Errorf("%s: only the tasks of loop %s can depend on its body task %s", task.Name, loop, dependent.Target)
+			}
+			depended[dependent.Target] = true
+		}
+	}
+	for name, loop := range loops {
+		if !depended[name] {
+			return fmt.Errorf("%s: loop task must depend on its body task %s", loop, name)
+		}
+	}
+	return nil
+}
+
+// validateOnExit validates the exit handler tasks, which are not a part of
+// the DAG and run the commands that are decided before running.
+func validateOnExit(execution *genev1alpha1.Execution) error {
+	// the exit variants are only provided to the exit handler tasks.
+	for _, task := range execution.Spec.Tasks {
+		if dag.IsExitTask(execution, task.Name) {
+			continue
+		}
+		commands := append([]string{}, task.CommandSet...)
+		if task.CommandsIter != nil {
+			commands = append(commands, task.CommandsIter.Command)
+		}
+		for _, command := range commands {
+			if common.HasExitVariant(command) {
+				return fmt.Errorf("%s: only the exit handler tasks can use ${%s} and ${%s}", task.Name, common.ExitPhaseVariant, common.ExitFailuresVariant)
+			}
+		}
+	}
+	if len(execution.Spec.OnExit) == 0 {
+		return nil
+	}
+	if len(execution.Spec.OnExit) >= len(execution.Spec.Tasks) {
+		return fmt.Errorf("there should be at least one task that is not an exit handler")
+	}
+	for _, name := range execution.Spec.OnExit {
+		var exitTask *genev1alpha1.Task
+		for i := range execution.Spec.Tasks {
+			if execution.Spec.Tasks[i].Name == name {
+				exitTask = &execution.Spec.Tasks[i]
+				break
+			}
+		}
+		if exitTask == nil {
+			return fmt.Errorf("exit handler task %s not exist", name)
+		}
+		if len(exitTask.Dependents) != 0 {
+			return fmt.Errorf("%s: exit handler task must not have dependents", name)
+		}
+		if exitTask.CommandsIter != nil || exitTask.Condition != nil || exitTask.Loop != nil {
+			return fmt.Errorf("%s: exit handler task must only have commandSet", name)
+		}
+	}
+	for _, task := range execution.Spec.Tasks {
+		for _, dependent := range task.Dependents {
+			if dag.IsExitTask(execution, dependent.Target) {
+				return fmt.Errorf("%s: dependent target %s is an exit handler task", task.Name, dependent.Target)
+			}
+		}
+	}
+	return nil
+}
+
+func validateWebhook(hook *genev1alpha1.Webhook) error {
+	if hook == nil {
+		return nil
+	}
+	for _, rawURL := range hook.URLs {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return fmt.Errorf("webhook url %s is not valid: %v", rawURL, err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("webhook url %s is not valid: scheme must be http or https", rawURL)
+		}
+	}
 	return nil
 }
 
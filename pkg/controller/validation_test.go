@@ -19,6 +19,8 @@ package controller
 import (
 	"testing"
 
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	genev1alpha1 "kubegene.io/kubegene/pkg/apis/gene/v1alpha1"
 )
@@ -439,6 +441,230 @@ func TestValidateExecution(t *testing.T) {
 			},
 			ExpectErr: true,
 		},
+		{
+			Name: "task volume must have exactly one source",
+			ModifyFunc: func(exec *genev1alpha1.Execution) {
+				exec.Spec.Tasks[0].Volumes = map[string]genev1alpha1.Volume{
+					"scratch": {MountPath: "/scratch"},
+				}
+			},
+			ExpectErr: true,
+		},
+		{
+			Name: "task volume subPath must be within the volume",
+			ModifyFunc: func(exec *genev1alpha1.Execution) {
+				exec.Spec.Tasks[0].Volumes = map[string]genev1alpha1.Volume{
+					"data": {MountPath: "/data", MountFrom: genev1alpha1.VolumeSource{Pvc: "data"}, SubPath: "../other"},
+				}
+			},
+			ExpectErr: true,
+		},
+		{
+			Name: "task volume subPath may start with dots",
+			ModifyFunc: func(exec *genev1alpha1.Execution) {
+				exec.Spec.Tasks[0].Volumes = map[string]genev1alpha1.Volume{
+					"data": {MountPath: "/data", MountFrom: genev1alpha1.VolumeSource{Pvc: "data"}, SubPath: "..data"},
+				}
+			},
+			ExpectErr: false,
+		},
+		{
+			Name: "task resource limits are valid",
+			ModifyFunc: func(exec *genev1alpha1.Execution) {
+				exec.Spec.Tasks[0].Resources = genev1alpha1.ResourceRequirements{
+					Memory:   resource.MustParse("4G"),
+					Requests: v1.ResourceList{"nvidia.com/gpu": resource.MustParse("1")},
+					Limits: v1.ResourceList{
+						v1.ResourceMemory: resource.MustParse("8G"),
+						"nvidia.com/gpu":  resource.MustParse("1"),
+					},
+				}
+			},
+			ExpectErr: false,
+		},
+		{
+			Name: "task resource limit must not be less than the request",
+			ModifyFunc: func(exec *genev1alpha1.Execution) {
+				exec.Spec.Tasks[0].Resources = genev1alpha1.ResourceRequirements{
+					Memory: resource.MustParse("8G"),
+					Limits: v1.ResourceList{v1.ResourceMemory: resource.MustParse("4G")},
+				}
+			},
+			ExpectErr: true,
+		},
+		{
+			Name: "task extended resource limit must equal the request",
+			ModifyFunc: func(exec *genev1alpha1.Execution) {
+				exec.Spec.Tasks[0].Resources = genev1alpha1.ResourceRequirements{
+					Requests: v1.ResourceList{"nvidia.com/gpu": resource.MustParse("1")},
+					Limits:   v1.ResourceList{"nvidia.com/gpu": resource.MustParse("2")},
+				}
+			},
+			ExpectErr: true,
+		},
+		{
+			Name: "pod settings are valid",
+			ModifyFunc: func(exec *genev1alpha1.Execution) {
+				exec.Spec.PodLabels = map[string]string{"team": "genomics"}
+				exec.Spec.PodSpecPatch = "dnsPolicy: Default"
+				exec.Spec.Tasks[0].ServiceAccountName = "gene-runner"
+				exec.Spec.Tasks[0].PodSpecPatch = `{"hostname": "worker"}`
+			},
+			ExpectErr: false,
+		},
+		{
+			Name: "pod label must be valid",
+			ModifyFunc: func(exec *genev1alpha1.Execution) {
+				exec.Spec.Tasks[0].PodLabels = map[string]string{"team": "gen omics"}
+			},
+			ExpectErr: true,
+		},
+		{
+			Name: "pod label must not be reserved",
+			ModifyFunc: func(exec *genev1alpha1.Execution) {
+				exec.Spec.Tasks[0].PodLabels = map[string]string{"kubegene.io/task": "other"}
+			},
+			ExpectErr: true,
+		},
+		{
+			Name: "pod spec patch must apply to the pod spec",
+			ModifyFunc: func(exec *genev1alpha1.Execution) {
+				exec.Spec.PodSpecPatch = "- dnsPolicy"
+			},
+			ExpectErr: true,
+		},
+		{
+			Name: "task imagePullPolicy must be valid",
+			ModifyFunc: func(exec *genev1alpha1.Execution) {
+				exec.Spec.Tasks[0].ImagePullPolicy = "Sometimes"
+			},
+			ExpectErr: true,
+		},
+		{
+			Name: "loop task",
+			ModifyFunc: func(exec *genev1alpha1.Execution) {
+				exec.Spec.Tasks[0].Loop = &genev1alpha1.Loop{
+					Until:         "COMPLETE",
+					MaxIterations: 10,
+				}
+			},
+			ExpectErr: false,
+		},
+		{
+			Name: "loop maxIterations must be greater than 0",
+			ModifyFunc: func(exec *genev1alpha1.Execution) {
+				exec.Spec.Tasks[0].Loop = &genev1alpha1.Loop{
+					Until: "COMPLETE",
+				}
+			},
+			ExpectErr: true,
+		},
+		{
+			Name: "loop task must have exactly one command",
+			ModifyFunc: func(exec *genev1alpha1.Execution) {
+				exec.Spec.Tasks[0].CommandSet = []string{"echo A", "echo B"}
+				exec.Spec.Tasks[0].Loop = &genev1alpha1.Loop{
+					MaxIterations: 3,
+				}
+			},
+			ExpectErr: true,
+		},
+		{
+			Name: "loop task with body",
+			ModifyFunc: func(exec *genev1alpha1.Execution) {
+				exec.Spec.Tasks[3].Loop = &genev1alpha1.Loop{
+					Until:         "CONVERGED",
+					MaxIterations: 3,
+					Body:          []string{"b"},
+				}
+			},
+			ExpectErr: false,
+		},
+		{
+			Name: "loop body task must exist",
+			ModifyFunc: func(exec *genev1alpha1.Execution) {
+				exec.Spec.Tasks[3].Loop = &genev1alpha1.Loop{
+					MaxIterations: 3,
+					Body:          []string{"e"},
+				}
+			},
+			ExpectErr: true,
+		},
+		{
+			Name: "loop task must depend on its body task",
+			ModifyFunc: func(exec *genev1alpha1.Execution) {
+				exec.Spec.Tasks[3].Loop = &genev1alpha1.Loop{
+					MaxIterations: 3,
+					Body:          []string{"c"},
+				}
+			},
+			ExpectErr: true,
+		},
+		{
+			Name: "only the tasks of a loop can depend on its body task",
+			ModifyFunc: func(exec *genev1alpha1.Execution) {
+				exec.Spec.Tasks[1].Loop = &genev1alpha1.Loop{
+					MaxIterations: 3,
+					Body:          []string{"a"},
+				}
+			},
+			ExpectErr: true,
+		},
+		{
+			Name: "exit handler task",
+			ModifyFunc: func(exec *genev1alpha1.Execution) {
+				exec.Spec.Tasks = append(exec.Spec.Tasks, genev1alpha1.Task{
+					Name:       "cleanup",
+					Type:       genev1alpha1.JobTaskType,
+					CommandSet: []string{"echo ${workflow.phase}"},
+					Image:      "hello-word",
+				})
+				exec.Spec.OnExit = []string{"cleanup"}
+			},
+			ExpectErr: false,
+		},
+		{
+			Name: "webhook url",
+			ModifyFunc: func(exec *genev1alpha1.Execution) {
+				exec.Spec.Webhook = &genev1alpha1.Webhook{URLs: []string{"https://chat.example.com/hooks/lab"}}
+			},
+			ExpectErr: false,
+		},
+		{
+			Name: "webhook url must be http or https",
+			ModifyFunc: func(exec *genev1alpha1.Execution) {
+				exec.Spec.Webhook = &genev1alpha1.Webhook{URLs: []string{"ftp://chat.example.com"}}
+			},
+			ExpectErr: true,
+		},
+		{
+			Name: "exit handler task not exist",
+			ModifyFunc: func(exec *genev1alpha1.Execution) {
+				exec.Spec.OnExit = []string{"cleanup"}
+			},
+			ExpectErr: true,
+		},
+		{
+			Name: "exit handler task must not have dependents",
+			ModifyFunc: func(exec *genev1alpha1.Execution) {
+				exec.Spec.OnExit = []string{"b"}
+			},
+			ExpectErr: true,
+		},
+		{
+			Name: "only exit handler tasks can use the exit variants",
+			ModifyFunc: func(exec *genev1alpha1.Execution) {
+				exec.Spec.Tasks[0].CommandSet = []string{"echo ${workflow.phase}"}
+			},
+			ExpectErr: true,
+		},
+		{
+			Name: "exit handler task must not be depended on",
+			ModifyFunc: func(exec *genev1alpha1.Execution) {
+				exec.Spec.OnExit = []string{"a"}
+			},
+			ExpectErr: true,
+		},
 	}
 
 	for _, testCase := range testCases {
@@ -457,6 +683,23 @@ func TestValidateExecution(t *testing.T) {
 
 }
 
+func TestValidateNoHostPath(t *testing.T) {
+	exec := validateExecution()
+	if err := validateNoHostPath(exec); err != nil {
+		t.Errorf("expected pvc volumes to be allowed, got %v", err)
+	}
+
+	exec.Spec.Tasks[0].Volumes = map[string]genev1alpha1.Volume{
+		"host": {
+			MountPath: "/tmp/hostvolume",
+			MountFrom: genev1alpha1.VolumeSource{HostPath: &v1.HostPathVolumeSource{Path: "/var/run"}},
+		},
+	}
+	if err := validateNoHostPath(exec); err == nil {
+		t.Errorf("expected hostPath volumes to be rejected")
+	}
+}
+
 func NewInt64(n int) *int64 {
 	num := int64(n)
 	return &num
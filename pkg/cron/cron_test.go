@@ -0,0 +1,90 @@
+/*
+Copyright 2019 The Kubegene Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		spec   string
+		expErr bool
+	}{
+		{spec: "0 2 * * *"},
+		{spec: "*/15 0-6,18 1,15 jan-jun mon-fri"},
+		{spec: "5/10 * ? * 7"},
+		{spec: "@daily"},
+		{spec: "@every 5m", expErr: true},
+		{spec: "0 2 * *", expErr: true},
+		{spec: "60 * * * *", expErr: true},
+		{spec: "* 5-1 * * *", expErr: true},
+		{spec: "*/0 * * * *", expErr: true},
+		{spec: "* * 0 * *", expErr: true},
+		{spec: "* * * foo *", expErr: true},
+	}
+
+	for _, testCase := range testCases {
+		_, err := Parse(testCase.spec)
+		if testCase.expErr && err == nil {
+			t.Errorf("%s: expected error, but got nil", testCase.spec)
+		}
+		if !testCase.expErr && err != nil {
+			t.Errorf("%s: unexpected error: %v", testCase.spec, err)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	testCases := []struct {
+		spec   string
+		time   string
+		expect string
+	}{
+		{spec: "0 2 * * *", time: "2019-03-01T01:59:30Z", expect: "2019-03-01T02:00:00Z"},
+		{spec: "0 2 * * *", time: "2019-03-01T02:00:00Z", expect: "2019-03-02T02:00:00Z"},
+		{spec: "*/15 * * * *", time: "2019-03-01T10:16:00Z", expect: "2019-03-01T10:30:00Z"},
+		{spec: "5/20 * * * *", time: "2019-03-01T10:30:00Z", expect: "2019-03-01T10:45:00Z"},
+		{spec: "0 0 * * *", time: "2019-12-31T23:59:00Z", expect: "2020-01-01T00:00:00Z"},
+		{spec: "0 0 29 2 *", time: "2019-03-01T00:00:00Z", expect: "2020-02-29T00:00:00Z"},
+		{spec: "30 9 * * mon-fri", time: "2019-03-01T10:00:00Z", expect: "2019-03-04T09:30:00Z"},
+		{spec: "0 0 * * 7", time: "2019-03-01T00:00:00Z", expect: "2019-03-03T00:00:00Z"},
+		// the day matches if either the day of month or the day of week matches.
+		{spec: "0 0 15 * fri", time: "2019-03-02T00:00:00Z", expect: "2019-03-08T00:00:00Z"},
+		{spec: "@monthly", time: "2019-03-02T00:00:00Z", expect: "2019-04-01T00:00:00Z"},
+		{spec: "0 0 30 2 *", time: "2019-03-02T00:00:00Z", expect: ""},
+	}
+
+	for _, testCase := range testCases {
+		schedule, err := Parse(testCase.spec)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", testCase.spec, err)
+			continue
+		}
+		now, _ := time.Parse(time.RFC3339, testCase.time)
+		next := schedule.Next(now)
+
+		var expect time.Time
+		if len(testCase.expect) != 0 {
+			expect, _ = time.Parse(time.RFC3339, testCase.expect)
+		}
+		if !next.Equal(expect) {
+			t.Errorf("%s: expected next of %s is %s, got %s", testCase.spec, testCase.time, testCase.expect, next)
+		}
+	}
+}
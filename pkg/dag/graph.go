@@ -0,0 +1,139 @@
+/*
+Copyright 2019 The Kubegene Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package dag builds the jobs of the tasks of an execution and the graph of
+// those jobs. It is shared by the controller and genectl.
+package dag
+
+import (
+	genev1alpha1 "kubegene.io/kubegene/pkg/apis/gene/v1alpha1"
+	"kubegene.io/kubegene/pkg/graph"
+)
+
+// IsExitTask returns whether the task is an exit handler of the execution.
+func IsExitTask(execution *genev1alpha1.Execution, taskName string) bool {
+	for _, name := range execution.Spec.OnExit {
+		if name == taskName {
+			return true
+		}
+	}
+	return false
+}
+
+// NewGraph returns the graph of the jobs of the execution as it is before
+// running, a dynamic task is a vertex of its own until it is expanded, every
+// task of a loop body is a vertex shared by its iterations and the exit
+// handlers are not part of it. It returns an error if the job of a task
+// can not be built, such as when its pod spec patch does not apply.
+func NewGraph(execution *genev1alpha1.Execution) (*graph.Graph, error) {
+	// the vertices of every task, and the vertices of every task keyed by
+	// their job index to connect the iterate dependents.
+	taskVertices := make(map[string][]*graph.Vertex, len(execution.Spec.Tasks))
+	indexVertices := make(map[string]map[int]*graph.Vertex, len(execution.Spec.Tasks))
+	vertices := []*graph.Vertex{}
+	// the loop tasks keyed by the tasks of their bodies.
+	loops := make(map[string]string)
+	for _, task := range execution.Spec.Tasks {
+		if task.Loop != nil {
+			for _, name := range task.Loop.Body {
+				loops[name] = task.Name
+			}
+		}
+	}
+	for _, task := range execution.Spec.Tasks {
+		// the exit handlers are added after the others have finished.
+		if IsExitTask(execution, task.Name) {
+			continue
+		}
+		SetTaskDefaults(execution, &task)
+
+		indexVertices[task.Name] = make(map[int]*graph.Vertex)
+
+		_, inBody := loops[task.Name]
+		if task.CommandsIter != nil || task.Condition != nil || task.Loop != nil || inBody {
+			localtask := task
+			// make up k8s job resource
+			job, err := NewJob(DynamicVertexName(execution.Name, task.Name), "", execution, &task, -1, 0)
+			if err != nil {
+				return nil, err
+			}
+			jobInfo := graph.NewJobInfo(job, false, task.Type, &localtask)
+			vertex := graph.NewVertex(jobInfo, true)
+			vertices = append(vertices, vertex)
+			taskVertices[task.Name] = append(taskVertices[task.Name], vertex)
+
+		} else {
+
+			for index, command := range task.CommandSet {
+				// make up k8s job resource
+				job, err := NewJob(JobName(execution.Name, task.Name, index), command, execution, &task, index, 0)
+				if err != nil {
+					return nil, err
+				}
+				jobInfo := graph.NewJobInfo(job, false, task.Type, nil)
+				vertex := graph.NewVertex(jobInfo, false)
+				vertices = append(vertices, vertex)
+				taskVertices[task.Name] = append(taskVertices[task.Name], vertex)
+				indexVertices[task.Name][index] = vertex
+			}
+		}
+	}
+
+	// the tasks of a loop body share the iteration of the loop task.
+	for name, loop := range loops {
+		if len(taskVertices[name]) != 0 && len(taskVertices[loop]) != 0 {
+			taskVertices[name][0].SetLoop(taskVertices[loop][0])
+		}
+	}
+
+	for _, task := range execution.Spec.Tasks {
+		for _, vertex := range taskVertices[task.Name] {
+			for _, dependent := range task.Dependents {
+				switch dependent.Type {
+				case genev1alpha1.DependTypeWhole, "":
+					for _, target := range taskVertices[dependent.Target] {
+						target.AddChild(vertex)
+					}
+				case genev1alpha1.DependTypeIterate:
+					// a dynamic vertex depends on or is depended on by all the jobs
+					// until it is expanded.
+					if vertex.IsDynamic() || len(indexVertices[dependent.Target]) == 0 {
+						for _, target := range taskVertices[dependent.Target] {
+							target.AddChild(vertex)
+						}
+						continue
+					}
+					if target, ok := indexVertices[dependent.Target][JobIndex(vertex.Data.Job)]; ok {
+						target.AddChild(vertex)
+					}
+				}
+			}
+		}
+	}
+
+	// create graph
+	g := graph.NewGraph(len(vertices))
+
+	// add vertex
+	for _, vertex := range vertices {
+		g.AddVertex(vertex)
+	}
+
+	// index the edges of the vertices
+	g.Build()
+
+	return g, nil
+}
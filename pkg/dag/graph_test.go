@@ -0,0 +1,63 @@
+/*
+Copyright 2019 The Kubegene Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dag
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	genev1alpha1 "kubegene.io/kubegene/pkg/apis/gene/v1alpha1"
+)
+
+func TestNewGraphLargeScatter(t *testing.T) {
+	commands := make([]string, 5000)
+	for i := range commands {
+		commands[i] = "sample"
+	}
+	exec := &genev1alpha1.Execution{
+		ObjectMeta: metav1.ObjectMeta{Name: "exec", Namespace: "default"},
+		Spec: genev1alpha1.ExecutionSpec{
+			Tasks: []genev1alpha1.Task{
+				{Name: "align", Type: genev1alpha1.JobTaskType, Image: "bwa", CommandSet: commands},
+				{Name: "sort", Type: genev1alpha1.JobTaskType, Image: "samtools", CommandSet: commands,
+					Dependents: []genev1alpha1.Dependent{{Target: "align", Type: genev1alpha1.DependTypeIterate}}},
+				{Name: "merge", Type: genev1alpha1.JobTaskType, Image: "samtools", CommandSet: []string{"merge"},
+					Dependents: []genev1alpha1.Dependent{{Target: "sort", Type: genev1alpha1.DependTypeWhole}}},
+			},
+		},
+	}
+
+	g, err := NewGraph(exec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.VertexCount != 10001 || len(g.GetRootVertex()) != 5000 {
+		t.Fatalf("expected 10001 vertices and 5000 roots, got %d and %d", g.VertexCount, len(g.GetRootVertex()))
+	}
+	sort := g.FindVertexByName("exec.sort.4321")
+	if dependents := g.FindDependentVertices(sort); len(dependents) != 1 || dependents[0].Data.Job.Name != "exec.align.4321" {
+		t.Errorf("expected sort to iterate over align, got %d dependents", len(dependents))
+	}
+	merge := g.FindVertexByName("exec.merge.0")
+	if dependents := g.FindDependentVertices(merge); len(dependents) != 5000 {
+		t.Errorf("expected merge to depend on all the sort jobs, got %d", len(dependents))
+	}
+	if !g.IsDAG() {
+		t.Errorf("expected the graph to be a DAG")
+	}
+}
@@ -0,0 +1,238 @@
+/*
+Copyright 2019 The Kubegene Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dag
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	batch "k8s.io/api/batch/v1"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/apimachinery/pkg/util/validation"
+	genev1alpha1 "kubegene.io/kubegene/pkg/apis/gene/v1alpha1"
+	"kubegene.io/kubegene/pkg/common"
+)
+
+// SetTaskDefaults sets the scheduling constraints of the execution to
+// the task if the task does not specify its own.
+func SetTaskDefaults(execution *genev1alpha1.Execution, task *genev1alpha1.Task) {
+	if len(task.Tolerations) == 0 {
+		task.Tolerations = execution.Spec.Tolerations
+	}
+	if len(task.NodeSelector) == 0 {
+		task.NodeSelector = execution.Spec.NodeSelector
+	}
+	if task.Affinity == nil {
+		task.Affinity = execution.Spec.Affinity
+	}
+	if task.SecurityContext == nil {
+		task.SecurityContext = execution.Spec.SecurityContext
+	}
+	if len(task.ServiceAccountName) == 0 {
+		task.ServiceAccountName = execution.Spec.ServiceAccountName
+	}
+	if len(task.PriorityClassName) == 0 {
+		task.PriorityClassName = execution.Spec.PriorityClassName
+	}
+
+	secrets := make([]v1.LocalObjectReference, 0, len(execution.Spec.ImagePullSecrets)+len(task.ImagePullSecrets))
+	seen := make(map[string]bool)
+	for _, secret := range append(append([]v1.LocalObjectReference{}, execution.Spec.ImagePullSecrets...), task.ImagePullSecrets...) {
+		if !seen[secret.Name] {
+			seen[secret.Name] = true
+			secrets = append(secrets, secret)
+		}
+	}
+	if len(secrets) != 0 {
+		task.ImagePullSecrets = secrets
+	}
+	task.PodLabels = common.MergeStringMap(execution.Spec.PodLabels, task.PodLabels)
+	task.PodAnnotations = common.MergeStringMap(execution.Spec.PodAnnotations, task.PodAnnotations)
+}
+
+// ApplyPodSpecPatch applies a strategic merge patch in JSON or YAML to the
+// pod spec.
+func ApplyPodSpecPatch(podSpec *v1.PodSpec, patch string) error {
+	if len(strings.TrimSpace(patch)) == 0 {
+		return nil
+	}
+	patchJSON, err := yaml.YAMLToJSON([]byte(patch))
+	if err != nil {
+		return fmt.Errorf("invalid pod spec patch: %v", err)
+	}
+	original, err := json.Marshal(podSpec)
+	if err != nil {
+		return err
+	}
+	patched, err := strategicpatch.StrategicMergePatch(original, patchJSON, v1.PodSpec{})
+	if err != nil {
+		return fmt.Errorf("apply pod spec patch error: %v", err)
+	}
+	result := v1.PodSpec{}
+	if err := json.Unmarshal(patched, &result); err != nil {
+		return fmt.Errorf("apply pod spec patch error: %v", err)
+	}
+	*podSpec = result
+	return nil
+}
+
+// ResourceRequirementsOf returns the container resources of the resources of
+// a task. Extended resources can not be overcommitted, their limits default
+// to the requests.
+func ResourceRequirementsOf(res genev1alpha1.ResourceRequirements) v1.ResourceRequirements {
+	requests := v1.ResourceList{}
+	for name, quantity := range res.Requests {
+		requests[name] = quantity.DeepCopy()
+	}
+	if !res.Cpu.IsZero() {
+		requests[v1.ResourceCPU] = res.Cpu.DeepCopy()
+	}
+	if !res.Memory.IsZero() {
+		requests[v1.ResourceMemory] = res.Memory.DeepCopy()
+	}
+
+	limits := v1.ResourceList{}
+	for name, quantity := range res.Limits {
+		limits[name] = quantity.DeepCopy()
+	}
+	for name, quantity := range requests {
+		if _, ok := limits[name]; !ok && IsExtendedResourceName(name) {
+			limits[name] = quantity.DeepCopy()
+		}
+	}
+
+	resources := v1.ResourceRequirements{Requests: requests}
+	if len(limits) != 0 {
+		resources.Limits = limits
+	}
+	return resources
+}
+
+// IsExtendedResourceName reports whether the name is the name of an extended
+// resource, that is a fully qualified name outside of the kubernetes.io domain.
+func IsExtendedResourceName(name v1.ResourceName) bool {
+	str := string(name)
+	if !strings.Contains(str, "/") || strings.HasPrefix(str, v1.ResourceDefaultNamespacePrefix) {
+		return false
+	}
+	return len(validation.IsQualifiedName(str)) == 0
+}
+
+// volumeSourceOf returns the pod volume source of the volume source of a task.
+func volumeSourceOf(source genev1alpha1.VolumeSource) v1.VolumeSource {
+	switch {
+	case source.EmptyDir != nil:
+		return v1.VolumeSource{EmptyDir: source.EmptyDir.DeepCopy()}
+	case source.HostPath != nil:
+		return v1.VolumeSource{HostPath: source.HostPath.DeepCopy()}
+	case source.ConfigMap != nil:
+		return v1.VolumeSource{ConfigMap: source.ConfigMap.DeepCopy()}
+	case source.Secret != nil:
+		return v1.VolumeSource{Secret: source.Secret.DeepCopy()}
+	case source.NFS != nil:
+		return v1.VolumeSource{NFS: source.NFS.DeepCopy()}
+	default:
+		return v1.VolumeSource{
+			PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: source.Pvc},
+		}
+	}
+}
+
+// NewJob returns the job of the given index and attempt of a task, the job
+// and its pods are labeled and annotated with them. It returns an error if
+// the pod spec patch of the execution or the task does not apply.
+func NewJob(name, command string, exec *genev1alpha1.Execution, task *genev1alpha1.Task, index, attempt int) (*batch.Job, error) {
+	volumes := []v1.Volume{}
+	volumeMounts := []v1.VolumeMount{}
+	for name, volume := range task.Volumes {
+		volumes = append(volumes, v1.Volume{
+			Name:         name,
+			VolumeSource: volumeSourceOf(volume.MountFrom),
+		})
+
+		volumeMounts = append(volumeMounts, v1.VolumeMount{
+			Name:      name,
+			MountPath: volume.MountPath,
+			ReadOnly:  volume.ReadOnly,
+			SubPath:   volume.SubPath,
+		})
+	}
+
+	controllerRef := metav1.NewControllerRef(exec, genev1alpha1.SchemeGroupVersion.WithKind("Execution"))
+	annotations := jobAnnotations(exec.Name, task.Name, index, attempt)
+	labels := jobLabels(annotations)
+	containerName := strings.Replace(name, ".", "-", -1)
+
+	imagePullPolicy := task.ImagePullPolicy
+	if len(imagePullPolicy) == 0 {
+		imagePullPolicy = v1.PullIfNotPresent
+	}
+	job := &batch.Job{
+		TypeMeta: metav1.TypeMeta{Kind: "Job"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       exec.Namespace,
+			Labels:          common.MergeStringMap(labels, map[string]string{"controller-uid": string(exec.UID)}),
+			Annotations:     annotations,
+			OwnerReferences: []metav1.OwnerReference{*controllerRef},
+		},
+		Spec: batch.JobSpec{
+			Template: v1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      common.MergeStringMap(task.PodLabels, labels),
+					Annotations: common.MergeStringMap(task.PodAnnotations, annotations),
+				},
+				Spec: v1.PodSpec{
+					RestartPolicy: v1.RestartPolicyOnFailure,
+					Containers: []v1.Container{
+						{
+							Name:            containerName,
+							Image:           task.Image,
+							Command:         []string{"sh", "-c", command},
+							Env:             task.Env,
+							EnvFrom:         task.EnvFrom,
+							WorkingDir:      task.WorkingDir,
+							VolumeMounts:    volumeMounts,
+							ImagePullPolicy: imagePullPolicy,
+							Resources:       ResourceRequirementsOf(task.Resources),
+						},
+					},
+					ImagePullSecrets:   task.ImagePullSecrets,
+					NodeSelector:       task.NodeSelector,
+					Affinity:           task.Affinity,
+					Tolerations:        task.Tolerations,
+					Volumes:            volumes,
+					SecurityContext:    task.SecurityContext,
+					ServiceAccountName: task.ServiceAccountName,
+					PriorityClassName:  task.PriorityClassName,
+				},
+			},
+		},
+	}
+
+	for _, patch := range []string{exec.Spec.PodSpecPatch, task.PodSpecPatch} {
+		if err := ApplyPodSpecPatch(&job.Spec.Template.Spec, patch); err != nil {
+			return nil, fmt.Errorf("job %s: %v", name, err)
+		}
+	}
+
+	return job, nil
+}
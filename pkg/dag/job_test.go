@@ -0,0 +1,269 @@
+/*
+Copyright 2019 The Kubegene Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dag
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	genev1alpha1 "kubegene.io/kubegene/pkg/apis/gene/v1alpha1"
+)
+
+func TestNewJobContainerSettings(t *testing.T) {
+	exec := &genev1alpha1.Execution{
+		ObjectMeta: metav1.ObjectMeta{Name: "exec", Namespace: "default"},
+	}
+	task := &genev1alpha1.Task{
+		Name:  "call",
+		Type:  genev1alpha1.JobTaskType,
+		Image: "broadinstitute/gatk:4.0.2.0",
+		Resources: genev1alpha1.ResourceRequirements{
+			Cpu:    resource.MustParse("2"),
+			Memory: resource.MustParse("4G"),
+		},
+		Env:        []v1.EnvVar{{Name: "JAVA_OPTS", Value: "-Xmx4g"}},
+		EnvFrom:    []v1.EnvFromSource{{SecretRef: &v1.SecretEnvSource{LocalObjectReference: v1.LocalObjectReference{Name: "license"}}}},
+		WorkingDir: "/gatk",
+		PodSettings: genev1alpha1.PodSettings{
+			ImagePullSecrets: []v1.LocalObjectReference{{Name: "registry-secret"}},
+		},
+	}
+
+	job, err := NewJob("exec.call.0", "gatk HaplotypeCaller", exec, task, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	podSpec := job.Spec.Template.Spec
+	container := podSpec.Containers[0]
+	if !reflect.DeepEqual(container.Env, task.Env) || !reflect.DeepEqual(container.EnvFrom, task.EnvFrom) {
+		t.Errorf("unexpected env %v or env from %v", container.Env, container.EnvFrom)
+	}
+	if container.WorkingDir != "/gatk" {
+		t.Errorf("expected working dir /gatk, got %s", container.WorkingDir)
+	}
+	if container.ImagePullPolicy != v1.PullIfNotPresent {
+		t.Errorf("expected default pull policy IfNotPresent, got %s", container.ImagePullPolicy)
+	}
+	if !reflect.DeepEqual(podSpec.ImagePullSecrets, task.ImagePullSecrets) {
+		t.Errorf("expected pull secrets %v, got %v", task.ImagePullSecrets, podSpec.ImagePullSecrets)
+	}
+	cpu := container.Resources.Requests[v1.ResourceCPU]
+	memory := container.Resources.Requests[v1.ResourceMemory]
+	if cpu.Cmp(task.Resources.Cpu) != 0 || memory.Cmp(task.Resources.Memory) != 0 {
+		t.Errorf("unexpected resource requests %v", container.Resources.Requests)
+	}
+
+	task.ImagePullPolicy = v1.PullAlways
+	task.Resources = genev1alpha1.ResourceRequirements{}
+	job, err = NewJob("exec.call.0", "gatk HaplotypeCaller", exec, task, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	container = job.Spec.Template.Spec.Containers[0]
+	if container.ImagePullPolicy != v1.PullAlways {
+		t.Errorf("expected pull policy Always, got %s", container.ImagePullPolicy)
+	}
+	if len(container.Resources.Requests) != 0 {
+		t.Errorf("expected no resource requests, got %v", container.Resources.Requests)
+	}
+}
+
+func TestNewJobResources(t *testing.T) {
+	exec := &genev1alpha1.Execution{
+		ObjectMeta: metav1.ObjectMeta{Name: "exec", Namespace: "default"},
+	}
+	task := &genev1alpha1.Task{
+		Name:  "call",
+		Type:  genev1alpha1.JobTaskType,
+		Image: "gatk",
+		Resources: genev1alpha1.ResourceRequirements{
+			Cpu:    resource.MustParse("2"),
+			Memory: resource.MustParse("4G"),
+			Requests: v1.ResourceList{
+				v1.ResourceEphemeralStorage: resource.MustParse("50Gi"),
+				"nvidia.com/gpu":            resource.MustParse("1"),
+			},
+			Limits: v1.ResourceList{
+				v1.ResourceMemory: resource.MustParse("8G"),
+			},
+		},
+	}
+
+	job, err := NewJob("exec.call.0", "gatk HaplotypeCaller", exec, task, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resources := job.Spec.Template.Spec.Containers[0].Resources
+	expected := v1.ResourceRequirements{
+		Requests: v1.ResourceList{
+			v1.ResourceCPU:              resource.MustParse("2"),
+			v1.ResourceMemory:           resource.MustParse("4G"),
+			v1.ResourceEphemeralStorage: resource.MustParse("50Gi"),
+			"nvidia.com/gpu":            resource.MustParse("1"),
+		},
+		Limits: v1.ResourceList{
+			v1.ResourceMemory: resource.MustParse("8G"),
+			"nvidia.com/gpu":  resource.MustParse("1"),
+		},
+	}
+	for _, list := range []struct{ got, expected v1.ResourceList }{
+		{resources.Requests, expected.Requests},
+		{resources.Limits, expected.Limits},
+	} {
+		if len(list.got) != len(list.expected) {
+			t.Errorf("expected resources %v, got %v", list.expected, list.got)
+			continue
+		}
+		for name, quantity := range list.expected {
+			if got := list.got[name]; got.Cmp(quantity) != 0 {
+				t.Errorf("expected %s %s, got %s", name, quantity.String(), got.String())
+			}
+		}
+	}
+}
+
+func TestNewJobPodSettings(t *testing.T) {
+	runAsUser := int64(1000)
+	fsGroup := int64(2000)
+	exec := &genev1alpha1.Execution{
+		ObjectMeta: metav1.ObjectMeta{Name: "exec", Namespace: "default"},
+		Spec: genev1alpha1.ExecutionSpec{
+			PodSettings: genev1alpha1.PodSettings{
+				SecurityContext:    &v1.PodSecurityContext{RunAsUser: &runAsUser, FSGroup: &fsGroup},
+				ServiceAccountName: "gene-runner",
+				ImagePullSecrets:   []v1.LocalObjectReference{{Name: "registry-secret"}},
+				PodLabels:          map[string]string{"team": "genomics", "tier": "batch"},
+				PodAnnotations:     map[string]string{"owner": "lab"},
+				PodSpecPatch:       "dnsPolicy: Default\nhostname: worker",
+			},
+		},
+	}
+	task := genev1alpha1.Task{
+		Name:  "call",
+		Type:  genev1alpha1.JobTaskType,
+		Image: "gatk",
+		PodSettings: genev1alpha1.PodSettings{
+			ImagePullSecrets:  []v1.LocalObjectReference{{Name: "gatk-secret"}},
+			PriorityClassName: "high-priority",
+			PodLabels:         map[string]string{"tier": "critical"},
+			PodSpecPatch:      `{"hostname": "caller"}`,
+		},
+	}
+	SetTaskDefaults(exec, &task)
+
+	job, err := NewJob("exec.call.0", "gatk HaplotypeCaller", exec, &task, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := job.Spec.Template
+	if template.Labels["team"] != "genomics" || template.Labels["tier"] != "critical" || template.Labels[TaskLabel] != "call" {
+		t.Errorf("unexpected pod labels %v", template.Labels)
+	}
+	if template.Annotations["owner"] != "lab" || template.Annotations[TaskLabel] != "call" {
+		t.Errorf("unexpected pod annotations %v", template.Annotations)
+	}
+	podSpec := template.Spec
+	if podSpec.SecurityContext == nil || *podSpec.SecurityContext.RunAsUser != 1000 || *podSpec.SecurityContext.FSGroup != 2000 {
+		t.Errorf("unexpected security context %v", podSpec.SecurityContext)
+	}
+	if podSpec.ServiceAccountName != "gene-runner" || podSpec.PriorityClassName != "high-priority" {
+		t.Errorf("unexpected service account %s or priority class %s", podSpec.ServiceAccountName, podSpec.PriorityClassName)
+	}
+	expectedSecrets := []v1.LocalObjectReference{{Name: "registry-secret"}, {Name: "gatk-secret"}}
+	if !reflect.DeepEqual(podSpec.ImagePullSecrets, expectedSecrets) {
+		t.Errorf("expected pull secrets %v, got %v", expectedSecrets, podSpec.ImagePullSecrets)
+	}
+	// the patch of the task is applied after the one of the execution.
+	if podSpec.DNSPolicy != v1.DNSDefault || podSpec.Hostname != "caller" {
+		t.Errorf("expected the patches to be applied, got dnsPolicy %s and hostname %s", podSpec.DNSPolicy, podSpec.Hostname)
+	}
+	if len(podSpec.Containers) != 1 || podSpec.Containers[0].Image != "gatk" {
+		t.Errorf("expected the patches to keep the containers, got %v", podSpec.Containers)
+	}
+	if len(exec.Spec.PodLabels) != 2 || exec.Spec.PodLabels["tier"] != "batch" {
+		t.Errorf("expected the labels of the execution to be unchanged, got %v", exec.Spec.PodLabels)
+	}
+}
+
+func TestNewJobInvalidPodSpecPatch(t *testing.T) {
+	exec := &genev1alpha1.Execution{
+		ObjectMeta: metav1.ObjectMeta{Name: "exec", Namespace: "default"},
+		Spec: genev1alpha1.ExecutionSpec{
+			PodSettings: genev1alpha1.PodSettings{PodSpecPatch: "- dnsPolicy"},
+		},
+	}
+	task := &genev1alpha1.Task{Name: "call", Type: genev1alpha1.JobTaskType, Image: "gatk"}
+	if _, err := NewJob("exec.call.0", "gatk", exec, task, 0, 0); err == nil {
+		t.Errorf("expected the patch error, but got nil")
+	}
+	exec.Spec.Tasks = []genev1alpha1.Task{*task}
+	exec.Spec.Tasks[0].CommandSet = []string{"gatk"}
+	if _, err := NewGraph(exec); err == nil {
+		t.Errorf("expected the graph to fail with the patch error, but got nil")
+	}
+}
+
+func TestNewJobVolumes(t *testing.T) {
+	exec := &genev1alpha1.Execution{
+		ObjectMeta: metav1.ObjectMeta{Name: "exec", Namespace: "default"},
+	}
+	sizeLimit := resource.MustParse("10Gi")
+	task := &genev1alpha1.Task{
+		Name:  "sort",
+		Type:  genev1alpha1.JobTaskType,
+		Image: "samtools",
+		Volumes: map[string]genev1alpha1.Volume{
+			"data": {
+				MountPath: "/data",
+				MountFrom: genev1alpha1.VolumeSource{Pvc: "gene-data"},
+				ReadOnly:  true,
+				SubPath:   "samples",
+			},
+			"scratch": {
+				MountPath: "/scratch",
+				MountFrom: genev1alpha1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{SizeLimit: &sizeLimit}},
+			},
+		},
+	}
+
+	job, err := NewJob("exec.sort.0", "samtools sort", exec, task, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	volumes := make(map[string]v1.VolumeSource)
+	for _, volume := range job.Spec.Template.Spec.Volumes {
+		volumes[volume.Name] = volume.VolumeSource
+	}
+	if claim := volumes["data"].PersistentVolumeClaim; claim == nil || claim.ClaimName != "gene-data" {
+		t.Errorf("expected the pvc gene-data, got %v", volumes["data"])
+	}
+	if emptyDir := volumes["scratch"].EmptyDir; emptyDir == nil || emptyDir.SizeLimit.Cmp(sizeLimit) != 0 {
+		t.Errorf("expected an empty dir of 10Gi, got %v", volumes["scratch"])
+	}
+	if volumes["scratch"].PersistentVolumeClaim != nil {
+		t.Errorf("expected no pvc for the empty dir, got %v", volumes["scratch"])
+	}
+	for _, mount := range job.Spec.Template.Spec.Containers[0].VolumeMounts {
+		if mount.Name == "data" && (!mount.ReadOnly || mount.SubPath != "samples") {
+			t.Errorf("expected a read-only mount of the sub path samples, got %v", mount)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2019 The Kubegene Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dag
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+
+	batch "k8s.io/api/batch/v1"
+)
+
+// Separator used to construct job name.
+const Separator = "."
+
+const (
+	// ExecutionLabel is the label of the jobs and pods of an execution, its
+	// value is the name of the execution.
+	ExecutionLabel = "kubegene.io/execution"
+
+	// TaskLabel is the label of the jobs and pods of a task, its value is the
+	// name of the task.
+	TaskLabel = "kubegene.io/task"
+
+	// IndexLabel is the label of the index of a job within its task.
+	IndexLabel = "kubegene.io/index"
+
+	// AttemptLabel is the label of the loop iteration of a job, it is 0 for
+	// the jobs of the tasks that are not loops.
+	AttemptLabel = "kubegene.io/attempt"
+
+	// maxJobNameLength is the max length of a job name, the name is used as
+	// the value of the job-name label of its pods and as the container name.
+	// It is the max length of a label value as well.
+	maxJobNameLength = 63
+
+	// jobNameHashLength is the length of the hash suffix of a shortened name.
+	jobNameHashLength = 8
+)
+
+// shortenName returns the name if it is not longer than maxJobNameLength,
+// otherwise the name is truncated and suffixed with a hash of the full name
+// so that the shortened names stay unique.
+func shortenName(name string) string {
+	if len(name) <= maxJobNameLength {
+		return name
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(name))
+	suffix := fmt.Sprintf("%0*x", jobNameHashLength, hash.Sum32())
+	prefix := strings.TrimRight(name[:maxJobNameLength-jobNameHashLength-1], Separator+"-_")
+	return prefix + "-" + suffix
+}
+
+// JobName returns the name of the job of the given index of a task, it is
+// execution.task.index shortened to 63 characters.
+func JobName(execName, taskName string, index int) string {
+	return shortenName(execName + Separator + taskName + Separator + strconv.Itoa(index))
+}
+
+// DynamicVertexName returns the name of the vertex of a dynamic task before
+// it is expanded, and of the vertex of a loop task. No job has the name.
+func DynamicVertexName(execName, taskName string) string {
+	return shortenName(execName + Separator + taskName + Separator)
+}
+
+// LabelValue returns the value of the execution or task label for the name,
+// a name longer than 63 characters is shortened the same way as a job name.
+func LabelValue(name string) string {
+	return shortenName(name)
+}
+
+// jobAnnotations returns the annotations that identify the job of the given
+// index and attempt of a task. A negative index is not annotated, it is used
+// by the vertices of the dynamic tasks.
+func jobAnnotations(execName, taskName string, index, attempt int) map[string]string {
+	annotations := map[string]string{
+		ExecutionLabel: execName,
+		TaskLabel:      taskName,
+		AttemptLabel:   strconv.Itoa(attempt),
+	}
+	if index >= 0 {
+		annotations[IndexLabel] = strconv.Itoa(index)
+	}
+	return annotations
+}
+
+// jobLabels returns the labels of the job, the annotations with the names of
+// the execution and the task shortened to valid label values.
+func jobLabels(annotations map[string]string) map[string]string {
+	labels := make(map[string]string, len(annotations))
+	for key, value := range annotations {
+		labels[key] = value
+	}
+	labels[ExecutionLabel] = LabelValue(annotations[ExecutionLabel])
+	labels[TaskLabel] = LabelValue(annotations[TaskLabel])
+	return labels
+}
+
+// JobExecution returns the name of the execution of the job from its
+// annotations, the label may be shortened.
+func JobExecution(job *batch.Job) string {
+	return fullName(job, ExecutionLabel)
+}
+
+// JobTask returns the name of the task of the job from its annotations, the
+// label may be shortened.
+func JobTask(job *batch.Job) string {
+	return fullName(job, TaskLabel)
+}
+
+func fullName(job *batch.Job, key string) string {
+	if name, ok := job.Annotations[key]; ok {
+		return name
+	}
+	return job.Labels[key]
+}
+
+// JobIndex returns the index of the job within its task from its labels,
+// or -1 if the job has no index.
+func JobIndex(job *batch.Job) int {
+	return intLabel(job, IndexLabel)
+}
+
+// JobAttempt returns the loop iteration of the job from its labels, or -1
+// if the job has no attempt.
+func JobAttempt(job *batch.Job) int {
+	return intLabel(job, AttemptLabel)
+}
+
+func intLabel(job *batch.Job, key string) int {
+	value, err := strconv.Atoi(job.Labels[key])
+	if err != nil {
+		return -1
+	}
+	return value
+}
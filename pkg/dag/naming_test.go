@@ -0,0 +1,104 @@
+/*
+Copyright 2019 The Kubegene Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dag
+
+import (
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	genev1alpha1 "kubegene.io/kubegene/pkg/apis/gene/v1alpha1"
+)
+
+func TestJobName(t *testing.T) {
+	if name := JobName("exec", "align", 3); name != "exec.align.3" {
+		t.Errorf("expected exec.align.3, got %s", name)
+	}
+
+	execName := strings.Repeat("e", 40)
+	taskName := strings.Repeat("t", 40)
+	names := make(map[string]bool)
+	for index := 0; index < 100; index++ {
+		name := JobName(execName, taskName, index)
+		if len(name) > maxJobNameLength {
+			t.Fatalf("expected name of at most %d characters, got %s", maxJobNameLength, name)
+		}
+		if msgs := validation.IsDNS1123Subdomain(name); len(msgs) != 0 {
+			t.Errorf("invalid name %s: %v", name, msgs)
+		}
+		if name != JobName(execName, taskName, index) {
+			t.Errorf("expected the same name for index %d", index)
+		}
+		names[name] = true
+	}
+	if len(names) != 100 {
+		t.Errorf("expected 100 unique names, got %d", len(names))
+	}
+}
+
+func TestNewJobLabels(t *testing.T) {
+	exec := &genev1alpha1.Execution{
+		ObjectMeta: metav1.ObjectMeta{Name: strings.Repeat("e", 100), Namespace: "default", UID: "uid"},
+	}
+	task := &genev1alpha1.Task{Name: strings.Repeat("t", 70), PodLabels: map[string]string{"team": "gene", TaskLabel: "other"}}
+	job, err := NewJob(JobName(exec.Name, task.Name, 2), "gatk", exec, task, 2, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if name := job.Spec.Template.Spec.Containers[0].Name; len(validation.IsDNS1123Label(name)) != 0 {
+		t.Errorf("invalid container name %s", name)
+	}
+	if JobExecution(job) != exec.Name || JobTask(job) != task.Name || JobIndex(job) != 2 || JobAttempt(job) != 5 {
+		t.Errorf("unexpected job labels %v and annotations %v", job.Labels, job.Annotations)
+	}
+	if job.Labels["controller-uid"] != "uid" {
+		t.Errorf("expected controller-uid label, got %v", job.Labels)
+	}
+	pod := job.Spec.Template
+	for _, labels := range []map[string]string{job.Labels, pod.Labels} {
+		for key, value := range labels {
+			if msgs := validation.IsValidLabelValue(value); len(msgs) != 0 {
+				t.Errorf("invalid value of label %s: %v", key, msgs)
+			}
+		}
+		if labels[ExecutionLabel] != LabelValue(exec.Name) || labels[TaskLabel] != LabelValue(task.Name) {
+			t.Errorf("expected the shortened names as label values, got %v", labels)
+		}
+	}
+	for _, annotations := range []map[string]string{job.Annotations, pod.Annotations} {
+		if annotations[ExecutionLabel] != exec.Name || annotations[TaskLabel] != task.Name || annotations[IndexLabel] != "2" || annotations[AttemptLabel] != "5" {
+			t.Errorf("expected the full names in the annotations, got %v", annotations)
+		}
+	}
+	if pod.Labels["team"] != "gene" {
+		t.Errorf("expected the pod labels of the task, got %v", pod.Labels)
+	}
+	if LabelValue(exec.Name) == LabelValue(exec.Name+"e") {
+		t.Errorf("expected different label values for different long names")
+	}
+
+	dynamic, err := NewJob(DynamicVertexName(exec.Name, task.Name), "", exec, task, -1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if JobIndex(dynamic) != -1 {
+		t.Errorf("expected no index of dynamic vertex, got %d", JobIndex(dynamic))
+	}
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2019 The Kubegene Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package graph
+
+import (
+	"fmt"
+	"time"
+)
+
+// TopologicalOrder returns the vertices of the graph in an order in which
+// every vertex comes after the vertices it depends on. Among the vertices
+// that are ready at the same time, the order of the graph is kept.
+func (g *Graph) TopologicalOrder() ([]*Vertex, error) {
+	g.RLock()
+	defer g.RUnlock()
+	return g.topologicalOrder()
+}
+
+func (g *Graph) topologicalOrder() ([]*Vertex, error) {
+	inDegree := make([]int, g.VertexCount)
+	for i, vertex := range g.VertexArray {
+		inDegree[i] = len(g.dependents[vertex])
+	}
+	queue := make([]int, 0, g.VertexCount)
+	for i, degree := range inDegree {
+		if degree == 0 {
+			queue = append(queue, i)
+		}
+	}
+
+	order := make([]*Vertex, 0, g.VertexCount)
+	for len(queue) != 0 {
+		index := queue[0]
+		queue = queue[1:]
+		order = append(order, g.VertexArray[index])
+		for _, child := range g.FindChildren(index) {
+			inDegree[child]--
+			if inDegree[child] == 0 {
+				queue = append(queue, child)
+			}
+		}
+	}
+	if len(order) != g.VertexCount {
+		return nil, fmt.Errorf("graph has a cycle")
+	}
+	return order, nil
+}
+
+// Levels returns the depth level of every vertex of the graph. The vertices
+// that depend on no vertex are at level 0, the others are one level deeper
+// than the deepest vertex they depend on.
+func (g *Graph) Levels() (map[*Vertex]int, error) {
+	g.RLock()
+	defer g.RUnlock()
+
+	order, err := g.topologicalOrder()
+	if err != nil {
+		return nil, err
+	}
+	levels := make(map[*Vertex]int, len(order))
+	for _, vertex := range order {
+		level := 0
+		for _, dependent := range g.sortedDependents(vertex) {
+			if levels[dependent]+1 > level {
+				level = levels[dependent] + 1
+			}
+		}
+		levels[vertex] = level
+	}
+	return levels, nil
+}
+
+// CriticalPath returns the longest path of the graph weighted by the
+// duration of every vertex, and the total duration of the path. The
+// vertices of the path are the ones whose delay delays the whole graph.
+// Among the paths of the same duration, the one that ends last in the
+// topological order is returned.
+func (g *Graph) CriticalPath(duration func(*Vertex) time.Duration) ([]*Vertex, time.Duration, error) {
+	g.RLock()
+	defer g.RUnlock()
+
+	order, err := g.topologicalOrder()
+	if err != nil {
+		return nil, 0, err
+	}
+	// finish is the duration of the longest path to the end of every
+	// vertex, previous is the vertex before it on that path.
+	finish := make(map[*Vertex]time.Duration, len(order))
+	previous := make(map[*Vertex]*Vertex, len(order))
+	var last *Vertex
+	for _, vertex := range order {
+		var start time.Duration
+		for _, dependent := range g.sortedDependents(vertex) {
+			if finish[dependent] > start || previous[vertex] == nil {
+				start = finish[dependent]
+				previous[vertex] = dependent
+			}
+		}
+		finish[vertex] = start + duration(vertex)
+		if last == nil || finish[vertex] >= finish[last] {
+			last = vertex
+		}
+	}
+	if last == nil {
+		return nil, 0, nil
+	}
+
+	var path []*Vertex
+	for vertex := last; vertex != nil; vertex = previous[vertex] {
+		path = append(path, vertex)
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path, finish[last], nil
+}
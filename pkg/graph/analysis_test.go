@@ -0,0 +1,96 @@
+/*
+Copyright 2019 The Kubegene Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package graph
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTopologicalOrder(t *testing.T) {
+	graph, vertices := newTestGraph(5)
+
+	vertices[0].AddChild(vertices[3])
+	vertices[3].AddChild(vertices[1])
+	vertices[2].AddChild(vertices[1])
+	vertices[1].AddChild(vertices[4])
+	graph.Build()
+
+	order, err := graph.TopologicalOrder()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []*Vertex{vertices[0], vertices[2], vertices[3], vertices[1], vertices[4]}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("expected order %v, got %v", names(expected), names(order))
+	}
+
+	levels, err := graph.Levels()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i, level := range []int{0, 2, 0, 1, 3} {
+		if levels[vertices[i]] != level {
+			t.Errorf("expected vertex %d at level %d, got %d", i, level, levels[vertices[i]])
+		}
+	}
+
+	vertices[4].AddChild(vertices[0])
+	graph.Build()
+	if _, err := graph.TopologicalOrder(); err == nil {
+		t.Errorf("expected an error for a cycle")
+	}
+}
+
+func TestCriticalPath(t *testing.T) {
+	graph, vertices := newTestGraph(5)
+
+	// 0 -> 1 -> 4 and 2 -> 3 -> 4
+	vertices[0].AddChild(vertices[1])
+	vertices[1].AddChild(vertices[4])
+	vertices[2].AddChild(vertices[3])
+	vertices[3].AddChild(vertices[4])
+	graph.Build()
+
+	durations := map[*Vertex]time.Duration{
+		vertices[0]: time.Minute,
+		vertices[1]: time.Minute,
+		vertices[2]: 30 * time.Second,
+		vertices[3]: 2 * time.Minute,
+		vertices[4]: time.Minute,
+	}
+	path, total, err := graph.CriticalPath(func(v *Vertex) time.Duration { return durations[v] })
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []*Vertex{vertices[2], vertices[3], vertices[4]}
+	if !reflect.DeepEqual(path, expected) {
+		t.Errorf("expected critical path %v, got %v", names(expected), names(path))
+	}
+	if total != 3*time.Minute+30*time.Second {
+		t.Errorf("expected total 3m30s, got %v", total)
+	}
+}
+
+func names(vertices []*Vertex) []string {
+	result := make([]string, 0, len(vertices))
+	for _, vertex := range vertices {
+		result = append(result, vertex.Data.Job.Name)
+	}
+	return result
+}
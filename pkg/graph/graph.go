@@ -19,7 +19,7 @@ package graph
 import (
 	"container/list"
 	"fmt"
-	"strings"
+	"sort"
 	"sync"
 
 	batch "k8s.io/api/batch/v1"
@@ -44,99 +44,296 @@ func NewJobInfo(job *batch.Job, finished bool, taskType genev1alpha1.TaskType, d
 	}
 }
 
+// Vertex is a vertex of the graph. The vertices of an execution are synced
+// by several workers, the state that changes while the execution is running
+// is guarded by the lock of the vertex.
 type Vertex struct {
-	Data          *JobInfo
-	Children      []*Vertex
-	dynamic       bool
-	dynamicJobCnt int
-	successCnt    int
+	lock     sync.RWMutex
+	Data     *JobInfo
+	Children []*Vertex
+	dynamic  bool
+
+	// loop is the vertex of the loop task of the body the vertex is in, the
+	// vertex of the loop task keeps the iteration of the whole body.
+	loop      *Vertex
+	iteration int
+	// succeeded are the iterations of the body vertices whose jobs have
+	// succeeded last.
+	succeeded map[*Vertex]int
 }
 
+// Graph is the DAG of the jobs of an execution. The edges are the children
+// of the vertices, the graph indexes the vertices by position and job name
+// and keeps the dependents of every vertex, so that building the graph and
+// handling a job event scale linearly with the number of the jobs.
 type Graph struct {
 	sync.RWMutex
 	NumOfSuccess  int
 	VertexCount   int
-	Size          int
 	VertexArray   []*Vertex
-	AdjMatrix     []int
 	DynamicJobCnt int
+
+	// index is the position of every vertex in VertexArray.
+	index map[*Vertex]int
+	// names are the vertices keyed by their job name.
+	names map[string]*Vertex
+	// dependents are the sets of the vertices every vertex depends on.
+	dependents map[*Vertex]map[*Vertex]struct{}
+	// unfinished is the number of the unfinished dependents of every vertex.
+	unfinished map[*Vertex]int
 }
 
+// NewGraph returns an empty graph with the capacity of size vertices.
 func NewGraph(size int) *Graph {
 	return &Graph{
-		Size:          size,
-		VertexArray:   make([]*Vertex, size),
-		AdjMatrix:     make([]int, size*size),
+		VertexArray:   make([]*Vertex, 0, size),
 		DynamicJobCnt: 0,
+		index:         make(map[*Vertex]int, size),
+		names:         make(map[string]*Vertex, size),
+		dependents:    make(map[*Vertex]map[*Vertex]struct{}, size),
+		unfinished:    make(map[*Vertex]int, size),
 	}
 }
 
 func NewVertex(data *JobInfo, flag bool, children ...*Vertex) *Vertex {
 	vertex := &Vertex{
-		Data:          data,
-		Children:      make([]*Vertex, 0),
-		dynamic:       flag,
-		dynamicJobCnt: 0,
-		successCnt:    0,
+		Data:     data,
+		Children: make([]*Vertex, 0),
+		dynamic:  flag,
 	}
 	vertex.Children = append(vertex.Children, children...)
+	if data != nil && data.DynamicJob != nil && data.DynamicJob.Loop != nil {
+		vertex.loop = vertex
+	}
 
 	return vertex
 }
+
+// IsDynamic returns whether the jobs of the vertex are decided at runtime.
+// A dynamic vertex is replaced by the vertices of its jobs once expanded,
+// except a loop vertex, whose iterations are created one by one.
 func (n *Vertex) IsDynamic() bool {
 	return n.dynamic
 }
 
-func (n *Vertex) SetDynamicJobCnt(cnt int) {
-	if cnt < 0 {
-		return
+// IsLoop returns whether the vertex is a loop task, or a task of the body of
+// a loop, whose iterations are created one by one at runtime.
+func (n *Vertex) IsLoop() bool {
+	return n.loop != nil
+}
+
+// Loop returns the vertex of the loop task of the body the vertex is in,
+// which is the vertex itself for a loop task.
+func (n *Vertex) Loop() *Vertex {
+	return n.loop
+}
+
+// SetLoop adds the vertex to the body of the given loop vertex.
+func (n *Vertex) SetLoop(loop *Vertex) {
+	n.loop = loop
+}
+
+// loopState returns the vertex that keeps the iteration of the vertex.
+func (n *Vertex) loopState() *Vertex {
+	if n.loop != nil {
+		return n.loop
+	}
+	return n
+}
+
+// IsFinished returns whether the job of the vertex has finished.
+func (n *Vertex) IsFinished() bool {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	return n.Data.Finished
+}
+
+// setFinished marks the job of the vertex as finished, it returns false if
+// it has already finished.
+func (n *Vertex) setFinished() bool {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	if n.Data.Finished {
+		return false
 	}
-	n.dynamicJobCnt = cnt
+	n.Data.Finished = true
+	return true
+}
+
+// GetLoopIteration returns the index of the current iteration of the loop
+// the vertex is in.
+func (n *Vertex) GetLoopIteration() int {
+	loop := n.loopState()
+	loop.lock.RLock()
+	defer loop.lock.RUnlock()
+	return loop.iteration
 }
 
-func (n *Vertex) GetDynamicJobCnt() int {
-	return n.dynamicJobCnt
+func (n *Vertex) IncLoopIteration() {
+	loop := n.loopState()
+	loop.lock.Lock()
+	defer loop.lock.Unlock()
+	loop.iteration++
 }
 
-func (n *Vertex) IncDynamicJobSuccCnt() {
+// SetLoopIteration sets the index of the current iteration of the loop the
+// vertex is in, such as the one restored from the execution status.
+func (n *Vertex) SetLoopIteration(iteration int) {
+	loop := n.loopState()
+	loop.lock.Lock()
+	defer loop.lock.Unlock()
+	loop.iteration = iteration
+}
 
-	n.successCnt++
+// SetLoopSucceeded records that the job of the given iteration of the vertex
+// has succeeded.
+func (n *Vertex) SetLoopSucceeded(iteration int) {
+	loop := n.loopState()
+	loop.lock.Lock()
+	defer loop.lock.Unlock()
+	if loop.succeeded == nil {
+		loop.succeeded = make(map[*Vertex]int)
+	}
+	loop.succeeded[n] = iteration
 }
 
-func (n *Vertex) GetDynamicSuccJobCnt() int {
-	return n.successCnt
+// IsLoopSucceeded returns whether the job of the given iteration of the
+// vertex has succeeded.
+func (n *Vertex) IsLoopSucceeded(iteration int) bool {
+	loop := n.loopState()
+	loop.lock.RLock()
+	defer loop.lock.RUnlock()
+	succeeded, ok := loop.succeeded[n]
+	return ok && succeeded == iteration
+}
+
+// GetChildren returns a copy of the children of the vertex.
+func (n *Vertex) GetChildren() []*Vertex {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	children := make([]*Vertex, len(n.Children))
+	copy(children, n.Children)
+	return children
 }
 
 func (n *Vertex) AddChild(vertex *Vertex) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
 	if vertex != nil {
 		n.Children = append(n.Children, vertex)
 	}
 }
 
-func (g *Graph) AddVertex(Vertex *Vertex) {
-	if g.VertexCount >= g.Size {
+// RemoveChild removes the edge from the vertex to the child.
+func (n *Vertex) RemoveChild(vertex *Vertex) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	children := make([]*Vertex, 0, len(n.Children))
+	for _, child := range n.Children {
+		if child != vertex {
+			children = append(children, child)
+		}
+	}
+	n.Children = children
+}
+
+// AddVertex adds a vertex to the graph. The edges of the vertices added are
+// indexed by Build.
+func (g *Graph) AddVertex(vertex *Vertex) {
+	g.Lock()
+	defer g.Unlock()
+	g.addVertex(vertex)
+}
+
+func (g *Graph) addVertex(vertex *Vertex) {
+	if _, ok := g.index[vertex]; ok {
 		return
 	}
-	g.VertexArray[g.VertexCount] = Vertex
-	g.VertexCount++
+	g.index[vertex] = len(g.VertexArray)
+	g.VertexArray = append(g.VertexArray, vertex)
+	g.VertexCount = len(g.VertexArray)
+	g.names[vertex.Data.Job.Name] = vertex
 }
 
-func (g *Graph) SetAdjMatrix() bool {
-	if len(g.VertexArray) != g.Size {
-		return false
+// Build indexes the edges of all the vertices of the graph from their
+// children. It must be called once the vertices and their edges are added.
+func (g *Graph) Build() {
+	g.Lock()
+	defer g.Unlock()
+	g.dependents = make(map[*Vertex]map[*Vertex]struct{}, len(g.VertexArray))
+	g.unfinished = make(map[*Vertex]int, len(g.VertexArray))
+	for _, vertex := range g.VertexArray {
+		g.addEdges(vertex)
 	}
+}
 
-	for row := 0; row < len(g.VertexArray); row++ {
-		for col := 0; col < len(g.VertexArray); col++ {
-			for _, child := range g.VertexArray[row].Children {
-				if child == g.VertexArray[col] {
-					g.AdjMatrix[row*g.Size+col] = 1
-				}
+// addEdges indexes the edges from the vertex to its children in the graph.
+// A duplicated edge is indexed once.
+func (g *Graph) addEdges(vertex *Vertex) {
+	finished := vertex.IsFinished()
+	for _, child := range vertex.GetChildren() {
+		if _, ok := g.index[child]; !ok || g.hasDependent(child, vertex) {
+			continue
+		}
+		g.addDependent(child, vertex)
+		if !finished {
+			g.unfinished[child]++
+		}
+	}
+}
+
+// addEdge indexes the edge from the vertex to the child if it exists.
+func (g *Graph) addEdge(vertex, child *Vertex) {
+	if g.hasDependent(child, vertex) {
+		return
+	}
+	for _, c := range vertex.GetChildren() {
+		if c == child {
+			g.addDependent(child, vertex)
+			if !vertex.IsFinished() {
+				g.unfinished[child]++
 			}
+			return
 		}
 	}
+}
 
-	return true
+func (g *Graph) hasDependent(vertex, dependent *Vertex) bool {
+	_, ok := g.dependents[vertex][dependent]
+	return ok
+}
+
+func (g *Graph) addDependent(vertex, dependent *Vertex) {
+	dependents, ok := g.dependents[vertex]
+	if !ok {
+		dependents = make(map[*Vertex]struct{})
+		g.dependents[vertex] = dependents
+	}
+	dependents[dependent] = struct{}{}
+}
+
+// removeVertex removes the vertex and its edges from the indexes, except its
+// position which is reused by the caller.
+func (g *Graph) removeVertex(vertex *Vertex) {
+	finished := vertex.IsFinished()
+	for _, child := range vertex.GetChildren() {
+		if !g.hasDependent(child, vertex) {
+			continue
+		}
+		delete(g.dependents[child], vertex)
+		if !finished {
+			g.unfinished[child]--
+		}
+	}
+	for dependent := range g.dependents[vertex] {
+		dependent.RemoveChild(vertex)
+	}
+	delete(g.dependents, vertex)
+	delete(g.unfinished, vertex)
+	delete(g.index, vertex)
+	if g.names[vertex.Data.Job.Name] == vertex {
+		delete(g.names, vertex.Data.Job.Name)
+	}
 }
 
 func (g *Graph) DFS(stack *list.List, onStack map[int]bool, visited map[int]bool) error {
@@ -144,19 +341,17 @@ func (g *Graph) DFS(stack *list.List, onStack map[int]bool, visited map[int]bool
 	vertexPos := indexEle.Value.(int)
 	onStack[vertexPos] = true
 	visited[vertexPos] = true
-	for col := 0; col < g.VertexCount; col++ {
-		if g.AdjMatrix[vertexPos*g.Size+col] != 0 {
-			visit, ok := visited[col]
-			if !ok || !visit {
-				ele := stack.PushBack(col)
-				err := g.DFS(stack, onStack, visited)
-				if err != nil {
-					return err
-				}
-				stack.Remove(ele)
-			} else if VertexOnStack, ok := onStack[col]; ok && VertexOnStack {
-				return fmt.Errorf("have Cycle")
+	for _, col := range g.FindChildren(vertexPos) {
+		visit, ok := visited[col]
+		if !ok || !visit {
+			ele := stack.PushBack(col)
+			err := g.DFS(stack, onStack, visited)
+			if err != nil {
+				return err
 			}
+			stack.Remove(ele)
+		} else if VertexOnStack, ok := onStack[col]; ok && VertexOnStack {
+			return fmt.Errorf("have Cycle")
 		}
 	}
 	onStack[vertexPos] = false
@@ -168,6 +363,9 @@ func (g *Graph) CheckCycle(checkEnd int) (bool, []int) {
 	onStack := make(map[int]bool)
 	var processIds []int
 	for index := 0; index < checkEnd; index++ {
+		if visited[index] {
+			continue
+		}
 		stack := list.New()
 		ele := stack.PushBack(index)
 		err := g.DFS(stack, onStack, visited)
@@ -184,12 +382,12 @@ func (g *Graph) CheckCycle(checkEnd int) (bool, []int) {
 }
 
 func (g *Graph) IsDAG() bool {
-	isDAG, _ := g.CheckCycle(g.Size)
+	isDAG, _ := g.CheckCycle(g.VertexCount)
 	return isDAG
 }
 
 func (g *Graph) DirectedTraverse(start int) ([]int, error) {
-	if start >= g.Size {
+	if start >= g.VertexCount {
 		return nil, fmt.Errorf("start Vertex exceed the graph size")
 	}
 	visited := make(map[int]bool)
@@ -205,25 +403,29 @@ func (g *Graph) DirectedTraverse(start int) ([]int, error) {
 	return vertices, nil
 }
 
+// FindDependents returns the positions of the vertices the vertex at the
+// given position depends on, in ascending order.
 func (g *Graph) FindDependents(vertex int) []int {
 	dependents := []int{}
-	for row := 0; row < g.VertexCount; row++ {
-		if g.AdjMatrix[row*g.Size+vertex] != 0 {
-			dependents = append(dependents, row)
-		}
+	for dependent := range g.dependents[g.VertexArray[vertex]] {
+		dependents = append(dependents, g.index[dependent])
 	}
-
+	sort.Ints(dependents)
 	return dependents
 }
 
+// FindChildren returns the positions of the children of the vertex at the
+// given position, in ascending order.
 func (g *Graph) FindChildren(vertex int) []int {
 	children := []int{}
-	for col := 0; col < g.VertexCount; col++ {
-		if g.AdjMatrix[vertex*g.Size+col] != 0 {
-			children = append(children, col)
+	seen := make(map[int]bool)
+	for _, child := range g.VertexArray[vertex].GetChildren() {
+		if index, ok := g.index[child]; ok && !seen[index] {
+			seen[index] = true
+			children = append(children, index)
 		}
 	}
-
+	sort.Ints(children)
 	return children
 }
 
@@ -251,65 +453,176 @@ func (g *Graph) dirTraverse(start int, visited map[int]bool, queue *list.List) {
 }
 
 func (g *Graph) FindChildrenByName(jobName string) []*Vertex {
-	for _, vertex := range g.VertexArray {
-		if jobInfo := vertex.Data; jobInfo.Job.Name == jobName {
-			return vertex.Children
-		}
-		//jobNamePrefix := execution.Name + Separator + task.Name + Separator
-		if vertex.IsDynamic() {
-			if jobInfo := vertex.Data; strings.HasPrefix(jobName, jobInfo.Job.Name) {
-				return vertex.Children
-			}
-		}
+	g.RLock()
+	defer g.RUnlock()
+	if vertex := g.names[jobName]; vertex != nil {
+		return vertex.GetChildren()
 	}
-
 	return nil
 }
 
 func (g *Graph) FindDependentsByName(jobName string) []int {
-	for i, vertex := range g.VertexArray {
-		if jobInfo := vertex.Data; jobInfo.Job.Name == jobName {
-			return g.FindDependents(i)
-		}
-		//jobNamePrefix := execution.Name + Separator + task.Name + Separator
-		if vertex.IsDynamic() {
-			if jobInfo := vertex.Data; strings.HasPrefix(jobName, jobInfo.Job.Name) {
-				return g.FindDependents(i)
-			}
-		}
+	g.RLock()
+	defer g.RUnlock()
+	if vertex := g.names[jobName]; vertex != nil {
+		return g.FindDependents(g.index[vertex])
 	}
-
 	return nil
 }
 
+// GetRootVertex returns the vertices that depend on no vertex.
 func (g *Graph) GetRootVertex() []*Vertex {
+	g.RLock()
+	defer g.RUnlock()
 	rootVertex := make([]*Vertex, 0)
-	for i, vertex := range g.VertexArray {
-		if len(g.FindDependents(i)) == 0 {
+	for _, vertex := range g.VertexArray {
+		if len(g.dependents[vertex]) == 0 {
 			rootVertex = append(rootVertex, vertex)
 		}
 	}
 	return rootVertex
 }
 
+// FindVertexByName returns the vertex whose job has the given name.
 func (g *Graph) FindVertexByName(jobName string) *Vertex {
-	for _, vertex := range g.VertexArray {
-		if jobInfo := vertex.Data; jobInfo.Job.Name == jobName {
-			return vertex
+	g.RLock()
+	defer g.RUnlock()
+	return g.names[jobName]
+}
+
+// HasVertex returns whether the vertex is in the graph.
+func (g *Graph) HasVertex(vertex *Vertex) bool {
+	g.RLock()
+	defer g.RUnlock()
+	_, ok := g.index[vertex]
+	return ok
+}
+
+// FindDependentVertices returns the vertices the given vertex depends on,
+// in the order of their positions.
+func (g *Graph) FindDependentVertices(vertex *Vertex) []*Vertex {
+	g.RLock()
+	defer g.RUnlock()
+	return g.sortedDependents(vertex)
+}
+
+// sortedDependents returns the vertices the given vertex depends on, in the
+// order of their positions. The caller should hold the lock.
+func (g *Graph) sortedDependents(vertex *Vertex) []*Vertex {
+	dependents := make([]*Vertex, 0, len(g.dependents[vertex]))
+	for dependent := range g.dependents[vertex] {
+		dependents = append(dependents, dependent)
+	}
+	sort.Slice(dependents, func(i, j int) bool {
+		return g.index[dependents[i]] < g.index[dependents[j]]
+	})
+	return dependents
+}
+
+// DependentsFinished returns whether all the vertices the given vertex
+// depends on have finished.
+func (g *Graph) DependentsFinished(vertex *Vertex) bool {
+	g.RLock()
+	defer g.RUnlock()
+	return g.unfinished[vertex] == 0
+}
+
+// MarkFinished marks the job of the vertex as finished.
+func (g *Graph) MarkFinished(vertex *Vertex) {
+	g.Lock()
+	defer g.Unlock()
+	if !vertex.setFinished() {
+		return
+	}
+	if _, ok := g.index[vertex]; !ok {
+		return
+	}
+	for _, child := range vertex.GetChildren() {
+		if g.hasDependent(child, vertex) {
+			g.unfinished[child]--
 		}
-		//jobNamePrefix := execution.Name + Separator + task.Name + Separator
-		if vertex.IsDynamic() {
-			if jobInfo := vertex.Data; strings.HasPrefix(jobName, jobInfo.Job.Name) {
-				return vertex
-			}
+	}
+}
+
+// ReplaceVertex replaces a vertex with the given vertices, such as a dynamic
+// vertex that is expanded at runtime. The edges of the new vertices should
+// be added by the caller before, from the dependents of the old vertex and
+// to the vertices of the graph. The edges to the old vertex are removed.
+// The first new vertex takes the position of the old one and the others are
+// appended, so that the order of the other vertices is kept. If there are no
+// new vertices, the vertices after the old one move up by one.
+func (g *Graph) ReplaceVertex(old *Vertex, vertices []*Vertex) bool {
+	g.Lock()
+	defer g.Unlock()
+
+	index, ok := g.index[old]
+	if !ok {
+		return false
+	}
+	dependents := g.sortedDependents(old)
+	g.removeVertex(old)
+
+	if len(vertices) == 0 {
+		// the vertices after the old one move up by one.
+		last := len(g.VertexArray) - 1
+		copy(g.VertexArray[index:], g.VertexArray[index+1:])
+		g.VertexArray[last] = nil
+		g.VertexArray = g.VertexArray[:last]
+		for i := index; i < last; i++ {
+			g.index[g.VertexArray[i]] = i
 		}
 	}
+	for i, vertex := range vertices {
+		if i == 0 {
+			g.VertexArray[index] = vertex
+		} else {
+			index = len(g.VertexArray)
+			g.VertexArray = append(g.VertexArray, vertex)
+		}
+		g.index[vertex] = index
+		g.names[vertex.Data.Job.Name] = vertex
+	}
+	g.VertexCount = len(g.VertexArray)
 
-	return nil
+	for _, vertex := range vertices {
+		g.addEdges(vertex)
+		for _, dependent := range dependents {
+			g.addEdge(dependent, vertex)
+		}
+	}
+
+	return true
+}
+
+// AppendVertices adds the given vertices to a graph that has been built,
+// such as the exit handler vertices that are added after the others
+// have finished.
+func (g *Graph) AppendVertices(vertices []*Vertex) {
+	g.Lock()
+	defer g.Unlock()
+
+	for _, vertex := range vertices {
+		g.addVertex(vertex)
+	}
+	for _, vertex := range vertices {
+		g.addEdges(vertex)
+	}
+}
+
+// Vertices returns a snapshot of the vertices of the graph, the vertices may
+// be replaced by other workers while the caller ranges over them.
+func (g *Graph) Vertices() []*Vertex {
+	g.RLock()
+	defer g.RUnlock()
+	vertices := make([]*Vertex, g.VertexCount)
+	copy(vertices, g.VertexArray[:g.VertexCount])
+	return vertices
 }
 
 func (g *Graph) FindVertex(vertex int) *Vertex {
-	if vertex > g.VertexCount {
+	g.RLock()
+	defer g.RUnlock()
+	if vertex >= g.VertexCount {
 		return nil
 	}
 
@@ -328,6 +641,13 @@ func (g *Graph) GetNumOfSuccess() int {
 	return g.NumOfSuccess
 }
 
+// AllSucceeded returns whether all the jobs of the graph have succeeded.
+func (g *Graph) AllSucceeded() bool {
+	g.RLock()
+	defer g.RUnlock()
+	return g.NumOfSuccess == g.VertexCount+g.DynamicJobCnt
+}
+
 func (g *Graph) AddDynamicJobCnt(cnt int) {
 	g.Lock()
 	defer g.Unlock()
@@ -18,20 +18,79 @@ package graph
 
 import (
 	"reflect"
+	"strconv"
+	"sync"
 	"testing"
+
+	batch "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-func TestSetAdjMatrix(t *testing.T) {
+func TestBuild(t *testing.T) {
 	graph, vertices := newTestGraph(4)
 
 	vertices[0].AddChild(vertices[2])
 	vertices[1].AddChild(vertices[3])
+	vertices[1].AddChild(vertices[3])
+
+	graph.Build()
+	expectedChildren := [][]int{{2}, {3}, {}, {}}
+	if children := childrenOf(graph); !reflect.DeepEqual(children, expectedChildren) {
+		t.Errorf("expected children %#v, got %#v", expectedChildren, children)
+	}
+	if dependents := graph.FindDependents(3); !reflect.DeepEqual(dependents, []int{1}) {
+		t.Errorf("expected dependents [1], got %#v", dependents)
+	}
+	if roots := graph.GetRootVertex(); !reflect.DeepEqual(roots, vertices[:2]) {
+		t.Errorf("expected the first 2 vertices as roots, got %#v", roots)
+	}
+}
+
+func TestFindVertexByName(t *testing.T) {
+	graph := NewGraph(2)
+	static := newTestVertex("exec.sort.0")
+	dynamic := NewVertex(&JobInfo{Job: &batch.Job{ObjectMeta: metav1.ObjectMeta{Name: "exec.align."}}}, true)
+	graph.AddVertex(static)
+	graph.AddVertex(dynamic)
+	graph.Build()
 
-	graph.SetAdjMatrix()
-	expectedAdjMatrix := []int{0, 0, 1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0}
+	for name, expected := range map[string]*Vertex{
+		"exec.sort.0":  static,
+		"exec.align.":  dynamic,
+		"exec.align.3": nil,
+		"exec.sort.1":  nil,
+	} {
+		if vertex := graph.FindVertexByName(name); vertex != expected {
+			t.Errorf("%s: expected vertex %v, got %v", name, expected, vertex)
+		}
+	}
+}
+
+func TestDependentsFinished(t *testing.T) {
+	graph, vertices := newTestGraph(3)
+
+	vertices[0].AddChild(vertices[2])
+	vertices[1].AddChild(vertices[2])
+	graph.Build()
+
+	graph.MarkFinished(vertices[0])
+	if graph.DependentsFinished(vertices[2]) {
+		t.Errorf("expected a dependent to be unfinished")
+	}
+	// a vertex is counted once however many times it is marked.
+	graph.MarkFinished(vertices[0])
+	graph.MarkFinished(vertices[1])
+	if !graph.DependentsFinished(vertices[2]) {
+		t.Errorf("expected all the dependents to be finished")
+	}
 
-	if !reflect.DeepEqual(graph.AdjMatrix, expectedAdjMatrix) {
-		t.Errorf("expected matrix %#v, got %#v", expectedAdjMatrix, graph.AdjMatrix)
+	// the new vertices of an expanded vertex depend on its dependents.
+	news := []*Vertex{newTestVertex("exec.new.1")}
+	vertices[1].AddChild(news[0])
+	news[0].AddChild(vertices[2])
+	graph.ReplaceVertex(vertices[1], news)
+	if graph.DependentsFinished(vertices[2]) || !graph.DependentsFinished(news[0]) {
+		t.Errorf("expected the new vertex to be the unfinished dependent")
 	}
 }
 
@@ -44,7 +103,7 @@ func TestIsDAG(t *testing.T) {
 	vertices[1].AddChild(vertices[3])
 	vertices[2].AddChild(vertices[4])
 	vertices[3].AddChild(vertices[2])
-	graph.SetAdjMatrix()
+	graph.Build()
 
 	isDAG := graph.IsDAG()
 	if !isDAG {
@@ -53,7 +112,7 @@ func TestIsDAG(t *testing.T) {
 
 	// set cycle
 	vertices[4].AddChild(vertices[3])
-	graph.SetAdjMatrix()
+	graph.Build()
 	isDAG = graph.IsDAG()
 	if isDAG {
 		t.Errorf("expected graph not a DAG")
@@ -66,7 +125,7 @@ func TestDirectedTraverse(t *testing.T) {
 	vertices[0].AddChild(vertices[2])
 	vertices[1].AddChild(vertices[3])
 	vertices[2].AddChild(vertices[4])
-	graph.SetAdjMatrix()
+	graph.Build()
 
 	expectedSeqs := []int{0, 2, 4}
 	seqs, err := graph.DirectedTraverse(0)
@@ -84,7 +143,7 @@ func TestDirectedTraverse(t *testing.T) {
 	vertices[2].AddChild(vertices[4])
 	vertices[3].AddChild(vertices[2])
 
-	graph.SetAdjMatrix()
+	graph.Build()
 
 	expectedSeqs = []int{0, 1, 3, 2, 4}
 	seqs, err = graph.DirectedTraverse(0)
@@ -96,13 +155,152 @@ func TestDirectedTraverse(t *testing.T) {
 	}
 }
 
+func TestReplaceVertex(t *testing.T) {
+	graph, vertices := newTestGraph(3)
+
+	vertices[0].AddChild(vertices[1])
+	vertices[1].AddChild(vertices[2])
+	graph.Build()
+
+	// expand vertex 1 into two vertices.
+	news := []*Vertex{newTestVertex("exec.new.2"), newTestVertex("exec.new.3")}
+	for _, vertex := range news {
+		vertices[0].AddChild(vertex)
+		vertex.AddChild(vertices[2])
+	}
+	if !graph.ReplaceVertex(vertices[1], news) {
+		t.Fatalf("expected vertex to be replaced")
+	}
+
+	if graph.VertexCount != 4 || graph.HasVertex(vertices[1]) {
+		t.Errorf("expected 4 vertices without the replaced one, got %d", graph.VertexCount)
+	}
+	// the first new vertex takes the position of the replaced one, the
+	// others are appended.
+	expectedChildren := [][]int{{1, 3}, {2}, {}, {2}}
+	if children := childrenOf(graph); !reflect.DeepEqual(children, expectedChildren) {
+		t.Errorf("expected children %#v, got %#v", expectedChildren, children)
+	}
+	if dependents := graph.FindDependentVertices(vertices[2]); !reflect.DeepEqual(dependents, news) {
+		t.Errorf("expected dependents %#v, got %#v", news, dependents)
+	}
+
+	// replace a vertex with nothing, the vertices after it move up.
+	graph.ReplaceVertex(news[0], nil)
+	if graph.VertexCount != 3 || len(vertices[0].Children) != 1 {
+		t.Errorf("expected 3 vertices and 1 child, got %d and %d", graph.VertexCount, len(vertices[0].Children))
+	}
+	expectedChildren = [][]int{{2}, {}, {1}}
+	if children := childrenOf(graph); !reflect.DeepEqual(children, expectedChildren) {
+		t.Errorf("expected children %#v, got %#v", expectedChildren, children)
+	}
+	if dependents := graph.FindDependents(1); !reflect.DeepEqual(dependents, []int{2}) {
+		t.Errorf("expected dependents %v, got %v", []int{2}, dependents)
+	}
+	order, err := graph.TopologicalOrder()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectedOrder := []*Vertex{vertices[0], news[1], vertices[2]}
+	if !reflect.DeepEqual(order, expectedOrder) {
+		t.Errorf("expected order %v, got %v", names(expectedOrder), names(order))
+	}
+	if graph.ReplaceVertex(news[0], nil) {
+		t.Errorf("expected removed vertex not to be replaced")
+	}
+}
+
+func TestBuildFanIn(t *testing.T) {
+	graph, vertices := newTestGraph(20001)
+
+	// every vertex is a dependent of the last one.
+	last := vertices[len(vertices)-1]
+	for _, vertex := range vertices[:len(vertices)-1] {
+		vertex.AddChild(last)
+	}
+	graph.Build()
+
+	if dependents := graph.FindDependents(len(vertices) - 1); len(dependents) != 20000 || dependents[19999] != 19999 {
+		t.Errorf("expected 20000 dependents in ascending order, got %d", len(dependents))
+	}
+	for _, vertex := range vertices[:len(vertices)-1] {
+		graph.MarkFinished(vertex)
+	}
+	if !graph.DependentsFinished(last) {
+		t.Errorf("expected the dependents of the last vertex to be finished")
+	}
+}
+
+func TestAppendVertices(t *testing.T) {
+	graph, vertices := newTestGraph(2)
+
+	vertices[0].AddChild(vertices[1])
+	graph.Build()
+
+	vertex := newTestVertex("exec.new.4")
+	graph.AppendVertices([]*Vertex{vertex})
+
+	if graph.VertexCount != 3 || !graph.HasVertex(vertex) {
+		t.Errorf("expected 3 vertices with the appended one, got %d", graph.VertexCount)
+	}
+	expectedChildren := [][]int{{1}, {}, {}}
+	if children := childrenOf(graph); !reflect.DeepEqual(children, expectedChildren) {
+		t.Errorf("expected children %#v, got %#v", expectedChildren, children)
+	}
+}
+
+func TestConcurrentUpdates(t *testing.T) {
+	graph := NewGraph(2)
+	first := newTestVertex("exec.new.5")
+	second := newTestVertex("exec.new.6")
+	graph.AddVertex(first)
+	graph.AddVertex(second)
+	graph.Build()
+
+	// the jobs of an execution finish while its dynamic vertices are expanded.
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			graph.MarkFinished(first)
+			first.IncLoopIteration()
+			graph.PlusNumOfSuccess()
+		}()
+		go func() {
+			defer wg.Done()
+			graph.AppendVertices([]*Vertex{newTestVertex("exec.new.7")})
+			for _, vertex := range graph.Vertices() {
+				vertex.IsFinished()
+				vertex.GetChildren()
+			}
+			graph.AllSucceeded()
+		}()
+	}
+	wg.Wait()
+
+	if !first.IsFinished() || first.GetLoopIteration() != 10 || graph.GetNumOfSuccess() != 10 {
+		t.Errorf("expected 10 iterations and successes, got %d and %d", first.GetLoopIteration(), graph.GetNumOfSuccess())
+	}
+	if len(graph.Vertices()) != 12 {
+		t.Errorf("expected 12 vertices, got %d", len(graph.Vertices()))
+	}
+}
+
+func childrenOf(graph *Graph) [][]int {
+	children := make([][]int, graph.VertexCount)
+	for i := range children {
+		children[i] = graph.FindChildren(i)
+	}
+	return children
+}
+
 func newTestGraph(size int) (*Graph, []*Vertex) {
 	graph := NewGraph(size)
 	vertices := make([]*Vertex, size)
-	jobInfo := &JobInfo{}
 
 	for i := 0; i < size; i++ {
-		vertex := NewVertex(jobInfo, false)
+		vertex := newTestVertex("exec.task." + strconv.Itoa(i))
 		vertices[i] = vertex
 
 		graph.AddVertex(vertex)
@@ -110,3 +308,7 @@ func newTestGraph(size int) (*Graph, []*Vertex) {
 
 	return graph, vertices
 }
+
+func newTestVertex(name string) *Vertex {
+	return NewVertex(&JobInfo{Job: &batch.Job{ObjectMeta: metav1.ObjectMeta{Name: name}}}, false)
+}
@@ -326,7 +326,7 @@ func ValidateTemplate(template, prefix, typeStr string, inputs map[string]Input)
 	maxIndex := 0
 	for _, subMatch := range subMatches {
 		variant := subMatch[1]
-		if variant == "item" {
+		if variant == "item" || variant == "iteration" || IsExitVariant(variant) {
 			continue
 		}
 		if index, ok := ToArrayIndex(variant); ok {
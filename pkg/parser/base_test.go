@@ -230,6 +230,30 @@ func TestVarIter2Vars(t *testing.T) {
 	}
 }
 
+func TestZipVars(t *testing.T) {
+	varIter := []common.Var{
+		{1, 2},
+		{3, 4},
+		{5, 6},
+	}
+	expect := []common.Var{
+		{1, 3, 5},
+		{2, 4, 6},
+	}
+
+	result, err := common.ZipVars(varIter)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(result, expect) {
+		t.Errorf("unexpected result: got %v, expected %v", result, expect)
+	}
+
+	if _, err := common.ZipVars([]common.Var{{1, 2}, {3}}); err == nil {
+		t.Errorf("expect error for rows of different length, but got nil")
+	}
+}
+
 func TestInstantiateRangeFunc(t *testing.T) {
 	testCases := []struct {
 		str          string
@@ -77,24 +77,18 @@ func validateCheckResultDependency(prefix string, jobName string, dependJobName
 		return err
 	}
 
-	// depend job should have single command only because it should be single k8s- job related to that job
-
-	if (len(dependJob.Commands) > 1) || (len(dependJob.CommandsIter.Vars) > 1) || (len(dependJob.CommandsIter.VarsIter) > 1) {
-		err := fmt.Errorf("the check_result function dependecy job has more than one command %s dependjobName :%s", prefix, dependJobName)
+	if dependJob.SubWorkflow != nil {
+		err := fmt.Errorf("%s: the check_result function dependecy job %s references a sub workflow", prefix, dependJobName)
 		return err
 	}
 
+	// the result of a job with more than one command is aggregated from all of its k8s jobs.
 	currentJob, ok := workflow.Jobs[jobName]
 	if !ok {
 		err := fmt.Errorf("%s: the check_result function  job is missing, but the real one is %s", prefix, dependJobName)
 		return err
 	}
 
-	if len(currentJob.Depends) != 1 {
-		err := fmt.Errorf("%s: the check_result  job has more dependecies %v", prefix, currentJob.Depends)
-		return err
-	}
-
 	for i := 0; i < len(currentJob.Depends); i++ {
 		if (currentJob.Depends[i].Target == dependJobName) &&
 			(currentJob.Depends[i].Type) == "whole" {
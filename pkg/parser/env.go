@@ -0,0 +1,217 @@
+/*
+Copyright 2019 The Kubegene Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package parser
+
+import (
+	"fmt"
+	"regexp"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"kubegene.io/kubegene/pkg/common"
+)
+
+const EnvNameRegexFmt = `^[-._a-zA-Z][-._a-zA-Z0-9]*$`
+
+// ValidateEnv validates the environment variables. The values and the names
+// and keys of the ConfigMaps and Secrets can reference the inputs.
+func ValidateEnv(prefix string, env []EnvVar, inputs map[string]Input) ErrorList {
+	errors := ErrorList{}
+	names := make(map[string]bool, len(env))
+	for i, envVar := range env {
+		envPrefix := fmt.Sprintf("%s.env[%d]", prefix, i)
+		if matched, _ := regexp.MatchString(EnvNameRegexFmt, envVar.Name); !matched {
+			errors = append(errors, fmt.Errorf("%s.name %q is illegal", envPrefix, envVar.Name))
+		} else if names[envVar.Name] {
+			errors = append(errors, fmt.Errorf("%s.name %s is duplicated", envPrefix, envVar.Name))
+		}
+		names[envVar.Name] = true
+
+		_, errs := ValidateTemplate(envVar.Value, envPrefix+".value", "value", inputs)
+		errors = append(errors, errs...)
+		if envVar.ValueFrom == nil {
+			continue
+		}
+		if len(envVar.Value) != 0 {
+			errors = append(errors, fmt.Errorf("%s: value and value_from can not be both specified", envPrefix))
+		}
+		errors = append(errors, validateEnvVarSource(envPrefix+".value_from", envVar.ValueFrom, inputs)...)
+	}
+	return errors
+}
+
+func validateEnvVarSource(prefix string, source *EnvVarSource, inputs map[string]Input) ErrorList {
+	errors := ErrorList{}
+	refs := map[string]*KeySelector{}
+	if source.ConfigMapKeyRef != nil {
+		refs["config_map_key_ref"] = source.ConfigMapKeyRef
+	}
+	if source.SecretKeyRef != nil {
+		refs["secret_key_ref"] = source.SecretKeyRef
+	}
+	if len(refs) != 1 {
+		return append(errors, fmt.Errorf("%s: exactly one of config_map_key_ref and secret_key_ref should be specified", prefix))
+	}
+	for field, ref := range refs {
+		if len(ref.Name) == 0 || len(ref.Key) == 0 {
+			errors = append(errors, fmt.Errorf("%s.%s: name and key should not be empty", prefix, field))
+		}
+		_, errs := ValidateTemplate(ref.Name, prefix+"."+field+".name", "name", inputs)
+		errors = append(errors, errs...)
+		_, errs = ValidateTemplate(ref.Key, prefix+"."+field+".key", "key", inputs)
+		errors = append(errors, errs...)
+	}
+	return errors
+}
+
+// ValidateEnvFrom validates the ConfigMaps and Secrets of env_from.
+func ValidateEnvFrom(prefix string, envFrom []EnvFromSource, inputs map[string]Input) ErrorList {
+	errors := ErrorList{}
+	for i, source := range envFrom {
+		sourcePrefix := fmt.Sprintf("%s.env_from[%d]", prefix, i)
+		if (len(source.ConfigMap) == 0) == (len(source.Secret) == 0) {
+			errors = append(errors, fmt.Errorf("%s: exactly one of config_map and secret should be specified", sourcePrefix))
+			continue
+		}
+		if len(source.Prefix) != 0 {
+			if matched, _ := regexp.MatchString(EnvNameRegexFmt, source.Prefix); !matched {
+				errors = append(errors, fmt.Errorf("%s.prefix %q is illegal", sourcePrefix, source.Prefix))
+			}
+		}
+		_, errs := ValidateTemplate(source.ConfigMap+source.Secret, sourcePrefix, "name", inputs)
+		errors = append(errors, errs...)
+	}
+	return errors
+}
+
+// InstantiateEnv replaces the inputs referenced by the environment variables.
+func InstantiateEnv(env []EnvVar, data map[string]string) []EnvVar {
+	if len(env) == 0 {
+		return nil
+	}
+	instance := make([]EnvVar, 0, len(env))
+	for _, envVar := range env {
+		envVar.Value = common.ReplaceVariant(envVar.Value, data)
+		if envVar.ValueFrom != nil {
+			envVar.ValueFrom = &EnvVarSource{
+				ConfigMapKeyRef: instantiateKeySelector(envVar.ValueFrom.ConfigMapKeyRef, data),
+				SecretKeyRef:    instantiateKeySelector(envVar.ValueFrom.SecretKeyRef, data),
+			}
+		}
+		instance = append(instance, envVar)
+	}
+	return instance
+}
+
+func instantiateKeySelector(ref *KeySelector, data map[string]string) *KeySelector {
+	if ref == nil {
+		return nil
+	}
+	return &KeySelector{
+		Name:     common.ReplaceVariant(ref.Name, data),
+		Key:      common.ReplaceVariant(ref.Key, data),
+		Optional: ref.Optional,
+	}
+}
+
+// InstantiateEnvFrom replaces the inputs referenced by the names of the
+// ConfigMaps and Secrets.
+func InstantiateEnvFrom(envFrom []EnvFromSource, data map[string]string) []EnvFromSource {
+	if len(envFrom) == 0 {
+		return nil
+	}
+	instance := make([]EnvFromSource, 0, len(envFrom))
+	for _, source := range envFrom {
+		source.ConfigMap = common.ReplaceVariant(source.ConfigMap, data)
+		source.Secret = common.ReplaceVariant(source.Secret, data)
+		instance = append(instance, source)
+	}
+	return instance
+}
+
+func TransEnv2ExecEnv(env []EnvVar) []corev1.EnvVar {
+	var execEnv []corev1.EnvVar
+	for _, envVar := range env {
+		execEnvVar := corev1.EnvVar{Name: envVar.Name, Value: envVar.Value}
+		if source := envVar.ValueFrom; source != nil {
+			execEnvVar.ValueFrom = &corev1.EnvVarSource{}
+			if ref := source.ConfigMapKeyRef; ref != nil {
+				execEnvVar.ValueFrom.ConfigMapKeyRef = &corev1.ConfigMapKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: ref.Name},
+					Key:                  ref.Key,
+					Optional:             optional(ref.Optional),
+				}
+			}
+			if ref := source.SecretKeyRef; ref != nil {
+				execEnvVar.ValueFrom.SecretKeyRef = &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: ref.Name},
+					Key:                  ref.Key,
+					Optional:             optional(ref.Optional),
+				}
+			}
+		}
+		execEnv = append(execEnv, execEnvVar)
+	}
+	return execEnv
+}
+
+// TransExecEnv2Env is the reverse of TransEnv2ExecEnv, the sources other than
+// ConfigMaps and Secrets are dropped.
+func TransExecEnv2Env(execEnv []corev1.EnvVar) []EnvVar {
+	var env []EnvVar
+	for _, execEnvVar := range execEnv {
+		envVar := EnvVar{Name: execEnvVar.Name, Value: execEnvVar.Value}
+		if source := execEnvVar.ValueFrom; source != nil {
+			envVar.ValueFrom = &EnvVarSource{}
+			if ref := source.ConfigMapKeyRef; ref != nil {
+				envVar.ValueFrom.ConfigMapKeyRef = &KeySelector{Name: ref.Name, Key: ref.Key, Optional: ref.Optional != nil && *ref.Optional}
+			}
+			if ref := source.SecretKeyRef; ref != nil {
+				envVar.ValueFrom.SecretKeyRef = &KeySelector{Name: ref.Name, Key: ref.Key, Optional: ref.Optional != nil && *ref.Optional}
+			}
+		}
+		env = append(env, envVar)
+	}
+	return env
+}
+
+func TransEnvFrom2ExecEnvFrom(envFrom []EnvFromSource) []corev1.EnvFromSource {
+	var execEnvFrom []corev1.EnvFromSource
+	for _, source := range envFrom {
+		execSource := corev1.EnvFromSource{Prefix: source.Prefix}
+		if len(source.ConfigMap) != 0 {
+			execSource.ConfigMapRef = &corev1.ConfigMapEnvSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: source.ConfigMap},
+				Optional:             optional(source.Optional),
+			}
+		} else {
+			execSource.SecretRef = &corev1.SecretEnvSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: source.Secret},
+				Optional:             optional(source.Optional),
+			}
+		}
+		execEnvFrom = append(execEnvFrom, execSource)
+	}
+	return execEnvFrom
+}
+
+func optional(value bool) *bool {
+	if !value {
+		return nil
+	}
+	return &value
+}
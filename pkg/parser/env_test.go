@@ -0,0 +1,141 @@
+/*
+Copyright 2019 The Kubegene Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package parser
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestValidateEnv(t *testing.T) {
+	inputs := map[string]Input{"sample": {Type: StringType}}
+	testCases := []struct {
+		name    string
+		env     []EnvVar
+		envFrom []EnvFromSource
+		expErr  bool
+	}{
+		{
+			name: "valid",
+			env: []EnvVar{
+				{Name: "SAMPLE", Value: "${sample}"},
+				{Name: "LICENSE", ValueFrom: &EnvVarSource{SecretKeyRef: &KeySelector{Name: "license-${sample}", Key: "key"}}},
+			},
+			envFrom: []EnvFromSource{{ConfigMap: "db-config"}, {Secret: "db-credentials", Prefix: "DB_"}},
+		},
+		{name: "illegal name", env: []EnvVar{{Name: "1 SAMPLE"}}, expErr: true},
+		{name: "duplicated name", env: []EnvVar{{Name: "A"}, {Name: "A"}}, expErr: true},
+		{name: "undefined input", env: []EnvVar{{Name: "A", Value: "${missing}"}}, expErr: true},
+		{
+			name: "value and value_from",
+			env: []EnvVar{
+				{Name: "A", Value: "a", ValueFrom: &EnvVarSource{ConfigMapKeyRef: &KeySelector{Name: "config", Key: "a"}}},
+			},
+			expErr: true,
+		},
+		{
+			name:   "value_from without source",
+			env:    []EnvVar{{Name: "A", ValueFrom: &EnvVarSource{}}},
+			expErr: true,
+		},
+		{
+			name:   "value_from without key",
+			env:    []EnvVar{{Name: "A", ValueFrom: &EnvVarSource{SecretKeyRef: &KeySelector{Name: "secret"}}}},
+			expErr: true,
+		},
+		{name: "env_from without source", envFrom: []EnvFromSource{{Prefix: "DB_"}}, expErr: true},
+		{name: "env_from with both sources", envFrom: []EnvFromSource{{ConfigMap: "a", Secret: "b"}}, expErr: true},
+		{name: "env_from illegal prefix", envFrom: []EnvFromSource{{ConfigMap: "a", Prefix: "1 A"}}, expErr: true},
+	}
+
+	for _, testCase := range testCases {
+		errs := append(ValidateEnv("workflow.job", testCase.env, inputs), ValidateEnvFrom("workflow.job", testCase.envFrom, inputs)...)
+		if testCase.expErr && len(errs) == 0 {
+			t.Errorf("%s: expected error, but got nil", testCase.name)
+		}
+		if !testCase.expErr && len(errs) != 0 {
+			t.Errorf("%s: unexpected error: %v", testCase.name, errs)
+		}
+	}
+}
+
+func TestInstantiateEnv(t *testing.T) {
+	workflowStr := `
+version: genecontainer_0_1
+inputs:
+  sample:
+    default: NA12878
+    type: string
+workflow:
+  align:
+    tool: bwa:0.7.17
+    env:
+    - name: SAMPLE
+      value: ${sample}
+    - name: LICENSE_KEY
+      value_from:
+        secret_key_ref:
+          name: license-${sample}
+          key: key
+    env_from:
+    - config_map: db-config
+      optional: true
+    commands:
+    - bwa mem $SAMPLE
+`
+	workflow, err := UnmarshalWorkflow([]byte(workflowStr))
+	if err != nil {
+		t.Fatalf("unmarshal workflow err: %v", err)
+	}
+	SetDefaultWorkflow(workflow)
+	if errs := ValidateWorkflow(workflow); len(errs) != 0 {
+		t.Fatalf("unexpected validate error: %v", errs)
+	}
+	bwa := Tool{Name: "bwa", Version: "0.7.17", Image: "bwa:0.7.17"}
+	if err := InstantiateWorkflow(workflow, nil, TransTools2Map([]Tool{bwa})); err != nil {
+		t.Fatalf("unexpected instantiate error: %v", err)
+	}
+	exec, err := TransWorkflow2Execution(workflow)
+	if err != nil {
+		t.Fatalf("unexpected trans error: %v", err)
+	}
+
+	task := exec.Spec.Tasks[0]
+	expectEnv := []corev1.EnvVar{
+		{Name: "SAMPLE", Value: "NA12878"},
+		{Name: "LICENSE_KEY", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "license-NA12878"},
+			Key:                  "key",
+		}}},
+	}
+	if !reflect.DeepEqual(task.Env, expectEnv) {
+		t.Errorf("expected env %v, got %v", expectEnv, task.Env)
+	}
+	optional := true
+	expectEnvFrom := []corev1.EnvFromSource{{ConfigMapRef: &corev1.ConfigMapEnvSource{
+		LocalObjectReference: corev1.LocalObjectReference{Name: "db-config"},
+		Optional:             &optional,
+	}}}
+	if !reflect.DeepEqual(task.EnvFrom, expectEnvFrom) {
+		t.Errorf("expected env from %v, got %v", expectEnvFrom, task.EnvFrom)
+	}
+	if !reflect.DeepEqual(TransExecEnv2Env(task.Env), workflow.Jobs["align"].Env) {
+		t.Errorf("expected env %v to be converted back, got %v", workflow.Jobs["align"].Env, TransExecEnv2Env(task.Env))
+	}
+}
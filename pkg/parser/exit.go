@@ -0,0 +1,117 @@
+/*
+Copyright 2019 The Kubegene Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package parser
+
+import (
+	"fmt"
+
+	"kubegene.io/kubegene/pkg/common"
+)
+
+// ValidateOnExit validate the exit handler jobs of the workflow. An exit
+// handler job is not a part of the workflow DAG, so it can not depend on
+// or be depended on by other jobs, and its commands must be decided before
+// running. Only the exit handler jobs can use the exit variants.
+func ValidateOnExit(workflow *Workflow) ErrorList {
+	allErr := ErrorList{}
+	allErr = append(allErr, validateExitVariants(workflow)...)
+	if len(workflow.OnExit) == 0 {
+		return allErr
+	}
+
+	exitJobs := make(map[string]bool, len(workflow.OnExit))
+	for i, jobName := range workflow.OnExit {
+		prefix := fmt.Sprintf("on_exit[%d]", i)
+		if exitJobs[jobName] {
+			allErr = append(allErr, fmt.Errorf("%s: job %s is duplicated", prefix, jobName))
+			continue
+		}
+		exitJobs[jobName] = true
+
+		job, ok := workflow.Jobs[jobName]
+		if !ok {
+			allErr = append(allErr, fmt.Errorf("%s: job %s does not exist", prefix, jobName))
+			continue
+		}
+		if job.SubWorkflow != nil {
+			allErr = append(allErr, fmt.Errorf("%s: job %s should not be a sub workflow", prefix, jobName))
+		}
+		if len(job.Depends) != 0 {
+			allErr = append(allErr, fmt.Errorf("%s: job %s should not have depends", prefix, jobName))
+		}
+		if job.Condition != nil {
+			allErr = append(allErr, fmt.Errorf("%s: job %s should not have condition", prefix, jobName))
+		}
+		if job.Loop != nil {
+			allErr = append(allErr, fmt.Errorf("%s: job %s should not have loop", prefix, jobName))
+		}
+		for _, v := range job.CommandsIter.VarsIter {
+			if str, ok := v.(string); ok && IsGetResultFunc(str) {
+				allErr = append(allErr, fmt.Errorf("%s: job %s should not use get_result function", prefix, jobName))
+				break
+			}
+		}
+	}
+
+	if len(exitJobs) == len(workflow.Jobs) {
+		allErr = append(allErr, fmt.Errorf("on_exit: there should be at least one job that is not an exit handler"))
+	}
+
+	for jobName, job := range workflow.Jobs {
+		for i, depend := range job.Depends {
+			if exitJobs[depend.Target] {
+				err := fmt.Errorf("workflow.%s.depends[%d]: the exit handler job %s can not be depended on", jobName, i, depend.Target)
+				allErr = append(allErr, err)
+			}
+		}
+	}
+
+	return allErr
+}
+
+// validateExitVariants checks that the jobs that are not exit handlers do not
+// use ${workflow.phase} and ${workflow.failures}, which are only known once
+// the DAG has finished.
+func validateExitVariants(workflow *Workflow) ErrorList {
+	allErr := ErrorList{}
+	exitJobs := make(map[string]bool, len(workflow.OnExit))
+	for _, jobName := range workflow.OnExit {
+		exitJobs[jobName] = true
+	}
+	for jobName, job := range workflow.Jobs {
+		if exitJobs[jobName] {
+			continue
+		}
+		for i, command := range job.Commands {
+			if common.HasExitVariant(command) {
+				allErr = append(allErr, fmt.Errorf("workflow.%s.commands[%d]: only the exit handler jobs can use ${%s} and ${%s}",
+					jobName, i, common.ExitPhaseVariant, common.ExitFailuresVariant))
+			}
+		}
+		if common.HasExitVariant(job.CommandsIter.Command) {
+			allErr = append(allErr, fmt.Errorf("workflow.%s.commands_iter.command: only the exit handler jobs can use ${%s} and ${%s}",
+				jobName, common.ExitPhaseVariant, common.ExitFailuresVariant))
+		}
+	}
+	return allErr
+}
+
+// IsExitVariant returns whether the variant is provided to the exit
+// handler jobs when they run.
+func IsExitVariant(variant string) bool {
+	return variant == common.ExitPhaseVariant || variant == common.ExitFailuresVariant
+}
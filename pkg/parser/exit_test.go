@@ -0,0 +1,98 @@
+/*
+Copyright 2019 The Kubegene Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package parser
+
+import (
+	"testing"
+)
+
+func TestValidateOnExit(t *testing.T) {
+	newWorkflow := func() *Workflow {
+		return &Workflow{
+			Jobs: map[string]JobInfo{
+				"align":   {Commands: []string{"align"}},
+				"sort":    {Commands: []string{"sort"}, Depends: []Depend{{Target: "align", Type: WholeDependType}}},
+				"cleanup": {Commands: []string{"cleanup ${workflow.phase} ${workflow.failures}"}},
+			},
+		}
+	}
+
+	testCases := []struct {
+		onExit    []string
+		modify    func(workflow *Workflow)
+		expectErr bool
+	}{
+		// only the exit handlers can use the exit variants.
+		{onExit: nil, expectErr: true},
+		{onExit: []string{"cleanup"}, expectErr: false},
+		{
+			onExit: []string{"cleanup"},
+			modify: func(workflow *Workflow) {
+				job := workflow.Jobs["sort"]
+				job.CommandsIter = CommandsIter{Command: "sort ${workflow.phase}", VarsIter: []interface{}{[]interface{}{1, 2}}}
+				workflow.Jobs["sort"] = job
+			},
+			expectErr: true,
+		},
+		{onExit: []string{"missing"}, expectErr: true},
+		{onExit: []string{"cleanup", "cleanup"}, expectErr: true},
+		// the exit handler depends on other jobs.
+		{onExit: []string{"sort"}, expectErr: true},
+		// the exit handler is depended on.
+		{onExit: []string{"align"}, expectErr: true},
+		{
+			onExit: []string{"cleanup"},
+			modify: func(workflow *Workflow) {
+				job := workflow.Jobs["cleanup"]
+				job.Loop = &Loop{MaxIterations: 3}
+				workflow.Jobs["cleanup"] = job
+			},
+			expectErr: true,
+		},
+		{
+			onExit: []string{"cleanup"},
+			modify: func(workflow *Workflow) {
+				job := workflow.Jobs["cleanup"]
+				job.CommandsIter = CommandsIter{Command: "echo ${1}", VarsIter: []interface{}{"get_result(align)"}}
+				workflow.Jobs["cleanup"] = job
+			},
+			expectErr: true,
+		},
+	}
+
+	for i, testCase := range testCases {
+		workflow := newWorkflow()
+		workflow.OnExit = testCase.onExit
+		if testCase.modify != nil {
+			testCase.modify(workflow)
+		}
+		errs := ValidateOnExit(workflow)
+		if testCase.expectErr && len(errs) == 0 {
+			t.Errorf("%d: expect error, but got nil", i)
+		}
+		if !testCase.expectErr && len(errs) != 0 {
+			t.Errorf("%d: unexpected error: %v", i, errs)
+		}
+	}
+}
+
+func TestValidateExitVariant(t *testing.T) {
+	_, errs := ValidateTemplate("cleanup ${workflow.phase} ${workflow.failures}", "prefix", "command", makeInputs())
+	if len(errs) != 0 {
+		t.Errorf("unexpected error: %v", errs)
+	}
+}
@@ -92,29 +92,29 @@ func validateDependency(prefix string, jobName string, dependJobName string, wor
 		return err
 	}
 
-	// depend job should have single command only because it should be single k8s- job related to that job
-
-	if (len(dependJob.Commands) > 1) || (len(dependJob.CommandsIter.Vars) > 1) || (len(dependJob.CommandsIter.VarsIter) > 1) {
-		err := fmt.Errorf("the get_result function dependecy job has more than one command %s dependjobName :%s", prefix, dependJobName)
+	if dependJob.SubWorkflow != nil {
+		err := fmt.Errorf("%s: the get_result function dependecy job %s references a sub workflow", prefix, dependJobName)
 		return err
 	}
 
+	// the result of a job with more than one command is aggregated from all of its k8s jobs.
 	currentJob, ok := workflow.Jobs[jobName]
 	if !ok {
 		err := fmt.Errorf("%s: the get_result function  job is missing, but the real one is %s", prefix, dependJobName)
 		return err
 	}
 
-	if len(currentJob.Depends) != 1 {
-		err := fmt.Errorf("%s: the get_result  job has more dependecies %v", prefix, currentJob.Depends)
-		return err
-	}
-
 	for i := 0; i < len(currentJob.Depends); i++ {
 		if (currentJob.Depends[i].Target == dependJobName) &&
 			(currentJob.Depends[i].Type) == "whole" {
 			return nil
 		}
+		// the job can iterate over a job expanded from the same result,
+		// such as split --> align chunk i --> sort chunk i.
+		if (currentJob.Depends[i].Type == IterateDependType) &&
+			isAncestorJob(dependJobName, currentJob.Depends[i].Target, workflow, map[string]bool{}) {
+			return nil
+		}
 	}
 
 	err := fmt.Errorf("%s: the get_result function dependecy job type is wrong %s", prefix, dependJobName)
@@ -122,6 +122,21 @@ func validateDependency(prefix string, jobName string, dependJobName string, wor
 	return err
 }
 
+// isAncestorJob returns whether the job depends on the ancestor directly or indirectly.
+func isAncestorJob(ancestor, jobName string, workflow *Workflow, visited map[string]bool) bool {
+	if visited[jobName] {
+		return false
+	}
+	visited[jobName] = true
+
+	for _, depend := range workflow.Jobs[jobName].Depends {
+		if depend.Target == ancestor || isAncestorJob(ancestor, depend.Target, workflow, visited) {
+			return true
+		}
+	}
+	return false
+}
+
 // validateGetResultFunc validate parameter of get_result function is valid.
 func validateGetResultFunc(prefix, str string, inputs map[string]Input, jobName string, workflow *Workflow) ErrorList {
 	allErr := ErrorList{}
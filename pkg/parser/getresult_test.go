@@ -106,3 +106,43 @@ func TestGetResultFuncParam(t *testing.T) {
 		}
 	}
 }
+
+func TestValidateDependency(t *testing.T) {
+	workflow := &Workflow{
+		Jobs: map[string]JobInfo{
+			"split": {Commands: []string{"split"}},
+			"align": {Depends: []Depend{{Target: "split", Type: WholeDependType}}},
+			"sort":  {Depends: []Depend{{Target: "align", Type: IterateDependType}}},
+			"merge": {Depends: []Depend{{Target: "sort", Type: WholeDependType}}},
+			"other": {Depends: []Depend{{Target: "merge", Type: IterateDependType}}},
+			"index": {Depends: []Depend{{Target: "split", Type: IterateDependType}}},
+			"multi": {Commands: []string{"a", "b"}},
+			"both": {Depends: []Depend{
+				{Target: "split", Type: WholeDependType},
+				{Target: "multi", Type: WholeDependType},
+			}},
+		},
+	}
+
+	testCases := []struct {
+		jobName   string
+		expectErr bool
+	}{
+		{jobName: "align", expectErr: false},
+		{jobName: "sort", expectErr: false},
+		{jobName: "other", expectErr: false},
+		{jobName: "merge", expectErr: true},
+		{jobName: "index", expectErr: true},
+		{jobName: "both", expectErr: false},
+	}
+
+	for i, testCase := range testCases {
+		err := validateDependency("prefix", testCase.jobName, "split", workflow)
+		if testCase.expectErr && err == nil {
+			t.Errorf("%d: expect error, but got nil", i)
+		}
+		if !testCase.expectErr && err != nil {
+			t.Errorf("%d: unexpected error: %v", i, err)
+		}
+	}
+}
@@ -18,8 +18,13 @@ package parser
 
 import (
 	"fmt"
-	execv1alpha1 "kubegene.io/kubegene/pkg/apis/gene/v1alpha1"
 	"regexp"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation"
+	execv1alpha1 "kubegene.io/kubegene/pkg/apis/gene/v1alpha1"
 )
 
 const CPURegexFmt = `^\d+(\.\d+)?[cC]?$`
@@ -40,22 +45,160 @@ func ValidateMemory(prefix, memory string) error {
 	return fmt.Errorf("%s.memory %s is illegal", prefix, memory)
 }
 
+// ValidateResources validates the requests and the limits of the resources
+// of a job.
 func ValidateResources(jobName string, res Resources) ErrorList {
+	return validateResources(fmt.Sprintf("workflow.%s.resources", jobName), res)
+}
+
+func validateResources(prefix string, res Resources) ErrorList {
+	errors := validateResourceList(prefix, res.ResourceList)
+	if res.Limits == nil {
+		return errors
+	}
+	errors = append(errors, validateResourceList(prefix+".limits", *res.Limits)...)
+	if len(errors) != 0 {
+		return errors
+	}
+
+	requests, _ := TransResourceList2ExecResourceList(res.ResourceList)
+	limits, _ := TransResourceList2ExecResourceList(*res.Limits)
+	for name, limit := range limits {
+		request, ok := requests[name]
+		if !ok {
+			continue
+		}
+		if limit.Cmp(request) < 0 {
+			errors = append(errors, fmt.Errorf("%s.limits: the limit %s of %s is less than the request %s",
+				prefix, limit.String(), name, request.String()))
+		} else if IsExtendedResourceName(name) && limit.Cmp(request) != 0 {
+			errors = append(errors, fmt.Errorf("%s.limits: the limit %s of the extended resource %s must equal the request %s",
+				prefix, limit.String(), name, request.String()))
+		}
+	}
+	return errors
+}
+
+func validateResourceList(prefix string, list ResourceList) ErrorList {
 	errors := ErrorList{}
-	prefix := fmt.Sprintf("workflow.%s.resources", jobName)
-	if len(res.Cpu) != 0 {
-		if err := ValidateCPU(prefix, res.Cpu); err != nil {
+	if len(list.Cpu) != 0 {
+		if err := ValidateCPU(prefix, list.Cpu); err != nil {
 			errors = append(errors, err)
 		}
 	}
-	if len(res.Memory) != 0 {
-		if err := ValidateMemory(prefix, res.Memory); err != nil {
+	if len(list.Memory) != 0 {
+		if err := ValidateMemory(prefix, list.Memory); err != nil {
+			errors = append(errors, err)
+		}
+	}
+	if len(list.EphemeralStorage) != 0 {
+		if err := validateQuantity(prefix+".ephemeral_storage", list.EphemeralStorage); err != nil {
+			errors = append(errors, err)
+		}
+	}
+	for name, value := range list.Extended {
+		if !IsExtendedResourceName(corev1.ResourceName(name)) {
+			errors = append(errors, fmt.Errorf("%s.extended %s is not a fully qualified resource name such as nvidia.com/gpu", prefix, name))
+			continue
+		}
+		if err := validateQuantity(fmt.Sprintf("%s.extended[%s]", prefix, name), value); err != nil {
 			errors = append(errors, err)
 		}
 	}
 	return errors
 }
 
+func validateQuantity(prefix, value string) error {
+	quantity, err := resource.ParseQuantity(value)
+	if err != nil || quantity.Sign() < 0 {
+		return fmt.Errorf("%s %s is illegal", prefix, value)
+	}
+	return nil
+}
+
+// IsExtendedResourceName reports whether the name is the name of an extended
+// resource, that is a fully qualified name outside of the kubernetes.io domain.
+func IsExtendedResourceName(name corev1.ResourceName) bool {
+	str := string(name)
+	if !strings.Contains(str, "/") || strings.HasPrefix(str, corev1.ResourceDefaultNamespacePrefix) {
+		return false
+	}
+	return len(validation.IsQualifiedName(str)) == 0
+}
+
+// TransResourceList2ExecResourceList parses the resources to kubernetes
+// quantities.
+func TransResourceList2ExecResourceList(list ResourceList) (corev1.ResourceList, error) {
+	resources := corev1.ResourceList{}
+	// parse cpu
+	if len(list.Cpu) > 0 {
+		cpuNum := strings.TrimRight(list.Cpu, "cC")
+		quantity, err := resource.ParseQuantity(cpuNum)
+		if err != nil {
+			return nil, fmt.Errorf("parse cpu quantity error: %v", err)
+		}
+		resources[corev1.ResourceCPU] = quantity
+	}
+	// parse memory
+	if len(list.Memory) > 0 {
+		quantity, err := resource.ParseQuantity(strings.ToUpper(list.Memory))
+		if err != nil {
+			return nil, fmt.Errorf("parse mem quantity error: %v", err)
+		}
+		resources[corev1.ResourceMemory] = quantity
+	}
+	if len(list.EphemeralStorage) > 0 {
+		quantity, err := resource.ParseQuantity(list.EphemeralStorage)
+		if err != nil {
+			return nil, fmt.Errorf("parse ephemeral storage quantity error: %v", err)
+		}
+		resources[corev1.ResourceEphemeralStorage] = quantity
+	}
+	for name, value := range list.Extended {
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s quantity error: %v", name, err)
+		}
+		resources[corev1.ResourceName(name)] = quantity
+	}
+	return resources, nil
+}
+
+// TransResources2ExecResources converts the resources of a job to the
+// resources of a task.
+func TransResources2ExecResources(res Resources) (execv1alpha1.ResourceRequirements, error) {
+	var execRes execv1alpha1.ResourceRequirements
+	requests, err := TransResourceList2ExecResourceList(res.ResourceList)
+	if err != nil {
+		return execRes, err
+	}
+	execRes.Cpu = requests[corev1.ResourceCPU]
+	execRes.Memory = requests[corev1.ResourceMemory]
+	delete(requests, corev1.ResourceCPU)
+	delete(requests, corev1.ResourceMemory)
+	if len(requests) != 0 {
+		execRes.Requests = requests
+	}
+	if res.Limits != nil {
+		limits, err := TransResourceList2ExecResourceList(*res.Limits)
+		if err != nil {
+			return execRes, err
+		}
+		if len(limits) != 0 {
+			execRes.Limits = limits
+		}
+	}
+	return execRes, nil
+}
+
+func ValidateImagePullPolicy(prefix, policy string) error {
+	switch policy {
+	case "", string(corev1.PullAlways), string(corev1.PullIfNotPresent), string(corev1.PullNever):
+		return nil
+	}
+	return fmt.Errorf("%s.image_pull_policy %s is illegal, it should be one of Always, IfNotPresent, Never", prefix, policy)
+}
+
 func ValidateDepend(prefix string, depend Depend, jobs map[string]JobInfo) ErrorList {
 	errors := ErrorList{}
 	if IsVariant(depend.Type) {
@@ -121,6 +264,11 @@ func ValidateCommandsIter(jobName string, commandsIter CommandsIter, inputs map[
 		return append(allError, err)
 	}
 
+	if commandsIter.Zip && len(commandsIter.VarsIter) == 0 {
+		err := fmt.Errorf("workflow.%s.commands_iter.zip: vars_iter must be specified when zip is true", jobName)
+		allError = append(allError, err)
+	}
+
 	prefix := fmt.Sprintf("workflow.%s.commands_iter.command", jobName)
 	maxIndex, errors := ValidateTemplate(commandsIter.Command, prefix, "command", inputs)
 	allError = append(allError, errors...)
@@ -220,9 +368,18 @@ func TransDepend2ExecDepend(depends []Depend) []execv1alpha1.Dependent {
 	return execDepends
 }
 
+func TransImagePullSecrets2ExecSecrets(secrets []string) []corev1.LocalObjectReference {
+	var execSecrets []corev1.LocalObjectReference
+	for _, secret := range secrets {
+		execSecrets = append(execSecrets, corev1.LocalObjectReference{Name: secret})
+	}
+	return execSecrets
+}
+
 func TransCommandIter2ExecCommandIter(commandsIter CommandsIter) *execv1alpha1.CommandsIter {
 	var execCommandIter execv1alpha1.CommandsIter
 	execCommandIter.Command = commandsIter.Command
+	execCommandIter.Zip = commandsIter.Zip
 	execCommandIter.VarsIter = make([]interface{}, 0)
 
 	for _, var1 := range commandsIter.VarsIter {
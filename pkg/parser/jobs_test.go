@@ -20,6 +20,9 @@ import (
 	"github.com/ghodss/yaml"
 	"strconv"
 	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 )
 
 func TestValidateCPU(t *testing.T) {
@@ -138,27 +141,56 @@ func TestValidateResources(t *testing.T) {
 	}{
 		{
 			Name:         "valid Res case1",
-			Res:          Resources{Cpu: "8C", Memory: "8G"},
+			Res:          Resources{ResourceList: ResourceList{Cpu: "8C", Memory: "8G"}},
 			ExpectErrNum: 0,
 		},
 		{
 			Name:         "valid Res case2",
-			Res:          Resources{Memory: "8G"},
+			Res:          Resources{ResourceList: ResourceList{Memory: "8G"}},
 			ExpectErrNum: 0,
 		},
 		{
 			Name:         "valid Res case3",
-			Res:          Resources{Cpu: "8c"},
+			Res:          Resources{ResourceList: ResourceList{Cpu: "8c"}},
 			ExpectErrNum: 0,
 		},
 		{
 			Name:         "invalid Res case1",
-			Res:          Resources{Cpu: "8Cc", Memory: "8G"},
+			Res:          Resources{ResourceList: ResourceList{Cpu: "8Cc", Memory: "8G"}},
 			ExpectErrNum: 1,
 		},
 		{
 			Name:         "invalid Res case2",
-			Res:          Resources{Cpu: "8Cc", Memory: "8Gg"},
+			Res:          Resources{ResourceList: ResourceList{Cpu: "8Cc", Memory: "8Gg"}},
+			ExpectErrNum: 2,
+		},
+		{
+			Name: "valid Res with limits and extended resources",
+			Res: Resources{
+				ResourceList: ResourceList{Memory: "4G", EphemeralStorage: "50Gi", Extended: map[string]string{"nvidia.com/gpu": "1"}},
+				Limits:       &ResourceList{Memory: "8g", Extended: map[string]string{"nvidia.com/gpu": "1"}},
+			},
+			ExpectErrNum: 0,
+		},
+		{
+			Name: "invalid Res limit less than request",
+			Res: Resources{
+				ResourceList: ResourceList{Cpu: "4c", Memory: "8G"},
+				Limits:       &ResourceList{Cpu: "2c", Memory: "4G"},
+			},
+			ExpectErrNum: 2,
+		},
+		{
+			Name: "invalid Res extended limit not equal to request",
+			Res: Resources{
+				ResourceList: ResourceList{Extended: map[string]string{"nvidia.com/gpu": "1"}},
+				Limits:       &ResourceList{Extended: map[string]string{"nvidia.com/gpu": "2"}},
+			},
+			ExpectErrNum: 1,
+		},
+		{
+			Name:         "invalid Res ephemeral storage and extended resource name",
+			Res:          Resources{ResourceList: ResourceList{EphemeralStorage: "50GB", Extended: map[string]string{"gpu": "1"}}},
 			ExpectErrNum: 2,
 		},
 	}
@@ -171,6 +203,28 @@ func TestValidateResources(t *testing.T) {
 	}
 }
 
+func TestTransResources2ExecResources(t *testing.T) {
+	res := Resources{
+		ResourceList: ResourceList{Cpu: "2C", Memory: "4G", EphemeralStorage: "50Gi"},
+		Limits:       &ResourceList{Memory: "8G"},
+	}
+	execRes, err := TransResources2ExecResources(res)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if execRes.Cpu.Cmp(resource.MustParse("2")) != 0 || execRes.Memory.Cmp(resource.MustParse("4G")) != 0 {
+		t.Errorf("unexpected cpu %s or memory %s", execRes.Cpu.String(), execRes.Memory.String())
+	}
+	storage := execRes.Requests[corev1.ResourceEphemeralStorage]
+	if len(execRes.Requests) != 1 || storage.Cmp(resource.MustParse("50Gi")) != 0 {
+		t.Errorf("unexpected requests %v", execRes.Requests)
+	}
+	memory := execRes.Limits[corev1.ResourceMemory]
+	if len(execRes.Limits) != 1 || memory.Cmp(resource.MustParse("8G")) != 0 {
+		t.Errorf("unexpected limits %v", execRes.Limits)
+	}
+}
+
 func TestValidateDepend(t *testing.T) {
 	testCases := []struct {
 		Name         string
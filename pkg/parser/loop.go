@@ -0,0 +1,79 @@
+/*
+Copyright 2019 The Kubegene Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package parser
+
+import (
+	"fmt"
+
+	execv1alpha1 "kubegene.io/kubegene/pkg/apis/gene/v1alpha1"
+)
+
+// ValidateLoop validate the loop of a job is valid. A loop job and the jobs
+// of its body must have exactly one command, and can not be used with
+// commands_iter or condition.
+func ValidateLoop(jobName string, job JobInfo, jobs map[string]JobInfo, inputs map[string]Input) ErrorList {
+	allErr := ErrorList{}
+	if job.Loop == nil {
+		return allErr
+	}
+
+	prefix := fmt.Sprintf("workflow.%s.loop", jobName)
+	if job.Loop.MaxIterations <= 0 {
+		err := fmt.Errorf("%s.max_iterations should be larger than 0, but the real one is %d", prefix, job.Loop.MaxIterations)
+		allErr = append(allErr, err)
+	}
+	if len(job.Commands) != 1 || !IsCommandIterEmpty(job.CommandsIter) {
+		err := fmt.Errorf("%s: the loop job should have exactly one command", prefix)
+		allErr = append(allErr, err)
+	}
+	if job.Condition != nil {
+		err := fmt.Errorf("%s: the loop job should not have condition", prefix)
+		allErr = append(allErr, err)
+	}
+	for _, name := range job.Loop.Body {
+		body, ok := jobs[name]
+		if !ok || name == jobName {
+			err := fmt.Errorf("%s.body: the job %s does not exist", prefix, name)
+			allErr = append(allErr, err)
+			continue
+		}
+		if len(body.Commands) != 1 || !IsCommandIterEmpty(body.CommandsIter) || body.Condition != nil ||
+			body.Loop != nil || body.SubWorkflow != nil {
+			err := fmt.Errorf("%s.body: the job %s should have exactly one command", prefix, name)
+			allErr = append(allErr, err)
+		}
+	}
+
+	if IsVariant(job.Loop.Until) {
+		if err := ValidateVariant(prefix+".until", job.Loop.Until, []string{StringType, NumberType, BoolType}, inputs); err != nil {
+			allErr = append(allErr, err)
+		}
+	} else {
+		_, errors := ValidateTemplate(job.Loop.Until, prefix+".until", "until", inputs)
+		allErr = append(allErr, errors...)
+	}
+
+	return allErr
+}
+
+func TransLoop2ExecLoop(loop *Loop) *execv1alpha1.Loop {
+	return &execv1alpha1.Loop{
+		Until:         loop.Until,
+		MaxIterations: int32(loop.MaxIterations),
+		Body:          loop.Body,
+	}
+}
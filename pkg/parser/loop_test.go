@@ -0,0 +1,117 @@
+/*
+Copyright 2019 The Kubegene Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package parser
+
+import (
+	"testing"
+)
+
+func TestValidateLoop(t *testing.T) {
+	jobs := map[string]JobInfo{
+		"job-b": {Commands: []string{"sh refine.sh ${iteration}"}},
+		"job-c": {Commands: []string{"sh a.sh", "sh b.sh"}},
+	}
+	testCases := []struct {
+		job       JobInfo
+		jobs      map[string]JobInfo
+		expectErr bool
+	}{
+		{
+			job: JobInfo{
+				Commands: []string{"sh check.sh"},
+			},
+			expectErr: false,
+		},
+		{
+			job: JobInfo{
+				Commands: []string{"sh check.sh ${iteration}"},
+				Loop:     &Loop{Until: "COMPLETE", MaxIterations: 10},
+			},
+			expectErr: false,
+		},
+		{
+			job: JobInfo{
+				Commands: []string{"sh check.sh"},
+				Loop:     &Loop{Until: "${obs-path}", MaxIterations: 10},
+			},
+			expectErr: false,
+		},
+		{
+			job: JobInfo{
+				Commands: []string{"sh check.sh"},
+				Loop:     &Loop{Until: "${undefined}", MaxIterations: 10},
+			},
+			expectErr: true,
+		},
+		{
+			job: JobInfo{
+				Commands: []string{"sh check.sh"},
+				Loop:     &Loop{},
+			},
+			expectErr: true,
+		},
+		{
+			job: JobInfo{
+				Commands: []string{"sh check.sh", "sh check.sh"},
+				Loop:     &Loop{MaxIterations: 3},
+			},
+			expectErr: true,
+		},
+		{
+			job: JobInfo{
+				Commands:  []string{"sh check.sh"},
+				Condition: true,
+				Loop:      &Loop{MaxIterations: 3},
+			},
+			expectErr: true,
+		},
+		{
+			job: JobInfo{
+				Commands: []string{"sh check.sh"},
+				Loop:     &Loop{Until: "CONVERGED", MaxIterations: 3, Body: []string{"job-b"}},
+			},
+			jobs:      jobs,
+			expectErr: false,
+		},
+		{
+			job: JobInfo{
+				Commands: []string{"sh check.sh"},
+				Loop:     &Loop{MaxIterations: 3, Body: []string{"job-d"}},
+			},
+			jobs:      jobs,
+			expectErr: true,
+		},
+		{
+			job: JobInfo{
+				Commands: []string{"sh check.sh"},
+				Loop:     &Loop{MaxIterations: 3, Body: []string{"job-c"}},
+			},
+			jobs:      jobs,
+			expectErr: true,
+		},
+	}
+
+	for i, testCase := range testCases {
+		errs := ValidateLoop("job-a", testCase.job, testCase.jobs, makeInputs())
+		if testCase.expectErr && len(errs) == 0 {
+			t.Errorf("%d: expect error, but got nil", i)
+		}
+		if !testCase.expectErr && len(errs) != 0 {
+			t.Errorf("%d: unexpected error: %v", i, errs)
+		}
+	}
+}
@@ -21,7 +21,7 @@ import (
 	"strings"
 
 	"github.com/ghodss/yaml"
-	"k8s.io/apimachinery/pkg/api/resource"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	execv1alpha1 "kubegene.io/kubegene/pkg/apis/gene/v1alpha1"
 
@@ -47,8 +47,7 @@ func SetDefaultWorkflow(workflow *Workflow) {
 func ValidateWorkflow(workflow *Workflow) ErrorList {
 	allErr := ErrorList{}
 	if len(workflow.Jobs) <= 0 {
-		errMsg := fmt.Sprint("No job defined in workflows")
-		return append(allErr, fmt.Errorf(errMsg))
+		return append(allErr, fmt.Errorf("No job defined in workflows"))
 	}
 
 	allErr = append(allErr, ValidateInputs(workflow.Inputs)...)
@@ -57,12 +56,31 @@ func ValidateWorkflow(workflow *Workflow) ErrorList {
 		// validate job Name
 		allErr = append(allErr, ValidateJobName(jobName)...)
 
+		// a job that references a nested workflow has no commands of its own.
+		if job.SubWorkflow != nil {
+			allErr = append(allErr, ValidateSubWorkflow(jobName, job, workflow.Inputs)...)
+			allErr = append(allErr, ValidateDepends(jobName, job.Depends, workflow.Jobs)...)
+			continue
+		}
+
 		// validate resources
 		allErr = append(allErr, ValidateResources(jobName, job.Resources)...)
 
 		// validate tool
 		allErr = append(allErr, ValidateTool(jobName, job.Tool)...)
 
+		// validate container settings
+		prefix := fmt.Sprintf("workflow.%s", jobName)
+		allErr = append(allErr, ValidateEnv(prefix, job.Env, workflow.Inputs)...)
+		allErr = append(allErr, ValidateEnvFrom(prefix, job.EnvFrom, workflow.Inputs)...)
+
+		// validate the volumes mounted by the job
+		allErr = append(allErr, ValidateJobVolumes(jobName, job.Volumes, workflow.Volumes, workflow.Inputs)...)
+		if err := ValidateImagePullPolicy(prefix, job.ImagePullPolicy); err != nil {
+			allErr = append(allErr, err)
+		}
+		allErr = append(allErr, ValidatePodSettings(prefix, job.PodSettings)...)
+
 		// validate commands
 		allErr = append(allErr, ValidateCommands(jobName, job.Commands, workflow.Inputs)...)
 
@@ -83,6 +101,9 @@ func ValidateWorkflow(workflow *Workflow) ErrorList {
 		// validate condition
 		allErr = append(allErr, validateCondition(jobName, job.Condition, workflow.Inputs, workflow)...)
 
+		// validate loop
+		allErr = append(allErr, ValidateLoop(jobName, job, workflow.Jobs, workflow.Inputs)...)
+
 	}
 
 	// detect cycle depends.
@@ -90,6 +111,12 @@ func ValidateWorkflow(workflow *Workflow) ErrorList {
 		allErr = append(allErr, err)
 	}
 
+	// validate exit handlers
+	allErr = append(allErr, ValidateOnExit(workflow)...)
+
+	// validate the pod settings of the workflow
+	allErr = append(allErr, ValidatePodSettings("workflow", workflow.PodSettings)...)
+
 	// validate volumes
 	allErr = append(allErr, ValidateVolumes(workflow.Volumes, workflow.Inputs)...)
 
@@ -126,34 +153,76 @@ func InstantiateWorkflow(workflow *Workflow, inputs map[string]interface{}, tool
 	volumes := make(map[string]Volume, len(workflow.Volumes))
 	for key, volume := range workflow.Volumes {
 		volume.MountPath = common.ReplaceVariant(volume.MountPath, inputsReplaceData)
-		volume.MountFrom.PVC = common.ReplaceVariant(volume.MountFrom.PVC, inputsReplaceData)
+		volume.MountFrom = InstantiateVolumeSource(volume.MountFrom, inputsReplaceData)
 		volumes[key] = volume
 	}
 	workflow.Volumes = volumes
 
 	// populate data for job.
 	jobs := make(map[string]JobInfo, len(workflow.Jobs))
+	// the tools resolved for the tool references of the jobs.
+	resolvedTools := make(map[string]Tool)
+	// leaf jobs of the nested workflows.
+	subLeaves := make(map[string][]string)
 
 	for jobName, jobInfo := range workflow.Jobs {
+		if jobInfo.SubWorkflow != nil {
+			sub, err := InstantiateSubWorkflow(jobName, jobInfo.SubWorkflow, mergedInputs, tools)
+			if err != nil {
+				return err
+			}
+			leaves, err := MergeSubWorkflow(jobName, jobInfo.Depends, sub, jobs, volumes)
+			if err != nil {
+				return err
+			}
+			subLeaves[jobName] = leaves
+			for ref, tool := range sub.Tools {
+				resolvedTools[ref] = tool
+			}
+			continue
+		}
+		// a job of a nested workflow merged before has the same name, the
+		// check of MergeSubWorkflow covers the jobs merged after.
+		if _, ok := jobs[jobName]; ok {
+			return fmt.Errorf("workflows.%s: job name duplicated with a job of a sub workflow", jobName)
+		}
+
 		var tmpJob JobInfo
 
 		tmpJob.Description = jobInfo.Description
 		tmpJob.Tool = jobInfo.Tool
 
-		tool, ok := tools[jobInfo.Tool]
-		if !ok {
-			return fmt.Errorf("workflows.%s.tool [%s] does not exist", jobName, jobInfo.Tool)
+		tool, err := ResolveTool(tools, jobInfo.Tool)
+		if err != nil {
+			return fmt.Errorf("workflows.%s.tool: %v", jobName, err)
 		}
+		resolvedTools[jobInfo.Tool] = tool
 
 		tmpJob.Image = tool.Image
-		if len(jobInfo.Resources.Memory) != 0 {
-			tmpJob.Resources.Memory = strings.ToUpper(jobInfo.Resources.Memory)
+		tmpJob.Resources = jobInfo.Resources
+		if jobInfo.Resources.Limits != nil {
+			limits := *jobInfo.Resources.Limits
+			tmpJob.Resources.Limits = &limits
 		}
-		if len(jobInfo.Resources.Cpu) != 0 {
-			tmpJob.Resources.Cpu = strings.ToUpper(jobInfo.Resources.Cpu)
+		tmpJob.Env = InstantiateEnv(jobInfo.Env, inputsReplaceData)
+		tmpJob.EnvFrom = InstantiateEnvFrom(jobInfo.EnvFrom, inputsReplaceData)
+		tmpJob.WorkingDir = jobInfo.WorkingDir
+		tmpJob.ImagePullPolicy = jobInfo.ImagePullPolicy
+		tmpJob.PodSettings = jobInfo.PodSettings
+		tmpJob.Volumes = InstantiateJobVolumes(jobInfo.Volumes, inputsReplaceData)
+		// the settings of the job override the defaults of the tool.
+		MergeToolDefaults(&tmpJob, tool)
+		tmpJob.Resources.Memory = strings.ToUpper(tmpJob.Resources.Memory)
+		tmpJob.Resources.Cpu = strings.ToUpper(tmpJob.Resources.Cpu)
+		if limits := tmpJob.Resources.Limits; limits != nil {
+			limits.Memory = strings.ToUpper(limits.Memory)
+			limits.Cpu = strings.ToUpper(limits.Cpu)
 		}
-		if len(jobInfo.Commands) == 0 && IsCommandIterEmpty(jobInfo.CommandsIter) {
-			tmpJob.Commands = append(tmpJob.Commands, tool.Command)
+		if jobInfo.Loop != nil {
+			// populate data for loop
+			loop := *jobInfo.Loop
+			loop.Until = common.ReplaceVariant(loop.Until, inputsReplaceData)
+			tmpJob.Loop = &loop
 		}
 
 		if jobInfo.Condition != nil {
@@ -169,6 +238,14 @@ func InstantiateWorkflow(workflow *Workflow, inputs map[string]interface{}, tool
 
 		// populate data for commands
 		newCommands := ReplaceArray(jobInfo.Commands, inputsReplaceData)
+		for i := range newCommands {
+			newCommands[i] = PrefixCommand(tool.CommandPrefix, newCommands[i])
+		}
+		// a job without commands runs the command of its tool.
+		if len(jobInfo.Commands) == 0 && IsCommandIterEmpty(jobInfo.CommandsIter) {
+			command := common.ReplaceVariant(tool.Command, inputsReplaceData)
+			newCommands = append(newCommands, PrefixCommand(tool.CommandPrefix, command))
+		}
 
 		// populate data for commandIter.vars
 		prefix := fmt.Sprintf("workflows.commands_iter.%s.vars", jobName)
@@ -199,12 +276,19 @@ func InstantiateWorkflow(workflow *Workflow, inputs map[string]interface{}, tool
 
 			// convert varsIter to var
 			iterVars := common.VarIter2Vars(varsIter)
+			if jobInfo.CommandsIter.Zip {
+				iterVars, err = common.ZipVars(varsIter)
+				if err != nil {
+					return fmt.Errorf("workflows.%s.commands_iter: %v", jobName, err)
+				}
+			}
 
 			// merge vars
 			vars = append(vars, iterVars...)
 
 			// populate data for CommandsIter.Command.
 			command := common.ReplaceVariant(jobInfo.CommandsIter.Command, inputsReplaceData)
+			command = PrefixCommand(tool.CommandPrefix, command)
 
 			// generate all commands.
 			iterCommands := common.Iter2Array(command, vars)
@@ -222,16 +306,20 @@ func InstantiateWorkflow(workflow *Workflow, inputs map[string]interface{}, tool
 			tmpJob.Commands = newCommands
 			// populate data for CommandsIter.Command.
 			command := common.ReplaceVariant(jobInfo.CommandsIter.Command, inputsReplaceData)
+			command = PrefixCommand(tool.CommandPrefix, command)
 
 			tmpJob.CommandsIter.Command = command
 			tmpJob.CommandsIter.VarsIter = convert2ArrayOfIfs(varsIter)
+			tmpJob.CommandsIter.Zip = jobInfo.CommandsIter.Zip
 			tmpJob.Depends = jobInfo.Depends
 			tmpJob.Condition = jobInfo.Condition
 			jobs[jobName] = tmpJob
 
 		}
 	}
+	replaceSubWorkflowDepends(jobs, subLeaves)
 	workflow.Jobs = jobs
+	workflow.Tools = resolvedTools
 
 	outPuts := make(map[string]OutputDesc, len(workflow.Outputs))
 	for outputName, outputInfo := range workflow.Outputs {
@@ -282,7 +370,6 @@ func InstantiateWorkflow(workflow *Workflow, inputs map[string]interface{}, tool
 func TransWorkflow2Execution(workflow *Workflow) (*execv1alpha1.Execution, error) {
 	namespace := GetExecutionNamespace(workflow.Inputs)
 	name := GetExecutionName(workflow.Inputs)
-	execVolumes := TransVolume2ExecVolume(workflow.Volumes)
 
 	// TODO make parallelism configurable
 	parallelism := int64(5)
@@ -295,15 +382,35 @@ func TransWorkflow2Execution(workflow *Workflow) (*execv1alpha1.Execution, error
 		Spec: execv1alpha1.ExecutionSpec{
 			Parallelism: &parallelism,
 			Tasks:       []execv1alpha1.Task{},
+			OnExit:      workflow.OnExit,
+			PodSettings: TransPodSettings2ExecPodSettings(workflow.PodSettings),
 		},
 	}
 
+	if len(workflow.Tools) != 0 {
+		resolvedTools, err := ResolvedToolsAnnotationValue(workflow.Tools)
+		if err != nil {
+			return nil, err
+		}
+		exec.Annotations = map[string]string{ResolvedToolsAnnotation: resolvedTools}
+	}
+
 	for jobName, jobInfo := range workflow.Jobs {
 		var task execv1alpha1.Task
 		task.Name = jobName
 		task.Type = "Job"
+		task.SubWorkflow = jobInfo.subWorkflow
 		task.Image = jobInfo.Image
-		task.Volumes = execVolumes
+		volumes, err := TransJobVolumes2ExecVolumes(jobInfo.Volumes, workflow.Volumes)
+		if err != nil {
+			return nil, fmt.Errorf("job %s: %v", jobName, err)
+		}
+		task.Volumes = volumes
+		task.Env = TransEnv2ExecEnv(jobInfo.Env)
+		task.EnvFrom = TransEnvFrom2ExecEnvFrom(jobInfo.EnvFrom)
+		task.WorkingDir = jobInfo.WorkingDir
+		task.ImagePullPolicy = corev1.PullPolicy(jobInfo.ImagePullPolicy)
+		task.PodSettings = TransPodSettings2ExecPodSettings(jobInfo.PodSettings)
 		// we have alreay merge workflows command and commandIter.
 		task.CommandSet = jobInfo.Commands
 
@@ -312,35 +419,20 @@ func TransWorkflow2Execution(workflow *Workflow) (*execv1alpha1.Execution, error
 			task.CommandsIter = TransCommandIter2ExecCommandIter(jobInfo.CommandsIter)
 		}
 
-		var cpuQuantity resource.Quantity
-		var memoryQuantity resource.Quantity
-		var err error
-
-		// parse cpu
-		if len(jobInfo.Resources.Cpu) > 0 {
-			cpuNum := strings.TrimRight(jobInfo.Resources.Cpu, "cC")
-			cpuQuantity, err = resource.ParseQuantity(cpuNum)
-			if err != nil {
-				return nil, fmt.Errorf("parse cpu quantity error: %v", err)
-			}
-		}
-		// parse memory
-		if len(jobInfo.Resources.Memory) > 0 {
-			memoryQuantity, err = resource.ParseQuantity(jobInfo.Resources.Memory)
-			if err != nil {
-				return nil, fmt.Errorf("parse mem quantity error: %v", err)
-			}
-		}
-
-		task.Resources = execv1alpha1.ResourceRequirements{
-			Cpu:    cpuQuantity,
-			Memory: memoryQuantity,
+		resources, err := TransResources2ExecResources(jobInfo.Resources)
+		if err != nil {
+			return nil, err
 		}
+		task.Resources = resources
 
 		if jobInfo.Condition != nil {
 			task.Condition = TransCond2ExecCond(jobInfo.Condition)
 		}
 
+		if jobInfo.Loop != nil {
+			task.Loop = TransLoop2ExecLoop(jobInfo.Loop)
+		}
+
 		task.Dependents = TransDepend2ExecDepend(jobInfo.Depends)
 		exec.Spec.Tasks = append(exec.Spec.Tasks, task)
 	}
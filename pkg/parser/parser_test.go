@@ -157,7 +157,7 @@ outputs:
 `
 
 func TestValidateWorkflow(t *testing.T) {
-	data, _ := ioutil.ReadFile("../../../example/gatk4-practices/gatk4-practices.yaml")
+	data, _ := ioutil.ReadFile("../../example/gatk4-practices/gatk4-practices.yaml")
 	workflow, err := UnmarshalWorkflow(data)
 	if err != nil {
 		t.Fatalf("unmarshal workflow err: %v", err)
@@ -0,0 +1,133 @@
+/*
+Copyright 2019 The Kubegene Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package parser
+
+import (
+	"fmt"
+
+	"github.com/ghodss/yaml"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	execv1alpha1 "kubegene.io/kubegene/pkg/apis/gene/v1alpha1"
+	"kubegene.io/kubegene/pkg/common"
+)
+
+// ValidatePodSettings validates the pod settings of the workflow or a job.
+// The pod spec patch must be a YAML or JSON object, whether it applies to
+// the pod spec is checked when the execution is created.
+func ValidatePodSettings(prefix string, settings PodSettings) ErrorList {
+	errors := ErrorList{}
+	if len(settings.ServiceAccountName) != 0 {
+		if msgs := validation.IsDNS1123Subdomain(settings.ServiceAccountName); len(msgs) != 0 {
+			errors = append(errors, fmt.Errorf("%s.service_account_name %s is illegal: %v", prefix, settings.ServiceAccountName, msgs))
+		}
+	}
+	if len(settings.PriorityClassName) != 0 {
+		if msgs := validation.IsDNS1123Subdomain(settings.PriorityClassName); len(msgs) != 0 {
+			errors = append(errors, fmt.Errorf("%s.priority_class_name %s is illegal: %v", prefix, settings.PriorityClassName, msgs))
+		}
+	}
+	for i, secret := range settings.ImagePullSecrets {
+		if msgs := validation.IsDNS1123Subdomain(secret); len(msgs) != 0 {
+			errors = append(errors, fmt.Errorf("%s.image_pull_secrets[%d] %s is illegal: %v", prefix, i, secret, msgs))
+		}
+	}
+	for key, value := range settings.PodLabels {
+		if msgs := validation.IsQualifiedName(key); len(msgs) != 0 {
+			errors = append(errors, fmt.Errorf("%s.pod_labels key %s is illegal: %v", prefix, key, msgs))
+		}
+		if common.IsReservedLabel(key) {
+			errors = append(errors, fmt.Errorf("%s.pod_labels key %s is reserved", prefix, key))
+		}
+		if msgs := validation.IsValidLabelValue(value); len(msgs) != 0 {
+			errors = append(errors, fmt.Errorf("%s.pod_labels[%s] value %s is illegal: %v", prefix, key, value, msgs))
+		}
+	}
+	for key := range settings.PodAnnotations {
+		if msgs := validation.IsQualifiedName(key); len(msgs) != 0 {
+			errors = append(errors, fmt.Errorf("%s.pod_annotations key %s is illegal: %v", prefix, key, msgs))
+		}
+	}
+	if len(settings.PodSpecPatch) != 0 {
+		patch := map[string]interface{}{}
+		if err := yaml.Unmarshal([]byte(settings.PodSpecPatch), &patch); err != nil {
+			errors = append(errors, fmt.Errorf("%s.pod_spec_patch must be an object: %v", prefix, err))
+		}
+	}
+	return errors
+}
+
+// MergePodSettings returns the pod settings of a job with the defaults. The
+// image pull secrets, labels and annotations are merged, for the other
+// settings, including the pod spec patch, the ones of the job win.
+func MergePodSettings(defaults, job PodSettings) PodSettings {
+	merged := job
+	if merged.SecurityContext == nil {
+		merged.SecurityContext = defaults.SecurityContext
+	}
+	if len(merged.ServiceAccountName) == 0 {
+		merged.ServiceAccountName = defaults.ServiceAccountName
+	}
+	if len(merged.PriorityClassName) == 0 {
+		merged.PriorityClassName = defaults.PriorityClassName
+	}
+	if len(merged.PodSpecPatch) == 0 {
+		merged.PodSpecPatch = defaults.PodSpecPatch
+	}
+	merged.ImagePullSecrets = unionStrings(defaults.ImagePullSecrets, job.ImagePullSecrets)
+	merged.PodLabels = common.MergeStringMap(defaults.PodLabels, job.PodLabels)
+	merged.PodAnnotations = common.MergeStringMap(defaults.PodAnnotations, job.PodAnnotations)
+	return merged
+}
+
+// unionStrings returns the strings of both slices without duplicates, in order.
+func unionStrings(a, b []string) []string {
+	if len(a) == 0 && len(b) == 0 {
+		return nil
+	}
+	union := make([]string, 0, len(a)+len(b))
+	for _, str := range append(append([]string{}, a...), b...) {
+		if sliceContain(union, str) == -1 {
+			union = append(union, str)
+		}
+	}
+	return union
+}
+
+// TransPodSettings2ExecPodSettings converts the pod settings of the workflow
+// or a job to the pod settings of the execution or a task.
+func TransPodSettings2ExecPodSettings(settings PodSettings) execv1alpha1.PodSettings {
+	execSettings := execv1alpha1.PodSettings{
+		ServiceAccountName: settings.ServiceAccountName,
+		ImagePullSecrets:   TransImagePullSecrets2ExecSecrets(settings.ImagePullSecrets),
+		PriorityClassName:  settings.PriorityClassName,
+		PodLabels:          settings.PodLabels,
+		PodAnnotations:     settings.PodAnnotations,
+		PodSpecPatch:       settings.PodSpecPatch,
+	}
+	if context := settings.SecurityContext; context != nil {
+		execSettings.SecurityContext = &corev1.PodSecurityContext{
+			RunAsUser:          context.RunAsUser,
+			RunAsGroup:         context.RunAsGroup,
+			RunAsNonRoot:       context.RunAsNonRoot,
+			FSGroup:            context.FSGroup,
+			SupplementalGroups: context.SupplementalGroups,
+		}
+	}
+	return execSettings
+}
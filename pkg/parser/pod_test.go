@@ -0,0 +1,141 @@
+/*
+Copyright 2019 The Kubegene Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package parser
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestValidatePodSettings(t *testing.T) {
+	testCases := []struct {
+		name     string
+		settings PodSettings
+		expErr   bool
+	}{
+		{
+			name: "valid",
+			settings: PodSettings{
+				ServiceAccountName: "gene-runner",
+				ImagePullSecrets:   []string{"registry-secret"},
+				PodLabels:          map[string]string{"team": "genomics"},
+				PodAnnotations:     map[string]string{"sidecar.istio.io/inject": "false"},
+				PodSpecPatch:       "dnsPolicy: Default",
+			},
+		},
+		{name: "illegal service account", settings: PodSettings{ServiceAccountName: "Gene Runner"}, expErr: true},
+		{name: "illegal pull secret", settings: PodSettings{ImagePullSecrets: []string{"a/b"}}, expErr: true},
+		{name: "illegal label key", settings: PodSettings{PodLabels: map[string]string{"a b": "c"}}, expErr: true},
+		{name: "reserved label key", settings: PodSettings{PodLabels: map[string]string{"job-name": "other"}}, expErr: true},
+		{name: "illegal label value", settings: PodSettings{PodLabels: map[string]string{"team": "gen omics"}}, expErr: true},
+		{name: "illegal annotation key", settings: PodSettings{PodAnnotations: map[string]string{"-a": "b"}}, expErr: true},
+		{name: "patch is not an object", settings: PodSettings{PodSpecPatch: "- dnsPolicy"}, expErr: true},
+	}
+
+	for _, testCase := range testCases {
+		errs := ValidatePodSettings("workflow.job", testCase.settings)
+		if testCase.expErr && len(errs) == 0 {
+			t.Errorf("%s: expected error, but got nil", testCase.name)
+		}
+		if !testCase.expErr && len(errs) != 0 {
+			t.Errorf("%s: unexpected error: %v", testCase.name, errs)
+		}
+	}
+}
+
+func TestTransPodSettings(t *testing.T) {
+	workflowStr := `
+version: genecontainer_0_1
+service_account_name: gene-runner
+security_context:
+  run_as_user: 1000
+  fs_group: 2000
+image_pull_secrets:
+- registry-secret
+pod_labels:
+  team: genomics
+workflow:
+  align:
+    tool: bwa:0.7.17
+    priority_class_name: high-priority
+    pod_labels:
+      step: align
+    pod_spec_patch: |
+      dnsPolicy: Default
+    commands:
+    - bwa mem
+`
+	workflow, err := UnmarshalWorkflow([]byte(workflowStr))
+	if err != nil {
+		t.Fatalf("unmarshal workflow err: %v", err)
+	}
+	SetDefaultWorkflow(workflow)
+	if errs := ValidateWorkflow(workflow); len(errs) != 0 {
+		t.Fatalf("unexpected validate error: %v", errs)
+	}
+	bwa := Tool{Name: "bwa", Version: "0.7.17", Image: "bwa:0.7.17"}
+	if err := InstantiateWorkflow(workflow, nil, TransTools2Map([]Tool{bwa})); err != nil {
+		t.Fatalf("unexpected instantiate error: %v", err)
+	}
+	exec, err := TransWorkflow2Execution(workflow)
+	if err != nil {
+		t.Fatalf("unexpected trans error: %v", err)
+	}
+
+	spec := exec.Spec
+	if spec.ServiceAccountName != "gene-runner" || !reflect.DeepEqual(spec.PodLabels, map[string]string{"team": "genomics"}) {
+		t.Errorf("unexpected pod settings of the execution %v", spec.PodSettings)
+	}
+	if spec.SecurityContext == nil || *spec.SecurityContext.RunAsUser != 1000 || *spec.SecurityContext.FSGroup != 2000 {
+		t.Errorf("unexpected security context %v", spec.SecurityContext)
+	}
+	if !reflect.DeepEqual(spec.ImagePullSecrets, []corev1.LocalObjectReference{{Name: "registry-secret"}}) {
+		t.Errorf("unexpected image pull secrets %v", spec.ImagePullSecrets)
+	}
+
+	task := spec.Tasks[0]
+	if task.PriorityClassName != "high-priority" || task.PodSpecPatch != "dnsPolicy: Default\n" {
+		t.Errorf("unexpected pod settings of the task %v", task.PodSettings)
+	}
+	if !reflect.DeepEqual(task.PodLabels, map[string]string{"step": "align"}) {
+		t.Errorf("unexpected pod labels of the task %v", task.PodLabels)
+	}
+}
+
+func TestMergePodSettings(t *testing.T) {
+	defaults := PodSettings{
+		ServiceAccountName: "gene-runner",
+		ImagePullSecrets:   []string{"registry-secret"},
+		PodLabels:          map[string]string{"team": "genomics", "step": "qc"},
+	}
+	job := PodSettings{
+		ServiceAccountName: "aligner",
+		ImagePullSecrets:   []string{"registry-secret", "bwa-secret"},
+		PodLabels:          map[string]string{"step": "align"},
+	}
+	merged := MergePodSettings(defaults, job)
+	expected := PodSettings{
+		ServiceAccountName: "aligner",
+		ImagePullSecrets:   []string{"registry-secret", "bwa-secret"},
+		PodLabels:          map[string]string{"team": "genomics", "step": "align"},
+	}
+	if !reflect.DeepEqual(merged, expected) {
+		t.Errorf("expected %v, got %v", expected, merged)
+	}
+}
@@ -0,0 +1,336 @@
+/*
+Copyright 2019 The Kubegene Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"kubegene.io/kubegene/pkg/common"
+)
+
+// SubWorkflowSeparator is used to construct the name of the jobs of a nested workflow.
+const SubWorkflowSeparator = "-"
+
+// ValidateSubWorkflow validate the sub_workflow of a job is valid. A job that
+// references a nested workflow should not have its own tool or commands.
+func ValidateSubWorkflow(jobName string, job JobInfo, inputs map[string]Input) ErrorList {
+	allErr := ErrorList{}
+	if job.SubWorkflow == nil {
+		return allErr
+	}
+
+	prefix := fmt.Sprintf("workflow.%s.sub_workflow", jobName)
+	sub := job.SubWorkflow
+	if (len(sub.Path) == 0) == (len(sub.Catalog) == 0) {
+		allErr = append(allErr, fmt.Errorf("%s: one of path or catalog should be specified", prefix))
+	}
+	// nested workflows are loaded before the inputs are instantiated.
+	if strings.Contains(sub.Path, "${") || strings.Contains(sub.Catalog, "${") {
+		allErr = append(allErr, fmt.Errorf("%s: path or catalog should not contain variant", prefix))
+	}
+	if len(job.Tool) != 0 || len(job.Commands) != 0 || !IsCommandIterEmpty(job.CommandsIter) ||
+		job.Condition != nil || job.Loop != nil {
+		err := fmt.Errorf("%s: the job should not have tool, commands, commands_iter, condition or loop", prefix)
+		allErr = append(allErr, err)
+	}
+
+	for key, value := range sub.Inputs {
+		if str, ok := value.(string); ok {
+			_, errors := ValidateTemplate(str, fmt.Sprintf("%s.inputs.%s", prefix, key), "input", inputs)
+			allErr = append(allErr, errors...)
+		}
+	}
+
+	return allErr
+}
+
+// WorkflowLoader reads the nested workflow referenced by a job. baseDir is
+// the directory of the workflow that references it. It returns the location
+// of the nested workflow, which identifies it to detect circles, and its data.
+type WorkflowLoader func(sub *SubWorkflow, baseDir string) (location string, data []byte, err error)
+
+// LoadSubWorkflows reads and validates the nested workflows referenced by the
+// jobs of the workflow recursively. baseDir is the directory of the workflow
+// file, and repo is the directory of the workflow repository.
+func LoadSubWorkflows(workflow *Workflow, baseDir, repo string) ErrorList {
+	return LoadSubWorkflowsWith(workflow, baseDir, fileLoader(repo))
+}
+
+// LoadSubWorkflowsWith reads and validates the nested workflows of the
+// workflow recursively with the loader.
+func LoadSubWorkflowsWith(workflow *Workflow, baseDir string, loader WorkflowLoader) ErrorList {
+	return loadSubWorkflows(workflow, baseDir, loader, nil)
+}
+
+func loadSubWorkflows(workflow *Workflow, baseDir string, loader WorkflowLoader, stack []string) ErrorList {
+	allErr := ErrorList{}
+	for jobName, job := range workflow.Jobs {
+		if job.SubWorkflow == nil {
+			continue
+		}
+		prefix := fmt.Sprintf("workflow.%s.sub_workflow", jobName)
+
+		location, data, err := loader(job.SubWorkflow, baseDir)
+		if err != nil {
+			allErr = append(allErr, fmt.Errorf("%s: %v", prefix, err))
+			continue
+		}
+		if index := sliceContain(stack, location); index != -1 {
+			err := fmt.Errorf("%s: detect circle from sub workflows. Circle:%s", prefix, printCircle(append(stack, location), index))
+			allErr = append(allErr, err)
+			continue
+		}
+
+		sub, err := UnmarshalWorkflow(data)
+		if err != nil {
+			allErr = append(allErr, fmt.Errorf("%s: %v", prefix, err))
+			continue
+		}
+		SetDefaultWorkflow(sub)
+
+		errors := ValidateWorkflow(sub)
+		if len(sub.OnExit) != 0 {
+			errors = append(errors, fmt.Errorf("on_exit is only supported in the top workflow"))
+		}
+		errors = append(errors, loadSubWorkflows(sub, filepath.Dir(location), loader, append(stack, location))...)
+		for _, err := range errors {
+			allErr = append(allErr, fmt.Errorf("%s: %v", prefix, err))
+		}
+
+		job.SubWorkflow.workflow = sub
+	}
+	return allErr
+}
+
+// fileLoader reads the nested workflows from the files.
+func fileLoader(repo string) WorkflowLoader {
+	return func(sub *SubWorkflow, baseDir string) (string, []byte, error) {
+		file, err := filepath.Abs(subWorkflowFile(sub, baseDir, repo))
+		if err != nil {
+			return "", nil, err
+		}
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return "", nil, fmt.Errorf("read workflow file %s failed: %v", file, err)
+		}
+		return file, data, nil
+	}
+}
+
+func subWorkflowFile(sub *SubWorkflow, baseDir, repo string) string {
+	if len(sub.Catalog) != 0 {
+		return filepath.Join(repo, sub.Catalog+".yaml")
+	}
+	if filepath.IsAbs(sub.Path) {
+		return sub.Path
+	}
+	return filepath.Join(baseDir, sub.Path)
+}
+
+// copyWorkflow deep copies a loaded workflow, so that it can be instantiated
+// more than once with different inputs.
+func copyWorkflow(workflow *Workflow) (*Workflow, error) {
+	data, err := json.Marshal(workflow)
+	if err != nil {
+		return nil, err
+	}
+	var out Workflow
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, err
+	}
+	for jobName, job := range workflow.Jobs {
+		if job.SubWorkflow != nil {
+			out.Jobs[jobName].SubWorkflow.workflow = job.SubWorkflow.workflow
+		}
+	}
+	return &out, nil
+}
+
+// InstantiateSubWorkflow instantiates the nested workflow referenced by a job
+// with the inputs bound by the job.
+func InstantiateSubWorkflow(jobName string, sub *SubWorkflow, parentInputs map[string]Input, tools map[string]Tool) (*Workflow, error) {
+	if sub.workflow == nil {
+		return nil, fmt.Errorf("workflows.%s.sub_workflow has not been loaded", jobName)
+	}
+
+	data := Inputs2ReplaceData(parentInputs)
+	inputs := make(map[string]interface{}, len(sub.Inputs))
+	for key, value := range sub.Inputs {
+		if str, ok := value.(string); ok {
+			// keep the type of the input if it references a variant directly.
+			if IsVariant(str) {
+				if input, ok := parentInputs[GetVariantName(str)]; ok {
+					inputs[key] = input.Value
+					continue
+				}
+			}
+			value = common.ReplaceVariant(str, data)
+		}
+		inputs[key] = value
+	}
+
+	workflow, err := copyWorkflow(sub.workflow)
+	if err != nil {
+		return nil, fmt.Errorf("workflows.%s.sub_workflow: copy workflow error: %v", jobName, err)
+	}
+	if err := InstantiateWorkflow(workflow, inputs, tools); err != nil {
+		return nil, fmt.Errorf("workflows.%s.sub_workflow: %v", jobName, err)
+	}
+
+	return workflow, nil
+}
+
+// MergeSubWorkflow adds the jobs and volumes of an instantiated nested workflow
+// to the referencing workflow. It returns the name of the leaf jobs of the
+// nested workflow, which the jobs depending on the referencing job will depend on.
+func MergeSubWorkflow(jobName string, depends []Depend, sub *Workflow, jobs map[string]JobInfo, volumes map[string]Volume) ([]string, error) {
+	rename := func(name string) string {
+		return jobName + SubWorkflowSeparator + name
+	}
+
+	targets := make(map[string]bool)
+	for _, job := range sub.Jobs {
+		for _, depend := range job.Depends {
+			targets[depend.Target] = true
+		}
+	}
+
+	// the names of the volumes of the nested workflow in the referencing workflow.
+	volumeNames := make(map[string]string, len(sub.Volumes))
+	for name, volume := range sub.Volumes {
+		for key, existing := range volumes {
+			if existing.MountPath != volume.MountPath {
+				continue
+			}
+			if !reflect.DeepEqual(existing.MountFrom, volume.MountFrom) {
+				return nil, fmt.Errorf("workflows.%s.sub_workflow: volumes[%s] and volumes[%s] have the same mount path %s",
+					jobName, name, key, volume.MountPath)
+			}
+			volumeNames[name] = key
+		}
+		if _, shared := volumeNames[name]; !shared {
+			volumeNames[name] = rename(name)
+			volumes[rename(name)] = volume
+		}
+	}
+
+	leaves := make([]string, 0)
+	for name, job := range sub.Jobs {
+		newName := rename(name)
+		if errors := ValidateJobName(newName); len(errors) != 0 {
+			return nil, fmt.Errorf("workflows.%s.sub_workflow: %v", jobName, errors[0])
+		}
+		if _, ok := jobs[newName]; ok {
+			return nil, fmt.Errorf("workflows.%s.sub_workflow: job %s duplicated", jobName, newName)
+		}
+
+		newDepends := make([]Depend, 0, len(job.Depends))
+		for _, depend := range job.Depends {
+			depend.Target = rename(depend.Target)
+			newDepends = append(newDepends, depend)
+		}
+		// root jobs of the nested workflow inherit the depends of the referencing job.
+		if len(job.Depends) == 0 {
+			newDepends = append(newDepends, depends...)
+		}
+		job.Depends = newDepends
+
+		if job.Loop != nil && len(job.Loop.Body) != 0 {
+			loop := *job.Loop
+			loop.Body = make([]string, 0, len(job.Loop.Body))
+			for _, name := range job.Loop.Body {
+				loop.Body = append(loop.Body, rename(name))
+			}
+			job.Loop = &loop
+		}
+
+		renameResultFuncs(&job, rename)
+
+		// the pod settings of the nested workflow are the defaults of its jobs.
+		job.PodSettings = MergePodSettings(sub.PodSettings, job.PodSettings)
+
+		if job.Volumes != nil {
+			mounts := make([]VolumeMount, 0, len(job.Volumes))
+			for _, mount := range job.Volumes {
+				mount.Name = volumeNames[mount.Name]
+				mounts = append(mounts, mount)
+			}
+			job.Volumes = mounts
+		}
+
+		if len(job.subWorkflow) != 0 {
+			job.subWorkflow = jobName + "/" + job.subWorkflow
+		} else {
+			job.subWorkflow = jobName
+		}
+
+		jobs[newName] = job
+		if !targets[name] {
+			leaves = append(leaves, newName)
+		}
+	}
+
+	return leaves, nil
+}
+
+// renameResultFuncs renames the job referenced by the instantiated get_result and
+// check_result function of a job.
+func renameResultFuncs(job *JobInfo, rename func(string) string) {
+	for _, vars := range job.CommandsIter.VarsIter {
+		if v, ok := toVar(vars); ok && len(v) == 3 && v[0] == "get_result" {
+			v[1] = rename(common.ToString(v[1]))
+		}
+	}
+	if v, ok := toVar(job.Condition); ok && len(v) == 3 && v[0] == "check_result" {
+		v[1] = rename(common.ToString(v[1]))
+	}
+}
+
+func toVar(i interface{}) (common.Var, bool) {
+	switch v := i.(type) {
+	case common.Var:
+		return v, true
+	case []interface{}:
+		return v, true
+	}
+	return nil, false
+}
+
+// replaceSubWorkflowDepends replaces the depends on a job that references a nested
+// workflow with the depends on the leaf jobs of the nested workflow.
+func replaceSubWorkflowDepends(jobs map[string]JobInfo, leaves map[string][]string) {
+	for name, job := range jobs {
+		newDepends := make([]Depend, 0, len(job.Depends))
+		for _, depend := range job.Depends {
+			subLeaves, ok := leaves[depend.Target]
+			if !ok {
+				newDepends = append(newDepends, depend)
+				continue
+			}
+			for _, leaf := range subLeaves {
+				newDepends = append(newDepends, Depend{Target: leaf, Type: depend.Type})
+			}
+		}
+		job.Depends = newDepends
+		jobs[name] = job
+	}
+}
@@ -0,0 +1,285 @@
+/*
+Copyright 2019 The Kubegene Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package parser
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var alignWorkflow = `
+version: genecontainer_0_1
+inputs:
+  sample:
+    default: s0
+    type: string
+workflow:
+  bwa:
+    tool: bwa:0.71r
+    commands:
+    - bwa mem ${sample}
+  sort:
+    tool: bwa:0.71r
+    commands:
+    - sort ${sample}
+    depends:
+    - target: bwa
+      type: whole
+`
+
+var mainWorkflow = `
+workflow:
+  prepare:
+    tool: bwa:0.71r
+    commands:
+    - prepare
+  align:
+    sub_workflow:
+      path: align.yaml
+      inputs:
+        sample: ${obs-path}/sample1
+    depends:
+    - target: prepare
+      type: whole
+  report:
+    tool: bwa:0.71r
+    commands:
+    - report
+    depends:
+    - target: align
+      type: whole
+`
+
+func TestValidateSubWorkflow(t *testing.T) {
+	testCases := []struct {
+		job       JobInfo
+		expectErr bool
+	}{
+		{
+			job: JobInfo{
+				SubWorkflow: &SubWorkflow{Path: "align.yaml"},
+			},
+			expectErr: false,
+		},
+		{
+			job: JobInfo{
+				SubWorkflow: &SubWorkflow{
+					Catalog: "qc/align",
+					Inputs:  map[string]interface{}{"sample": "${obs-path}/sample1", "npart": 2},
+				},
+			},
+			expectErr: false,
+		},
+		{
+			job: JobInfo{
+				SubWorkflow: &SubWorkflow{},
+			},
+			expectErr: true,
+		},
+		{
+			job: JobInfo{
+				SubWorkflow: &SubWorkflow{Path: "align.yaml", Catalog: "qc/align"},
+			},
+			expectErr: true,
+		},
+		{
+			job: JobInfo{
+				SubWorkflow: &SubWorkflow{Path: "${obs-path}/align.yaml"},
+			},
+			expectErr: true,
+		},
+		{
+			job: JobInfo{
+				SubWorkflow: &SubWorkflow{
+					Path:   "align.yaml",
+					Inputs: map[string]interface{}{"sample": "${undefined}"},
+				},
+			},
+			expectErr: true,
+		},
+		{
+			job: JobInfo{
+				Tool:        "bwa:0.71r",
+				Commands:    []string{"bwa mem"},
+				SubWorkflow: &SubWorkflow{Path: "align.yaml"},
+			},
+			expectErr: true,
+		},
+	}
+
+	for i, testCase := range testCases {
+		errs := ValidateSubWorkflow("job-a", testCase.job, makeInputs())
+		if testCase.expectErr && len(errs) == 0 {
+			t.Errorf("%d: expect error, but got nil", i)
+		}
+		if !testCase.expectErr && len(errs) != 0 {
+			t.Errorf("%d: unexpected error: %v", i, errs)
+		}
+	}
+}
+
+func writeWorkflowFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "subworkflow")
+	if err != nil {
+		t.Fatalf("create temp dir error: %v", err)
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("write file %s error: %v", name, err)
+		}
+	}
+	return dir
+}
+
+func TestInstantiateSubWorkflow(t *testing.T) {
+	dir := writeWorkflowFiles(t, map[string]string{"align.yaml": alignWorkflow})
+	defer os.RemoveAll(dir)
+
+	workflow, err := UnmarshalWorkflow([]byte(version + inputs + mainWorkflow))
+	if err != nil {
+		t.Fatalf("unmarshal workflow err: %v", err)
+	}
+	SetDefaultWorkflow(workflow)
+	if errs := ValidateWorkflow(workflow); len(errs) != 0 {
+		t.Fatalf("unexpected validate error: %v", errs)
+	}
+	if errs := LoadSubWorkflows(workflow, dir, ""); len(errs) != 0 {
+		t.Fatalf("unexpected load error: %v", errs)
+	}
+	if err := InstantiateWorkflow(workflow, nil, makeTools()); err != nil {
+		t.Fatalf("unexpected instantiate error: %v", err)
+	}
+
+	expectDepends := map[string][]Depend{
+		"prepare":    {},
+		"align-bwa":  {{Target: "prepare", Type: "whole"}},
+		"align-sort": {{Target: "align-bwa", Type: "whole"}},
+		"report":     {{Target: "align-sort", Type: "whole"}},
+	}
+	if len(workflow.Jobs) != len(expectDepends) {
+		t.Fatalf("expect %d jobs, but got %v", len(expectDepends), workflow.Jobs)
+	}
+	for name, depends := range expectDepends {
+		job, ok := workflow.Jobs[name]
+		if !ok {
+			t.Fatalf("expect job %s, but got %v", name, workflow.Jobs)
+		}
+		if len(depends) == 0 && len(job.Depends) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(depends, job.Depends) {
+			t.Errorf("job %s: expect depends %v, but got %v", name, depends, job.Depends)
+		}
+	}
+
+	bwa := workflow.Jobs["align-bwa"]
+	if !reflect.DeepEqual(bwa.Commands, []string{"bwa mem /root/sample1"}) {
+		t.Errorf("expect commands of align-bwa [bwa mem /root/sample1], but got %v", bwa.Commands)
+	}
+
+	exec, err := TransWorkflow2Execution(workflow)
+	if err != nil {
+		t.Fatalf("unexpected trans error: %v", err)
+	}
+	for _, task := range exec.Spec.Tasks {
+		expect := ""
+		if task.Name == "align-bwa" || task.Name == "align-sort" {
+			expect = "align"
+		}
+		if task.SubWorkflow != expect {
+			t.Errorf("task %s: expect sub workflow %q, but got %q", task.Name, expect, task.SubWorkflow)
+		}
+	}
+}
+
+func TestInstantiateSubWorkflowDuplicatedJob(t *testing.T) {
+	dir := writeWorkflowFiles(t, map[string]string{"align.yaml": alignWorkflow})
+	defer os.RemoveAll(dir)
+
+	// the job bwa of the nested workflow align is renamed to align-bwa.
+	duplicated := mainWorkflow + `
+  align-bwa:
+    tool: bwa:0.71r
+    commands:
+    - bwa
+`
+	// the jobs are instantiated in the random order of the map, the
+	// duplicated name must be rejected whichever comes first.
+	for i := 0; i < 20; i++ {
+		workflow, err := UnmarshalWorkflow([]byte(version + inputs + duplicated))
+		if err != nil {
+			t.Fatalf("unmarshal workflow err: %v", err)
+		}
+		SetDefaultWorkflow(workflow)
+		if errs := LoadSubWorkflows(workflow, dir, ""); len(errs) != 0 {
+			t.Fatalf("unexpected load error: %v", errs)
+		}
+		if err := InstantiateWorkflow(workflow, nil, makeTools()); err == nil {
+			t.Fatalf("expect duplicated job error, but got nil")
+		}
+	}
+}
+
+func TestLoadSubWorkflowsCircle(t *testing.T) {
+	loop := `
+workflow:
+  again:
+    sub_workflow:
+      path: loop.yaml
+`
+	dir := writeWorkflowFiles(t, map[string]string{"loop.yaml": loop})
+	defer os.RemoveAll(dir)
+
+	workflow, err := UnmarshalWorkflow([]byte(loop))
+	if err != nil {
+		t.Fatalf("unmarshal workflow err: %v", err)
+	}
+	if errs := LoadSubWorkflows(workflow, dir, ""); len(errs) == 0 {
+		t.Errorf("expect circle error, but got nil")
+	}
+}
+
+func TestMergeSubWorkflowVolumes(t *testing.T) {
+	sub := &Workflow{
+		Jobs: map[string]JobInfo{
+			"bwa": {Volumes: []VolumeMount{{Name: "ref", ReadOnly: true}, {Name: "scratch"}}},
+		},
+		Volumes: map[string]Volume{
+			"ref":     {MountPath: "/reference", MountFrom: VolumeSource{PVC: "reference"}},
+			"scratch": {MountPath: "/scratch", MountFrom: VolumeSource{EmptyDir: &EmptyDirVolumeSource{}}},
+		},
+	}
+	volumes := map[string]Volume{
+		"reference": {MountPath: "/reference", MountFrom: VolumeSource{PVC: "reference"}},
+	}
+	jobs := make(map[string]JobInfo)
+	if _, err := MergeSubWorkflow("align", nil, sub, jobs, volumes); err != nil {
+		t.Fatalf("unexpected merge error: %v", err)
+	}
+
+	// the shared volume keeps the name of the referencing workflow.
+	expect := []VolumeMount{{Name: "reference", ReadOnly: true}, {Name: "align-scratch"}}
+	if !reflect.DeepEqual(jobs["align-bwa"].Volumes, expect) {
+		t.Errorf("expected volumes %v, got %v", expect, jobs["align-bwa"].Volumes)
+	}
+	if _, ok := volumes["align-scratch"]; !ok || len(volumes) != 2 {
+		t.Errorf("expected the scratch volume to be added, got %v", volumes)
+	}
+}
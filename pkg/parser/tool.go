@@ -0,0 +1,339 @@
+/*
+Copyright 2018 The Kubegene Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package parser
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	kubeyaml "k8s.io/apimachinery/pkg/util/yaml"
+	execv1alpha1 "kubegene.io/kubegene/pkg/apis/gene/v1alpha1"
+	"kubegene.io/kubegene/pkg/common"
+)
+
+// ClusterToolRepo is the tool repo that resolves the tools against the Tool
+// and ClusterTool resources in the cluster instead of the tool files.
+const ClusterToolRepo = "cluster"
+
+// LoadTools loads the tools from the tool repo, which is either a local
+// directory or the URL of a tool file.
+func LoadTools(toolRepo string) (map[string]Tool, error) {
+	// remote tool repo
+	if strings.Index(toolRepo, "http://") == 0 || strings.Index(toolRepo, "https://") == 0 {
+		bytes, err := DownloadToolFile(toolRepo)
+		if err != nil {
+			return nil, fmt.Errorf("fetch remote repo tools error: %v", err)
+		}
+		tools, err := ParseTool(bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parse remote repo tools error: %v", err)
+		}
+
+		return TransTools2Map(tools), nil
+	}
+
+	// local tool repo
+	if _, err := os.Stat(toolRepo); err != nil {
+		return nil, fmt.Errorf("tool repo path %v is not exist: %v", toolRepo, err)
+	}
+
+	toolFiles, err := GetAllToolFile(toolRepo)
+	if err != nil {
+		return nil, fmt.Errorf("fetch local repo tools error: %v", err)
+	}
+
+	totalTools := make([]Tool, 0)
+	for _, toolFile := range toolFiles {
+		body, err := ioutil.ReadFile(toolFile)
+		if err != nil {
+			return nil, fmt.Errorf("fetch local repo tools error: %v", err)
+		}
+		tools, err := ParseTool(body)
+		if err != nil {
+			return nil, fmt.Errorf("parse local repo tools error: %v", err)
+		}
+		totalTools = append(totalTools, tools...)
+	}
+
+	return TransTools2Map(totalTools), nil
+}
+
+func TransTools2Map(tools []Tool) map[string]Tool {
+	toolsMap := make(map[string]Tool, len(tools))
+
+	for _, tool := range tools {
+		key := tool.Name + ":" + tool.Version
+		toolsMap[key] = tool
+	}
+
+	return toolsMap
+}
+
+// TransToolResources2Map returns the tools of the cluster tools and the tools
+// of a namespace keyed by name:version. A tool of the namespace overrides the
+// cluster tool with the same name and version.
+func TransToolResources2Map(clusterTools []execv1alpha1.ClusterTool, tools []execv1alpha1.Tool) map[string]Tool {
+	totalTools := make([]Tool, 0, len(clusterTools)+len(tools))
+	for _, clusterTool := range clusterTools {
+		totalTools = append(totalTools, TransToolSpec2Tool(clusterTool.Spec))
+	}
+	for _, tool := range tools {
+		totalTools = append(totalTools, TransToolSpec2Tool(tool.Spec))
+	}
+	return TransTools2Map(totalTools)
+}
+
+func TransToolSpec2Tool(spec execv1alpha1.ToolSpec) Tool {
+	return Tool{
+		Name:             spec.Name,
+		Version:          spec.Version,
+		Image:            spec.Image,
+		Command:          spec.Command,
+		Type:             spec.Type,
+		Description:      spec.Description,
+		Resources:        TransToolResources2Resources(spec.Resources),
+		Env:              TransExecEnv2Env(spec.Env),
+		WorkingDir:       spec.WorkingDir,
+		ImagePullPolicy:  string(spec.ImagePullPolicy),
+		ImagePullSecrets: spec.ImagePullSecrets,
+		CommandPrefix:    spec.CommandPrefix,
+	}
+}
+
+func TransTool2ToolSpec(tool Tool) execv1alpha1.ToolSpec {
+	return execv1alpha1.ToolSpec{
+		Name:             tool.Name,
+		Version:          tool.Version,
+		Image:            tool.Image,
+		Command:          tool.Command,
+		Type:             tool.Type,
+		Description:      tool.Description,
+		Resources:        TransResources2ToolResources(tool.Resources),
+		Env:              TransEnv2ExecEnv(tool.Env),
+		WorkingDir:       tool.WorkingDir,
+		ImagePullPolicy:  corev1.PullPolicy(tool.ImagePullPolicy),
+		ImagePullSecrets: tool.ImagePullSecrets,
+		CommandPrefix:    tool.CommandPrefix,
+	}
+}
+
+var invalidResourceNameChars = regexp.MustCompile("[^a-z0-9.-]+")
+
+// ToolResourceName returns the name of the resource of the tool, which is
+// name-version with the characters not allowed in a resource name replaced,
+// e.g. broadinstitute-gatk-4.0.2.0 for broadinstitute/gatk:4.0.2.0.
+func ToolResourceName(name, version string) string {
+	resourceName := invalidResourceNameChars.ReplaceAllString(strings.ToLower(name+"-"+version), "-")
+	return strings.Trim(resourceName, "-.")
+}
+
+func ValidateToolAttr(tool Tool) error {
+	if len(tool.Name) == 0 {
+		return errors.New("tool Name is required")
+	}
+	if len(tool.Version) == 0 {
+		return errors.New("tool version is required")
+	}
+	if len(tool.Image) == 0 {
+		return errors.New("tool image is required")
+	}
+	if errs := validateResources("tool.resources", tool.Resources); len(errs) != 0 {
+		return errs[0]
+	}
+	// the environment variables of a tool can not reference the inputs.
+	if errs := ValidateEnv("tool", tool.Env, nil); len(errs) != 0 {
+		return errs[0]
+	}
+	return ValidateImagePullPolicy("tool", tool.ImagePullPolicy)
+}
+
+// MergeToolDefaults fills in the settings of the job that are not set with
+// the defaults of the tool. The environment variables of the job override
+// the ones of the tool with the same name, and the pull secrets of the tool
+// and the job are both used.
+func MergeToolDefaults(job *JobInfo, tool Tool) {
+	mergeResourceList(&job.Resources.ResourceList, tool.Resources.ResourceList)
+	if tool.Resources.Limits != nil {
+		limits := ResourceList{}
+		if job.Resources.Limits != nil {
+			limits = *job.Resources.Limits
+		}
+		mergeResourceList(&limits, *tool.Resources.Limits)
+		job.Resources.Limits = &limits
+	}
+	if len(job.WorkingDir) == 0 {
+		job.WorkingDir = tool.WorkingDir
+	}
+	if len(job.ImagePullPolicy) == 0 {
+		job.ImagePullPolicy = tool.ImagePullPolicy
+	}
+
+	if len(tool.Env) != 0 {
+		env := make([]EnvVar, 0, len(tool.Env)+len(job.Env))
+		overridden := make(map[string]bool, len(job.Env))
+		for _, envVar := range job.Env {
+			overridden[envVar.Name] = true
+		}
+		for _, envVar := range tool.Env {
+			if !overridden[envVar.Name] {
+				env = append(env, envVar)
+			}
+		}
+		job.Env = append(env, job.Env...)
+	}
+
+	secrets := make([]string, 0, len(tool.ImagePullSecrets)+len(job.ImagePullSecrets))
+	seen := make(map[string]bool)
+	for _, secret := range append(append([]string{}, tool.ImagePullSecrets...), job.ImagePullSecrets...) {
+		if !seen[secret] {
+			seen[secret] = true
+			secrets = append(secrets, secret)
+		}
+	}
+	if len(secrets) != 0 {
+		job.ImagePullSecrets = secrets
+	}
+}
+
+// mergeResourceList fills in the resources of the list that are not set with
+// the defaults of the tool, the extended resources of the list win over the
+// ones of the defaults.
+func mergeResourceList(list *ResourceList, defaults ResourceList) {
+	if len(list.Cpu) == 0 {
+		list.Cpu = defaults.Cpu
+	}
+	if len(list.Memory) == 0 {
+		list.Memory = defaults.Memory
+	}
+	if len(list.EphemeralStorage) == 0 {
+		list.EphemeralStorage = defaults.EphemeralStorage
+	}
+	list.Extended = common.MergeStringMap(defaults.Extended, list.Extended)
+}
+
+// TransToolResources2Resources converts the resources of a tool resource to
+// the resources of a tool.
+func TransToolResources2Resources(res execv1alpha1.ToolResources) Resources {
+	transList := func(list execv1alpha1.ToolResourceList) ResourceList {
+		return ResourceList{
+			Memory:           list.Memory,
+			Cpu:              list.Cpu,
+			EphemeralStorage: list.EphemeralStorage,
+			Extended:         list.Extended,
+		}
+	}
+	resources := Resources{ResourceList: transList(res.ToolResourceList)}
+	if res.Limits != nil {
+		limits := transList(*res.Limits)
+		resources.Limits = &limits
+	}
+	return resources
+}
+
+// TransResources2ToolResources converts the resources of a tool to the
+// resources of a tool resource.
+func TransResources2ToolResources(res Resources) execv1alpha1.ToolResources {
+	transList := func(list ResourceList) execv1alpha1.ToolResourceList {
+		return execv1alpha1.ToolResourceList{
+			Cpu:              list.Cpu,
+			Memory:           list.Memory,
+			EphemeralStorage: list.EphemeralStorage,
+			Extended:         list.Extended,
+		}
+	}
+	resources := execv1alpha1.ToolResources{ToolResourceList: transList(res.ResourceList)}
+	if res.Limits != nil {
+		limits := transList(*res.Limits)
+		resources.Limits = &limits
+	}
+	return resources
+}
+
+// PrefixCommand prepends the command prefix of a tool to a command.
+func PrefixCommand(prefix, command string) string {
+	if len(prefix) == 0 || len(command) == 0 {
+		return command
+	}
+	return strings.TrimRight(prefix, " ") + " " + command
+}
+
+func ParseTool(data []byte) ([]Tool, error) {
+	var tools []Tool
+	var empty = Tool{}
+	reader := bytes.NewReader(data)
+
+	// We store tools as a YAML stream; there may be more than one decoder.
+	yamlDecoder := kubeyaml.NewYAMLOrJSONDecoder(reader, 512*1024)
+	for {
+		tool := Tool{}
+		err := yamlDecoder.Decode(&tool)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse tool: %v", err)
+		}
+		if reflect.DeepEqual(tool, empty) {
+			continue
+		}
+		if err := ValidateToolAttr(tool); err != nil {
+			return nil, fmt.Errorf("invalidate tool: %v", err)
+		}
+		tools = append(tools, tool)
+	}
+
+	return tools, nil
+}
+
+func GetAllToolFile(rootPath string) ([]string, error) {
+	list := make([]string, 0)
+	err := filepath.Walk(rootPath, func(path string, info os.FileInfo, err error) error {
+		if info.IsDir() {
+			return nil
+		}
+		if filepath.Ext(path) == ".yaml" {
+			list = append(list, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walk error [%v]\n", err)
+	}
+	return list, nil
+}
+
+func DownloadToolFile(url string) ([]byte, error) {
+	// Get the data
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// ready body
+	return ioutil.ReadAll(resp.Body)
+}
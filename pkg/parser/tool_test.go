@@ -0,0 +1,190 @@
+/*
+Copyright 2019 The Kubegene Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package parser
+
+import (
+	"reflect"
+	"testing"
+
+	execv1alpha1 "kubegene.io/kubegene/pkg/apis/gene/v1alpha1"
+)
+
+func TestToolResourceName(t *testing.T) {
+	testCases := []struct {
+		name    string
+		version string
+		expect  string
+	}{
+		{name: "nginx", version: "latest", expect: "nginx-latest"},
+		{name: "broadinstitute/gatk", version: "4.0.2.0", expect: "broadinstitute-gatk-4.0.2.0"},
+		{name: "BWA_MEM", version: "0.7.17_r1188", expect: "bwa-mem-0.7.17-r1188"},
+	}
+
+	for _, testCase := range testCases {
+		if name := ToolResourceName(testCase.name, testCase.version); name != testCase.expect {
+			t.Errorf("%s:%s: expected resource name %s, got %s", testCase.name, testCase.version, testCase.expect, name)
+		}
+	}
+}
+
+func TestTransToolResources2Map(t *testing.T) {
+	clusterTools := []execv1alpha1.ClusterTool{
+		{Spec: execv1alpha1.ToolSpec{Name: "gatk", Version: "4.0", Image: "gatk:cluster"}},
+		{Spec: execv1alpha1.ToolSpec{Name: "bwa", Version: "0.7", Image: "bwa:cluster"}},
+	}
+	tools := []execv1alpha1.Tool{
+		{Spec: execv1alpha1.ToolSpec{Name: "gatk", Version: "4.0", Image: "gatk:namespace"}},
+	}
+
+	toolsMap := TransToolResources2Map(clusterTools, tools)
+	if len(toolsMap) != 2 {
+		t.Fatalf("expected 2 tools, got %d", len(toolsMap))
+	}
+	if image := toolsMap["gatk:4.0"].Image; image != "gatk:namespace" {
+		t.Errorf("expected the tool in the namespace to override the cluster tool, got %s", image)
+	}
+	if image := toolsMap["bwa:0.7"].Image; image != "bwa:cluster" {
+		t.Errorf("expected the cluster tool bwa:0.7, got %s", image)
+	}
+}
+
+func TestInstantiateWithToolDefaults(t *testing.T) {
+	workflowStr := `
+version: genecontainer_0_1
+workflow:
+  call:
+    tool: gatk:4.0.2.0
+    resources:
+      memory: 8g
+    env:
+    - name: JAVA_OPTS
+      value: -Xmx8g
+    image_pull_policy: IfNotPresent
+    image_pull_secrets:
+    - job-secret
+    commands:
+    - gatk HaplotypeCaller
+  split:
+    tool: gatk:4.0.2.0
+    commands_iter:
+      command: gatk SplitIntervals ${1}
+      vars_iter:
+      - [chr1]
+`
+	gatk := Tool{
+		Name:             "gatk",
+		Version:          "4.0.2.0",
+		Image:            "broadinstitute/gatk:4.0.2.0",
+		Resources:        Resources{ResourceList: ResourceList{Cpu: "2c", Memory: "4g"}},
+		Env:              []EnvVar{{Name: "JAVA_OPTS", Value: "-Xmx4g"}, {Name: "GATK_HOME", Value: "/gatk"}},
+		WorkingDir:       "/gatk",
+		ImagePullPolicy:  "Always",
+		ImagePullSecrets: []string{"registry-secret"},
+		CommandPrefix:    "source /gatk/env.sh &&",
+	}
+	workflow, err := UnmarshalWorkflow([]byte(workflowStr))
+	if err != nil {
+		t.Fatalf("unmarshal workflow err: %v", err)
+	}
+	if errs := ValidateWorkflow(workflow); len(errs) != 0 {
+		t.Fatalf("unexpected validate error: %v", errs)
+	}
+	if err := InstantiateWorkflow(workflow, nil, TransTools2Map([]Tool{gatk})); err != nil {
+		t.Fatalf("unexpected instantiate error: %v", err)
+	}
+
+	call := workflow.Jobs["call"]
+	if call.Resources.Cpu != "2C" || call.Resources.Memory != "8G" {
+		t.Errorf("expected the memory of the job and the cpu of the tool, got %v", call.Resources)
+	}
+	expectEnv := []EnvVar{{Name: "GATK_HOME", Value: "/gatk"}, {Name: "JAVA_OPTS", Value: "-Xmx8g"}}
+	if !reflect.DeepEqual(call.Env, expectEnv) {
+		t.Errorf("expected env %v, got %v", expectEnv, call.Env)
+	}
+	if call.WorkingDir != "/gatk" || call.ImagePullPolicy != "IfNotPresent" {
+		t.Errorf("unexpected working dir %s or pull policy %s", call.WorkingDir, call.ImagePullPolicy)
+	}
+	if !reflect.DeepEqual(call.ImagePullSecrets, []string{"registry-secret", "job-secret"}) {
+		t.Errorf("unexpected pull secrets %v", call.ImagePullSecrets)
+	}
+	if !reflect.DeepEqual(call.Commands, []string{"source /gatk/env.sh && gatk HaplotypeCaller"}) {
+		t.Errorf("expected prefixed commands, got %v", call.Commands)
+	}
+	split := workflow.Jobs["split"]
+	if !reflect.DeepEqual(split.Commands, []string{"source /gatk/env.sh && gatk SplitIntervals chr1"}) {
+		t.Errorf("expected prefixed commands, got %v", split.Commands)
+	}
+	if split.ImagePullPolicy != "Always" {
+		t.Errorf("expected the pull policy of the tool, got %s", split.ImagePullPolicy)
+	}
+}
+
+func TestInstantiateToolCommand(t *testing.T) {
+	workflowStr := `
+version: genecontainer_0_1
+workflow:
+  version:
+    tool: gatk:4.0.2.0
+`
+	gatk := Tool{
+		Name:          "gatk",
+		Version:       "4.0.2.0",
+		Image:         "broadinstitute/gatk:4.0.2.0",
+		Command:       "gatk --version",
+		CommandPrefix: "source /gatk/env.sh &&",
+	}
+	workflow, err := UnmarshalWorkflow([]byte(workflowStr))
+	if err != nil {
+		t.Fatalf("unmarshal workflow err: %v", err)
+	}
+	if err := InstantiateWorkflow(workflow, nil, TransTools2Map([]Tool{gatk})); err != nil {
+		t.Fatalf("unexpected instantiate error: %v", err)
+	}
+
+	// a job without commands runs the command of its tool.
+	commands := workflow.Jobs["version"].Commands
+	if !reflect.DeepEqual(commands, []string{"source /gatk/env.sh && gatk --version"}) {
+		t.Errorf("expected the prefixed command of the tool, got %v", commands)
+	}
+}
+
+func TestValidateToolAttr(t *testing.T) {
+	valid := Tool{Name: "gatk", Version: "4.0.2.0", Image: "broadinstitute/gatk:4.0.2.0"}
+	testCases := []struct {
+		name   string
+		modify func(tool *Tool)
+		expErr bool
+	}{
+		{name: "valid", modify: func(tool *Tool) {}},
+		{name: "invalid cpu", modify: func(tool *Tool) { tool.Resources.Cpu = "two" }, expErr: true},
+		{name: "invalid memory", modify: func(tool *Tool) { tool.Resources.Memory = "4T" }, expErr: true},
+		{name: "invalid env", modify: func(tool *Tool) { tool.Env = []EnvVar{{Name: "1 A"}} }, expErr: true},
+		{name: "invalid pull policy", modify: func(tool *Tool) { tool.ImagePullPolicy = "Sometimes" }, expErr: true},
+	}
+
+	for _, testCase := range testCases {
+		tool := valid
+		testCase.modify(&tool)
+		err := ValidateToolAttr(tool)
+		if testCase.expErr && err == nil {
+			t.Errorf("%s: expected error, but got nil", testCase.name)
+		}
+		if !testCase.expErr && err != nil {
+			t.Errorf("%s: unexpected error: %v", testCase.name, err)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+/*
+Copyright 2019 The Kubegene Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// LatestToolVersion resolves to the tool with the version "latest" if there
+// is one, otherwise to the highest released version of the tool.
+const LatestToolVersion = "latest"
+
+// toolVersion is a version of a tool such as 4.0.2.0 or v1.2-rc1. Unlike
+// semver it may have any number of numeric components.
+type toolVersion struct {
+	numbers    []int
+	prerelease string
+}
+
+// parseToolVersion parses a version with an optional "v" prefix and an
+// optional pre-release suffix after "-".
+func parseToolVersion(version string) (toolVersion, bool) {
+	version = strings.TrimPrefix(version, "v")
+	var v toolVersion
+	if index := strings.Index(version, "-"); index != -1 {
+		v.prerelease = version[index+1:]
+		version = version[:index]
+		if len(v.prerelease) == 0 {
+			return v, false
+		}
+	}
+	for _, part := range strings.Split(version, ".") {
+		number, err := strconv.Atoi(part)
+		if err != nil || number < 0 {
+			return v, false
+		}
+		v.numbers = append(v.numbers, number)
+	}
+	return v, true
+}
+
+// compare returns -1, 0 or 1 if v is lower than, equal to or higher than
+// other. Missing components are zero, and a pre-release is lower than the
+// release of the same numbers.
+func (v toolVersion) compare(other toolVersion) int {
+	for i := 0; i < len(v.numbers) || i < len(other.numbers); i++ {
+		a, b := 0, 0
+		if i < len(v.numbers) {
+			a = v.numbers[i]
+		}
+		if i < len(other.numbers) {
+			b = other.numbers[i]
+		}
+		if a != b {
+			if a < b {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case v.prerelease == other.prerelease:
+		return 0
+	case len(v.prerelease) == 0:
+		return 1
+	case len(other.prerelease) == 0:
+		return -1
+	case v.prerelease < other.prerelease:
+		return -1
+	default:
+		return 1
+	}
+}
+
+// versionPredicate reports whether a version satisfies a constraint.
+type versionPredicate func(v toolVersion) bool
+
+// parseVersionConstraint parses a version constraint. It is one of:
+//
+// "latest" - any released version.
+// "4.x", "4.0.*", "4" - the versions with the given leading components.
+// ">=4.0.1", ">4", "<=4.1", "<5", "=4.0.1" - compared with the given version.
+//
+// Several constraints separated by "," must all be satisfied, e.g. ">=4.0,<5".
+// Pre-release versions only satisfy the constraints that name them exactly.
+func parseVersionConstraint(constraint string) (versionPredicate, error) {
+	if constraint == LatestToolVersion {
+		return func(v toolVersion) bool { return len(v.prerelease) == 0 }, nil
+	}
+
+	predicates := make([]versionPredicate, 0)
+	for _, part := range strings.Split(constraint, ",") {
+		predicate, err := parseSingleConstraint(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		predicates = append(predicates, predicate)
+	}
+	return func(v toolVersion) bool {
+		for _, predicate := range predicates {
+			if !predicate(v) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+func parseSingleConstraint(constraint string) (versionPredicate, error) {
+	for _, op := range []string{">=", "<=", ">", "<", "="} {
+		if !strings.HasPrefix(constraint, op) {
+			continue
+		}
+		bound, ok := parseToolVersion(strings.TrimSpace(constraint[len(op):]))
+		if !ok {
+			return nil, fmt.Errorf("invalid version in constraint %q", constraint)
+		}
+		return func(v toolVersion) bool {
+			result := v.compare(bound)
+			if len(v.prerelease) != 0 && result != 0 {
+				return false
+			}
+			switch op {
+			case ">=":
+				return result >= 0
+			case "<=":
+				return result <= 0
+			case ">":
+				return result > 0
+			case "<":
+				return result < 0
+			default:
+				return result == 0
+			}
+		}, nil
+	}
+
+	// x-range: the wildcard components match any number.
+	prefix := make([]int, 0)
+	for _, part := range strings.Split(constraint, ".") {
+		if part == "x" || part == "X" || part == "*" {
+			break
+		}
+		number, err := strconv.Atoi(strings.TrimPrefix(part, "v"))
+		if err != nil || number < 0 {
+			return nil, fmt.Errorf("invalid version constraint %q", constraint)
+		}
+		prefix = append(prefix, number)
+	}
+	return func(v toolVersion) bool {
+		if len(v.prerelease) != 0 || len(v.numbers) < len(prefix) {
+			return false
+		}
+		for i, number := range prefix {
+			if v.numbers[i] != number {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+// SplitToolReference splits a tool reference such as broadinstitute/gatk:4.x
+// into the tool name and the version constraint.
+func SplitToolReference(ref string) (name, constraint string, ok bool) {
+	index := strings.LastIndex(ref, ":")
+	if index <= 0 || index == len(ref)-1 {
+		return "", "", false
+	}
+	return ref[:index], ref[index+1:], true
+}
+
+// ResolveTool returns the tool that a job references by name:version. The
+// tool with exactly that version is used if it exists. Otherwise the version
+// is taken as a constraint, and the highest version of the tool satisfying it
+// is used.
+func ResolveTool(tools map[string]Tool, ref string) (Tool, error) {
+	if tool, ok := tools[ref]; ok {
+		return tool, nil
+	}
+
+	name, constraint, ok := SplitToolReference(ref)
+	if !ok {
+		return Tool{}, fmt.Errorf("tool [%s] does not exist", ref)
+	}
+	predicate, err := parseVersionConstraint(constraint)
+	if err != nil {
+		return Tool{}, fmt.Errorf("tool [%s] does not exist: %v", ref, err)
+	}
+
+	var resolved Tool
+	var resolvedVersion toolVersion
+	found := false
+	for _, tool := range tools {
+		if tool.Name != name {
+			continue
+		}
+		version, ok := parseToolVersion(tool.Version)
+		if !ok || !predicate(version) {
+			continue
+		}
+		if !found || version.compare(resolvedVersion) > 0 {
+			resolved, resolvedVersion, found = tool, version, true
+		}
+	}
+	if !found {
+		return Tool{}, fmt.Errorf("tool [%s] does not exist: no version of %s satisfies %s", ref, name, constraint)
+	}
+	return resolved, nil
+}
+
+// ResolvedToolsAnnotation is the annotation of an execution that records the
+// concrete version and image of every tool reference of the workflow, so that
+// the run can be reproduced after the tool repository has changed.
+const ResolvedToolsAnnotation = "kubegene.io/resolved-tools"
+
+// ResolvedTool is the tool a tool reference has been resolved to.
+type ResolvedTool struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Image   string `json:"image"`
+}
+
+// ResolvedToolsAnnotationValue returns the value of the resolved tools
+// annotation, a JSON object of the resolved tools keyed by the reference.
+func ResolvedToolsAnnotationValue(tools map[string]Tool) (string, error) {
+	resolved := make(map[string]ResolvedTool, len(tools))
+	for ref, tool := range tools {
+		resolved[ref] = ResolvedTool{Name: tool.Name, Version: tool.Version, Image: tool.Image}
+	}
+	data, err := json.Marshal(resolved)
+	if err != nil {
+		return "", fmt.Errorf("marshal resolved tools error: %v", err)
+	}
+	return string(data), nil
+}
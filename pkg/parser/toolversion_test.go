@@ -0,0 +1,126 @@
+/*
+Copyright 2019 The Kubegene Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package parser
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func makeVersionedTools() map[string]Tool {
+	tools := make([]Tool, 0)
+	for _, version := range []string{"3.8", "4.0.1", "4.0.2.0", "4.1.0", "4.10.0", "5.0-rc1", "nightly"} {
+		tools = append(tools, Tool{Name: "gatk", Version: version, Image: "broadinstitute/gatk:" + version})
+	}
+	tools = append(tools, Tool{Name: "bwa", Version: "latest", Image: "bwa:latest"})
+	tools = append(tools, Tool{Name: "bwa", Version: "0.7.17", Image: "bwa:0.7.17"})
+	return TransTools2Map(tools)
+}
+
+func TestResolveTool(t *testing.T) {
+	testCases := []struct {
+		ref    string
+		expect string
+		expErr bool
+	}{
+		{ref: "gatk:4.0.1", expect: "4.0.1"},
+		{ref: "gatk:nightly", expect: "nightly"},
+		{ref: "gatk:5.0-rc1", expect: "5.0-rc1"},
+		{ref: "gatk:4.x", expect: "4.10.0"},
+		{ref: "gatk:4.0.*", expect: "4.0.2.0"},
+		{ref: "gatk:4", expect: "4.10.0"},
+		{ref: "gatk:>=4.0.1", expect: "4.10.0"},
+		{ref: "gatk:>=4.0, <4.1", expect: "4.0.2.0"},
+		{ref: "gatk:<4", expect: "3.8"},
+		{ref: "gatk:=5.0-rc1", expect: "5.0-rc1"},
+		{ref: "gatk:latest", expect: "4.10.0"},
+		{ref: "bwa:latest", expect: "latest"},
+		{ref: "gatk:6.x", expErr: true},
+		{ref: "gatk:>=a", expErr: true},
+		{ref: "gatk", expErr: true},
+		{ref: "samtools:latest", expErr: true},
+	}
+
+	tools := makeVersionedTools()
+	for _, testCase := range testCases {
+		tool, err := ResolveTool(tools, testCase.ref)
+		if testCase.expErr {
+			if err == nil {
+				t.Errorf("%s: expected error, but got %s", testCase.ref, tool.Version)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", testCase.ref, err)
+			continue
+		}
+		if tool.Version != testCase.expect {
+			t.Errorf("%s: expected version %s, got %s", testCase.ref, testCase.expect, tool.Version)
+		}
+	}
+}
+
+func TestResolvedToolsAnnotation(t *testing.T) {
+	workflowStr := `
+version: genecontainer_0_1
+workflow:
+  call:
+    tool: gatk:4.x
+    commands:
+    - gatk HaplotypeCaller
+  align:
+    tool: bwa:0.7.17
+    commands:
+    - bwa mem
+`
+	workflow, err := UnmarshalWorkflow([]byte(workflowStr))
+	if err != nil {
+		t.Fatalf("unmarshal workflow err: %v", err)
+	}
+	if err := InstantiateWorkflow(workflow, nil, makeVersionedTools()); err != nil {
+		t.Fatalf("unexpected instantiate error: %v", err)
+	}
+	exec, err := TransWorkflow2Execution(workflow)
+	if err != nil {
+		t.Fatalf("unexpected trans error: %v", err)
+	}
+
+	images := make(map[string]string)
+	for _, task := range exec.Spec.Tasks {
+		images[task.Name] = task.Image
+	}
+	if images["call"] != "broadinstitute/gatk:4.10.0" {
+		t.Errorf("expected the image of the highest 4.x version, got %s", images["call"])
+	}
+
+	var resolved map[string]ResolvedTool
+	if err := json.Unmarshal([]byte(exec.Annotations[ResolvedToolsAnnotation]), &resolved); err != nil {
+		t.Fatalf("unexpected annotation %q: %v", exec.Annotations[ResolvedToolsAnnotation], err)
+	}
+	expect := map[string]ResolvedTool{
+		"gatk:4.x":   {Name: "gatk", Version: "4.10.0", Image: "broadinstitute/gatk:4.10.0"},
+		"bwa:0.7.17": {Name: "bwa", Version: "0.7.17", Image: "bwa:0.7.17"},
+	}
+	if len(resolved) != len(expect) {
+		t.Fatalf("expected resolved tools %v, got %v", expect, resolved)
+	}
+	for ref, tool := range expect {
+		if resolved[ref] != tool {
+			t.Errorf("%s: expected resolved tool %v, got %v", ref, tool, resolved[ref])
+		}
+	}
+}
@@ -0,0 +1,614 @@
+/*
+Copyright 2018 The Kubegene Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package parser
+
+const (
+	StringType = "string"
+	NumberType = "number"
+	BoolType   = "bool"
+	ArrayType  = "array"
+)
+
+var InputTypeList = []string{StringType, NumberType, BoolType, ArrayType}
+
+// Tool is an abstraction of the gene sequencing container. It contains the basic information
+// about a gene sequencing container. such as image, version, description and so on. When we
+// use it in gene sequencing workflows, we can simply specify the tool as Name:version, and the
+// real image and other information will be replaced when running.
+//
+// tool example
+//
+// Name: GATK
+// version: 4.0.1
+// image: 1.0.0.21:/root/GATK:4.0.1
+// command: gatk hello world
+// type: basic
+// description: software package to analyze next-generation sequencing data
+// resources:
+//   memory: 1G
+//   cpu: 1C
+// env:
+//   - name: JAVA_OPTS
+//     value: -Xmx1g
+// working_dir: /gatk
+// image_pull_policy: Always
+// image_pull_secrets:
+//   - registry-secret
+// command_prefix: source /gatk/env.sh &&
+//
+// use example
+//
+// job-GATK:
+//   tool: GATK:4.0.1
+//   resources:
+//     memory: 2G
+//     cpu: 2C
+//   command:
+//     - sh ${obs-path}/${jobid}/bwa_mem.sh obs/path/sample1.fastq.gz obs/path/hg19.fa >obs/path/sample1.sam
+//     - sh ${obs-path}/${jobid}/bwa_mem.sh obs/path/sample1.fastq.gz obs/path/hg19.fa >obs/path/sample2.sam
+//
+// the final workflows job, the settings of the job override the defaults of the tool
+//
+// job-GATK:
+//   tool: GATK:4.0.1
+//   image: 1.0.0.21:/root/GATK:4.0.1
+//   resources:
+//     memory: 2G
+//     cpu: 2C
+//   env:
+//     - name: JAVA_OPTS
+//       value: -Xmx1g
+//   working_dir: /gatk
+//   image_pull_policy: Always
+//   image_pull_secrets:
+//     - registry-secret
+//   command:
+//     - source /gatk/env.sh && sh ${obs-path}/${jobid}/bwa_mem.sh obs/path/sample1.fastq.gz obs/path/hg19.fa >obs/path/sample1.sam
+//     - source /gatk/env.sh && sh ${obs-path}/${jobid}/bwa_mem.sh obs/path/sample1.fastq.gz obs/path/hg19.fa >obs/path/sample2.sam
+type Tool struct {
+	// The Name of tool.
+	// Required.
+	Name string `json:"Name" yaml:"Name"`
+	// The version of tool.
+	// Required.
+	Version string `json:"version" yaml:"version"`
+	// Docker image Name.
+	// Required.
+	Image string `json:"image" yaml:"image"`
+	// Command is the default command of the jobs using the tool that have
+	// no commands or commands_iter. The CommandPrefix is prepended to it as
+	// to the other commands.
+	Command string `json:"command,omitempty" yaml:"command,omitempty"`
+	// the type of tool.
+	// Deprecated: the type is kept for the existing tool files and is not
+	// used, the jobs of all the tools are run the same way.
+	Type string `json:"type" yaml:"type"`
+	// Description describes what the tool is used for.
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	// Resources is the default compute resources of the jobs using the tool.
+	Resources Resources `json:"resources,omitempty" yaml:"resources,omitempty"`
+	// Env is the default environment variables of the jobs using the tool.
+	Env []EnvVar `json:"env,omitempty" yaml:"env,omitempty"`
+	// WorkingDir is the default working directory of the jobs using the tool.
+	WorkingDir string `json:"working_dir,omitempty" yaml:"working_dir,omitempty"`
+	// ImagePullPolicy is the default pull policy of the image.
+	// One of Always, IfNotPresent, Never.
+	ImagePullPolicy string `json:"image_pull_policy,omitempty" yaml:"image_pull_policy,omitempty"`
+	// ImagePullSecrets is the names of the secrets to pull the image.
+	ImagePullSecrets []string `json:"image_pull_secrets,omitempty" yaml:"image_pull_secrets,omitempty"`
+	// CommandPrefix is prepended to every command of the jobs using the tool,
+	// such as setting up the environment of the tool.
+	CommandPrefix string `json:"command_prefix,omitempty" yaml:"command_prefix,omitempty"`
+}
+
+// EnvVar is an environment variable of the container of a job. The value is
+// either a literal, which can reference the inputs, or taken from a key of a
+// ConfigMap or a Secret.
+//
+// env example
+//
+// env:
+//   - name: SAMPLE
+//     value: ${sample}
+//   - name: LICENSE_KEY
+//     value_from:
+//       secret_key_ref:
+//         name: aligner-license
+//         key: key
+type EnvVar struct {
+	// Name of the environment variable.
+	Name string `json:"name" yaml:"name"`
+	// Value of the environment variable.
+	Value string `json:"value,omitempty" yaml:"value,omitempty"`
+	// ValueFrom is the source of the value, can not be used with value.
+	ValueFrom *EnvVarSource `json:"value_from,omitempty" yaml:"value_from,omitempty"`
+}
+
+// EnvVarSource is the source of the value of an environment variable.
+// Exactly one of the fields must be set.
+type EnvVarSource struct {
+	// ConfigMapKeyRef selects a key of a ConfigMap.
+	ConfigMapKeyRef *KeySelector `json:"config_map_key_ref,omitempty" yaml:"config_map_key_ref,omitempty"`
+	// SecretKeyRef selects a key of a Secret.
+	SecretKeyRef *KeySelector `json:"secret_key_ref,omitempty" yaml:"secret_key_ref,omitempty"`
+}
+
+// KeySelector selects a key of a ConfigMap or a Secret in the namespace of
+// the execution.
+type KeySelector struct {
+	// Name of the ConfigMap or the Secret.
+	Name string `json:"name" yaml:"name"`
+	// Key to select.
+	Key string `json:"key" yaml:"key"`
+	// Optional specifies whether the ConfigMap or the Secret or the key
+	// may be missing.
+	Optional bool `json:"optional,omitempty" yaml:"optional,omitempty"`
+}
+
+// EnvFromSource sets all the keys of a ConfigMap or a Secret as environment
+// variables. Exactly one of config_map and secret must be set.
+//
+// env_from example
+//
+// env_from:
+//   - config_map: database-config
+//   - secret: database-credentials
+//     prefix: DB_
+type EnvFromSource struct {
+	// Prefix is prepended to every key.
+	Prefix string `json:"prefix,omitempty" yaml:"prefix,omitempty"`
+	// ConfigMap is the name of the ConfigMap.
+	ConfigMap string `json:"config_map,omitempty" yaml:"config_map,omitempty"`
+	// Secret is the name of the Secret.
+	Secret string `json:"secret,omitempty" yaml:"secret,omitempty"`
+	// Optional specifies whether the ConfigMap or the Secret may be missing.
+	Optional bool `json:"optional,omitempty" yaml:"optional,omitempty"`
+}
+
+// PodSettings are the settings of the pods of the jobs. They can be set for
+// the whole workflow and for a single job.
+//
+// pod settings example
+//
+// security_context:
+//   run_as_user: 1000
+//   fs_group: 2000
+// service_account_name: gene-runner
+// image_pull_secrets:
+//   - registry-secret
+// priority_class_name: high-priority
+// pod_labels:
+//   team: genomics
+// pod_annotations:
+//   sidecar.istio.io/inject: "false"
+// pod_spec_patch: |
+//   dnsPolicy: Default
+type PodSettings struct {
+	// SecurityContext holds the pod-level security attributes.
+	SecurityContext *PodSecurityContext `json:"security_context,omitempty" yaml:"security_context,omitempty"`
+	// ServiceAccountName is the name of the service account to run the pods.
+	ServiceAccountName string `json:"service_account_name,omitempty" yaml:"service_account_name,omitempty"`
+	// ImagePullSecrets is the names of the secrets to pull the images.
+	ImagePullSecrets []string `json:"image_pull_secrets,omitempty" yaml:"image_pull_secrets,omitempty"`
+	// PriorityClassName is the priority class of the pods.
+	PriorityClassName string `json:"priority_class_name,omitempty" yaml:"priority_class_name,omitempty"`
+	// PodLabels are the extra labels of the pods.
+	PodLabels map[string]string `json:"pod_labels,omitempty" yaml:"pod_labels,omitempty"`
+	// PodAnnotations are the extra annotations of the pods.
+	PodAnnotations map[string]string `json:"pod_annotations,omitempty" yaml:"pod_annotations,omitempty"`
+	// PodSpecPatch is a strategic merge patch of the kubernetes pod spec, for
+	// the fields that are not supported otherwise. The patch of the workflow
+	// is applied before the one of the job.
+	PodSpecPatch string `json:"pod_spec_patch,omitempty" yaml:"pod_spec_patch,omitempty"`
+}
+
+// PodSecurityContext holds the pod-level security attributes.
+type PodSecurityContext struct {
+	// RunAsUser is the UID to run the entrypoint of the containers.
+	RunAsUser *int64 `json:"run_as_user,omitempty" yaml:"run_as_user,omitempty"`
+	// RunAsGroup is the GID to run the entrypoint of the containers.
+	RunAsGroup *int64 `json:"run_as_group,omitempty" yaml:"run_as_group,omitempty"`
+	// RunAsNonRoot requires the containers to run as a non-root user.
+	RunAsNonRoot *bool `json:"run_as_non_root,omitempty" yaml:"run_as_non_root,omitempty"`
+	// FSGroup is the group that owns the volumes, such as NFS volumes.
+	FSGroup *int64 `json:"fs_group,omitempty" yaml:"fs_group,omitempty"`
+	// SupplementalGroups are the groups of the containers in addition to
+	// their primary GID.
+	SupplementalGroups []int64 `json:"supplemental_groups,omitempty" yaml:"supplemental_groups,omitempty"`
+}
+
+// Input defines input parameter that used for gene sequencing.
+//
+// input example
+//
+// sample:
+//   default: /sample/data
+//   description: the path that stores the sample data.
+//   type: string
+type Input struct {
+	// Default is the default value that will be used for an input parameter
+	// if a value was not provided.
+	Default interface{} `json:"default,omitempty" yaml:"default,omitempty"`
+
+	// Value is the literal value to use for the parameter.
+	Value interface{} `json:"value,omitempty" yaml:"value,omitempty"`
+
+	// Description is the information about what the parameter is used for.
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+
+	// Type defines the type for the input parameter.
+	// One of string, number, bool, array.
+	// The default type is string if not specified.
+	Type string `json:"type,omitempty" yaml:"type,omitempty"`
+}
+
+// Compute Resources required by this container. The resources at the top
+// level are the requests.
+//
+// resources example
+//
+// resources:
+//   memory: 4G
+//   cpu: 2C
+//   ephemeral_storage: 50Gi
+//   extended:
+//     nvidia.com/gpu: "1"
+//   limits:
+//     memory: 8G
+type Resources struct {
+	ResourceList `json:",inline" yaml:",inline"`
+	// Limits are the limits of the resources, a limit must not be less than
+	// the request. The limit of an extended resource defaults to its request.
+	Limits *ResourceList `json:"limits,omitempty" yaml:"limits,omitempty"`
+}
+
+// ResourceList is a set of compute resources.
+type ResourceList struct {
+	Memory string `json:"memory,omitempty" yaml:"memory,omitempty"`
+	Cpu    string `json:"cpu,omitempty" yaml:"cpu,omitempty"`
+	// EphemeralStorage is the local ephemeral storage, a kubernetes quantity
+	// such as 50Gi.
+	EphemeralStorage string `json:"ephemeral_storage,omitempty" yaml:"ephemeral_storage,omitempty"`
+	// Extended is the extended resources keyed by the fully qualified resource
+	// name such as nvidia.com/gpu, the values are kubernetes quantities.
+	Extended map[string]string `json:"extended,omitempty" yaml:"extended,omitempty"`
+}
+
+// CommandsIter defines command for workflows job. If both Vars and Vars_iter are specified,
+// the generate command will be merged.
+type CommandsIter struct {
+	// Command is the base command that contains variables.
+	Command string `json:"command" yaml:"command"`
+
+	// Vars list all the parameters for the command.
+	//
+	// commandsIter example
+	//
+	//    commands_iter:
+	//      command: sh /tmp/scripts/step1.splitfq.sh ${1} ${2} /tmp/data ${3}
+	//      vars:
+	//        - ["sample1", 0, 25] # Each member of the array will be the ${1}, ${2}, ${3}
+	//        - ["sample2", 0, 25]
+	//        - ["sample1", 1, 25]
+	//        - ["sample2", 1, 25]
+	//
+	// then the final command will be:
+	//
+	// sh /tmp/scripts/step1.splitfq.sh sample1 0 ${sample-path} 25
+	// sh /tmp/scripts/step1.splitfq.sh sample2 0 ${sample-path} 25
+	// sh /tmp/scripts/step1.splitfq.sh sample1 1 ${sample-path} 25
+	// sh /tmp/scripts/step1.splitfq.sh sample2 1 ${sample-path} 25
+	Vars []interface{} `json:"vars,omitempty" yaml:"vars,omitempty"`
+
+	// VarsIter list all the possible parameters for every position in the command line.
+	// And we will use algorithm Of Full Permutation to generate all the permutation and
+	// combinations for these parameter that will be used to replace the ${number} variable.
+	//
+	// commandsIter example
+	//
+	//    commands_iter:
+	//      command: sh /tmp/scripts/step1.splitfq.sh ${1} ${2} /tmp/data ${3}
+	//      vars_iter:
+	//        - ["sample1", "sample2"]
+	//        - [0, 1]
+	//        - [25]
+	//
+	// then the final command will be:
+	//
+	// sh /tmp/scripts/step1.splitfq.sh sample1 0 /tmp/data 25
+	// sh /tmp/scripts/step1.splitfq.sh sample2 0 /tmp/data 25
+	// sh /tmp/scripts/step1.splitfq.sh sample1 1 /tmp/data 25
+	// sh /tmp/scripts/step1.splitfq.sh sample2 1 /tmp/data 25
+	VarsIter []interface{} `json:"vars_iter,omitempty" yaml:"vars_iter,omitempty"`
+
+	// Zip combines the rows of vars_iter one by one instead of the full
+	// permutation, every row must have the same length.
+	//
+	// commandsIter example
+	//
+	//    commands_iter:
+	//      command: sh /tmp/scripts/align.sh ${1} ${2}
+	//      vars_iter:
+	//        - get_result(job-reads, " ")
+	//        - get_result(job-index, " ")
+	//      zip: true
+	//
+	// if stdout of job-reads is "r1 r2" and stdout of job-index is "i1 i2",
+	// then the final command will be:
+	//
+	// sh /tmp/scripts/align.sh r1 i1
+	// sh /tmp/scripts/align.sh r2 i2
+	Zip bool `json:"zip,omitempty" yaml:"zip,omitempty"`
+}
+
+const (
+	WholeDependType   = "whole"
+	IterateDependType = "iterate"
+)
+
+type Depend struct {
+	// Target is the Name of job this depends on.
+	Target string `json:"target" yaml:"target"`
+
+	// Type is the depends type.
+	// One of whole, iterate
+	// Default to `whole`.
+	//
+	// Examples:
+	//
+	// "whole" - A-->B": jobs of B depends on all jobs of A execution done.
+	// "iterate" - A[1,2,3]-->B[1,2,3]: jobs of B depends on jobs of A one by one.
+	// That is to say: A[1]->B[1], A[2]->B[2], A[3]->B[3]
+	Type string `json:"type,omitempty" yaml:"type,omitempty"`
+}
+
+// job information.
+type JobInfo struct {
+	// Description describes what this job is to do.
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	// tool Name
+	Tool string `json:"tool" yaml:"tool"`
+	// docker image Name.
+	Image string `json:"image,omitempty" yaml:"image,omitempty"`
+	// Compute Resources required by this job.
+	Resources Resources `json:"resources,omitempty" yaml:"resources,omitempty"`
+	// Env is the environment variables of the job.
+	Env []EnvVar `json:"env,omitempty" yaml:"env,omitempty"`
+	// EnvFrom sets the keys of ConfigMaps or Secrets as environment variables
+	// of the job. The variables of env take precedence.
+	EnvFrom []EnvFromSource `json:"env_from,omitempty" yaml:"env_from,omitempty"`
+	// WorkingDir is the working directory of the job.
+	WorkingDir string `json:"working_dir,omitempty" yaml:"working_dir,omitempty"`
+	// ImagePullPolicy is the pull policy of the image of the job.
+	// One of Always, IfNotPresent, Never.
+	ImagePullPolicy string `json:"image_pull_policy,omitempty" yaml:"image_pull_policy,omitempty"`
+	// PodSettings are the settings of the pod of the job. The image pull
+	// secrets are used in addition to the secrets of the tool and the
+	// workflow, the other settings override the ones of the workflow.
+	PodSettings `json:",inline" yaml:",inline"`
+	// Volumes is the workflow volumes the job mounts. If not specified, the
+	// job mounts all the volumes of the workflow.
+	Volumes []VolumeMount `json:"volumes,omitempty" yaml:"volumes,omitempty"`
+	// command to run for gene sequencing.
+	Commands []string `json:"commands,omitempty" yaml:"commands,omitempty"`
+	// CommandsIter defines batch command for workflows job.
+	CommandsIter CommandsIter `json:"commands_iter,omitempty" yaml:"commands_iter,omitempty"`
+	// Depends is the Name of task this depends on.
+	Depends []Depend `json:"depends,omitempty" yaml:"depends,omitempty"`
+	// conditional branch handling
+	Condition interface{} `json:"condition,omitempty" yaml:"condition,omitempty"`
+	// Loop re-runs the job until the loop condition is met.
+	Loop *Loop `json:"loop,omitempty" yaml:"loop,omitempty"`
+	// SubWorkflow references a nested workflow that this job expands to.
+	SubWorkflow *SubWorkflow `json:"sub_workflow,omitempty" yaml:"sub_workflow,omitempty"`
+
+	// subWorkflow is the path of the nested workflow the job is expanded
+	// from, such as qc/align. Empty for the jobs of the top workflow.
+	subWorkflow string
+}
+
+// SubWorkflow references another workflow that will be expanded inside
+// the current workflow. Every job of the nested workflow is renamed with
+// the name of the referencing job as prefix. The root jobs of the nested
+// workflow inherit the depends of the referencing job, and the jobs that
+// depend on the referencing job will depend on the leaf jobs of the nested
+// workflow.
+//
+// sub workflow example
+//
+// workflow:
+//   align:
+//     sub_workflow:
+//       path: align.yaml
+//       inputs:
+//         sample: ${sample}
+//     depends:
+//       - target: prepare
+//
+// then job bwa of align.yaml will become job align-bwa.
+type SubWorkflow struct {
+	// Path is the path of the workflow file. A relative path is relative
+	// to the directory of the referencing workflow file.
+	Path string `json:"path,omitempty" yaml:"path,omitempty"`
+	// Catalog is the name of the workflow in the workflow repository.
+	// One of path or catalog must be specified.
+	Catalog string `json:"catalog,omitempty" yaml:"catalog,omitempty"`
+	// Inputs binds values to the inputs of the nested workflow.
+	// Variants of the referencing workflow can be used.
+	Inputs map[string]interface{} `json:"inputs,omitempty" yaml:"inputs,omitempty"`
+
+	// workflow is the loaded nested workflow.
+	workflow *Workflow
+}
+
+// Loop defines how a job is re-run. Every iteration is run as a separate
+// k8s job, and ${iteration} in the command is replaced with the index of
+// the iteration.
+//
+// loop example
+//
+//   loop:
+//     until: COMPLETE
+//     max_iterations: 20
+//
+// the job will be re-run until its stdout is COMPLETE, at most 20 times.
+// If until is not specified, the job will be re-run until it succeeds.
+//
+// The jobs of the body are re-run with the job in every iteration, the job
+// depends on all of them and is the only one the other jobs can depend on.
+//
+//   loop:
+//     until: CONVERGED
+//     max_iterations: 3
+//     body:
+//       - recalibrate
+type Loop struct {
+	// Until is the expected stdout of an iteration.
+	Until string `json:"until,omitempty" yaml:"until,omitempty"`
+	// MaxIterations is the max number of iterations.
+	// Required.
+	MaxIterations int `json:"max_iterations" yaml:"max_iterations"`
+	// Body are the other jobs that are re-run in every iteration.
+	Body []string `json:"body,omitempty" yaml:"body,omitempty"`
+}
+
+// PathsIter similar to CommandsIter.
+type PathsIter struct {
+	Path     string        `json:"path" yaml:"path"`
+	Vars     []interface{} `json:"vars,omitempty" yaml:"vars,omitempty"`
+	VarsIter []interface{} `json:"vars_iter,omitempty" yaml:"vars_iter,omitempty"`
+}
+
+// VolumeMount mounts a volume of the workflow into a job.
+//
+// volume mount example
+//
+// volumes:
+//   - name: reference
+//     read_only: true
+//   - name: data
+//     mount_path: /output
+//     sub_path: ${sample}
+type VolumeMount struct {
+	// Name is the name of the volume of the workflow.
+	Name string `json:"name" yaml:"name"`
+	// MountPath overrides the mount path of the volume.
+	MountPath string `json:"mount_path,omitempty" yaml:"mount_path,omitempty"`
+	// ReadOnly mounts the volume read-only.
+	ReadOnly bool `json:"read_only,omitempty" yaml:"read_only,omitempty"`
+	// SubPath is the path within the volume to mount instead of its root.
+	SubPath string `json:"sub_path,omitempty" yaml:"sub_path,omitempty"`
+}
+
+// VolumeSource is the source of a volume, exactly one of the fields must be set.
+//
+// volumes example
+//
+// volumes:
+//   data:
+//     mount_path: /data
+//     mount_from:
+//       pvc: ${pvc}
+//   scratch:
+//     mount_path: /scratch
+//     mount_from:
+//       empty_dir:
+//         medium: Memory
+//         size_limit: 10Gi
+//   reference:
+//     mount_path: /reference
+//     mount_from:
+//       nfs:
+//         server: nfs.example.com
+//         path: /exports/hg19
+type VolumeSource struct {
+	// PVC is the name of a PersistentVolumeClaim.
+	PVC string `json:"pvc,omitempty" yaml:"pvc,omitempty"`
+	// EmptyDir is a node-local scratch directory that lives as long as the pod.
+	EmptyDir *EmptyDirVolumeSource `json:"empty_dir,omitempty" yaml:"empty_dir,omitempty"`
+	// HostPath is a directory of the node.
+	HostPath *HostPathVolumeSource `json:"host_path,omitempty" yaml:"host_path,omitempty"`
+	// ConfigMap is a ConfigMap whose keys are mounted as files.
+	ConfigMap *ObjectVolumeSource `json:"config_map,omitempty" yaml:"config_map,omitempty"`
+	// Secret is a Secret whose keys are mounted as files.
+	Secret *ObjectVolumeSource `json:"secret,omitempty" yaml:"secret,omitempty"`
+	// NFS is an export of an NFS server.
+	NFS *NFSVolumeSource `json:"nfs,omitempty" yaml:"nfs,omitempty"`
+}
+
+type EmptyDirVolumeSource struct {
+	// Medium is the storage medium, empty for the disk of the node or Memory
+	// for tmpfs.
+	Medium string `json:"medium,omitempty" yaml:"medium,omitempty"`
+	// SizeLimit is the max size of the directory, such as 10Gi.
+	SizeLimit string `json:"size_limit,omitempty" yaml:"size_limit,omitempty"`
+}
+
+type HostPathVolumeSource struct {
+	// Path of the directory on the node.
+	Path string `json:"path" yaml:"path"`
+	// Type of the path, such as Directory or DirectoryOrCreate.
+	Type string `json:"type,omitempty" yaml:"type,omitempty"`
+}
+
+type ObjectVolumeSource struct {
+	// Name of the ConfigMap or the Secret.
+	Name string `json:"name" yaml:"name"`
+	// Optional specifies whether the ConfigMap or the Secret may be missing.
+	Optional bool `json:"optional,omitempty" yaml:"optional,omitempty"`
+}
+
+type NFSVolumeSource struct {
+	// Server is the hostname or the IP address of the NFS server.
+	Server string `json:"server" yaml:"server"`
+	// Path of the export.
+	Path string `json:"path" yaml:"path"`
+	// ReadOnly mounts the export read-only.
+	ReadOnly bool `json:"read_only,omitempty" yaml:"read_only,omitempty"`
+}
+
+type Volume struct {
+	MountPath string       `json:"mount_path" yaml:"mount_path"`
+	MountFrom VolumeSource `json:"mount_from" yaml:"mount_from"`
+}
+
+type OutputDesc struct {
+	Paths     []string  `json:"paths" yaml:"paths"`
+	PathsIter PathsIter `json:"paths_iter,omitempty" yaml:"paths_iter,omitempty"`
+}
+
+//
+type Workflow struct {
+	// Workflow version
+	Version string                `json:"version" yaml:"version"`
+	Inputs  map[string]Input      `json:"inputs,omitempty" yaml:"inputs,omitempty"`
+	Jobs    map[string]JobInfo    `json:"workflow" yaml:"workflow"`
+	Volumes map[string]Volume     `json:"volumes" yaml:"volumes"`
+	Outputs map[string]OutputDesc `json:"outputs,omitempty" yaml:"outputs,omitempty"`
+	// Tools is the tools resolved for the tool references of the jobs once
+	// the workflow is instantiated, keyed by the reference such as gatk:4.x.
+	Tools map[string]Tool `json:"tools" yaml:"tools"`
+	// OnExit is the names of the jobs that run after all the other jobs
+	// have finished, whether the workflow succeeded or failed. Their
+	// commands can use ${workflow.phase} and ${workflow.failures}.
+	OnExit []string `json:"on_exit,omitempty" yaml:"on_exit,omitempty"`
+	// PodSettings are the settings of the pods of all the jobs.
+	PodSettings `json:",inline" yaml:",inline"`
+}
+
+// ErrorList holds a set of Errors.
+type ErrorList []error
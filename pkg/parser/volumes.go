@@ -0,0 +1,305 @@
+/*
+Copyright 2018 The Kubegene Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package parser
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	execv1alpha1 "kubegene.io/kubegene/pkg/apis/gene/v1alpha1"
+	"kubegene.io/kubegene/pkg/common"
+)
+
+var hostPathTypes = []string{
+	string(corev1.HostPathUnset), string(corev1.HostPathDirectoryOrCreate), string(corev1.HostPathDirectory),
+	string(corev1.HostPathFileOrCreate), string(corev1.HostPathFile), string(corev1.HostPathSocket),
+	string(corev1.HostPathCharDev), string(corev1.HostPathBlockDev),
+}
+
+func ValidateVolumes(volumes map[string]Volume, inputs map[string]Input) ErrorList {
+	errors := ErrorList{}
+	for key, volume := range volumes {
+		prefix := fmt.Sprintf("volumes[%s].mount_from", key)
+		if sources := volumeSourceNames(volume.MountFrom); len(sources) != 1 {
+			err := fmt.Errorf("%s: exactly one of pvc, empty_dir, host_path, config_map, secret and nfs should be specified, but got %v", prefix, sources)
+			errors = append(errors, err)
+			continue
+		}
+		errors = append(errors, validateVolumeSource(prefix, volume.MountFrom, inputs)...)
+
+		mountPath := volume.MountPath
+		if len(mountPath) == 0 {
+			errors = append(errors, fmt.Errorf("volumes[%s].mountPath: mountPath should be empty", key))
+			continue
+		} else if IsVariant(mountPath) {
+			prefix := fmt.Sprintf("volumes[%s].mountPath", key)
+			if err := ValidateVariant(prefix, mountPath, []string{StringType}, inputs); err != nil {
+				errors = append(errors, err)
+			}
+		} else if !path.IsAbs(mountPath) {
+			err := fmt.Errorf("volumes[%s].mountPath: mountPath should be an absolute path, but the real one is %s", key, mountPath)
+			errors = append(errors, err)
+		}
+	}
+	return errors
+}
+
+// ValidateJobVolumes validates the volumes mounted by a job. They should be
+// volumes of the workflow, and the sub path should be a relative path within
+// the volume.
+func ValidateJobVolumes(jobName string, mounts []VolumeMount, volumes map[string]Volume, inputs map[string]Input) ErrorList {
+	errors := ErrorList{}
+	names := make(map[string]bool, len(mounts))
+	for i, mount := range mounts {
+		prefix := fmt.Sprintf("workflow.%s.volumes[%d]", jobName, i)
+		if _, ok := volumes[mount.Name]; !ok {
+			errors = append(errors, fmt.Errorf("%s.name: volume [%s] does not exist in the volumes of the workflow", prefix, mount.Name))
+		}
+		if names[mount.Name] {
+			errors = append(errors, fmt.Errorf("%s.name: volume [%s] is mounted more than once", prefix, mount.Name))
+		}
+		names[mount.Name] = true
+
+		if IsVariant(mount.MountPath) {
+			if err := ValidateVariant(prefix+".mount_path", mount.MountPath, []string{StringType}, inputs); err != nil {
+				errors = append(errors, err)
+			}
+		} else if len(mount.MountPath) != 0 && !path.IsAbs(mount.MountPath) {
+			errors = append(errors, fmt.Errorf("%s.mount_path should be an absolute path, but the real one is %s", prefix, mount.MountPath))
+		}
+
+		_, errs := ValidateTemplate(mount.SubPath, prefix+".sub_path", "sub_path", inputs)
+		errors = append(errors, errs...)
+		if path.IsAbs(mount.SubPath) || sliceContain(strings.Split(mount.SubPath, "/"), "..") != -1 {
+			errors = append(errors, fmt.Errorf("%s.sub_path should be a relative path within the volume, but the real one is %s", prefix, mount.SubPath))
+		}
+	}
+	return errors
+}
+
+// InstantiateJobVolumes replaces the inputs referenced by the volumes mounted
+// by a job.
+func InstantiateJobVolumes(mounts []VolumeMount, data map[string]string) []VolumeMount {
+	if mounts == nil {
+		return nil
+	}
+	instance := make([]VolumeMount, 0, len(mounts))
+	for _, mount := range mounts {
+		mount.MountPath = common.ReplaceVariant(mount.MountPath, data)
+		mount.SubPath = common.ReplaceVariant(mount.SubPath, data)
+		instance = append(instance, mount)
+	}
+	return instance
+}
+
+// volumeSourceNames returns the names of the sources set in the volume source.
+func volumeSourceNames(source VolumeSource) []string {
+	names := make([]string, 0)
+	if len(source.PVC) != 0 {
+		names = append(names, "pvc")
+	}
+	if source.EmptyDir != nil {
+		names = append(names, "empty_dir")
+	}
+	if source.HostPath != nil {
+		names = append(names, "host_path")
+	}
+	if source.ConfigMap != nil {
+		names = append(names, "config_map")
+	}
+	if source.Secret != nil {
+		names = append(names, "secret")
+	}
+	if source.NFS != nil {
+		names = append(names, "nfs")
+	}
+	return names
+}
+
+func validateVolumeSource(prefix string, source VolumeSource, inputs map[string]Input) ErrorList {
+	errors := ErrorList{}
+	// validateString validates a required field that can be a string variant.
+	validateString := func(field, value string) {
+		if len(value) == 0 {
+			errors = append(errors, fmt.Errorf("%s.%s should not be empty", prefix, field))
+		} else if IsVariant(value) {
+			if err := ValidateVariant(prefix+"."+field, value, []string{StringType}, inputs); err != nil {
+				errors = append(errors, err)
+			}
+		}
+	}
+	// validatePath validates a required absolute path that can be a string variant.
+	validatePath := func(field, value string) {
+		validateString(field, value)
+		if len(value) != 0 && !IsVariant(value) && !path.IsAbs(value) {
+			errors = append(errors, fmt.Errorf("%s.%s should be an absolute path, but the real one is %s", prefix, field, value))
+		}
+	}
+
+	switch {
+	case len(source.PVC) != 0:
+		validateString("pvc", source.PVC)
+	case source.EmptyDir != nil:
+		medium := source.EmptyDir.Medium
+		if medium != string(corev1.StorageMediumDefault) && medium != string(corev1.StorageMediumMemory) {
+			errors = append(errors, fmt.Errorf("%s.empty_dir.medium %s is illegal, it should be empty or Memory", prefix, medium))
+		}
+		sizeLimit := source.EmptyDir.SizeLimit
+		if IsVariant(sizeLimit) {
+			if err := ValidateVariant(prefix+".empty_dir.size_limit", sizeLimit, []string{StringType, NumberType}, inputs); err != nil {
+				errors = append(errors, err)
+			}
+		} else if len(sizeLimit) != 0 {
+			if _, err := resource.ParseQuantity(sizeLimit); err != nil {
+				errors = append(errors, fmt.Errorf("%s.empty_dir.size_limit %s is illegal: %v", prefix, sizeLimit, err))
+			}
+		}
+	case source.HostPath != nil:
+		validatePath("host_path.path", source.HostPath.Path)
+		if sliceContain(hostPathTypes, source.HostPath.Type) == -1 {
+			errors = append(errors, fmt.Errorf("%s.host_path.type %s is illegal, it should be one of %s",
+				prefix, source.HostPath.Type, strings.Join(hostPathTypes[1:], ", ")))
+		}
+	case source.ConfigMap != nil:
+		validateString("config_map.name", source.ConfigMap.Name)
+	case source.Secret != nil:
+		validateString("secret.name", source.Secret.Name)
+	case source.NFS != nil:
+		validateString("nfs.server", source.NFS.Server)
+		validatePath("nfs.path", source.NFS.Path)
+	}
+	return errors
+}
+
+// InstantiateVolumeSource replaces the inputs referenced by the volume source.
+func InstantiateVolumeSource(source VolumeSource, data map[string]string) VolumeSource {
+	source.PVC = common.ReplaceVariant(source.PVC, data)
+	if source.EmptyDir != nil {
+		emptyDir := *source.EmptyDir
+		emptyDir.SizeLimit = common.ReplaceVariant(emptyDir.SizeLimit, data)
+		source.EmptyDir = &emptyDir
+	}
+	if source.HostPath != nil {
+		hostPath := *source.HostPath
+		hostPath.Path = common.ReplaceVariant(hostPath.Path, data)
+		source.HostPath = &hostPath
+	}
+	if source.ConfigMap != nil {
+		configMap := *source.ConfigMap
+		configMap.Name = common.ReplaceVariant(configMap.Name, data)
+		source.ConfigMap = &configMap
+	}
+	if source.Secret != nil {
+		secret := *source.Secret
+		secret.Name = common.ReplaceVariant(secret.Name, data)
+		source.Secret = &secret
+	}
+	if source.NFS != nil {
+		nfs := *source.NFS
+		nfs.Server = common.ReplaceVariant(nfs.Server, data)
+		nfs.Path = common.ReplaceVariant(nfs.Path, data)
+		source.NFS = &nfs
+	}
+	return source
+}
+
+func TransVolume2ExecVolume(volumes map[string]Volume) (map[string]execv1alpha1.Volume, error) {
+	execVolumes := make(map[string]execv1alpha1.Volume, len(volumes))
+	for key, volume := range volumes {
+		var tmpVolume execv1alpha1.Volume
+		mountFrom, err := TransVolumeSource2ExecVolumeSource(volume.MountFrom)
+		if err != nil {
+			return nil, fmt.Errorf("volume %s: %v", key, err)
+		}
+		tmpVolume.MountFrom = mountFrom
+		tmpVolume.MountPath = volume.MountPath
+		execVolumes[key] = tmpVolume
+	}
+	return execVolumes, nil
+}
+
+// TransJobVolumes2ExecVolumes returns the volumes of the task of a job. A job
+// that does not list its volumes mounts all the volumes of the workflow.
+func TransJobVolumes2ExecVolumes(mounts []VolumeMount, volumes map[string]Volume) (map[string]execv1alpha1.Volume, error) {
+	execVolumes, err := TransVolume2ExecVolume(volumes)
+	if err != nil || mounts == nil {
+		return execVolumes, err
+	}
+
+	jobVolumes := make(map[string]execv1alpha1.Volume, len(mounts))
+	for _, mount := range mounts {
+		volume, ok := execVolumes[mount.Name]
+		if !ok {
+			continue
+		}
+		if len(mount.MountPath) != 0 {
+			volume.MountPath = mount.MountPath
+		}
+		volume.ReadOnly = mount.ReadOnly
+		volume.SubPath = mount.SubPath
+		jobVolumes[mount.Name] = volume
+	}
+	return jobVolumes, nil
+}
+
+// TransVolumeSource2ExecVolumeSource returns the volume source of an
+// execution. The size limit of an empty dir may come from an input, so that
+// it is parsed again once the inputs are replaced.
+func TransVolumeSource2ExecVolumeSource(source VolumeSource) (execv1alpha1.VolumeSource, error) {
+	execSource := execv1alpha1.VolumeSource{Pvc: source.PVC}
+	if source.EmptyDir != nil {
+		execSource.EmptyDir = &corev1.EmptyDirVolumeSource{Medium: corev1.StorageMedium(source.EmptyDir.Medium)}
+		if len(source.EmptyDir.SizeLimit) != 0 {
+			sizeLimit, err := resource.ParseQuantity(source.EmptyDir.SizeLimit)
+			if err != nil {
+				return execSource, fmt.Errorf("empty_dir.size_limit %s is illegal: %v", source.EmptyDir.SizeLimit, err)
+			}
+			execSource.EmptyDir.SizeLimit = &sizeLimit
+		}
+	}
+	if source.HostPath != nil {
+		execSource.HostPath = &corev1.HostPathVolumeSource{Path: source.HostPath.Path}
+		if len(source.HostPath.Type) != 0 {
+			hostPathType := corev1.HostPathType(source.HostPath.Type)
+			execSource.HostPath.Type = &hostPathType
+		}
+	}
+	if source.ConfigMap != nil {
+		execSource.ConfigMap = &corev1.ConfigMapVolumeSource{
+			LocalObjectReference: corev1.LocalObjectReference{Name: source.ConfigMap.Name},
+			Optional:             optional(source.ConfigMap.Optional),
+		}
+	}
+	if source.Secret != nil {
+		execSource.Secret = &corev1.SecretVolumeSource{
+			SecretName: source.Secret.Name,
+			Optional:   optional(source.Secret.Optional),
+		}
+	}
+	if source.NFS != nil {
+		execSource.NFS = &corev1.NFSVolumeSource{
+			Server:   source.NFS.Server,
+			Path:     source.NFS.Path,
+			ReadOnly: source.NFS.ReadOnly,
+		}
+	}
+	return execSource, nil
+}
@@ -0,0 +1,384 @@
+/*
+Copyright 2018 The Kubegene Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package parser
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ghodss/yaml"
+
+	execv1alpha1 "kubegene.io/kubegene/pkg/apis/gene/v1alpha1"
+)
+
+func TestValidateVolumes(t *testing.T) {
+	testCases := []struct {
+		Name      string
+		Volumes   string
+		Inputs    map[string]Input
+		ExpectErr bool
+	}{
+		{
+			Name: "valid volume",
+			Volumes: `
+  reference:
+    mount_path: /root
+    mount_from:
+      pvc: ${gcs-pvc}`,
+			Inputs:    makeInputs(),
+			ExpectErr: false,
+		},
+		{
+			Name: "volumes[reference].mount_from: volume only support pvc and mount_from.pvc should not be empty",
+			Volumes: `
+  reference:
+    mount_path: /root
+    mount_from:
+      xxx: ${gcs-pvc}`,
+			Inputs:    makeInputs(),
+			ExpectErr: true,
+		},
+		{
+			Name: "volumes[reference].mount_from.pvc: the variant [xxx] is not defined in the inputs",
+			Volumes: `
+  reference:
+    mount_path: /root
+    mount_from:
+      pvc: ${xxx}`,
+			Inputs:    makeInputs(),
+			ExpectErr: true,
+		},
+		{
+			Name: "volumes[reference].mount_from.pvc: the type of bwaArray can only be in [string], but the real one is array",
+			Volumes: `
+volumes:
+  reference:
+    mount_path: /root
+    mount_from:
+      pvc: ${bwaArray}`,
+			Inputs:    makeInputs(),
+			ExpectErr: true,
+		},
+		{
+			Name: "volumes[reference].mount_path: the type of bwaArray can only be in [string], but the real one is array",
+			Volumes: `
+  reference:
+    mount_path: ${bwaArray}
+    mount_from:
+      pvc: ${gcs-pvc}`,
+			Inputs:    makeInputs(),
+			ExpectErr: true,
+		},
+		{
+			Name: "volumes[reference].mount_path: mount path should be empty",
+			Volumes: `
+  reference:
+    mount_path:
+    mount_from:
+      pvc: ${gcs-pvc}`,
+			Inputs:    makeInputs(),
+			ExpectErr: true,
+		},
+		{
+			Name: "volumes[reference].mount_path: mount path should be an absolute path, but the real one is fdafdasf/fdaf",
+			Volumes: `
+  reference:
+    mount_path: fdafdasf/fdaf
+    mount_from:
+      pvc: ${gcs-pvc}`,
+			Inputs:    makeInputs(),
+			ExpectErr: true,
+		},
+		{
+			Name: "valid volume sources",
+			Volumes: `
+  scratch:
+    mount_path: /scratch
+    mount_from:
+      empty_dir:
+        medium: Memory
+        size_limit: 10Gi
+  docker:
+    mount_path: /var/run/docker.sock
+    mount_from:
+      host_path:
+        path: /var/run/docker.sock
+        type: Socket
+  config:
+    mount_path: /etc/gene
+    mount_from:
+      config_map:
+        name: gene-config
+  license:
+    mount_path: /etc/license
+    mount_from:
+      secret:
+        name: ${gcs-pvc}
+  reference:
+    mount_path: /reference
+    mount_from:
+      nfs:
+        server: nfs.example.com
+        path: /exports/hg19
+        read_only: true`,
+			Inputs:    makeInputs(),
+			ExpectErr: false,
+		},
+		{
+			Name: "volumes[reference].mount_from: exactly one source should be specified",
+			Volumes: `
+  reference:
+    mount_path: /root
+    mount_from:
+      pvc: ${gcs-pvc}
+      empty_dir: {}`,
+			Inputs:    makeInputs(),
+			ExpectErr: true,
+		},
+		{
+			Name: "volumes[scratch].mount_from.empty_dir.medium is illegal",
+			Volumes: `
+  scratch:
+    mount_path: /scratch
+    mount_from:
+      empty_dir:
+        medium: SSD`,
+			Inputs:    makeInputs(),
+			ExpectErr: true,
+		},
+		{
+			Name: "volumes[scratch].mount_from.empty_dir.size_limit is illegal",
+			Volumes: `
+  scratch:
+    mount_path: /scratch
+    mount_from:
+      empty_dir:
+        size_limit: ten`,
+			Inputs:    makeInputs(),
+			ExpectErr: true,
+		},
+		{
+			Name: "volumes[docker].mount_from.host_path.path should be an absolute path",
+			Volumes: `
+  docker:
+    mount_path: /docker
+    mount_from:
+      host_path:
+        path: var/run`,
+			Inputs:    makeInputs(),
+			ExpectErr: true,
+		},
+		{
+			Name: "volumes[docker].mount_from.host_path.type is illegal",
+			Volumes: `
+  docker:
+    mount_path: /docker
+    mount_from:
+      host_path:
+        path: /var/run
+        type: Folder`,
+			Inputs:    makeInputs(),
+			ExpectErr: true,
+		},
+		{
+			Name: "volumes[config].mount_from.config_map.name should not be empty",
+			Volumes: `
+  config:
+    mount_path: /etc/gene
+    mount_from:
+      config_map: {}`,
+			Inputs:    makeInputs(),
+			ExpectErr: true,
+		},
+		{
+			Name: "volumes[reference].mount_from.nfs.server should not be empty",
+			Volumes: `
+  reference:
+    mount_path: /reference
+    mount_from:
+      nfs:
+        path: /exports/hg19`,
+			Inputs:    makeInputs(),
+			ExpectErr: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		var volumes map[string]Volume
+		yaml.Unmarshal([]byte(testCase.Volumes), &volumes)
+		err := ValidateVolumes(volumes, testCase.Inputs)
+		if testCase.ExpectErr == true && len(err) == 0 {
+			t.Errorf("%s: Expect error, but got nil", testCase.Name)
+		}
+		if testCase.ExpectErr == false && len(err) != 0 {
+			t.Errorf("%s: Expect no error, but got error %v", testCase.Name, err)
+		}
+	}
+}
+
+func TestTransVolume2ExecVolume(t *testing.T) {
+	volumes := map[string]Volume{
+		"scratch": {
+			MountPath: "/scratch",
+			MountFrom: VolumeSource{EmptyDir: &EmptyDirVolumeSource{SizeLimit: "${size}"}},
+		},
+		"reference": {
+			MountPath: "/reference",
+			MountFrom: VolumeSource{NFS: &NFSVolumeSource{Server: "${server}", Path: "/exports/hg19", ReadOnly: true}},
+		},
+	}
+	data := map[string]string{"size": "10Gi", "server": "nfs.example.com"}
+	for key, volume := range volumes {
+		volume.MountFrom = InstantiateVolumeSource(volume.MountFrom, data)
+		volumes[key] = volume
+	}
+
+	execVolumes, err := TransVolume2ExecVolume(volumes)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	scratch := execVolumes["scratch"].MountFrom.EmptyDir
+	if scratch == nil || scratch.SizeLimit == nil || scratch.SizeLimit.String() != "10Gi" {
+		t.Errorf("expected an empty dir of 10Gi, got %v", scratch)
+	}
+	reference := execVolumes["reference"].MountFrom.NFS
+	if reference == nil || reference.Server != "nfs.example.com" || !reference.ReadOnly {
+		t.Errorf("expected a read-only nfs volume of nfs.example.com, got %v", reference)
+	}
+	if len(execVolumes["reference"].MountFrom.Pvc) != 0 {
+		t.Errorf("expected no pvc, got %s", execVolumes["reference"].MountFrom.Pvc)
+	}
+}
+
+func TestTransVolume2ExecVolumeInvalidSizeLimit(t *testing.T) {
+	volumes := map[string]Volume{
+		"scratch": {
+			MountPath: "/scratch",
+			MountFrom: VolumeSource{EmptyDir: &EmptyDirVolumeSource{SizeLimit: "${size}"}},
+		},
+	}
+	// the size limit is checked once the input is replaced.
+	for key, volume := range volumes {
+		volume.MountFrom = InstantiateVolumeSource(volume.MountFrom, map[string]string{"size": "ten"})
+		volumes[key] = volume
+	}
+	if _, err := TransVolume2ExecVolume(volumes); err == nil {
+		t.Errorf("expected an error for the size limit ten")
+	}
+}
+
+func TestValidateJobVolumes(t *testing.T) {
+	volumes := map[string]Volume{
+		"reference": {MountPath: "/reference", MountFrom: VolumeSource{PVC: "reference"}},
+		"data":      {MountPath: "/data", MountFrom: VolumeSource{PVC: "data"}},
+	}
+	testCases := []struct {
+		name   string
+		mounts []VolumeMount
+		expErr bool
+	}{
+		{
+			name: "valid",
+			mounts: []VolumeMount{
+				{Name: "reference", ReadOnly: true},
+				{Name: "data", MountPath: "${obs-path}", SubPath: "samples/${gcs-pvc}"},
+			},
+		},
+		{name: "volume not exist", mounts: []VolumeMount{{Name: "scratch"}}, expErr: true},
+		{name: "volume mounted twice", mounts: []VolumeMount{{Name: "data"}, {Name: "data", MountPath: "/output"}}, expErr: true},
+		{name: "relative mount path", mounts: []VolumeMount{{Name: "data", MountPath: "output"}}, expErr: true},
+		{name: "absolute sub path", mounts: []VolumeMount{{Name: "data", SubPath: "/samples"}}, expErr: true},
+		{name: "sub path out of the volume", mounts: []VolumeMount{{Name: "data", SubPath: "samples/../.."}}, expErr: true},
+		{name: "undefined input in sub path", mounts: []VolumeMount{{Name: "data", SubPath: "${sample}"}}, expErr: true},
+	}
+
+	for _, testCase := range testCases {
+		errs := ValidateJobVolumes("job", testCase.mounts, volumes, makeInputs())
+		if testCase.expErr && len(errs) == 0 {
+			t.Errorf("%s: expected error, but got nil", testCase.name)
+		}
+		if !testCase.expErr && len(errs) != 0 {
+			t.Errorf("%s: unexpected error: %v", testCase.name, errs)
+		}
+	}
+}
+
+func TestInstantiateJobVolumes(t *testing.T) {
+	workflowStr := `
+version: genecontainer_0_1
+inputs:
+  sample:
+    default: NA12878
+    type: string
+volumes:
+  reference:
+    mount_path: /reference
+    mount_from:
+      pvc: reference
+  data:
+    mount_path: /data
+    mount_from:
+      pvc: data
+workflow:
+  align:
+    tool: bwa:0.7.17
+    volumes:
+    - name: reference
+      read_only: true
+    - name: data
+      mount_path: /output
+      sub_path: samples/${sample}
+    commands:
+    - bwa mem /reference/hg19.fa > /output/${sample}.sam
+  report:
+    tool: bwa:0.7.17
+    commands:
+    - report
+`
+	workflow, err := UnmarshalWorkflow([]byte(workflowStr))
+	if err != nil {
+		t.Fatalf("unmarshal workflow err: %v", err)
+	}
+	SetDefaultWorkflow(workflow)
+	if errs := ValidateWorkflow(workflow); len(errs) != 0 {
+		t.Fatalf("unexpected validate error: %v", errs)
+	}
+	bwa := Tool{Name: "bwa", Version: "0.7.17", Image: "bwa:0.7.17"}
+	if err := InstantiateWorkflow(workflow, nil, TransTools2Map([]Tool{bwa})); err != nil {
+		t.Fatalf("unexpected instantiate error: %v", err)
+	}
+	exec, err := TransWorkflow2Execution(workflow)
+	if err != nil {
+		t.Fatalf("unexpected trans error: %v", err)
+	}
+
+	taskVolumes := make(map[string]map[string]execv1alpha1.Volume)
+	for _, task := range exec.Spec.Tasks {
+		taskVolumes[task.Name] = task.Volumes
+	}
+	expect := map[string]execv1alpha1.Volume{
+		"reference": {MountPath: "/reference", MountFrom: execv1alpha1.VolumeSource{Pvc: "reference"}, ReadOnly: true},
+		"data":      {MountPath: "/output", MountFrom: execv1alpha1.VolumeSource{Pvc: "data"}, SubPath: "samples/NA12878"},
+	}
+	if !reflect.DeepEqual(taskVolumes["align"], expect) {
+		t.Errorf("expected volumes of align %v, got %v", expect, taskVolumes["align"])
+	}
+	// a job that does not list its volumes mounts all of them.
+	if len(taskVolumes["report"]) != 2 || taskVolumes["report"]["data"].MountPath != "/data" {
+		t.Errorf("expected all the volumes of the workflow for report, got %v", taskVolumes["report"])
+	}
+}
@@ -153,6 +153,10 @@ func MarkVertexFailed(exec *genev1alpha1.Execution, vertexName string, message s
 	MarkVertexPhase(exec, vertexName, genev1alpha1.VertexFailed, message)
 }
 
+func MarkVertexRetried(exec *genev1alpha1.Execution, vertexName string, message string) {
+	MarkVertexPhase(exec, vertexName, genev1alpha1.VertexRetried, message)
+}
+
 func MarkVertexError(exec *genev1alpha1.Execution, vertexName string, err error) {
 	MarkVertexPhase(exec, vertexName, genev1alpha1.VertexError, err.Error())
 }
@@ -188,3 +192,38 @@ func MarkVertexPhase(exec *genev1alpha1.Execution, vertexName string, phase gene
 
 	exec.Status.Vertices[vertexStatus.ID] = *vertexStatus
 }
+
+// GetExecutionCondition returns the condition of the execution with the
+// given type, or nil if the execution does not have it.
+func GetExecutionCondition(exec *genev1alpha1.Execution, conditionType genev1alpha1.ExecutionConditionType) *genev1alpha1.ExecutionCondition {
+	for i := range exec.Status.Conditions {
+		if exec.Status.Conditions[i].Type == conditionType {
+			return &exec.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// SetExecutionCondition adds or updates the condition of the execution with
+// the type of the given condition. The last transition time is kept unless
+// the status changes. It returns whether the condition has changed.
+func SetExecutionCondition(exec *genev1alpha1.Execution, condition genev1alpha1.ExecutionCondition) bool {
+	existing := GetExecutionCondition(exec, condition.Type)
+	if existing == nil {
+		if condition.LastTransitionTime.IsZero() {
+			condition.LastTransitionTime = metav1.Now()
+		}
+		exec.Status.Conditions = append(exec.Status.Conditions, condition)
+		return true
+	}
+	if existing.Status == condition.Status && existing.Reason == condition.Reason && existing.Message == condition.Message {
+		return false
+	}
+	if existing.Status != condition.Status {
+		existing.LastTransitionTime = metav1.Now()
+	}
+	existing.Status = condition.Status
+	existing.Reason = condition.Reason
+	existing.Message = condition.Message
+	return true
+}
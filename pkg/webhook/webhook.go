@@ -0,0 +1,423 @@
+/*
+Copyright 2019 The Kubegene Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	genev1alpha1 "kubegene.io/kubegene/pkg/apis/gene/v1alpha1"
+	"kubegene.io/kubegene/pkg/util"
+)
+
+// URLsAnnotation is the annotation of an execution that lists the webhook
+// URLs separated by comma.
+const URLsAnnotation = "kubegene.io/webhook-urls"
+
+// The headers of a webhook request.
+const (
+	EventHeader     = "X-Kubegene-Event"
+	SignatureHeader = "X-Kubegene-Signature"
+)
+
+// EventType is the type of a lifecycle event of an execution.
+type EventType string
+
+// The lifecycle events of an execution.
+const (
+	ExecutionStarted   EventType = "ExecutionStarted"
+	ExecutionSucceeded EventType = "ExecutionSucceeded"
+	ExecutionFailed    EventType = "ExecutionFailed"
+	ExecutionError     EventType = "ExecutionError"
+	VertexFailed       EventType = "VertexFailed"
+)
+
+// Payload is the JSON body posted to the webhook URLs.
+type Payload struct {
+	Event     EventType                `json:"event"`
+	Namespace string                   `json:"namespace"`
+	Execution string                   `json:"execution"`
+	UID       types.UID                `json:"uid"`
+	Phase     genev1alpha1.VertexPhase `json:"phase"`
+	Message   string                   `json:"message,omitempty"`
+	// Vertex is the name of the failed vertex for a VertexFailed event.
+	Vertex     string      `json:"vertex,omitempty"`
+	StartedAt  metav1.Time `json:"startedAt,omitempty"`
+	FinishedAt metav1.Time `json:"finishedAt,omitempty"`
+	Timestamp  metav1.Time `json:"timestamp"`
+}
+
+// Config is the cluster wide configuration of the webhook notifications.
+type Config struct {
+	// URLs are notified of every execution.
+	URLs []string
+	// AllowedURLs are the prefixes of the URLs an execution may set in its
+	// spec or annotation, the scheme and host must match exactly. The URLs
+	// of the executions are ignored if it is empty.
+	AllowedURLs []string
+	// Secret is the key to sign the payloads with HMAC-SHA256. The payloads
+	// posted to all the URLs are signed, and none if it is empty.
+	Secret string
+	// VertexFailure notifies every failed vertex of every execution.
+	VertexFailure bool
+	// Timeout is the timeout of a request.
+	Timeout time.Duration
+	// Backoff is used to retry a failed delivery.
+	Backoff wait.Backoff
+	// Workers is the max number of deliveries in progress at once, the
+	// other deliveries wait in a queue.
+	Workers int
+}
+
+// DefaultBackoff tries a delivery 5 times in about 30 seconds.
+var DefaultBackoff = wait.Backoff{
+	Steps:    5,
+	Duration: 2 * time.Second,
+	Factor:   2.0,
+	Jitter:   0.1,
+}
+
+// DefaultWorkers is the default max number of deliveries in progress.
+const DefaultWorkers = 10
+
+// Notifier posts the lifecycle events of executions to the webhook URLs.
+type Notifier struct {
+	config Config
+	client *http.Client
+
+	lock sync.Mutex
+	// sent records the events that have been sent for every execution, so
+	// that an event will not be sent twice for a stale original execution.
+	sent map[string]*sentEvents
+	// queue is the deliveries waiting for a worker, and workers is the
+	// number of the workers draining it, both guarded by lock.
+	queue   []delivery
+	workers int
+	// wg tracks the deliveries queued or in progress.
+	wg sync.WaitGroup
+}
+
+// delivery is a payload to post to a webhook URL.
+type delivery struct {
+	key   string
+	url   string
+	event EventType
+	body  []byte
+}
+
+func NewNotifier(config Config) *Notifier {
+	if config.Backoff.Steps == 0 {
+		config.Backoff = DefaultBackoff
+	}
+	if config.Workers <= 0 {
+		config.Workers = DefaultWorkers
+	}
+	return &Notifier{
+		config: config,
+		client: &http.Client{
+			Timeout: config.Timeout,
+			// a redirect could lead an allowed URL to any other one.
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		sent: make(map[string]*sentEvents),
+	}
+}
+
+// sentEvents is the events that have been sent for an execution.
+type sentEvents struct {
+	uid    types.UID
+	events map[string]bool
+}
+
+// Sign returns the signature of the body with the secret, which is the
+// hex encoded HMAC-SHA256 prefixed with sha256=.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// URLs returns the webhook URLs of the execution, including the ones of
+// the cluster, and the ones of the spec and the annotation that are allowed.
+func (n *Notifier) URLs(exec *genev1alpha1.Execution) []string {
+	set := make(map[string]bool)
+	for _, url := range n.config.URLs {
+		set[url] = true
+	}
+	add := func(url string) {
+		if n.isAllowed(url) {
+			set[url] = true
+		} else if !set[url] {
+			glog.Warningf("webhook url %s of execution %s is not allowed", url, util.KeyOf(exec))
+		}
+	}
+	if exec.Spec.Webhook != nil {
+		for _, url := range exec.Spec.Webhook.URLs {
+			add(url)
+		}
+	}
+	for _, url := range strings.Split(exec.Annotations[URLsAnnotation], ",") {
+		if url = strings.TrimSpace(url); len(url) != 0 {
+			add(url)
+		}
+	}
+
+	urls := make([]string, 0, len(set))
+	for url := range set {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+	return urls
+}
+
+// isAllowed returns true if the url of an execution matches one of the
+// allowed URLs of the cluster.
+func (n *Notifier) isAllowed(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || len(u.Host) == 0 || u.User != nil {
+		return false
+	}
+	// the path is cleaned so that a dot segment can not escape the allowed
+	// path, which must match a whole segment.
+	urlPath := cleanPath(u.Path)
+	for _, rawAllowed := range n.config.AllowedURLs {
+		allowed, err := url.Parse(rawAllowed)
+		if err != nil {
+			continue
+		}
+		if !strings.EqualFold(u.Scheme, allowed.Scheme) || !strings.EqualFold(u.Host, allowed.Host) {
+			continue
+		}
+		allowedPath := cleanPath(allowed.Path)
+		if allowedPath == "/" || urlPath == allowedPath || strings.HasPrefix(urlPath, allowedPath+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanPath returns the shortest absolute path equivalent to the path of a URL.
+func cleanPath(urlPath string) string {
+	return path.Clean("/" + urlPath)
+}
+
+// Events returns the lifecycle events from the original execution status
+// to the modified one.
+func Events(modified, original *genev1alpha1.Execution, vertexFailure bool) []*Payload {
+	payloads := make([]*Payload, 0)
+	newPayload := func(event EventType) *Payload {
+		return &Payload{
+			Event:      event,
+			Namespace:  modified.Namespace,
+			Execution:  modified.Name,
+			UID:        modified.UID,
+			Phase:      modified.Status.Phase,
+			Message:    modified.Status.Message,
+			StartedAt:  modified.Status.StartedAt,
+			FinishedAt: modified.Status.FinishedAt,
+			Timestamp:  metav1.Now(),
+		}
+	}
+
+	if len(original.Status.Phase) == 0 && len(modified.Status.Phase) != 0 {
+		payloads = append(payloads, newPayload(ExecutionStarted))
+	}
+
+	if vertexFailure {
+		names := make([]string, 0)
+		for id, vertex := range modified.Status.Vertices {
+			if vertex.Type == genev1alpha1.DAGVertexType || vertex.Phase != genev1alpha1.VertexFailed {
+				continue
+			}
+			if old, ok := original.Status.Vertices[id]; ok && old.Phase == genev1alpha1.VertexFailed {
+				continue
+			}
+			names = append(names, vertex.Name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			payload := newPayload(VertexFailed)
+			payload.Vertex = name
+			payload.Message = modified.Status.Vertices[util.VertexId(name)].Message
+			payloads = append(payloads, payload)
+		}
+	}
+
+	if !util.IsExecutionCompleted(original) && util.IsExecutionCompleted(modified) {
+		switch modified.Status.Phase {
+		case genev1alpha1.VertexSucceeded:
+			payloads = append(payloads, newPayload(ExecutionSucceeded))
+		case genev1alpha1.VertexFailed:
+			payloads = append(payloads, newPayload(ExecutionFailed))
+		case genev1alpha1.VertexError:
+			payloads = append(payloads, newPayload(ExecutionError))
+		}
+	}
+
+	return payloads
+}
+
+// Notify sends the lifecycle events from the original execution status to
+// the modified one asynchronously. It should be called after the modified
+// status has been updated.
+func (n *Notifier) Notify(modified, original *genev1alpha1.Execution) {
+	urls := n.URLs(modified)
+	if len(urls) == 0 {
+		return
+	}
+
+	vertexFailure := n.config.VertexFailure || (modified.Spec.Webhook != nil && modified.Spec.Webhook.VertexFailure)
+	for _, payload := range Events(modified, original, vertexFailure) {
+		if !n.markSent(util.KeyOf(modified), payload) {
+			continue
+		}
+		body, err := json.Marshal(payload)
+		if err != nil {
+			glog.Errorf("marshal webhook payload of execution %s error: %v", util.KeyOf(modified), err)
+			continue
+		}
+		for _, url := range urls {
+			n.enqueue(delivery{key: util.KeyOf(modified), url: url, event: payload.Event, body: body})
+		}
+	}
+}
+
+// enqueue queues the delivery, and starts a worker to drain the queue if
+// fewer than the max number of workers are running.
+func (n *Notifier) enqueue(d delivery) {
+	n.wg.Add(1)
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.queue = append(n.queue, d)
+	if n.workers < n.config.Workers {
+		n.workers++
+		go n.worker()
+	}
+}
+
+// worker delivers the queued deliveries one by one until the queue is empty.
+func (n *Notifier) worker() {
+	for {
+		n.lock.Lock()
+		if len(n.queue) == 0 {
+			n.workers--
+			n.lock.Unlock()
+			return
+		}
+		d := n.queue[0]
+		n.queue[0] = delivery{}
+		n.queue = n.queue[1:]
+		n.lock.Unlock()
+
+		if err := n.deliver(d.url, d.event, d.body); err != nil {
+			glog.Errorf("deliver %s of execution %s to %s error: %v", d.event, d.key, d.url, err)
+		}
+		n.wg.Done()
+	}
+}
+
+// Forget drops the record of the sent events of a deleted execution.
+func (n *Notifier) Forget(key string) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	delete(n.sent, key)
+}
+
+// Wait waits for the deliveries queued or in progress.
+func (n *Notifier) Wait() {
+	n.wg.Wait()
+}
+
+// markSent records the event, it returns false if the event has been sent.
+func (n *Notifier) markSent(key string, payload *Payload) bool {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	// the execution may be recreated with the same name.
+	sent, ok := n.sent[key]
+	if !ok || sent.uid != payload.UID {
+		sent = &sentEvents{uid: payload.UID, events: make(map[string]bool)}
+		n.sent[key] = sent
+	}
+
+	event := string(payload.Event) + "/" + payload.Vertex
+	if sent.events[event] {
+		return false
+	}
+	sent.events[event] = true
+	return true
+}
+
+// deliver posts the body to the url, and retries with backoff until it
+// succeeds or the retries are exhausted.
+func (n *Notifier) deliver(url string, event EventType, body []byte) error {
+	var lastErr error
+	err := wait.ExponentialBackoff(n.config.Backoff, func() (bool, error) {
+		lastErr = n.post(url, event, body)
+		if lastErr != nil {
+			glog.V(2).Infof("post %s to %s error: %v", event, url, lastErr)
+			return false, nil
+		}
+		return true, nil
+	})
+	if err == wait.ErrWaitTimeout {
+		return lastErr
+	}
+	return err
+}
+
+func (n *Notifier) post(url string, event EventType, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, string(event))
+	// the signature does not disclose the secret, the URLs of the executions
+	// verify the payloads the same way as the ones of the cluster.
+	if len(n.config.Secret) != 0 {
+		req.Header.Set(SignatureHeader, Sign(n.config.Secret, body))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
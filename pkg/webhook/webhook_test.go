@@ -0,0 +1,326 @@
+/*
+Copyright 2019 The Kubegene Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	genev1alpha1 "kubegene.io/kubegene/pkg/apis/gene/v1alpha1"
+)
+
+func newExecution(phase genev1alpha1.VertexPhase, vertices ...genev1alpha1.VertexStatus) *genev1alpha1.Execution {
+	exec := &genev1alpha1.Execution{
+		ObjectMeta: metav1.ObjectMeta{Name: "exec", Namespace: "default", UID: "uid"},
+		Status: genev1alpha1.ExecutionStatus{
+			Phase:    phase,
+			Vertices: make(map[string]genev1alpha1.VertexStatus),
+		},
+	}
+	for _, vertex := range vertices {
+		exec.Status.Vertices[vertex.ID] = vertex
+	}
+	return exec
+}
+
+func eventsOf(payloads []*Payload) []EventType {
+	events := make([]EventType, 0)
+	for _, payload := range payloads {
+		events = append(events, payload.Event)
+	}
+	return events
+}
+
+func TestEvents(t *testing.T) {
+	failed := genev1alpha1.VertexStatus{ID: "exec.a.0", Name: "exec.a.0", Phase: genev1alpha1.VertexFailed}
+	running := genev1alpha1.VertexStatus{ID: "exec.a.0", Name: "exec.a.0", Phase: genev1alpha1.VertexRunning}
+
+	testCases := []struct {
+		name          string
+		original      *genev1alpha1.Execution
+		modified      *genev1alpha1.Execution
+		vertexFailure bool
+		expect        []EventType
+	}{
+		{
+			name:     "started",
+			original: newExecution(""),
+			modified: newExecution(genev1alpha1.VertexRunning, running),
+			expect:   []EventType{ExecutionStarted},
+		},
+		{
+			name:     "running",
+			original: newExecution(genev1alpha1.VertexRunning),
+			modified: newExecution(genev1alpha1.VertexRunning, running),
+			expect:   []EventType{},
+		},
+		{
+			name:     "succeeded",
+			original: newExecution(genev1alpha1.VertexRunning),
+			modified: newExecution(genev1alpha1.VertexSucceeded),
+			expect:   []EventType{ExecutionSucceeded},
+		},
+		{
+			name:          "failed with vertex failure",
+			original:      newExecution(genev1alpha1.VertexRunning, running),
+			modified:      newExecution(genev1alpha1.VertexFailed, failed),
+			vertexFailure: true,
+			expect:        []EventType{VertexFailed, ExecutionFailed},
+		},
+		{
+			name:     "failed without vertex failure",
+			original: newExecution(genev1alpha1.VertexRunning, running),
+			modified: newExecution(genev1alpha1.VertexFailed, failed),
+			expect:   []EventType{ExecutionFailed},
+		},
+		{
+			name:     "error",
+			original: newExecution(""),
+			modified: newExecution(genev1alpha1.VertexError),
+			expect:   []EventType{ExecutionStarted, ExecutionError},
+		},
+	}
+
+	for _, testCase := range testCases {
+		events := eventsOf(Events(testCase.modified, testCase.original, testCase.vertexFailure))
+		if !reflect.DeepEqual(events, testCase.expect) {
+			t.Errorf("%s: expected events %v, got %v", testCase.name, testCase.expect, events)
+		}
+	}
+}
+
+func TestURLs(t *testing.T) {
+	testCases := []struct {
+		name    string
+		allowed []string
+		expect  []string
+	}{
+		{
+			name:   "not allowed",
+			expect: []string{"http://cluster"},
+		},
+		{
+			name:    "allowed",
+			allowed: []string{"http://spec", "http://annotation/hooks"},
+			expect:  []string{"http://annotation/hooks/a", "http://cluster", "http://spec"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		notifier := NewNotifier(Config{URLs: []string{"http://cluster"}, AllowedURLs: testCase.allowed})
+		exec := newExecution("")
+		exec.Spec.Webhook = &genev1alpha1.Webhook{URLs: []string{"http://spec", "http://cluster", "http://spec.evil"}}
+		exec.Annotations = map[string]string{URLsAnnotation: "http://annotation/hooks/a, http://annotation/admin, http://spec"}
+
+		if urls := notifier.URLs(exec); !reflect.DeepEqual(urls, testCase.expect) {
+			t.Errorf("%s: expected urls %v, got %v", testCase.name, testCase.expect, urls)
+		}
+	}
+}
+
+func TestIsAllowed(t *testing.T) {
+	notifier := NewNotifier(Config{AllowedURLs: []string{"https://hooks.example.com/hooks", "https://any.example.com/"}})
+	testCases := []struct {
+		url    string
+		expect bool
+	}{
+		{url: "https://hooks.example.com/hooks", expect: true},
+		{url: "https://hooks.example.com/hooks/", expect: true},
+		{url: "https://hooks.example.com/hooks/gene", expect: true},
+		{url: "https://HOOKS.example.com/hooks/gene", expect: true},
+		{url: "https://hooks.example.com/hooksevil", expect: false},
+		{url: "https://hooks.example.com/hooks/../admin", expect: false},
+		{url: "https://hooks.example.com/hooks/%2e%2e/admin", expect: false},
+		{url: "https://hooks.example.com/admin", expect: false},
+		{url: "http://hooks.example.com/hooks", expect: false},
+		{url: "https://user@hooks.example.com/hooks", expect: false},
+		{url: "https://any.example.com/admin", expect: true},
+		{url: "https://any.example.com", expect: true},
+	}
+
+	for _, testCase := range testCases {
+		if allowed := notifier.isAllowed(testCase.url); allowed != testCase.expect {
+			t.Errorf("%s: expected allowed %v, got %v", testCase.url, testCase.expect, allowed)
+		}
+	}
+}
+
+type receiver struct {
+	lock     sync.Mutex
+	failures int
+	payloads []Payload
+	headers  []http.Header
+	bodies   [][]byte
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	// fail the first requests to test the retries.
+	if r.failures > 0 {
+		r.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	body, _ := ioutil.ReadAll(req.Body)
+	var payload Payload
+	json.Unmarshal(body, &payload)
+	r.payloads = append(r.payloads, payload)
+	r.headers = append(r.headers, req.Header)
+	r.bodies = append(r.bodies, body)
+}
+
+func TestNotify(t *testing.T) {
+	r := &receiver{failures: 2}
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	notifier := NewNotifier(Config{
+		URLs:    []string{server.URL},
+		Secret:  "secret",
+		Timeout: time.Second,
+		Backoff: wait.Backoff{Steps: 3, Duration: time.Millisecond, Factor: 1.0},
+	})
+
+	original := newExecution(genev1alpha1.VertexRunning)
+	modified := newExecution(genev1alpha1.VertexSucceeded)
+	notifier.Notify(modified, original)
+	// the event is sent only once for a stale original execution.
+	notifier.Notify(modified, original)
+	notifier.Wait()
+
+	if len(r.payloads) != 1 {
+		t.Fatalf("expected 1 delivery, got %d", len(r.payloads))
+	}
+	if r.payloads[0].Event != ExecutionSucceeded || r.payloads[0].Execution != "exec" {
+		t.Errorf("unexpected payload %#v", r.payloads[0])
+	}
+	if event := r.headers[0].Get(EventHeader); event != string(ExecutionSucceeded) {
+		t.Errorf("expected event header %s, got %s", ExecutionSucceeded, event)
+	}
+	if signature := r.headers[0].Get(SignatureHeader); signature != Sign("secret", r.bodies[0]) {
+		t.Errorf("unexpected signature %s", signature)
+	}
+
+	// a recreated execution is notified again.
+	notifier.Forget("default/exec")
+	notifier.Notify(modified, original)
+	notifier.Wait()
+	if len(r.payloads) != 2 {
+		t.Errorf("expected 2 deliveries, got %d", len(r.payloads))
+	}
+}
+
+func TestNotifyExecutionURL(t *testing.T) {
+	r := &receiver{}
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	notifier := NewNotifier(Config{
+		AllowedURLs: []string{server.URL},
+		Secret:      "secret",
+		Timeout:     time.Second,
+	})
+
+	original := newExecution(genev1alpha1.VertexRunning)
+	modified := newExecution(genev1alpha1.VertexSucceeded)
+	modified.Spec.Webhook = &genev1alpha1.Webhook{URLs: []string{server.URL + "/spec"}}
+	modified.Annotations = map[string]string{URLsAnnotation: server.URL + "/hook"}
+	notifier.Notify(modified, original)
+	notifier.Wait()
+
+	if len(r.payloads) != 2 {
+		t.Fatalf("expected 2 deliveries, got %d", len(r.payloads))
+	}
+	// the payloads posted to the urls of the executions are signed as well.
+	for i, header := range r.headers {
+		if signature := header.Get(SignatureHeader); signature != Sign("secret", r.bodies[i]) {
+			t.Errorf("%d: unexpected signature %q", i, signature)
+		}
+	}
+}
+
+func TestNotifyWorkers(t *testing.T) {
+	var lock sync.Mutex
+	running, maxRunning, delivered := 0, 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		lock.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		lock.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		lock.Lock()
+		running--
+		delivered++
+		lock.Unlock()
+	}))
+	defer server.Close()
+
+	notifier := NewNotifier(Config{AllowedURLs: []string{server.URL}, Timeout: time.Second, Workers: 2})
+	urls := make([]string, 0)
+	for i := 0; i < 8; i++ {
+		urls = append(urls, fmt.Sprintf("%s/hook%d", server.URL, i))
+	}
+	modified := newExecution(genev1alpha1.VertexSucceeded)
+	modified.Annotations = map[string]string{URLsAnnotation: strings.Join(urls, ",")}
+	notifier.Notify(modified, newExecution(genev1alpha1.VertexRunning))
+	notifier.Wait()
+
+	if delivered != 8 {
+		t.Errorf("expected 8 deliveries, got %d", delivered)
+	}
+	if maxRunning > 2 {
+		t.Errorf("expected at most 2 deliveries at once, got %d", maxRunning)
+	}
+}
+
+func TestDeliverRetriesExhausted(t *testing.T) {
+	r := &receiver{failures: 5}
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	notifier := NewNotifier(Config{
+		Backoff: wait.Backoff{Steps: 3, Duration: time.Millisecond, Factor: 1.0},
+	})
+	if err := notifier.deliver(server.URL, ExecutionFailed, []byte("{}")); err == nil {
+		t.Errorf("expected error after retries exhausted, but got nil")
+	}
+	if r.failures != 2 {
+		t.Errorf("expected 3 tries, got %d", 5-r.failures)
+	}
+}
+
+func TestSign(t *testing.T) {
+	// echo -n '{}' | openssl dgst -sha256 -hmac secret
+	expect := "sha256=77325902caca812dc259733aacd046b73817372c777b8d95b402647474516e13"
+	if got := Sign("secret", []byte("{}")); got != expect {
+		t.Errorf("unexpected signature %s", got)
+	}
+}